@@ -10,7 +10,9 @@ import (
 	"time"
 
 	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
 	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/notify"
 	"github.com/cmmoran/swarmcp/internal/state"
 	"github.com/cmmoran/swarmcp/internal/swarm"
 	"github.com/spf13/cobra"
@@ -21,6 +23,9 @@ var applyCmd = &cobra.Command{
 	Short: "Apply desired state to Swarm",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireLiveCluster("apply"); err != nil {
+			return err
+		}
 		if len(args) == 1 {
 			return runApplyPlanFile(cmd, args[0])
 		}
@@ -46,14 +51,23 @@ var applyCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
+			if err := resolveImageDigests(context.Background(), target.projectCtx, &desired, target.partitionFilters, target.stackFilters); err != nil {
+				return err
+			}
 
-			contextName := target.projectCtx.ContextName
 			client, err := target.projectCtx.SwarmClient()
 			if err != nil {
 				return err
 			}
 
 			ctx := context.Background()
+			lockScope := applyLockScope(cfg, target.partitionFilters, target.stackFilters, opts.Prune || opts.PruneNetworks)
+			lease, err := acquireApplyLock(ctx, cmd, client, lockScope, applyLockTimeout, applyLockTTL)
+			if err != nil {
+				return err
+			}
+			defer releaseApplyLock(cmd, lease)
+
 			plan, err := apply.BuildPlan(ctx, client, cfg, desired, values, target.partitionFilters, target.stackFilters, !opts.NoInfer)
 			if err != nil {
 				return err
@@ -82,6 +96,19 @@ var applyCmd = &cobra.Command{
 				plan.DeleteConfigs = nil
 				plan.DeleteSecrets = nil
 			}
+			pruneNetworks := opts.Prune || opts.PruneNetworks
+			if !pruneNetworks {
+				plan.DeleteNetworks = nil
+			} else if len(plan.DeleteNetworks) > 0 && opts.Confirm {
+				confirmed, err := confirmPruneNetworks(cmd, plan, opts.Confirm)
+				if err != nil {
+					return err
+				}
+				if !confirmed {
+					pruneNetworks = false
+					plan.DeleteNetworks = nil
+				}
+			}
 			if pruneServices {
 				existingDeploys := make(map[string]struct{}, len(plan.StackDeploys))
 				for _, deploy := range plan.StackDeploys {
@@ -124,12 +151,30 @@ var applyCmd = &cobra.Command{
 			skipCache := len(targets.deployments) > 1 || len(target.partitionFilters) > 1 || len(target.stackFilters) > 1
 			cached, cacheOK := loadStateCache(target.configPath, cfg, partitionState, stackState)
 			skipApply := !skipCache && cacheOK && cached.Command == "apply" && planSummaryZero(planSummary) && planSummariesEqual(cached.Plan, planSummary)
+			stackNames, serviceCreates, serviceUpdates := planDeploySummary(plan.StackDeploys)
+			if len(stackNames) == 0 {
+				stackNames = nil
+			}
+			notifier, err := projectNotifier(cmd, target.projectCtx)
+			if err != nil {
+				return err
+			}
+			event := notifyEvent("apply", cfg.Project.Name, cfg.Project.Deployment, target.projectCtx.ContextName, target.partitionFilters, target.stackFilters, planSummary)
+			event.Summary.ServicesCreated = serviceCreates
+			event.Summary.ServicesUpdated = serviceUpdates
+			event.Summary.StackNames = stackNames
+			notifier.Send(event.As(notify.EventPlanComputed))
+			var results []apply.StackDeployResult
 			if !skipApply {
 				stackParallel := 0
 				if opts.Serial {
 					stackParallel = 1
 				}
-				if err := apply.Apply(ctx, client, plan, contextName, pruneServices, stackParallel, noUI, outputMode, outputFlagSet); err != nil {
+				deployPlan := canaryPlan(plan, applyCanary)
+				notifier.Send(event.As(notify.EventApplyStarted))
+				results, err = apply.Apply(ctx, client, deployPlan, lease, pruneServices, !opts.NoRollback, stackParallel, noUI, outputMode, outputFlagSet)
+				if err != nil {
+					notifyApplyResult(notifier, event, results, "", err)
 					return err
 				}
 			}
@@ -138,13 +183,24 @@ var applyCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
-			stackNames, serviceCreates, serviceUpdates := planDeploySummary(plan.StackDeploys)
-			if len(stackNames) == 0 {
-				stackNames = nil
-			}
 			planSummary.ServicesCreated = serviceCreates
 			planSummary.ServicesUpdated = serviceUpdates
 			planSummary.StackNames = stackNames
+			if !skipApply {
+				releaseID := recordRelease(cmd, client, cfg.Project.Name, config.ReleaseHistory(cfg), plan, planSummary, func(release *apply.Release) {
+					release.Deployment = cfg.Project.Deployment
+					release.Context = target.projectCtx.ContextName
+					release.Partitions = target.partitionFilters
+					release.StackFilters = target.stackFilters
+					if inputs, err := buildPlanInputs(cfg, target.configPath, target.configPaths, target.releaseConfigPaths, target.projectCtx.ValuesSources, opts.SecretsFile); err == nil {
+						release.Inputs = inputs
+					}
+					if sources, err := buildPlanSourceInputs(cfg, desired, plan, target.projectCtx.ValuesSources); err == nil {
+						release.SourceInputs = sources
+					}
+				})
+				notifyApplyResult(notifier, event, results, releaseID, nil)
+			}
 			stateSnapshot := state.State{
 				Version:     state.CurrentVersion,
 				GeneratedAt: time.Now().UTC().Format(time.RFC3339),
@@ -165,17 +221,22 @@ var applyCmd = &cobra.Command{
 			} else {
 				_, _ = fmt.Fprintln(out, "apply OK")
 			}
-			_, _ = fmt.Fprintf(out, "networks created: %d\nconfigs created: %d\nsecrets created: %d\nstacks deployed: %d\nconfigs removed: %d\nsecrets removed: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\n", planSummary.NetworksCreated, planSummary.ConfigsCreated, planSummary.SecretsCreated, planSummary.StacksDeployed, planSummary.ConfigsRemoved, planSummary.SecretsRemoved, planSummary.ConfigsSkipped, planSummary.SecretsSkipped)
+			_, _ = fmt.Fprintf(out, "networks created: %d\nconfigs created: %d\nsecrets created: %d\nstacks deployed: %d\nconfigs removed: %d\nsecrets removed: %d\nnetworks removed: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\nnetworks skipped (in use): %d\n", planSummary.NetworksCreated, planSummary.ConfigsCreated, planSummary.SecretsCreated, planSummary.StacksDeployed, planSummary.ConfigsRemoved, planSummary.SecretsRemoved, planSummary.NetworksRemoved, planSummary.ConfigsSkipped, planSummary.SecretsSkipped, planSummary.NetworksSkipped)
 			if prune {
 				_, _ = fmt.Fprintf(out, "prune enabled: preserve=%d configs preserved: %d secrets preserved: %d\n", pruneResult.PreserveCount, pruneResult.ConfigsPreserved, pruneResult.SecretsPreserved)
 			} else {
 				_, _ = fmt.Fprintln(out, "prune disabled: unused configs/secrets preserved")
 			}
 			if pruneServices {
-				_, _ = fmt.Fprintln(out, "prune services enabled: stack deploy uses --prune")
+				_, _ = fmt.Fprintln(out, "prune services enabled: services removed from deployed stacks are deleted")
 			} else {
 				_, _ = fmt.Fprintln(out, "prune services disabled")
 			}
+			if pruneNetworks {
+				_, _ = fmt.Fprintln(out, "prune networks enabled: unused managed networks are removed")
+			} else {
+				_, _ = fmt.Fprintln(out, "prune networks disabled")
+			}
 			if len(desired.Missing) > 0 {
 				sort.Strings(desired.Missing)
 				_, _ = fmt.Fprintf(out, "missing secrets (placeholders): %d\n", len(desired.Missing))
@@ -188,7 +249,10 @@ var applyCmd = &cobra.Command{
 	},
 }
 
-var applyAllowContextOverride bool
+var (
+	applyAllowContextOverride bool
+	applyCanary               bool
+)
 
 func runApplyPlanFile(cmd *cobra.Command, path string) error {
 	outputMode := strings.TrimSpace(opts.Output)
@@ -212,44 +276,126 @@ func runApplyPlanFile(cmd *cobra.Command, path string) error {
 		}
 		contextName = opts.Context
 	}
-	client, err := swarmClientForContext(contextName)
+	outputFlagSet := cmd.Flags().Changed("output")
+	noUI := opts.NoUI || outputFlagSet
+	planSummary, _, err := applySavedPlan(cmd, planFile, path, contextName, planProjectContext(cmd, planFile), applyPlanOptions(), noUI, outputMode, outputFlagSet)
 	if err != nil {
 		return err
 	}
+	out := cmd.OutOrStdout()
+	_, _ = fmt.Fprintln(out, "apply OK")
+	_, _ = fmt.Fprintf(out, "plan artifact: %s\n", path)
+	_, _ = fmt.Fprintf(out, "networks created: %d\nconfigs created: %d\nsecrets created: %d\nstacks deployed: %d\nconfigs removed: %d\nsecrets removed: %d\nnetworks removed: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\nnetworks skipped (in use): %d\n", planSummary.NetworksCreated, planSummary.ConfigsCreated, planSummary.SecretsCreated, planSummary.StacksDeployed, planSummary.ConfigsRemoved, planSummary.SecretsRemoved, planSummary.NetworksRemoved, planSummary.ConfigsSkipped, planSummary.SecretsSkipped, planSummary.NetworksSkipped)
+	if planFile.PruneServices {
+		_, _ = fmt.Fprintln(out, "prune services enabled: services removed from deployed stacks are deleted")
+	} else {
+		_, _ = fmt.Fprintln(out, "prune services disabled")
+	}
+	return nil
+}
+
+// savedPlanOptions are the settings a saved plan is applied with. apply and
+// serve fill them from their own flags.
+type savedPlanOptions struct {
+	serial      bool
+	noRollback  bool
+	canary      bool
+	lockTimeout time.Duration
+	lockTTL     time.Duration
+}
+
+func applyPlanOptions() savedPlanOptions {
+	return savedPlanOptions{
+		serial:      opts.Serial,
+		noRollback:  opts.NoRollback,
+		canary:      applyCanary,
+		lockTimeout: applyLockTimeout,
+		lockTTL:     applyLockTTL,
+	}
+}
+
+// applySavedPlan executes a validated plan artifact against contextName under
+// the apply lock and records the release. The lock scope is derived from the
+// plan's changes, not from the artifact's lock_partition alone. projectCtx,
+// when loaded, supplies the notification sinks. It returns the applied
+// summary and the release ID ("" when nothing was recorded).
+func applySavedPlan(cmd *cobra.Command, planFile apply.PlanFile, planPath string, contextName string, projectCtx *cmdutil.ProjectContext, options savedPlanOptions, noUI bool, outputMode string, outputExplicit bool) (state.PlanSummary, string, error) {
+	registryCreds, err := apply.ResolvePlanRegistryCredentials(context.Background(), planFile)
+	if err != nil {
+		return state.PlanSummary{}, "", err
+	}
+	client, err := swarmClientForContext(contextName)
+	if err != nil {
+		return state.PlanSummary{}, "", err
+	}
+	if registryCreds != nil {
+		swarm.UseRegistryCredentials(client, func() (swarm.RegistryCredentials, error) { return registryCreds, nil })
+	}
+	lease, err := acquireApplyLock(context.Background(), cmd, client, apply.PlanLockScope(planFile), options.lockTimeout, options.lockTTL)
+	if err != nil {
+		return state.PlanSummary{}, "", err
+	}
+	defer releaseApplyLock(cmd, lease)
 	if err := apply.ValidatePlanAssumptions(context.Background(), client, planFile.Plan.Assumptions); err != nil {
-		return err
+		return state.PlanSummary{}, "", err
 	}
 	if err := apply.ResolvePlanSecretPayloads(context.Background(), &planFile); err != nil {
-		return err
-	}
-	outputFlagSet := cmd.Flags().Changed("output")
-	noUI := opts.NoUI || outputFlagSet
-	stackParallel := 0
-	if opts.Serial {
-		stackParallel = 1
+		return state.PlanSummary{}, "", err
 	}
-	if err := apply.Apply(context.Background(), client, planFile.Plan, contextName, planFile.PruneServices, stackParallel, noUI, outputMode, outputFlagSet); err != nil {
-		return err
+	notifier, err := planFileNotifier(cmd, planFile, projectCtx)
+	if err != nil {
+		return state.PlanSummary{}, "", err
 	}
 	planSummary := buildPlanSummary(planFile.Plan)
 	stackNames, serviceCreates, serviceUpdates := planDeploySummary(planFile.Plan.StackDeploys)
 	planSummary.StackNames = stackNames
 	planSummary.ServicesCreated = serviceCreates
 	planSummary.ServicesUpdated = serviceUpdates
-	out := cmd.OutOrStdout()
-	_, _ = fmt.Fprintln(out, "apply OK")
-	_, _ = fmt.Fprintf(out, "plan artifact: %s\n", path)
-	_, _ = fmt.Fprintf(out, "networks created: %d\nconfigs created: %d\nsecrets created: %d\nstacks deployed: %d\nconfigs removed: %d\nsecrets removed: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\n", planSummary.NetworksCreated, planSummary.ConfigsCreated, planSummary.SecretsCreated, planSummary.StacksDeployed, planSummary.ConfigsRemoved, planSummary.SecretsRemoved, planSummary.ConfigsSkipped, planSummary.SecretsSkipped)
-	if planFile.PruneServices {
-		_, _ = fmt.Fprintln(out, "prune services enabled: stack deploy uses --prune")
-	} else {
-		_, _ = fmt.Fprintln(out, "prune services disabled")
+	event := notifyEvent("apply", planFile.Project, planFile.Deployment, contextName, selectorValues(planFile.Partition), selectorValues(planFile.Stack), planSummary)
+	stackParallel := 0
+	if options.serial {
+		stackParallel = 1
 	}
-	return nil
+	deployPlan := canaryPlan(planFile.Plan, options.canary)
+	notifier.Send(event.As(notify.EventApplyStarted))
+	results, err := apply.Apply(context.Background(), client, deployPlan, lease, planFile.PruneServices, !options.noRollback, stackParallel, noUI, outputMode, outputExplicit)
+	if err != nil {
+		notifyApplyResult(notifier, event, results, "", err)
+		return state.PlanSummary{}, "", err
+	}
+	keepReleases := config.DefaultReleaseHistory
+	if planFile.ReleaseHistory != nil {
+		keepReleases = *planFile.ReleaseHistory
+	}
+	releaseID := recordRelease(cmd, client, planFile.Project, keepReleases, planFile.Plan, planSummary, func(release *apply.Release) {
+		release.Deployment = planFile.Deployment
+		release.Context = contextName
+		if planFile.Partition != "" {
+			release.Partitions = []string{planFile.Partition}
+		}
+		if planFile.Stack != "" {
+			release.StackFilters = []string{planFile.Stack}
+		}
+		release.PlanFile = planPath
+		release.Inputs = planFile.Inputs
+		release.SourceInputs = planFile.SourceInputs
+	})
+	notifyApplyResult(notifier, event, results, releaseID, nil)
+	return planSummary, releaseID, nil
+}
+
+// canaryPlan routes every service update of plan through a canary when
+// --canary is set. The plan recorded in the release stays as planned.
+func canaryPlan(plan apply.Plan, canary bool) apply.Plan {
+	if !canary {
+		return plan
+	}
+	plan.StackDeploys = apply.WithCanaryRollout(plan.StackDeploys)
+	return plan
 }
 
 func swarmClientForContext(contextName string) (swarm.Client, error) {
-	client, err := swarm.NewClient(contextName)
+	client, err := swarmClientFactory()(contextName)
 	if err != nil {
 		return nil, err
 	}
@@ -257,9 +403,11 @@ func swarmClientForContext(contextName string) (swarm.Client, error) {
 }
 
 func init() {
-	applyCmd.Flags().BoolVar(&opts.Serial, "serial", false, "Deploy stacks one at a time during apply")
-	applyCmd.Flags().BoolVar(&opts.NoUI, "no-ui", false, "Disable stack deployment UI and emit buffered output per stack")
+	applyCmd.Flags().BoolVar(&opts.Serial, "serial", false, "Deploy services one at a time within each rollout batch during apply")
+	applyCmd.Flags().BoolVar(&opts.NoRollback, "no-rollback", false, "Leave services that fail their health check on the new spec instead of rolling them back")
+	applyCmd.Flags().BoolVar(&opts.NoUI, "no-ui", false, "Disable stack deployment UI and emit per-service results per stack")
 	applyCmd.Flags().StringVar(&opts.Output, "output", "auto", "Deploy output mode for apply: auto|summary|stack|error-only (explicitly setting this implies --no-ui)")
+	applyCmd.Flags().BoolVar(&applyCanary, "canary", false, "Deploy every service update to a canary service first and promote it once it stays healthy for its canary window")
 	applyCmd.Flags().BoolVar(&applyAllowContextOverride, "allow-context-override", false, "Allow applying a saved plan to a Docker context different from the planned context")
 }
 
@@ -326,8 +474,10 @@ func buildPlanSummary(plan apply.Plan) state.PlanSummary {
 		StacksDeployed:  len(plan.StackDeploys),
 		ConfigsRemoved:  len(plan.DeleteConfigs),
 		SecretsRemoved:  len(plan.DeleteSecrets),
+		NetworksRemoved: len(plan.DeleteNetworks),
 		ConfigsSkipped:  plan.SkippedDeletes.Configs,
 		SecretsSkipped:  plan.SkippedDeletes.Secrets,
+		NetworksSkipped: plan.SkippedDeletes.Networks,
 	}
 }
 
@@ -338,8 +488,10 @@ func planSummaryZero(summary state.PlanSummary) bool {
 		summary.StacksDeployed == 0 &&
 		summary.ConfigsRemoved == 0 &&
 		summary.SecretsRemoved == 0 &&
+		summary.NetworksRemoved == 0 &&
 		summary.ConfigsSkipped == 0 &&
-		summary.SecretsSkipped == 0
+		summary.SecretsSkipped == 0 &&
+		summary.NetworksSkipped == 0
 }
 
 func planSummariesEqual(left, right state.PlanSummary) bool {
@@ -349,8 +501,10 @@ func planSummariesEqual(left, right state.PlanSummary) bool {
 		left.StacksDeployed == right.StacksDeployed &&
 		left.ConfigsRemoved == right.ConfigsRemoved &&
 		left.SecretsRemoved == right.SecretsRemoved &&
+		left.NetworksRemoved == right.NetworksRemoved &&
 		left.ConfigsSkipped == right.ConfigsSkipped &&
-		left.SecretsSkipped == right.SecretsSkipped
+		left.SecretsSkipped == right.SecretsSkipped &&
+		left.NetworksSkipped == right.NetworksSkipped
 }
 
 func loadStateCache(configPath string, cfg *config.Config, partition string, stack string) (state.State, bool) {
@@ -41,6 +41,7 @@ var bootstrapNetworksCmd = &cobra.Command{
 			Partition:          partition,
 			Offline:            opts.Offline,
 			Debug:              opts.Debug,
+			ClientFactory:      swarmClientFactory(),
 		}
 		cfg, _, err := cmdutil.LoadProjectConfig(projectOpts)
 		if err != nil {
@@ -82,6 +83,9 @@ var bootstrapNetworksCmd = &cobra.Command{
 			toCreate = append(toCreate, network)
 		}
 
+		if conflicts := apply.NetworkSubnetConflicts(toCreate, existing); len(conflicts) > 0 {
+			return fmt.Errorf("network subnet conflict: %s", apply.FormatNetworkConflict(conflicts[0]))
+		}
 		for _, network := range toCreate {
 			if _, err := client.CreateNetwork(context.Background(), network); err != nil {
 				return err
@@ -90,6 +94,7 @@ var bootstrapNetworksCmd = &cobra.Command{
 
 		out := cmd.OutOrStdout()
 		_, _ = fmt.Fprintf(out, "bootstrap networks OK\nnetworks to create: %d\n", len(toCreate))
+		printNetworkDrift(out, apply.NetworkDrifts(desired, existing))
 		if len(toCreate) > 0 {
 			sort.Slice(toCreate, func(i, j int) bool { return toCreate[i].Name < toCreate[j].Name })
 			_, _ = fmt.Fprintln(out, "networks created:")
@@ -126,6 +131,7 @@ var bootstrapLabelsCmd = &cobra.Command{
 			Partition:          partition,
 			Offline:            opts.Offline,
 			Debug:              opts.Debug,
+			ClientFactory:      swarmClientFactory(),
 		}
 		cfg, _, err := cmdutil.LoadProjectConfig(projectOpts)
 		if err != nil {
@@ -28,10 +28,18 @@ func confirmPruneConfigs(cmd *cobra.Command, plan apply.Plan, preserve int, conf
 	return cmdutil.ConfirmPrompt(cmd.InOrStdin(), cmd.OutOrStdout(), message)
 }
 
+func confirmPruneNetworks(cmd *cobra.Command, plan apply.Plan, confirm bool) (bool, error) {
+	if !confirm {
+		return true, nil
+	}
+	message := fmt.Sprintf("Prune unused networks? networks=%d", len(plan.DeleteNetworks))
+	return cmdutil.ConfirmPrompt(cmd.InOrStdin(), cmd.OutOrStdout(), message)
+}
+
 func confirmPruneServices(cmd *cobra.Command, stackDeploys int, confirm bool) (bool, error) {
 	if !confirm {
 		return true, nil
 	}
-	message := fmt.Sprintf("Prune removed services via stack deploy --prune? stacks=%d", stackDeploys)
+	message := fmt.Sprintf("Prune services removed from deployed stacks? stacks=%d", stackDeploys)
 	return cmdutil.ConfirmPrompt(cmd.InOrStdin(), cmd.OutOrStdout(), message)
 }
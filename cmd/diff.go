@@ -33,6 +33,9 @@ var diffCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
+			if err := resolveImageDigests(context.Background(), target.projectCtx, &desired, target.partitionFilters, target.stackFilters); err != nil {
+				return err
+			}
 
 			client, err := target.projectCtx.SwarmClient()
 			if err != nil {
@@ -49,6 +52,7 @@ var diffCmd = &cobra.Command{
 			}
 
 			warnings := cmdutil.VolumePlacementWarnings(cfg, target.partitionFilters, target.stackFilters, opts.Debug)
+			warnings = append(warnings, cmdutil.PlacementWarnings(cfg, target.partitionFilters, target.stackFilters)...)
 			sortServiceStates(report.Services)
 			sortConfigSpecs(report.MissingConfigs)
 			sortSecretSpecs(report.MissingSecrets)
@@ -64,7 +68,7 @@ var diffCmd = &cobra.Command{
 			}
 
 			cmdutil.PrintWarnings(out, warnings)
-			_, _ = fmt.Fprintf(out, "diff OK\nconfigs to create: %d\nsecrets to create: %d\nnetworks to create: %d\nconfigs to delete: %d\nsecrets to delete: %d\nconfigs preserved: %d\nsecrets preserved: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\nservices to update: %d\nservices missing: %d\n", len(report.MissingConfigs), len(report.MissingSecrets), len(report.MissingNetworks), len(report.StaleConfigs), len(report.StaleSecrets), report.Preserved.ConfigsPreserved, report.Preserved.SecretsPreserved, report.SkippedDeletes.Configs, report.SkippedDeletes.Secrets, len(changedServices), len(missingServices))
+			_, _ = fmt.Fprintf(out, "diff OK\nconfigs to create: %d\nsecrets to create: %d\nnetworks to create: %d\nconfigs to delete: %d\nsecrets to delete: %d\nnetworks to delete: %d\nconfigs preserved: %d\nsecrets preserved: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\nnetworks skipped (in use): %d\nservices to update: %d\nservices missing: %d\n", len(report.MissingConfigs), len(report.MissingSecrets), len(report.MissingNetworks), len(report.StaleConfigs), len(report.StaleSecrets), len(report.StaleNetworks), report.Preserved.ConfigsPreserved, report.Preserved.SecretsPreserved, report.SkippedDeletes.Configs, report.SkippedDeletes.Secrets, report.SkippedDeletes.Networks, len(changedServices), len(missingServices))
 			if len(report.MissingConfigs) > 0 {
 				_, _ = fmt.Fprintln(out, "configs to create:")
 				for _, cfg := range report.MissingConfigs {
@@ -107,6 +111,9 @@ var diffCmd = &cobra.Command{
 					_, _ = fmt.Fprintf(out, "  - %s\n", net.Name)
 				}
 			}
+			printStaleNetworks(out, "networks to delete:", report.StaleNetworks)
+			printNetworkDrift(out, report.DriftNetworks)
+			printNetworkConflicts(out, report.NetworkConflicts)
 			if len(changedServices) > 0 {
 				_, _ = fmt.Fprintln(out, "services to update:")
 				for _, state := range changedServices {
@@ -254,8 +261,10 @@ func printStateDiff(out io.Writer, configPath string, report apply.StatusReport,
 		SecretsCreated:  len(report.MissingSecrets),
 		ConfigsRemoved:  len(report.StaleConfigs),
 		SecretsRemoved:  len(report.StaleSecrets),
+		NetworksRemoved: len(report.StaleNetworks),
 		ConfigsSkipped:  report.SkippedDeletes.Configs,
 		SecretsSkipped:  report.SkippedDeletes.Secrets,
+		NetworksSkipped: report.SkippedDeletes.Networks,
 		ServicesCreated: len(missingServices),
 		ServicesUpdated: len(changedServices),
 		StacksDeployed:  uniqueServiceStacks(changedServices, missingServices),
@@ -684,8 +693,10 @@ func diffStateSummary(previous, current state.PlanSummary) []summaryDelta {
 	appendDelta("networks to create", previous.NetworksCreated, current.NetworksCreated)
 	appendDelta("configs to delete", previous.ConfigsRemoved, current.ConfigsRemoved)
 	appendDelta("secrets to delete", previous.SecretsRemoved, current.SecretsRemoved)
+	appendDelta("networks to delete", previous.NetworksRemoved, current.NetworksRemoved)
 	appendDelta("configs skipped", previous.ConfigsSkipped, current.ConfigsSkipped)
 	appendDelta("secrets skipped", previous.SecretsSkipped, current.SecretsSkipped)
+	appendDelta("networks skipped", previous.NetworksSkipped, current.NetworksSkipped)
 	appendDelta("services to create", previous.ServicesCreated, current.ServicesCreated)
 	appendDelta("services to update", previous.ServicesUpdated, current.ServicesUpdated)
 	appendDelta("stacks to deploy", previous.StacksDeployed, current.StacksDeployed)
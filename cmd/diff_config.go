@@ -80,6 +80,7 @@ func newDiffConfigListCmd() *cobra.Command {
 				Partition:          partition,
 				Offline:            opts.Offline,
 				Debug:              opts.Debug,
+				ClientFactory:      swarmClientFactory(),
 			}
 			cfg, _, err := cmdutil.LoadProjectConfig(projectOpts)
 			if err != nil {
@@ -178,6 +179,7 @@ func newDiffConfigCompareCmd() *cobra.Command {
 				Partition:          partition,
 				Offline:            opts.Offline,
 				Debug:              opts.Debug,
+				ClientFactory:      swarmClientFactory(),
 			}
 			cfg, _, err := cmdutil.LoadProjectConfig(projectOpts)
 			if err != nil {
@@ -269,7 +271,7 @@ func findConfigVersions(ctx context.Context, client swarm.Client, projectName st
 	records := make([]configVersionRecord, 0, len(configs))
 	for _, cfg := range configs {
 		labels := cfg.Labels
-		if labels == nil || labels[render.LabelManaged] != "true" || labels[render.LabelProject] != projectName {
+		if labels == nil || labels[render.LabelManaged] != "true" || labels[render.LabelProject] != projectName || labels[render.LabelRelease] != "" {
 			continue
 		}
 		id := identityFromLabels(labels)
@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/notify"
+	"github.com/cmmoran/swarmcp/internal/state"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyLimit    int
+	rollbackRunJobs bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [release]",
+	Short: "List release history recorded in Swarm, or show one release",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if historyLimit < 0 {
+			return fmt.Errorf("limit must be >= 0")
+		}
+		targets, err := prepareRuntimeTargets()
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		return forEachRuntimeTarget(out, targets, runtimeTargetOptions{}, func(target runtimeTarget) error {
+			client, err := target.projectCtx.SwarmClient()
+			if err != nil {
+				return err
+			}
+			releases, err := listTargetReleases(context.Background(), client, target.projectCtx.Config.Project.Name, targets.stackFilters, targets.partitionFilters)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				release, err := selectRelease(releases, args[0])
+				if err != nil {
+					return err
+				}
+				printRelease(out, release)
+				return nil
+			}
+			_, _ = fmt.Fprintf(out, "history OK\nreleases: %d\n", len(releases))
+			rows := releases
+			if historyLimit > 0 && len(rows) > historyLimit {
+				rows = rows[:historyLimit]
+			}
+			for i, release := range rows {
+				_, _ = fmt.Fprintf(out, "  @%d %s\n", i, formatReleaseLine(release))
+			}
+			if historyLimit > 0 && len(releases) > historyLimit {
+				_, _ = fmt.Fprintf(out, "  ... %d more\n", len(releases)-historyLimit)
+			}
+			return nil
+		})
+	},
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <release>",
+	Short: "Re-deploy the service intent recorded by a release",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireLiveCluster("rollback"); err != nil {
+			return err
+		}
+		outputMode := strings.TrimSpace(opts.Output)
+		if outputMode == "" {
+			outputMode = "auto"
+		}
+		if err := apply.ValidateDeployOutputMode(outputMode); err != nil {
+			return err
+		}
+		targets, err := prepareRuntimeTargets()
+		if err != nil {
+			return err
+		}
+		outputFlagSet := cmd.Flags().Changed("output")
+		noUI := opts.NoUI || outputFlagSet
+		out := cmd.OutOrStdout()
+		return forEachRuntimeTarget(out, targets, runtimeTargetOptions{includeSecrets: true}, func(target runtimeTarget) error {
+			cfg := target.projectCtx.Config
+			client, err := target.projectCtx.SwarmClient()
+			if err != nil {
+				return err
+			}
+			ctx := context.Background()
+			lease, err := acquireApplyLock(ctx, cmd, client, apply.LockScope{Project: cfg.Project.Name}, applyLockTimeout, applyLockTTL)
+			if err != nil {
+				return err
+			}
+			defer releaseApplyLock(cmd, lease)
+
+			releases, err := listTargetReleases(ctx, client, cfg.Project.Name, targets.stackFilters, targets.partitionFilters)
+			if err != nil {
+				return err
+			}
+			release, err := selectRelease(releases, args[0])
+			if err != nil {
+				return err
+			}
+			release = release.Filter(targets.stackFilters, targets.partitionFilters)
+			plan, err := apply.RollbackPlan(ctx, client, release)
+			if err != nil {
+				return err
+			}
+			if opts.Confirm {
+				confirmed, err := confirmRollback(cmd, release)
+				if err != nil {
+					return err
+				}
+				if !confirmed {
+					_, _ = fmt.Fprintln(out, "rollback cancelled")
+					return nil
+				}
+			}
+			notifier, err := projectNotifier(cmd, target.projectCtx)
+			if err != nil {
+				return err
+			}
+			stackParallel := 0
+			if opts.Serial {
+				stackParallel = 1
+			}
+			planSummary := buildPlanSummary(plan)
+			stackNames, serviceCreates, serviceUpdates := planDeploySummary(plan.StackDeploys)
+			planSummary.StackNames = stackNames
+			planSummary.ServicesCreated = serviceCreates
+			planSummary.ServicesUpdated = serviceUpdates
+			event := notifyEvent("rollback", cfg.Project.Name, cfg.Project.Deployment, target.projectCtx.ContextName, targets.partitionFilters, targets.stackFilters, planSummary)
+			event.Release = release.ID
+			deployPlan := plan
+			if !rollbackRunJobs {
+				deployPlan.StackDeploys = apply.WithoutJobs(plan.StackDeploys)
+			}
+			results, err := apply.Apply(ctx, client, deployPlan, lease, false, !opts.NoRollback, stackParallel, noUI, outputMode, outputFlagSet)
+			notifyStackResults(notifier, event, results)
+			if err != nil {
+				failed := event.As(notify.EventRollback)
+				failed.Error = err.Error()
+				notifier.Send(failed)
+				return err
+			}
+			recordRelease(cmd, client, cfg.Project.Name, config.ReleaseHistory(cfg), plan, planSummary, func(entry *apply.Release) {
+				entry.Deployment = cfg.Project.Deployment
+				entry.Context = target.projectCtx.ContextName
+				entry.Partitions = targets.partitionFilters
+				entry.StackFilters = targets.stackFilters
+				entry.RollbackOf = release.ID
+				entry.Inputs = release.Inputs
+				entry.SourceInputs = release.SourceInputs
+			})
+			notifier.Send(event.As(notify.EventRollback))
+			_, _ = fmt.Fprintf(out, "rollback OK\nrelease: %s\nstacks deployed: %d\n", release.ID, planSummary.StacksDeployed)
+			for _, name := range stackNames {
+				_, _ = fmt.Fprintf(out, "  - %s\n", name)
+			}
+			return nil
+		})
+	},
+}
+
+func listTargetReleases(ctx context.Context, client swarm.Client, project string, stackFilters []string, partitionFilters []string) ([]apply.Release, error) {
+	releases, err := apply.ListReleases(ctx, client, project)
+	if err != nil {
+		return nil, err
+	}
+	if len(stackFilters) == 0 && len(partitionFilters) == 0 {
+		return releases, nil
+	}
+	var out []apply.Release
+	for _, release := range releases {
+		if len(release.Filter(stackFilters, partitionFilters).Stacks) > 0 {
+			out = append(out, release)
+		}
+	}
+	return out, nil
+}
+
+// selectRelease resolves a release ID or an @index into the (newest first)
+// filtered history.
+func selectRelease(releases []apply.Release, selector string) (apply.Release, error) {
+	selector = strings.TrimSpace(selector)
+	if strings.HasPrefix(selector, "@") {
+		index, err := strconv.Atoi(strings.TrimPrefix(selector, "@"))
+		if err != nil || index < 0 {
+			return apply.Release{}, fmt.Errorf("invalid release selector %q: expected @<index> or a release id", selector)
+		}
+		if index >= len(releases) {
+			return apply.Release{}, fmt.Errorf("release selector %q out of range (releases=%d)", selector, len(releases))
+		}
+		return releases[index], nil
+	}
+	for _, release := range releases {
+		if release.ID == selector {
+			return release, nil
+		}
+	}
+	return apply.Release{}, fmt.Errorf("release %q not found", selector)
+}
+
+func formatReleaseLine(release apply.Release) string {
+	stacks := make([]string, 0, len(release.Stacks))
+	for _, entry := range release.Stacks {
+		stacks = append(stacks, entry.Name)
+	}
+	line := fmt.Sprintf("%s %s by %s stacks=%d", release.ID, release.CreatedAt.UTC().Format(time.RFC3339), release.Holder, len(stacks))
+	if len(stacks) > 0 {
+		line += " (" + strings.Join(stacks, ", ") + ")"
+	}
+	if release.RollbackOf != "" {
+		line += " rollback-of=" + release.RollbackOf
+	}
+	return line
+}
+
+func printRelease(out io.Writer, release apply.Release) {
+	_, _ = fmt.Fprintf(out, "release: %s\ncreated: %s\nholder: %s\ncommand: %s\n", release.ID, release.CreatedAt.UTC().Format(time.RFC3339), release.Holder, release.Command)
+	if release.Deployment != "" {
+		_, _ = fmt.Fprintf(out, "deployment: %s\n", release.Deployment)
+	}
+	if release.Context != "" {
+		_, _ = fmt.Fprintf(out, "context: %s\n", release.Context)
+	}
+	if len(release.Partitions) > 0 {
+		_, _ = fmt.Fprintf(out, "partitions: %s\n", strings.Join(release.Partitions, ", "))
+	}
+	if len(release.StackFilters) > 0 {
+		_, _ = fmt.Fprintf(out, "stack filters: %s\n", strings.Join(release.StackFilters, ", "))
+	}
+	if release.ToolVersion != "" {
+		_, _ = fmt.Fprintf(out, "tool version: %s\n", release.ToolVersion)
+	}
+	if release.PlanFile != "" {
+		_, _ = fmt.Fprintf(out, "plan artifact: %s\n", release.PlanFile)
+	}
+	if release.RollbackOf != "" {
+		_, _ = fmt.Fprintf(out, "rollback of: %s\n", release.RollbackOf)
+	}
+	summary := release.Summary
+	_, _ = fmt.Fprintf(out, "networks created: %d\nconfigs created: %d\nsecrets created: %d\nstacks deployed: %d\nservices created: %d\nservices updated: %d\nconfigs removed: %d\nsecrets removed: %d\nnetworks removed: %d\n", summary.NetworksCreated, summary.ConfigsCreated, summary.SecretsCreated, summary.StacksDeployed, summary.ServicesCreated, summary.ServicesUpdated, summary.ConfigsRemoved, summary.SecretsRemoved, summary.NetworksRemoved)
+	if len(release.Stacks) > 0 {
+		_, _ = fmt.Fprintln(out, "stacks:")
+		for _, entry := range release.Stacks {
+			scope := "stack=" + entry.Stack
+			if entry.Partition != "" {
+				scope += " partition=" + entry.Partition
+			}
+			_, _ = fmt.Fprintf(out, "  - %s %s\n", entry.Name, scope)
+		}
+	}
+	if len(release.Images) > 0 {
+		_, _ = fmt.Fprintln(out, "images:")
+		for _, key := range slices.Sorted(maps.Keys(release.Images)) {
+			_, _ = fmt.Fprintf(out, "  - %s: %s\n", key, release.Images[key])
+		}
+	}
+	if len(release.Inputs) > 0 {
+		_, _ = fmt.Fprintln(out, "inputs:")
+		for _, input := range release.Inputs {
+			_, _ = fmt.Fprintf(out, "  - %s %s sha256=%s\n", input.Kind, input.Path, input.SHA256)
+		}
+	}
+	if len(release.SourceInputs) > 0 {
+		_, _ = fmt.Fprintln(out, "sources:")
+		for _, source := range release.SourceInputs {
+			ref := source.URL
+			if source.Ref != "" {
+				ref += "@" + source.Ref
+			}
+			_, _ = fmt.Fprintf(out, "  - %s %s commit=%s\n", source.Kind, ref, source.Commit)
+		}
+	}
+}
+
+func confirmRollback(cmd *cobra.Command, release apply.Release) (bool, error) {
+	names := make([]string, 0, len(release.StackDeploys))
+	for _, deploy := range release.StackDeploys {
+		names = append(names, deploy.Name)
+	}
+	return cmdutil.ConfirmPrompt(cmd.InOrStdin(), cmd.OutOrStdout(), fmt.Sprintf("Roll back %d stack(s) to release %s (%s)?", len(names), release.ID, strings.Join(names, ", ")))
+}
+
+// planRecordsRelease reports whether an apply changed anything worth a
+// release entry.
+func planRecordsRelease(summary state.PlanSummary) bool {
+	return summary.StacksDeployed > 0 ||
+		summary.NetworksCreated > 0 ||
+		summary.ConfigsCreated > 0 ||
+		summary.SecretsCreated > 0 ||
+		summary.ConfigsRemoved > 0 ||
+		summary.SecretsRemoved > 0 ||
+		summary.NetworksRemoved > 0
+}
+
+// recordRelease writes the release ledger entry for a successful apply,
+// trims the history to keep entries and returns the new ID. The deploy
+// already happened, so failures are reported as warnings and yield "".
+func recordRelease(cmd *cobra.Command, client swarm.Client, project string, keep int, plan apply.Plan, summary state.PlanSummary, fill func(*apply.Release)) string {
+	if !planRecordsRelease(summary) {
+		return ""
+	}
+	release, err := apply.NewRelease(project, plan)
+	if err == nil {
+		release.Command = invocationCommand()
+		release.ToolVersion = Version
+		release.Summary = summary
+		fill(&release)
+		err = apply.RecordRelease(context.Background(), client, release)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: release not recorded: %v\n", err)
+		return ""
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "release recorded: %s\n", release.ID)
+	if removed, err := apply.PruneReleases(context.Background(), client, project, keep); err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: release history not pruned: %v\n", err)
+	} else if removed > 0 {
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "release history pruned: %d\n", removed)
+	}
+	return release.ID
+}
+
+func init() {
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "Maximum number of releases to list (0 for all)")
+	rollbackCmd.Flags().BoolVar(&opts.Serial, "serial", false, "Deploy services one at a time within each rollout batch")
+	rollbackCmd.Flags().BoolVar(&rollbackRunJobs, "run-jobs", false, "Run the lifecycle jobs recorded in the release (skipped by default)")
+	rollbackCmd.Flags().BoolVar(&opts.NoRollback, "no-rollback", false, "Leave services that fail their health check on the rolled back spec")
+	rollbackCmd.Flags().BoolVar(&opts.NoUI, "no-ui", false, "Disable stack deployment UI and emit per-service results per stack")
+	rollbackCmd.Flags().StringVar(&opts.Output, "output", "auto", "Deploy output mode: auto|summary|stack|error-only (explicitly setting this implies --no-ui)")
+	rollbackCmd.Flags().DurationVar(&applyLockTimeout, "lock-timeout", 0, "How long to wait for a conflicting apply lock to be released (0 fails immediately)")
+	rollbackCmd.Flags().DurationVar(&applyLockTTL, "lock-ttl", apply.DefaultLockTTL, "Lease duration of the apply lock; expired locks are taken over by the next apply")
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(rollbackCmd)
+}
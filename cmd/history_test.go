@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/state"
+)
+
+func TestSelectRelease(t *testing.T) {
+	releases := []apply.Release{{ID: "20261016T120000Z-bbbbbb"}, {ID: "20261015T120000Z-aaaaaa"}}
+
+	got, err := selectRelease(releases, "@1")
+	if err != nil || got.ID != "20261015T120000Z-aaaaaa" {
+		t.Fatalf("expected @1 to select the older release, got %#v, %v", got, err)
+	}
+	got, err = selectRelease(releases, "20261016T120000Z-bbbbbb")
+	if err != nil || got.ID != "20261016T120000Z-bbbbbb" {
+		t.Fatalf("expected id selection, got %#v, %v", got, err)
+	}
+	if _, err := selectRelease(releases, "@2"); err == nil {
+		t.Fatalf("expected out of range error")
+	}
+	if _, err := selectRelease(releases, "missing"); err == nil {
+		t.Fatalf("expected not found error")
+	}
+}
+
+func TestPlanRecordsRelease(t *testing.T) {
+	if planRecordsRelease(state.PlanSummary{ConfigsSkipped: 2}) {
+		t.Fatalf("skipped deletes alone should not record a release")
+	}
+	if !planRecordsRelease(state.PlanSummary{StacksDeployed: 1}) {
+		t.Fatalf("stack deploys should record a release")
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+)
+
+var newImageResolver = func(credentials swarm.RegistryCredentialSource) apply.ImageDigestResolver {
+	resolver := swarm.NewImageResolver()
+	resolver.Credentials = credentials
+	return resolver
+}
+
+type imageInspector interface {
+	InspectImage(ctx context.Context, image string) (swarm.ImageManifest, error)
+}
+
+var newImageInspector = func(credentials swarm.RegistryCredentialSource) imageInspector {
+	resolver := swarm.NewImageResolver()
+	resolver.Credentials = credentials
+	return resolver
+}
+
+// resolveImageDigests pins the selected services' images to registry
+// digests when --resolve-digests is set or the deployment requires digests
+// by policy, and enforces that policy.
+func resolveImageDigests(ctx context.Context, projectCtx *cmdutil.ProjectContext, desired *apply.DesiredState, partitionFilters []string, stackFilters []string) error {
+	cfg := projectCtx.Config
+	if !opts.ResolveDigests && !cfg.RequiresImageDigests() {
+		return nil
+	}
+	if opts.ResolveDigests && opts.Offline {
+		return fmt.Errorf("--resolve-digests needs registry access and cannot be combined with --offline")
+	}
+	images, err := apply.ServiceImages(cfg, *desired, projectCtx.Values, partitionFilters, stackFilters, !opts.NoInfer)
+	if err != nil {
+		return err
+	}
+	if !opts.Offline {
+		digests, err := apply.ResolveImageDigests(ctx, newImageResolver(projectCtx.RegistryCredentials), images)
+		if err != nil {
+			return err
+		}
+		desired.ImageDigests = digests
+	}
+	return apply.RequireImageDigests(cfg, images, desired.ImageDigests)
+}
+
+func printImageDigests(out io.Writer, digests map[string]string) {
+	if len(digests) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(out, "image digests:")
+	for _, image := range slices.Sorted(maps.Keys(digests)) {
+		_, _ = fmt.Fprintf(out, "  - %s -> %s\n", image, digests[image])
+	}
+}
+
+// checkServiceImages looks up the manifest of every selected service image
+// and reports missing tags, registry authorization failures and images not
+// published for the platform of a deployment node the service can land on.
+// The problems fail the command unless --skip-image-check is set, in which
+// case they are returned as warnings. The check is skipped with --offline.
+func checkServiceImages(ctx context.Context, projectCtx *cmdutil.ProjectContext, desired apply.DesiredState, partitionFilters []string, stackFilters []string) ([]string, error) {
+	if opts.Offline {
+		return nil, nil
+	}
+	cfg := projectCtx.Config
+	targets, err := apply.ServiceImageTargets(cfg, desired, projectCtx.Values, partitionFilters, stackFilters, !opts.NoInfer)
+	if err != nil {
+		return nil, err
+	}
+	nodes := cmdutil.ResolveDeploymentNodeSpecs(cfg)
+	inspector := newImageInspector(projectCtx.RegistryCredentials)
+	type inspection struct {
+		manifest swarm.ImageManifest
+		err      error
+	}
+	inspected := make(map[string]inspection)
+	var problems []string
+	for _, target := range targets {
+		result, ok := inspected[target.Image]
+		if !ok {
+			result.manifest, result.err = inspector.InspectImage(ctx, target.Image)
+			inspected[target.Image] = result
+		}
+		label := cmdutil.ServiceScopeLabel(target.Stack, target.Partition, target.Service)
+		switch {
+		case errors.Is(result.err, swarm.ErrManifestNotFound):
+			problems = append(problems, fmt.Sprintf("%s: image %s not found in registry", label, target.Image))
+		case errors.Is(result.err, swarm.ErrRegistryUnauthorized):
+			problems = append(problems, fmt.Sprintf("%s: registry authorization failed for %s: %v", label, target.Image, result.err))
+		case result.err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %v", label, result.err))
+		default:
+			if missing := cmdutil.NodesLackingImagePlatform(nodes, target.Constraints, result.manifest.Platforms); len(missing) > 0 {
+				problems = append(problems, fmt.Sprintf("%s: image %s (%s) has no build for node(s) %s", label, target.Image, strings.Join(result.manifest.Platforms, ", "), formatNodePlatforms(nodes, missing)))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	if opts.SkipImageCheck {
+		warnings := make([]string, 0, len(problems))
+		for _, problem := range problems {
+			warnings = append(warnings, "image check: "+problem)
+		}
+		return warnings, nil
+	}
+	return nil, fmt.Errorf("image check failed (use --skip-image-check to continue):\n  - %s", strings.Join(problems, "\n  - "))
+}
+
+func formatNodePlatforms(nodes map[string]config.NodeSpec, names []string) string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		platform := nodes[name].Platform
+		value := platform.OS
+		if platform.Arch != "" {
+			value += "/" + platform.Arch
+		}
+		out = append(out, fmt.Sprintf("%s (%s)", name, value))
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+)
+
+type fakeImageInspector map[string]swarm.ImageManifest
+
+func (f fakeImageInspector) InspectImage(ctx context.Context, image string) (swarm.ImageManifest, error) {
+	switch image {
+	case "private/api:1":
+		return swarm.ImageManifest{}, fmt.Errorf("inspect image %q: %w", image, swarm.ErrRegistryUnauthorized)
+	}
+	manifest, ok := f[image]
+	if !ok {
+		return swarm.ImageManifest{}, fmt.Errorf("inspect image %q: %w", image, swarm.ErrManifestNotFound)
+	}
+	return manifest, nil
+}
+
+func TestCheckServiceImages(t *testing.T) {
+	prevInspector, prevSkip, prevOffline := newImageInspector, opts.SkipImageCheck, opts.Offline
+	t.Cleanup(func() {
+		newImageInspector, opts.SkipImageCheck, opts.Offline = prevInspector, prevSkip, prevOffline
+	})
+	newImageInspector = func(swarm.RegistryCredentialSource) imageInspector {
+		return fakeImageInspector{
+			"web:1":    {Platforms: []string{"linux/amd64"}},
+			"worker:1": {Platforms: []string{"linux/amd64", "linux/arm64/v8"}},
+		}
+	}
+	cfg := &config.Config{
+		Project: config.Project{
+			Name: "proj",
+			Nodes: map[string]config.Node{
+				"amd": {Labels: map[string]string{"tier": "edge"}, Platform: config.NodePlatform{OS: "linux", Arch: "amd64"}},
+				"arm": {Platform: config.NodePlatform{OS: "linux", Arch: "arm64"}},
+			},
+		},
+		Stacks: map[string]config.Stack{
+			"app": {Mode: "shared", Services: map[string]config.Service{
+				"edge":    {Image: "web:1", Replicas: 1, Placement: config.Placement{Constraints: []string{"node.labels.tier==edge"}}},
+				"web":     {Image: "web:1", Replicas: 1},
+				"worker":  {Image: "worker:1", Replicas: 1},
+				"missing": {Image: "web:2", Replicas: 1},
+				"private": {Image: "private/api:1", Replicas: 1},
+			}},
+		},
+	}
+
+	projectCtx := &cmdutil.ProjectContext{Config: cfg}
+
+	_, err := checkServiceImages(context.Background(), projectCtx, apply.DesiredState{}, nil, nil)
+	if err == nil {
+		t.Fatalf("expected image check to fail")
+	}
+	message := err.Error()
+	for _, want := range []string{
+		"app/missing: image web:2 not found in registry",
+		"app/private: registry authorization failed for private/api:1",
+		"app/web: image web:1 (linux/amd64) has no build for node(s) arm (linux/arm64)",
+	} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("expected %q in:\n%s", want, message)
+		}
+	}
+	if strings.Contains(message, "app/edge") || strings.Contains(message, "app/worker") {
+		t.Fatalf("unexpected problems for placeable services:\n%s", message)
+	}
+
+	opts.SkipImageCheck = true
+	warnings, err := checkServiceImages(context.Background(), projectCtx, apply.DesiredState{}, nil, nil)
+	if err != nil || len(warnings) != 3 || !strings.HasPrefix(warnings[0], "image check: ") {
+		t.Fatalf("expected warnings with --skip-image-check, got %v, %v", warnings, err)
+	}
+
+	opts.Offline = true
+	warnings, err = checkServiceImages(context.Background(), projectCtx, apply.DesiredState{}, nil, nil)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("expected check to be skipped offline, got %v, %v", warnings, err)
+	}
+}
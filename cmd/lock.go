@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyLockTimeout time.Duration
+	applyLockTTL     time.Duration
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Inspect or break the cluster-side apply lock",
+}
+
+var lockStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show apply locks held for the project",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets, err := prepareRuntimeTargets()
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		return forEachRuntimeTarget(out, targets, runtimeTargetOptions{}, func(target runtimeTarget) error {
+			client, err := target.projectCtx.SwarmClient()
+			if err != nil {
+				return err
+			}
+			project := target.projectCtx.Config.Project.Name
+			locks, err := apply.ListLocks(context.Background(), client, project)
+			if err != nil {
+				return err
+			}
+			if len(locks) == 0 {
+				_, _ = fmt.Fprintf(out, "lock: none held for project %q\n", project)
+				return nil
+			}
+			now := time.Now()
+			_, _ = fmt.Fprintf(out, "locks held: %d\n", len(locks))
+			for _, lock := range locks {
+				line := "  - " + lock.Describe()
+				if lock.Expired(now) {
+					line += " [expired]"
+				}
+				_, _ = fmt.Fprintln(out, line)
+			}
+			return nil
+		})
+	},
+}
+
+var lockBreakCmd = &cobra.Command{
+	Use:   "break",
+	Short: "Forcibly remove the apply lock for the project (or the selected --partition)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireLiveCluster("lock break"); err != nil {
+			return err
+		}
+		if len(opts.Partitions) > 1 {
+			return fmt.Errorf("lock break accepts at most one --partition")
+		}
+		targets, err := prepareRuntimeTargets()
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		return forEachRuntimeTarget(out, targets, runtimeTargetOptions{}, func(target runtimeTarget) error {
+			client, err := target.projectCtx.SwarmClient()
+			if err != nil {
+				return err
+			}
+			scope := apply.LockScope{Project: target.projectCtx.Config.Project.Name}
+			if len(targets.partitionFilters) == 1 {
+				scope.Partition = targets.partitionFilters[0]
+			}
+			if opts.Confirm {
+				confirmed, err := cmdutil.ConfirmPrompt(cmd.InOrStdin(), out, fmt.Sprintf("Break apply lock for %s?", scope))
+				if err != nil {
+					return err
+				}
+				if !confirmed {
+					_, _ = fmt.Fprintln(out, "lock break cancelled")
+					return nil
+				}
+			}
+			lock, err := apply.BreakLock(context.Background(), client, scope)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(out, "lock broken: %s\n", lock.Describe())
+			return nil
+		})
+	},
+}
+
+// applyLockScope narrows the apply lock to a single partition only when the
+// run cannot touch anything outside it: exactly one partition is selected,
+// no shared stack is deployed and no project-wide pruning is requested.
+func applyLockScope(cfg *config.Config, partitionFilters []string, stackFilters []string, prune bool) apply.LockScope {
+	scope := apply.LockScope{Project: cfg.Project.Name}
+	if prune || len(partitionFilters) != 1 {
+		return scope
+	}
+	for stackName, stack := range cfg.Stacks {
+		if len(stackFilters) > 0 && !cmdutil.StackInFilters(stackFilters, stackName) {
+			continue
+		}
+		if stack.Mode != "partitioned" && cfg.StackSelectedForRuntime(stackName, partitionFilters) {
+			return scope
+		}
+	}
+	scope.Partition = partitionFilters[0]
+	return scope
+}
+
+func acquireApplyLock(ctx context.Context, cmd *cobra.Command, client swarm.Client, scope apply.LockScope, timeout time.Duration, ttl time.Duration) (*apply.Lease, error) {
+	if timeout > 0 {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "waiting up to %s for apply lock on %s\n", timeout, scope)
+	}
+	lease, err := apply.AcquireLock(ctx, client, scope, invocationCommand(), ttl, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("acquire apply lock: %w (use --lock-timeout to wait or `swarmcp lock break` to remove a stale lock)", err)
+	}
+	return lease, nil
+}
+
+func invocationCommand() string {
+	return strings.TrimSpace("swarmcp " + strings.Join(os.Args[1:], " "))
+}
+
+func releaseApplyLock(cmd *cobra.Command, lease *apply.Lease) {
+	if err := lease.Release(context.Background()); err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: %v\n", err)
+	}
+}
+
+func init() {
+	applyCmd.Flags().DurationVar(&applyLockTimeout, "lock-timeout", 0, "How long to wait for a conflicting apply lock to be released (0 fails immediately)")
+	applyCmd.Flags().DurationVar(&applyLockTTL, "lock-ttl", apply.DefaultLockTTL, "Lease duration of the apply lock; expired locks are taken over by the next apply")
+	lockCmd.AddCommand(lockStatusCmd)
+	lockCmd.AddCommand(lockBreakCmd)
+	rootCmd.AddCommand(lockCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+)
+
+func TestApplyLockScope(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{Name: "demo", Partitions: []string{"dev", "qa"}},
+		Stacks: map[string]config.Stack{
+			"app":  {Mode: "partitioned"},
+			"core": {Mode: "shared"},
+		},
+	}
+
+	if scope := applyLockScope(cfg, []string{"dev"}, []string{"app"}, false); scope.Partition != "dev" {
+		t.Fatalf("expected partition scope, got %#v", scope)
+	}
+	if scope := applyLockScope(cfg, []string{"dev"}, nil, false); scope.Partition != "" {
+		t.Fatalf("expected project scope when a shared stack is deployed, got %#v", scope)
+	}
+	if scope := applyLockScope(cfg, []string{"dev"}, []string{"app"}, true); scope.Partition != "" {
+		t.Fatalf("expected project scope when pruning, got %#v", scope)
+	}
+	if scope := applyLockScope(cfg, []string{"dev", "qa"}, []string{"app"}, false); scope.Partition != "" {
+		t.Fatalf("expected project scope for multiple partitions, got %#v", scope)
+	}
+}
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/metrics"
+	"github.com/cmmoran/swarmcp/internal/render"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metricsAddr     string
+	metricsInterval time.Duration
+)
+
+var metricsHelp = map[string]string{
+	"swarmcp_service_replicas_desired":                 "Desired replicas of a managed service (-1 when unknown).",
+	"swarmcp_service_replicas_running":                 "Running tasks of a managed service (-1 when unknown).",
+	"swarmcp_service_healthy":                          "1 when the service runs its desired replicas or is disabled, 0 otherwise.",
+	"swarmcp_service_missing":                          "1 when the service is declared but not deployed.",
+	"swarmcp_service_drift":                            "Number of service intent fields that differ from the desired spec.",
+	"swarmcp_service_canary_replicas_running":          "Running tasks of the canary of a service during a canary rollout.",
+	"swarmcp_network_drift":                            "Number of settings of a network that differ from the desired spec.",
+	"swarmcp_network_conflicts":                        "Existing networks whose subnet overlaps the subnet of a network to be created.",
+	"swarmcp_resources_missing":                        "Desired configs, secrets or networks that do not exist in the cluster.",
+	"swarmcp_resources_stale":                          "Unused managed configs, secrets or networks.",
+	"swarmcp_resources_drift":                          "Configs, secrets or networks whose labels or settings drifted.",
+	"swarmcp_missing_secret_values":                    "Secrets rendered as placeholders because their value is missing.",
+	"swarmcp_refresh_success":                          "1 when the last status refresh of the target succeeded.",
+	"swarmcp_last_refresh_success_timestamp_seconds":   "Unix time of the last successful status refresh.",
+	"swarmcp_reconcile_success":                        "1 when the last reconcile cycle of the target succeeded.",
+	"swarmcp_last_reconcile_success_timestamp_seconds": "Unix time of the last successful reconcile cycle.",
+}
+
+// statusMetrics publishes status reports of deployment targets as gauges.
+type statusMetrics struct {
+	registry *metrics.Registry
+
+	mu      sync.Mutex
+	targets map[string]*metricsTarget
+}
+
+type metricsTarget struct {
+	project       string
+	deployment    string
+	refreshed     bool
+	refreshOK     bool
+	reconciled    bool
+	reconcileOK   bool
+	lastRefresh   time.Time
+	lastReconcile time.Time
+}
+
+func newStatusMetrics() *statusMetrics {
+	return &statusMetrics{registry: metrics.NewRegistry(metricsHelp), targets: make(map[string]*metricsTarget)}
+}
+
+// observeRefresh records a refresh of a target. A failed refresh keeps the
+// previous status gauges and only flips swarmcp_refresh_success.
+func (m *statusMetrics) observeRefresh(deployment string, cfg *config.Config, report apply.StatusReport, missingValues int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	target := m.target(deployment)
+	if cfg != nil {
+		target.project = cfg.Project.Name
+		target.deployment = cfg.Project.Deployment
+	}
+	target.refreshed = true
+	target.refreshOK = err == nil
+	if err == nil {
+		target.lastRefresh = time.Now()
+		m.registry.Replace("status/"+deployment, statusSamples(target.project, target.deployment, report, missingValues))
+	}
+	m.publish(deployment, target)
+}
+
+func (m *statusMetrics) observeReconcile(deployment string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	target := m.target(deployment)
+	target.reconciled = true
+	target.reconcileOK = err == nil
+	if err == nil {
+		target.lastReconcile = time.Now()
+	}
+	m.publish(deployment, target)
+}
+
+func (m *statusMetrics) target(deployment string) *metricsTarget {
+	target, ok := m.targets[deployment]
+	if !ok {
+		target = &metricsTarget{deployment: deployment}
+		m.targets[deployment] = target
+	}
+	return target
+}
+
+func (m *statusMetrics) publish(deployment string, target *metricsTarget) {
+	labels := []metrics.Label{{Name: "project", Value: target.project}, {Name: "deployment", Value: target.deployment}}
+	var samples []metrics.Sample
+	if target.refreshed {
+		samples = append(samples, metrics.Sample{Name: "swarmcp_refresh_success", Labels: labels, Value: boolGauge(target.refreshOK)})
+	}
+	if !target.lastRefresh.IsZero() {
+		samples = append(samples, metrics.Sample{Name: "swarmcp_last_refresh_success_timestamp_seconds", Labels: labels, Value: float64(target.lastRefresh.Unix())})
+	}
+	if target.reconciled {
+		samples = append(samples, metrics.Sample{Name: "swarmcp_reconcile_success", Labels: labels, Value: boolGauge(target.reconcileOK)})
+	}
+	if !target.lastReconcile.IsZero() {
+		samples = append(samples, metrics.Sample{Name: "swarmcp_last_reconcile_success_timestamp_seconds", Labels: labels, Value: float64(target.lastReconcile.Unix())})
+	}
+	m.registry.Replace("target/"+deployment, samples)
+}
+
+func statusSamples(project, deployment string, report apply.StatusReport, missingValues int) []metrics.Sample {
+	base := []metrics.Label{{Name: "project", Value: project}, {Name: "deployment", Value: deployment}}
+	var samples []metrics.Sample
+	for _, state := range report.Services {
+		labels := append(append([]metrics.Label(nil), base...),
+			metrics.Label{Name: "stack", Value: state.Stack},
+			metrics.Label{Name: "partition", Value: state.Partition},
+			metrics.Label{Name: "service", Value: state.Service},
+		)
+		samples = append(samples, metrics.Sample{Name: "swarmcp_service_missing", Labels: labels, Value: boolGauge(state.Missing)})
+		if state.Missing {
+			continue
+		}
+		drift := len(state.IntentDiffs)
+		if drift == 0 && !state.IntentMatch {
+			drift = 1
+		}
+		samples = append(samples,
+			metrics.Sample{Name: "swarmcp_service_replicas_desired", Labels: labels, Value: float64(state.Desired)},
+			metrics.Sample{Name: "swarmcp_service_replicas_running", Labels: labels, Value: float64(state.Running)},
+			metrics.Sample{Name: "swarmcp_service_healthy", Labels: labels, Value: boolGauge(state.Health == "healthy" || state.Health == "disabled")},
+			metrics.Sample{Name: "swarmcp_service_drift", Labels: labels, Value: float64(drift)},
+		)
+		if state.Canary != nil {
+			samples = append(samples, metrics.Sample{Name: "swarmcp_service_canary_replicas_running", Labels: labels, Value: float64(state.Canary.Running)})
+		}
+	}
+
+	for _, drift := range report.DriftNetworks {
+		labels := append(append([]metrics.Label(nil), base...), metrics.Label{Name: "network", Value: drift.Name})
+		samples = append(samples, metrics.Sample{Name: "swarmcp_network_drift", Labels: labels, Value: float64(max(len(drift.Diffs), 1))})
+	}
+	conflicts := make(map[string]int)
+	for _, conflict := range report.NetworkConflicts {
+		conflicts[conflict.Name]++
+	}
+	for name, count := range conflicts {
+		labels := append(append([]metrics.Label(nil), base...), metrics.Label{Name: "network", Value: name})
+		samples = append(samples, metrics.Sample{Name: "swarmcp_network_conflicts", Labels: labels, Value: float64(count)})
+	}
+
+	counts := newResourceCounts()
+	for _, item := range report.MissingConfigs {
+		counts.add("swarmcp_resources_missing", "config", item.Labels)
+	}
+	for _, item := range report.MissingSecrets {
+		counts.add("swarmcp_resources_missing", "secret", item.Labels)
+	}
+	for _, item := range report.MissingNetworks {
+		counts.add("swarmcp_resources_missing", "network", item.Labels)
+	}
+	for _, item := range report.StaleConfigs {
+		counts.add("swarmcp_resources_stale", "config", item.Labels)
+	}
+	for _, item := range report.StaleSecrets {
+		counts.add("swarmcp_resources_stale", "secret", item.Labels)
+	}
+	for _, item := range report.StaleNetworks {
+		counts.add("swarmcp_resources_stale", "network", item.Labels)
+	}
+	for _, item := range report.DriftConfigs {
+		counts.add("swarmcp_resources_drift", "config", item.Labels)
+	}
+	for _, item := range report.DriftSecrets {
+		counts.add("swarmcp_resources_drift", "secret", item.Labels)
+	}
+	for range report.DriftNetworks {
+		counts.add("swarmcp_resources_drift", "network", nil)
+	}
+	samples = append(samples, counts.samples(base)...)
+	samples = append(samples, metrics.Sample{Name: "swarmcp_missing_secret_values", Labels: base, Value: float64(missingValues)})
+	return samples
+}
+
+type resourceKey struct {
+	name      string
+	kind      string
+	stack     string
+	partition string
+}
+
+// resourceCounts counts resources per metric, kind and stack/partition
+// scope. Every metric/kind pair starts at zero for the project scope so the
+// series exist even when nothing is missing, stale or drifting.
+type resourceCounts map[resourceKey]int
+
+func newResourceCounts() resourceCounts {
+	counts := make(resourceCounts)
+	for _, name := range []string{"swarmcp_resources_missing", "swarmcp_resources_stale", "swarmcp_resources_drift"} {
+		for _, kind := range []string{"config", "secret", "network"} {
+			counts[resourceKey{name: name, kind: kind}] = 0
+		}
+	}
+	return counts
+}
+
+func (c resourceCounts) add(name, kind string, labels map[string]string) {
+	c[resourceKey{name: name, kind: kind, stack: labels[render.LabelStack], partition: labels[render.LabelPartition]}]++
+}
+
+func (c resourceCounts) samples(base []metrics.Label) []metrics.Sample {
+	samples := make([]metrics.Sample, 0, len(c))
+	for key, count := range c {
+		labels := append(append([]metrics.Label(nil), base...),
+			metrics.Label{Name: "stack", Value: key.stack},
+			metrics.Label{Name: "partition", Value: key.partition},
+			metrics.Label{Name: "kind", Value: key.kind},
+		)
+		samples = append(samples, metrics.Sample{Name: key.name, Labels: labels, Value: float64(count)})
+	}
+	return samples
+}
+
+func boolGauge(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// runStatusMetrics refreshes the status of every deployment target on
+// --metrics-interval and serves the gauges until interrupted. A target that
+// fails to refresh keeps its previous gauges.
+func runStatusMetrics(cmd *cobra.Command, targets *runtimeTargets) error {
+	if metricsInterval <= 0 {
+		return fmt.Errorf("--metrics-interval must be greater than zero")
+	}
+	m := newStatusMetrics()
+	server, err := startMetricsServer(cmd, metricsAddr, m.registry)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		for _, deployment := range targets.deployments {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := refreshStatusMetrics(ctx, cmd, m, targets, deployment); err != nil {
+				m.observeRefresh(deployment, nil, apply.StatusReport{}, 0, err)
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: status refresh failed: %v\n", reconcileLabel(deployment), err)
+			}
+		}
+		if !waitReconcile(ctx, metricsInterval) {
+			break
+		}
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func refreshStatusMetrics(ctx context.Context, cmd *cobra.Command, m *statusMetrics, targets *runtimeTargets, deployment string) error {
+	if !opts.Offline {
+		config.ForgetResolvedRefs()
+	}
+	projectCtx, err := loadValidatedProjectContext(targets, deployment, runtimeTargetOptions{includeValues: true, includeSecrets: true})
+	if err != nil {
+		return err
+	}
+	cfg := projectCtx.Config
+	partitionFilters := cmdutil.FilterDeploymentPartitions(cfg, targets.partitionFilters)
+	desired, err := apply.BuildDesiredState(cfg, projectCtx.Secrets, projectCtx.Values, partitionFilters, targets.stackFilters, opts.AllowMissing, !opts.NoInfer)
+	if err != nil {
+		return err
+	}
+	if err := resolveImageDigests(ctx, projectCtx, &desired, partitionFilters, targets.stackFilters); err != nil {
+		return err
+	}
+	client, err := projectCtx.SwarmClient()
+	if err != nil {
+		return err
+	}
+	report, err := buildTargetStatus(ctx, cmd, projectCtx, desired, partitionFilters, targets.stackFilters, client)
+	if err != nil {
+		return err
+	}
+	m.observeRefresh(deployment, cfg, report, len(desired.Missing), nil)
+	return nil
+}
+
+// startMetricsServer serves the registry at /metrics until shut down.
+func startMetricsServer(cmd *cobra.Command, addr string, registry *metrics.Registry) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics listener: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", registry.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "metrics server: %v\n", err)
+		}
+	}()
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "serving metrics on http://%s/metrics\n", listener.Addr())
+	return server, nil
+}
+
+func init() {
+	statusCmd.Flags().StringVar(&metricsAddr, "serve-metrics", "", "Serve Prometheus metrics on this address (e.g. :9464) and refresh status until interrupted")
+	statusCmd.Flags().DurationVar(&metricsInterval, "metrics-interval", time.Minute, "Time between status refreshes with --serve-metrics")
+	reconcileCmd.Flags().StringVar(&metricsAddr, "serve-metrics", "", "Serve Prometheus metrics on this address (e.g. :9464), refreshed after every cycle")
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/render"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+)
+
+func TestStatusMetricsText(t *testing.T) {
+	cfg := &config.Config{Project: config.Project{Name: "demo", Deployment: "prod"}}
+	report := apply.StatusReport{
+		Services: []apply.ServiceState{
+			{Stack: "app", Service: "web", IntentMatch: false, IntentDiffs: []string{"image", "env"}, Desired: 3, Running: 2, Health: "degraded"},
+			{Stack: "app", Partition: "blue", Service: "api", IntentMatch: true, Desired: 1, Running: 1, Health: "healthy"},
+			{Stack: "app", Service: "worker", Missing: true},
+		},
+		MissingSecrets: []swarm.SecretSpec{{Name: "db", Labels: map[string]string{render.LabelStack: "app"}}},
+		StaleConfigs: []swarm.Config{
+			{Name: "old1", Labels: map[string]string{render.LabelStack: "app", render.LabelPartition: "blue"}},
+			{Name: "old2", Labels: map[string]string{render.LabelStack: "app", render.LabelPartition: "blue"}},
+		},
+		DriftNetworks: []apply.NetworkDrift{{Name: "demo_internal", Diffs: []string{"internal", "attachable"}}, {Name: "demo_edge"}},
+		NetworkConflicts: []apply.NetworkConflict{
+			{Name: "demo_app", Subnet: "10.20.0.0/24", Existing: "legacy", ExistingSubnet: "10.20.0.0/16"},
+			{Name: "demo_app", Subnet: "10.20.0.0/24", Existing: "other", ExistingSubnet: "10.20.0.0/23"},
+		},
+	}
+
+	m := newStatusMetrics()
+	m.observeRefresh("prod", cfg, report, 2, nil)
+	m.observeReconcile("prod", nil)
+	m.observeRefresh("prod", nil, apply.StatusReport{}, 0, errors.New("boom"))
+
+	var out strings.Builder
+	if err := m.registry.WriteText(&out); err != nil {
+		t.Fatalf("write metrics: %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		`swarmcp_service_replicas_desired{project="demo",deployment="prod",stack="app",partition="",service="web"} 3`,
+		`swarmcp_service_replicas_running{project="demo",deployment="prod",stack="app",partition="",service="web"} 2`,
+		`swarmcp_service_healthy{project="demo",deployment="prod",stack="app",partition="",service="web"} 0`,
+		`swarmcp_service_healthy{project="demo",deployment="prod",stack="app",partition="blue",service="api"} 1`,
+		`swarmcp_service_drift{project="demo",deployment="prod",stack="app",partition="",service="web"} 2`,
+		`swarmcp_service_missing{project="demo",deployment="prod",stack="app",partition="",service="worker"} 1`,
+		`swarmcp_resources_missing{project="demo",deployment="prod",stack="app",partition="",kind="secret"} 1`,
+		`swarmcp_resources_stale{project="demo",deployment="prod",stack="app",partition="blue",kind="config"} 2`,
+		`swarmcp_resources_drift{project="demo",deployment="prod",stack="",partition="",kind="network"} 2`,
+		`swarmcp_network_drift{project="demo",deployment="prod",network="demo_internal"} 2`,
+		`swarmcp_network_drift{project="demo",deployment="prod",network="demo_edge"} 1`,
+		`swarmcp_network_conflicts{project="demo",deployment="prod",network="demo_app"} 2`,
+		`swarmcp_missing_secret_values{project="demo",deployment="prod"} 2`,
+		`swarmcp_refresh_success{project="demo",deployment="prod"} 0`,
+		`swarmcp_reconcile_success{project="demo",deployment="prod"} 1`,
+		"swarmcp_last_refresh_success_timestamp_seconds{",
+		"swarmcp_last_reconcile_success_timestamp_seconds{",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, `swarmcp_service_replicas_desired{project="demo",deployment="prod",stack="app",partition="",service="worker"}`) {
+		t.Fatalf("unexpected replica gauges for missing service:\n%s", text)
+	}
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/notify"
+	"github.com/cmmoran/swarmcp/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// projectNotifier returns the notifier for project.notifications, or nil
+// when none are declared or --no-notify is set.
+func projectNotifier(cmd *cobra.Command, projectCtx *cmdutil.ProjectContext) (*notify.Notifier, error) {
+	if opts.NoNotify || len(projectCtx.Config.Project.Notifications) == 0 {
+		return nil, nil
+	}
+	sinks, err := apply.ResolveNotificationSinks(projectCtx.Config, projectCtx.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	return notify.New(sinks, cmd.ErrOrStderr()), nil
+}
+
+// planFileNotifier returns the notifier for the sinks a plan artifact names.
+// The sinks are rebuilt from projectCtx, the project config loaded for the
+// apply; artifacts never supply targets or commands. Without a project config
+// the apply runs without notifications.
+func planFileNotifier(cmd *cobra.Command, planFile apply.PlanFile, projectCtx *cmdutil.ProjectContext) (*notify.Notifier, error) {
+	if opts.NoNotify || len(planFile.NotificationSources) == 0 {
+		return nil, nil
+	}
+	if projectCtx == nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: notifications skipped: no project config loaded for project %q\n", planFile.Project)
+		return nil, nil
+	}
+	sinks, missing, err := apply.ResolvePlanNotificationSinks(projectCtx.Config, projectCtx.Secrets, planFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range missing {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: notification %q is no longer declared in project.notifications\n", name)
+	}
+	return notify.New(sinks, cmd.ErrOrStderr()), nil
+}
+
+// planProjectContext loads the project config for the deployment of a plan
+// artifact so its notification sinks can be rebuilt. Load failures and a
+// config of another project are reported as warnings and yield nil.
+func planProjectContext(cmd *cobra.Command, planFile apply.PlanFile) *cmdutil.ProjectContext {
+	if opts.NoNotify || len(planFile.NotificationSources) == 0 {
+		return nil
+	}
+	targets, err := prepareRuntimeTargets()
+	var projectCtx *cmdutil.ProjectContext
+	if err == nil {
+		projectCtx, err = loadValidatedProjectContext(targets, planFile.Deployment, runtimeTargetOptions{includeSecrets: true})
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: notifications skipped: load project config: %v\n", err)
+		return nil
+	}
+	if projectCtx.Config.Project.Name != planFile.Project {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "warning: notifications skipped: project config is for %q, plan is for %q\n", projectCtx.Config.Project.Name, planFile.Project)
+		return nil
+	}
+	return projectCtx
+}
+
+func notifyEvent(command, project, deployment, contextName string, partitions, stacks []string, summary state.PlanSummary) notify.Event {
+	return notify.Event{
+		Command:    command,
+		Project:    project,
+		Deployment: deployment,
+		Context:    contextName,
+		Partitions: partitions,
+		Stacks:     stacks,
+		Summary:    summary,
+	}
+}
+
+// notifyStackResults sends stack_deployed or stack_failed per deployed
+// stack; failed stacks carry their deploy output. It returns the number of
+// services the deploy removed.
+func notifyStackResults(notifier *notify.Notifier, event notify.Event, results []apply.StackDeployResult) int {
+	servicesRemoved := 0
+	for _, result := range results {
+		servicesRemoved += result.Count(apply.ServiceActionRemove)
+		stackEvent := event.As(notify.EventStackDeployed)
+		stackEvent.Stack = result.Name
+		if result.Error != "" {
+			stackEvent = stackEvent.As(notify.EventStackFailed)
+			stackEvent.Error = result.Error
+			stackEvent.Output = result.Output()
+		}
+		notifier.Send(stackEvent)
+	}
+	return servicesRemoved
+}
+
+// notifyApplyResult sends the stack events of an apply, then apply_failed
+// for err, or prune_performed when the apply removed resources or services
+// followed by apply_completed.
+func notifyApplyResult(notifier *notify.Notifier, event notify.Event, results []apply.StackDeployResult, releaseID string, err error) {
+	servicesRemoved := notifyStackResults(notifier, event, results)
+	if err != nil {
+		failed := event.As(notify.EventApplyFailed)
+		failed.Error = err.Error()
+		notifier.Send(failed)
+		return
+	}
+	event.Release = releaseID
+	if servicesRemoved > 0 || event.Summary.ConfigsRemoved > 0 || event.Summary.SecretsRemoved > 0 || event.Summary.NetworksRemoved > 0 {
+		notifier.Send(event.As(notify.EventPrunePerformed))
+	}
+	notifier.Send(event.As(notify.EventApplyCompleted))
+}
+
+func selectorValues(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/notify"
+)
+
+func TestSavedPlanApplySendsNotifications(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []notify.Event
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event notify.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	project := strings.Replace(testAPIProject, "  deployment: prod\n", "  deployment: prod\n  notifications:\n    ci:\n      type: webhook\n      url: "+hook.URL+"\n      events: [apply_started, stack_deployed, stack_failed, apply_completed]\n", 1)
+	server, _, _ := newTestAPIServerWithProject(t, project)
+
+	code, planBody := apiRequest(t, server, http.MethodGet, "/v1/plan", nil)
+	if code != http.StatusOK || !strings.Contains(string(planBody), `"notification_sources"`) {
+		t.Fatalf("unexpected plan %d: %s", code, planBody)
+	}
+	code, body := apiRequest(t, server, http.MethodPost, "/v1/apply", strings.NewReader(string(planBody)))
+	if code != http.StatusOK {
+		t.Fatalf("apply failed %d: %s", code, body)
+	}
+	var applied apiApplyResponse
+	if err := json.Unmarshal(body, &applied); err != nil {
+		t.Fatalf("decode apply: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var kinds []string
+	for _, event := range events {
+		kinds = append(kinds, event.Event)
+	}
+	if got := strings.Join(kinds, ","); got != "apply_started,stack_deployed,apply_completed" {
+		t.Fatalf("unexpected events %s", got)
+	}
+	if events[1].Stack != "demo_app" || applied.Release == "" || events[2].Release != applied.Release || events[2].Project != "demo" || events[2].Deployment != "prod" || events[2].Summary.StacksDeployed != 1 {
+		t.Fatalf("unexpected event payloads %#v", events)
+	}
+}
+
+func TestSavedPlanApplyIgnoresSinksFromTheArtifact(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits int
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	rogue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("plan artifact URL was called")
+	}))
+	defer rogue.Close()
+
+	project := strings.Replace(testAPIProject, "  deployment: prod\n", "  deployment: prod\n  notifications:\n    ci:\n      type: webhook\n      url: "+hook.URL+"\n      events: [apply_completed]\n", 1)
+	server, _, _ := newTestAPIServerWithProject(t, project)
+	code, planBody := apiRequest(t, server, http.MethodGet, "/v1/plan", nil)
+	if code != http.StatusOK {
+		t.Fatalf("plan failed %d: %s", code, planBody)
+	}
+	var plan map[string]any
+	if err := json.Unmarshal(planBody, &plan); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+	marker := filepath.Join(t.TempDir(), "pwned")
+	plan["notification_sources"] = []any{
+		map[string]any{"name": "ci", "notification": map[string]any{"type": "webhook", "url": rogue.URL}},
+		map[string]any{"name": "evil", "notification": map[string]any{"type": "command", "command": []string{"touch", marker}}},
+	}
+	tampered, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("encode plan: %v", err)
+	}
+	code, body := apiRequest(t, server, http.MethodPost, "/v1/apply", strings.NewReader(string(tampered)))
+	if code != http.StatusOK {
+		t.Fatalf("apply failed %d: %s", code, body)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("expected the artifact's command sink not to run, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 1 {
+		t.Fatalf("expected the configured sink to receive apply_completed once, got %d", hits)
+	}
+}
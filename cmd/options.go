@@ -18,12 +18,18 @@ type Options struct {
 	Debug           bool
 	Prune           bool
 	PruneServices   bool
+	PruneNetworks   bool
 	Preserve        int
 	Serial          bool
+	NoRollback      bool
 	NoUI            bool
 	Output          string
 	Confirm         bool
 	Offline         bool
 	PruneAutoLabels bool
 	DiffSources     bool
+	Snapshot        string
+	ResolveDigests  bool
+	SkipImageCheck  bool
+	NoNotify        bool
 }
@@ -10,6 +10,8 @@ import (
 
 	"github.com/cmmoran/swarmcp/internal/apply"
 	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/notify"
 	"github.com/cmmoran/swarmcp/internal/render"
 	"github.com/cmmoran/swarmcp/internal/state"
 	"github.com/cmmoran/swarmcp/internal/swarm"
@@ -79,6 +81,7 @@ var planCmd = &cobra.Command{
 			}
 			warnings = filterInferredRefWarnings(warnings)
 			warnings = append(warnings, cmdutil.VolumePlacementWarnings(cfg, partitionFilters, stackFilters, opts.Debug)...)
+			warnings = append(warnings, cmdutil.PlacementWarnings(cfg, partitionFilters, stackFilters)...)
 
 			done = progress.start("render desired configs/secrets")
 			summary, err := render.RenderProject(cfg, projectCtx.Secrets, projectCtx.Values, partitionFilters, stackFilters, opts.AllowMissing, !opts.NoInfer)
@@ -121,8 +124,26 @@ var planCmd = &cobra.Command{
 				warnings = append(warnings, cmdutil.NodeLabelWarnings(cfg, nodeSpecs, nodes)...)
 			}
 
-			done = progress.start("compute desired plan")
 			desired := apply.DesiredStateFromSummary(cfg, summary, partitionFilters, stackFilters)
+			if opts.ResolveDigests || cfg.RequiresImageDigests() {
+				done = progress.start("resolve image digests")
+				err := resolveImageDigests(context.Background(), projectCtx, &desired, partitionFilters, stackFilters)
+				done(err)
+				if err != nil {
+					return err
+				}
+			}
+			if !opts.Offline {
+				done = progress.start("check images")
+				imageWarnings, err := checkServiceImages(context.Background(), projectCtx, desired, partitionFilters, stackFilters)
+				done(err)
+				if err != nil {
+					return err
+				}
+				warnings = append(warnings, imageWarnings...)
+			}
+
+			done = progress.start("compute desired plan")
 			statePath, err := planStatePath(targets.configPath)
 			done(err)
 			if err != nil {
@@ -181,52 +202,25 @@ var planCmd = &cobra.Command{
 			}
 			if planOutPath != "" {
 				done = progress.start("write plan artifact")
-				plan, pruneServices, err := buildApplyPlanArtifact(cmd, projectCtx, cfg, desired, partitionFilters, stackFilters, opts)
-				if err != nil {
-					done(err)
-					return err
-				}
-				secretSources := apply.SecretSourcesForPlan(desired, plan)
-				planFile := apply.NewPlanFile(
-					Version,
-					cfg.Project.Name,
-					cfg.Project.Deployment,
-					partitionState,
-					stackState,
-					projectCtx.ContextName,
-					pruneServices,
-					plan,
-				)
-				planFile.SecretSources = secretSources
-				inputs, err := buildPlanInputs(cfg, targets.configPath, targets.configPaths, targets.releaseConfigPaths, projectCtx.ValuesSources, opts.SecretsFile)
-				if err != nil {
-					done(err)
-					return err
-				}
-				planFile.Inputs = inputs
-				planWarnings := planArtifactWarnings(inputs)
-				planFile.Warnings = planWarnings
-				warnings = append(warnings, planWarnings...)
-				sourceInputs, err := buildPlanSourceInputs(cfg, desired, plan, projectCtx.ValuesSources)
+				planFile, err := buildPlanFile(cmd.Context(), cmd, targets, projectCtx, desired, partitionFilters, stackFilters, opts, planIncludeSecretPayloads)
 				if err != nil {
 					done(err)
 					return err
 				}
-				planFile.SourceInputs = sourceInputs
-				if !planIncludeSecretPayloads {
-					apply.OmitReplayableSecretPayloadsFromPlan(cmd.Context(), &planFile)
-				}
-				if apply.PlanHasSecretPayloads(planFile.Plan) && !planIncludeSecretPayloads {
-					done(fmt.Errorf("plan artifact would contain unreplayable secret payloads"))
-					return fmt.Errorf("plan --out needs --include-secret-payloads when the plan creates swarm secrets that cannot be replayed from a single versioned secret source or pinned recipe")
-				}
-				apply.SetPlanSecretMode(&planFile)
+				warnings = append(warnings, planFile.Warnings...)
 				if err := apply.WritePlanFile(planOutPath, planFile); err != nil {
 					done(err)
 					return err
 				}
 				done(nil)
 				_, _ = fmt.Fprintf(out, "plan artifact: %s\n", planOutPath)
+				notifier, err := projectNotifier(cmd, projectCtx)
+				if err != nil {
+					return err
+				}
+				planSummary := buildPlanSummary(planFile.Plan)
+				planSummary.StackNames, planSummary.ServicesCreated, planSummary.ServicesUpdated = planDeploySummary(planFile.Plan.StackDeploys)
+				notifier.Send(notifyEvent("plan", cfg.Project.Name, cfg.Project.Deployment, projectCtx.ContextName, partitionFilters, stackFilters, planSummary).As(notify.EventPlanComputed))
 			}
 			cmdutil.PrintWarnings(out, warnings)
 			_, _ = fmt.Fprintf(out, "plan OK (dry-run)\nconfigs rendered: %d\nsecrets rendered: %d\n", summary.Configs, summary.Secrets)
@@ -257,6 +251,7 @@ var planCmd = &cobra.Command{
 					_, _ = fmt.Fprintf(out, "  - %s (%s)\n", status.Name, status.Status)
 				}
 			}
+			printImageDigests(out, desired.ImageDigests)
 			if len(summary.Mounts) > 0 {
 				_, _ = fmt.Fprintln(out, "service mounts:")
 				printGroupedRenderedItems(out, summary.Mounts, splitMountItem)
@@ -284,13 +279,33 @@ var planCmd = &cobra.Command{
 					_, _ = fmt.Fprintf(out, "  - %s\n", line)
 				}
 			}
-			if debugDefsEnabled {
-				done = progress.start("build stack compose output")
-				stackDeploys, err := apply.BuildStackDeploys(cfg, desired, projectCtx.Values, partitionFilters, stackFilters, nil, nil, nil, !opts.NoInfer)
-				done(err)
-				if err != nil {
-					return err
+			done = progress.start("plan named volumes")
+			namedVolumes, err := apply.PlanNamedVolumes(cfg, projectCtx.Values, partitionFilters, stackFilters)
+			done(err)
+			if err != nil {
+				return err
+			}
+			if len(namedVolumes) > 0 {
+				_, _ = fmt.Fprintln(out, "named volumes:")
+				for _, item := range namedVolumes {
+					_, _ = fmt.Fprintf(out, "  - %s\n", apply.FormatNamedVolumeLine(item))
 				}
+			}
+			done = progress.start("build stack deploys")
+			stackDeploys, err := apply.BuildStackDeploys(cfg, desired, projectCtx.Values, partitionFilters, stackFilters, nil, nil, nil, !opts.NoInfer)
+			if err != nil {
+				done(err)
+				return err
+			}
+			rollout, err := apply.BuildRollout(stackDeploys)
+			done(err)
+			if err != nil {
+				return err
+			}
+			printRolloutBatches(out, rollout)
+			printJobSteps(out, stackDeploys)
+			printCanaryRollouts(out, stackDeploys)
+			if debugDefsEnabled {
 				if len(stackDeploys) > 0 {
 					_, _ = fmt.Fprintln(out, "stacks:")
 					for _, deploy := range stackDeploys {
@@ -406,6 +421,71 @@ type renderedGroup struct {
 	items []string
 }
 
+// printRolloutBatches lists the rollout order when depends_on splits the
+// deploy into more than one batch.
+func printRolloutBatches(out io.Writer, batches []apply.RolloutBatch) {
+	if len(batches) < 2 {
+		return
+	}
+	_, _ = fmt.Fprintln(out, "rollout batches:")
+	for i, batch := range batches {
+		_, _ = fmt.Fprintf(out, "  %d. %s\n", i+1, strings.Join(batch.Names(), ", "))
+	}
+}
+
+// printJobSteps lists lifecycle jobs in execution order per service. Job env
+// values are left out since they may carry secrets.
+func printJobSteps(out io.Writer, stacks []apply.StackDeploy) {
+	var lines []string
+	for _, deploy := range stacks {
+		keys := make([]string, 0, len(deploy.Jobs))
+		for key := range deploy.Jobs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, step := range deploy.Jobs[key] {
+				command := strings.Join(append(append([]string(nil), step.Command...), step.Args...), " ")
+				lines = append(lines, fmt.Sprintf("  - %s_%s %s/%s: %s (cleanup=%s)", deploy.Name, key, step.Phase, step.Name, command, step.Cleanup))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(out, "jobs:")
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(out, line)
+	}
+}
+
+// printCanaryRollouts lists the services whose updates go through a canary.
+func printCanaryRollouts(out io.Writer, stacks []apply.StackDeploy) {
+	var lines []string
+	for _, deploy := range stacks {
+		keys := make([]string, 0, len(deploy.Canaries))
+		for key := range deploy.Canaries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			policy := deploy.Canaries[key]
+			window := policy.Window
+			if window == "" {
+				window = config.DefaultCanaryWindow.String()
+			}
+			lines = append(lines, fmt.Sprintf("  - %s_%s: replicas=%d window=%s", deploy.Name, key, policy.Replicas, window))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(out, "canary rollouts:")
+	for _, line := range lines {
+		_, _ = fmt.Fprintln(out, line)
+	}
+}
+
 func printGroupedRenderedItems(out io.Writer, items []string, split func(string) (string, string)) {
 	groups := groupRenderedItems(items, split)
 	for _, group := range groups {
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/cmmoran/swarmcp/internal/apply"
 	"github.com/cmmoran/swarmcp/internal/cmdutil"
@@ -29,6 +30,9 @@ func buildApplyPlanArtifact(cmd *cobra.Command, projectCtx *cmdutil.ProjectConte
 		plan.DeleteConfigs = nil
 		plan.DeleteSecrets = nil
 	}
+	if !opts.Prune && !opts.PruneNetworks {
+		plan.DeleteNetworks = nil
+	}
 
 	pruneServices := opts.Prune || opts.PruneServices
 	if pruneServices {
@@ -56,3 +60,55 @@ func buildApplyPlanArtifact(cmd *cobra.Command, projectCtx *cmdutil.ProjectConte
 
 	return plan, pruneServices, nil
 }
+
+// buildPlanFile assembles the applyable plan artifact for one deployment
+// target: the plan itself plus secret, registry and notification replay
+// sources, lock scope, input fingerprints and source commits. Replayable secret payloads are
+// omitted unless includeSecretPayloads is set; unreplayable ones require it.
+func buildPlanFile(ctx context.Context, cmd *cobra.Command, targets *runtimeTargets, projectCtx *cmdutil.ProjectContext, desired apply.DesiredState, partitionFilters []string, stackFilters []string, opts Options, includeSecretPayloads bool) (apply.PlanFile, error) {
+	cfg := projectCtx.Config
+	plan, pruneServices, err := buildApplyPlanArtifact(cmd, projectCtx, cfg, desired, partitionFilters, stackFilters, opts)
+	if err != nil {
+		return apply.PlanFile{}, err
+	}
+	secretSources := apply.SecretSourcesForPlan(desired, plan)
+	planFile := apply.NewPlanFile(
+		Version,
+		cfg.Project.Name,
+		cfg.Project.Deployment,
+		singleSelectorValue(partitionFilters),
+		singleSelectorValue(stackFilters),
+		projectCtx.ContextName,
+		pruneServices,
+		plan,
+	)
+	planFile.SecretSources = secretSources
+	_, registrySources, err := apply.ResolveRegistryCredentials(cfg, projectCtx.Secrets)
+	if err != nil {
+		return apply.PlanFile{}, err
+	}
+	planFile.RegistrySources = registrySources
+	planFile.NotificationSources = apply.PlanNotificationSources(cfg)
+	releaseHistory := config.ReleaseHistory(cfg)
+	planFile.ReleaseHistory = &releaseHistory
+	planFile.LockPartition = applyLockScope(cfg, partitionFilters, stackFilters, opts.Prune || opts.PruneNetworks).Partition
+	inputs, err := buildPlanInputs(cfg, targets.configPath, targets.configPaths, targets.releaseConfigPaths, projectCtx.ValuesSources, opts.SecretsFile)
+	if err != nil {
+		return apply.PlanFile{}, err
+	}
+	planFile.Inputs = inputs
+	planFile.Warnings = planArtifactWarnings(inputs)
+	sourceInputs, err := buildPlanSourceInputs(cfg, desired, plan, projectCtx.ValuesSources)
+	if err != nil {
+		return apply.PlanFile{}, err
+	}
+	planFile.SourceInputs = sourceInputs
+	if !includeSecretPayloads {
+		apply.OmitReplayableSecretPayloadsFromPlan(ctx, &planFile)
+	}
+	if apply.PlanHasSecretPayloads(planFile.Plan) && !includeSecretPayloads {
+		return apply.PlanFile{}, fmt.Errorf("plan --out needs --include-secret-payloads when the plan creates swarm secrets that cannot be replayed from a single versioned secret source or pinned recipe")
+	}
+	apply.SetPlanSecretMode(&planFile)
+	return planFile, nil
+}
@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/notify"
+	"github.com/cmmoran/swarmcp/internal/state"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reconcileInterval   time.Duration
+	reconcileJitter     time.Duration
+	reconcileMaxBackoff time.Duration
+	reconcileReportOnly bool
+	reconcileOnce       bool
+)
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Continuously refresh sources and apply desired state to Swarm",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !reconcileReportOnly {
+			if err := requireLiveCluster("reconcile"); err != nil {
+				return err
+			}
+		}
+		if reconcileInterval <= 0 {
+			return fmt.Errorf("--interval must be greater than zero")
+		}
+		if reconcileJitter < 0 || reconcileMaxBackoff < 0 {
+			return fmt.Errorf("--jitter and --max-backoff must not be negative")
+		}
+		outputMode := strings.TrimSpace(opts.Output)
+		if err := apply.ValidateDeployOutputMode(outputMode); err != nil {
+			return err
+		}
+		targets, err := prepareRuntimeTargets()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		// A second signal terminates immediately instead of waiting for the deploy.
+		context.AfterFunc(ctx, stop)
+
+		r := &reconciler{cmd: cmd, targets: targets, outputMode: outputMode, commits: make(map[string]map[string]string)}
+		cycle := r.cycle
+		if metricsAddr != "" {
+			r.metrics = newStatusMetrics()
+			server, err := startMetricsServer(cmd, metricsAddr, r.metrics.registry)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = server.Shutdown(shutdownCtx)
+			}()
+			cycle = func(ctx context.Context, deployment string) error {
+				err := r.cycle(ctx, deployment)
+				r.metrics.observeReconcile(deployment, err)
+				return err
+			}
+		}
+		loop := reconcileLoop{
+			interval:   reconcileInterval,
+			jitter:     reconcileJitter,
+			maxBackoff: reconcileMaxBackoff,
+			once:       reconcileOnce,
+			out:        cmd.OutOrStdout(),
+			cycle:      cycle,
+			now:        time.Now,
+			wait:       waitReconcile,
+		}
+		return loop.run(ctx, targets.deployments)
+	},
+}
+
+// reconcileLoop schedules reconcile cycles per deployment target. A target
+// that fails backs off exponentially without delaying the other targets.
+type reconcileLoop struct {
+	interval   time.Duration
+	jitter     time.Duration
+	maxBackoff time.Duration
+	once       bool
+	out        io.Writer
+	cycle      func(ctx context.Context, deployment string) error
+	now        func() time.Time
+	wait       func(ctx context.Context, d time.Duration) bool
+}
+
+func (l reconcileLoop) run(ctx context.Context, deployments []string) error {
+	next := make(map[string]time.Time, len(deployments))
+	failures := make(map[string]int, len(deployments))
+	for {
+		var failed []string
+		for _, deployment := range deployments {
+			if ctx.Err() != nil {
+				_, _ = fmt.Fprintln(l.out, "reconcile stopped")
+				return nil
+			}
+			if l.now().Before(next[deployment]) {
+				continue
+			}
+			label := reconcileLabel(deployment)
+			if err := l.cycle(ctx, deployment); err != nil {
+				if ctx.Err() != nil {
+					_, _ = fmt.Fprintln(l.out, "reconcile stopped")
+					return nil
+				}
+				failures[deployment]++
+				delay := reconcileBackoff(l.interval, l.maxBackoff, failures[deployment]) + l.jitterDelay()
+				next[deployment] = l.now().Add(delay)
+				failed = append(failed, label)
+				_, _ = fmt.Fprintf(l.out, "%s: reconcile failed (attempt %d, retry in %s): %v\n", label, failures[deployment], delay.Round(time.Second), err)
+				continue
+			}
+			failures[deployment] = 0
+			next[deployment] = l.now().Add(l.interval + l.jitterDelay())
+		}
+		if l.once {
+			if len(failed) > 0 {
+				return fmt.Errorf("reconcile failed for %s", strings.Join(failed, ", "))
+			}
+			return nil
+		}
+		var wake time.Time
+		for _, deployment := range deployments {
+			if wake.IsZero() || next[deployment].Before(wake) {
+				wake = next[deployment]
+			}
+		}
+		if !l.wait(ctx, wake.Sub(l.now())) {
+			_, _ = fmt.Fprintln(l.out, "reconcile stopped")
+			return nil
+		}
+	}
+}
+
+func (l reconcileLoop) jitterDelay() time.Duration {
+	if l.jitter <= 0 {
+		return 0
+	}
+	return rand.N(l.jitter)
+}
+
+// reconcileBackoff doubles the interval for every consecutive failure after
+// the first, capped at maxBackoff (never below the interval itself).
+func reconcileBackoff(interval, maxBackoff time.Duration, failures int) time.Duration {
+	limit := max(maxBackoff, interval)
+	delay := interval
+	for i := 1; i < failures && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+func waitReconcile(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func reconcileLabel(deployment string) string {
+	if deployment == "" {
+		return "(default)"
+	}
+	return deployment
+}
+
+type reconciler struct {
+	cmd        *cobra.Command
+	targets    *runtimeTargets
+	outputMode string
+	// commits holds the last observed commit per git source, by deployment.
+	commits map[string]map[string]string
+	metrics *statusMetrics
+}
+
+// cycle refreshes sources, renders and plans one deployment target, and
+// applies the plan unless it is in sync, report-only, or shutdown has been
+// requested. Once a deploy starts it runs to completion regardless of ctx.
+// With metrics enabled, a successful cycle ends with a status refresh.
+func (r *reconciler) cycle(ctx context.Context, deployment string) (err error) {
+	out := r.cmd.OutOrStdout()
+	label := reconcileLabel(deployment)
+	if !opts.Offline {
+		config.ForgetResolvedRefs()
+	}
+	projectCtx, err := loadValidatedProjectContext(r.targets, deployment, runtimeTargetOptions{includeValues: true, includeSecrets: true})
+	if err != nil {
+		return err
+	}
+	cfg := projectCtx.Config
+	if !opts.Offline {
+		r.reportSourceChanges(out, label, deployment, cfg)
+	}
+	partitionFilters := cmdutil.FilterDeploymentPartitions(cfg, r.targets.partitionFilters)
+	stackFilters := r.targets.stackFilters
+	desired, err := apply.BuildDesiredState(cfg, projectCtx.Secrets, projectCtx.Values, partitionFilters, stackFilters, opts.AllowMissing, !opts.NoInfer)
+	if err != nil {
+		return err
+	}
+	if err := resolveImageDigests(ctx, projectCtx, &desired, partitionFilters, stackFilters); err != nil {
+		return err
+	}
+	client, err := projectCtx.SwarmClient()
+	if err != nil {
+		return err
+	}
+	if r.metrics != nil {
+		defer func() {
+			if err == nil {
+				r.refreshMetrics(label, deployment, projectCtx, desired, partitionFilters, stackFilters, client)
+			}
+		}()
+	}
+	plan, err := apply.BuildPlan(ctx, client, cfg, desired, projectCtx.Values, partitionFilters, stackFilters, !opts.NoInfer)
+	if err != nil {
+		return err
+	}
+	if !reconcilePlanPending(plan) {
+		_, _ = fmt.Fprintf(out, "%s: in sync\n", label)
+		return nil
+	}
+	stackNames, _, _ := planDeploySummary(plan.StackDeploys)
+	_, _ = fmt.Fprintf(out, "%s: changes pending: networks %d, configs %d, secrets %d, stacks %d (%s)\n", label, len(plan.CreateNetworks), len(plan.CreateConfigs), len(plan.CreateSecrets), len(plan.StackDeploys), strings.Join(stackNames, ", "))
+	notifier, err := projectNotifier(r.cmd, projectCtx)
+	if err != nil {
+		return err
+	}
+	event := notifyEvent("reconcile", cfg.Project.Name, cfg.Project.Deployment, projectCtx.ContextName, partitionFilters, stackFilters, reconcileSummary(plan))
+	notifier.Send(event.As(notify.EventPlanComputed))
+	if reconcileReportOnly {
+		_, _ = fmt.Fprintf(out, "%s: report only, not applied\n", label)
+		return nil
+	}
+	if ctx.Err() != nil {
+		_, _ = fmt.Fprintf(out, "%s: shutdown requested, not applied\n", label)
+		return nil
+	}
+
+	// From here on the deploy is never interrupted: the lock, the re-plan
+	// and the apply all run on a background context.
+	applyCtx := context.Background()
+	lease, err := acquireApplyLock(applyCtx, r.cmd, client, applyLockScope(cfg, partitionFilters, stackFilters, false), applyLockTimeout, applyLockTTL)
+	if err != nil {
+		return err
+	}
+	defer releaseApplyLock(r.cmd, lease)
+	plan, err = apply.BuildPlan(applyCtx, client, cfg, desired, projectCtx.Values, partitionFilters, stackFilters, !opts.NoInfer)
+	if err != nil {
+		return err
+	}
+	if !reconcilePlanPending(plan) {
+		_, _ = fmt.Fprintf(out, "%s: in sync\n", label)
+		return nil
+	}
+	stackParallel := 0
+	if opts.Serial {
+		stackParallel = 1
+	}
+	summary := reconcileSummary(plan)
+	event.Summary = summary
+	notifier.Send(event.As(notify.EventApplyStarted))
+	results, err := apply.Apply(applyCtx, client, plan, lease, false, !opts.NoRollback, stackParallel, true, r.outputMode, true)
+	if err != nil {
+		notifyApplyResult(notifier, event, results, "", err)
+		return err
+	}
+	releaseID := recordRelease(r.cmd, client, cfg.Project.Name, config.ReleaseHistory(cfg), plan, summary, func(release *apply.Release) {
+		release.Deployment = cfg.Project.Deployment
+		release.Context = projectCtx.ContextName
+		release.Partitions = partitionFilters
+		release.StackFilters = stackFilters
+		if inputs, err := buildPlanInputs(cfg, r.targets.configPath, r.targets.configPaths, r.targets.releaseConfigPaths, projectCtx.ValuesSources, opts.SecretsFile); err == nil {
+			release.Inputs = inputs
+		}
+		if sources, err := buildPlanSourceInputs(cfg, desired, plan, projectCtx.ValuesSources); err == nil {
+			release.SourceInputs = sources
+		}
+	})
+	notifyApplyResult(notifier, event, results, releaseID, nil)
+	_, _ = fmt.Fprintf(out, "%s: apply OK\n", label)
+	return nil
+}
+
+func reconcileSummary(plan apply.Plan) state.PlanSummary {
+	summary := buildPlanSummary(plan)
+	summary.StackNames, summary.ServicesCreated, summary.ServicesUpdated = planDeploySummary(plan.StackDeploys)
+	return summary
+}
+
+func (r *reconciler) refreshMetrics(label, deployment string, projectCtx *cmdutil.ProjectContext, desired apply.DesiredState, partitionFilters, stackFilters []string, client swarm.Client) {
+	report, err := buildTargetStatus(context.Background(), r.cmd, projectCtx, desired, partitionFilters, stackFilters, client)
+	if err != nil {
+		_, _ = fmt.Fprintf(r.cmd.ErrOrStderr(), "%s: status refresh failed: %v\n", label, err)
+	}
+	r.metrics.observeRefresh(deployment, projectCtx.Config, report, len(desired.Missing), err)
+}
+
+// reportSourceChanges prints the git sources of the target whose commit moved
+// since the previous cycle. It reports the commits the cycle's project load
+// resolved and never fetches a source itself.
+func (r *reconciler) reportSourceChanges(out io.Writer, label, deployment string, cfg *config.Config) {
+	seen, ok := r.commits[deployment]
+	if !ok {
+		seen = make(map[string]string)
+		r.commits[deployment] = seen
+	}
+	for _, entry := range gatherSourceEntries(cfg) {
+		if entry.Kind != "git" {
+			continue
+		}
+		commit, ok := config.ResolvedSourceRef(entry.URL, entry.Ref)
+		if !ok {
+			continue
+		}
+		if previous, known := seen[entry.Key]; known && previous != commit {
+			_, _ = fmt.Fprintf(out, "%s: source %s commit %s -> %s\n", label, entry.Key, previous, commit)
+		}
+		seen[entry.Key] = commit
+	}
+}
+
+// reconcilePlanPending reports whether the plan creates or deploys anything.
+// Reconcile never prunes, so deletions and skipped deletions are ignored.
+func reconcilePlanPending(plan apply.Plan) bool {
+	return len(plan.CreateNetworks) > 0 || len(plan.CreateConfigs) > 0 || len(plan.CreateSecrets) > 0 || len(plan.StackDeploys) > 0
+}
+
+func init() {
+	reconcileCmd.Flags().DurationVar(&reconcileInterval, "interval", 5*time.Minute, "Time between reconcile cycles for each deployment target")
+	reconcileCmd.Flags().DurationVar(&reconcileJitter, "jitter", 30*time.Second, "Random delay of up to this duration added to each interval")
+	reconcileCmd.Flags().DurationVar(&reconcileMaxBackoff, "max-backoff", time.Hour, "Upper bound of the per-target retry delay after consecutive failures")
+	reconcileCmd.Flags().BoolVar(&reconcileReportOnly, "report-only", false, "Report pending changes and drift without applying them")
+	reconcileCmd.Flags().BoolVar(&reconcileOnce, "once", false, "Run a single cycle for every target and exit (non-zero when a target fails)")
+	reconcileCmd.Flags().BoolVar(&opts.Serial, "serial", false, "Deploy services one at a time within each rollout batch")
+	reconcileCmd.Flags().BoolVar(&opts.NoRollback, "no-rollback", false, "Leave services that fail their health check on the new spec")
+	reconcileCmd.Flags().StringVar(&opts.Output, "output", "auto", "Deploy output mode: auto|summary|stack|error-only (auto prints a summary)")
+	reconcileCmd.Flags().DurationVar(&applyLockTimeout, "lock-timeout", 0, "How long to wait for a conflicting apply lock to be released (0 fails immediately)")
+	rootCmd.AddCommand(reconcileCmd)
+}
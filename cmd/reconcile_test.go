@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+)
+
+func TestReconcileBackoff(t *testing.T) {
+	interval := time.Minute
+	for failures, want := range map[int]time.Duration{
+		1: time.Minute,
+		2: 2 * time.Minute,
+		3: 4 * time.Minute,
+		4: 5 * time.Minute,
+		9: 5 * time.Minute,
+	} {
+		if got := reconcileBackoff(interval, 5*time.Minute, failures); got != want {
+			t.Fatalf("failures=%d: expected %s, got %s", failures, want, got)
+		}
+	}
+	if got := reconcileBackoff(interval, 0, 3); got != interval {
+		t.Fatalf("expected backoff capped at the interval, got %s", got)
+	}
+}
+
+func TestReconcileLoopBacksOffFailingTarget(t *testing.T) {
+	clock := time.Unix(0, 0)
+	calls := map[string][]time.Duration{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var out bytes.Buffer
+	loop := reconcileLoop{
+		interval:   time.Minute,
+		maxBackoff: 4 * time.Minute,
+		out:        &out,
+		cycle: func(ctx context.Context, deployment string) error {
+			calls[deployment] = append(calls[deployment], clock.Sub(time.Unix(0, 0)))
+			if deployment == "prod" {
+				return fmt.Errorf("registry down")
+			}
+			return nil
+		},
+		now: func() time.Time { return clock },
+		wait: func(ctx context.Context, d time.Duration) bool {
+			clock = clock.Add(d)
+			if clock.Sub(time.Unix(0, 0)) > 10*time.Minute {
+				cancel()
+				return false
+			}
+			return true
+		},
+	}
+	if err := loop.run(ctx, []string{"qa", "prod"}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := len(calls["qa"]); got != 11 {
+		t.Fatalf("expected qa to run every minute, got %v", calls["qa"])
+	}
+	want := []time.Duration{0, time.Minute, 3 * time.Minute, 7 * time.Minute}
+	if fmt.Sprint(calls["prod"]) != fmt.Sprint(want) {
+		t.Fatalf("expected prod retries at %v, got %v", want, calls["prod"])
+	}
+	if !strings.Contains(out.String(), "prod: reconcile failed (attempt 3, retry in 4m0s): registry down") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestReconcileLoopStopsBetweenTargets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var ran []string
+	var out bytes.Buffer
+	loop := reconcileLoop{
+		interval: time.Minute,
+		out:      &out,
+		cycle: func(ctx context.Context, deployment string) error {
+			ran = append(ran, deployment)
+			// The signal arrives mid-deploy; the cycle still completes.
+			cancel()
+			return nil
+		},
+		now:  time.Now,
+		wait: waitReconcile,
+	}
+	if err := loop.run(ctx, []string{"qa", "prod"}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(ran) != 1 || ran[0] != "qa" {
+		t.Fatalf("expected only the in-flight target to run, got %v", ran)
+	}
+	if !strings.Contains(out.String(), "reconcile stopped") {
+		t.Fatalf("expected stop message, got %q", out.String())
+	}
+}
+
+func TestReconcileLoopOnceReportsFailures(t *testing.T) {
+	loop := reconcileLoop{
+		interval: time.Minute,
+		once:     true,
+		out:      &bytes.Buffer{},
+		cycle: func(ctx context.Context, deployment string) error {
+			if deployment == "prod" {
+				return fmt.Errorf("boom")
+			}
+			return nil
+		},
+		now:  time.Now,
+		wait: waitReconcile,
+	}
+	err := loop.run(context.Background(), []string{"qa", "prod"})
+	if err == nil || err.Error() != "reconcile failed for prod" {
+		t.Fatalf("expected failure for prod, got %v", err)
+	}
+}
+
+func TestForgetResolvedRefsPicksUpNewCommits(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	cacheDir := filepath.Join(dir, "cache")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatalf("mkdir repo: %v", err)
+	}
+	runGit(t, repo, "init", ".")
+	runGit(t, repo, "config", "user.name", "Test User")
+	runGit(t, repo, "config", "user.email", "test@example.com")
+	runGit(t, repo, "config", "uploadpack.allowReachableSHA1InWant", "true")
+	runGit(t, repo, "checkout", "-b", "main")
+	commitFile := func(content string) string {
+		if err := os.WriteFile(filepath.Join(repo, "stack.yaml"), []byte(content), 0o600); err != nil {
+			t.Fatalf("write stack: %v", err)
+		}
+		runGit(t, repo, "add", ".")
+		runGit(t, repo, "commit", "-m", content)
+		return runGit(t, repo, "rev-parse", "HEAD")
+	}
+	first := commitFile("mode: shared\n")
+	url := "file://" + filepath.ToSlash(repo)
+	cacheRepo := filepath.Join(cacheDir, "repos", testSourceHashKey(url))
+	if err := os.MkdirAll(filepath.Dir(cacheRepo), 0o755); err != nil {
+		t.Fatalf("mkdir cache repos: %v", err)
+	}
+	runGit(t, dir, "clone", "--bare", repo, cacheRepo)
+	loadOpts := config.LoadOptions{CacheDir: cacheDir}
+
+	for _, ref := range []string{"main", ""} {
+		meta, err := config.FetchGitSource(url, ref, "stack.yaml", loadOpts)
+		if err != nil || meta.Commit != first {
+			t.Fatalf("ref %q: expected commit %s, got %#v, %v", ref, first, meta, err)
+		}
+	}
+	second := commitFile("mode: partitioned\n")
+	for _, ref := range []string{"main", ""} {
+		meta, err := config.FetchGitSource(url, ref, "stack.yaml", loadOpts)
+		if err != nil || meta.Commit != first {
+			t.Fatalf("ref %q: expected cached commit %s before forgetting, got %#v, %v", ref, first, meta, err)
+		}
+	}
+	config.ForgetResolvedRefs()
+	for _, ref := range []string{"main", ""} {
+		meta, err := config.FetchGitSource(url, ref, "stack.yaml", loadOpts)
+		if err != nil || meta.Commit != second {
+			t.Fatalf("ref %q: expected refreshed commit %s, got %#v, %v", ref, second, meta, err)
+		}
+	}
+}
+
+func TestReconcileReportsResolvedSourceCommitsWithoutFetching(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatalf("mkdir repo: %v", err)
+	}
+	runGit(t, repo, "init", ".")
+	runGit(t, repo, "config", "user.name", "Test User")
+	runGit(t, repo, "config", "user.email", "test@example.com")
+	runGit(t, repo, "config", "uploadpack.allowReachableSHA1InWant", "true")
+	runGit(t, repo, "checkout", "-b", "main")
+	commitFile := func(content string) string {
+		if err := os.WriteFile(filepath.Join(repo, "app.conf"), []byte(content), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		runGit(t, repo, "add", ".")
+		runGit(t, repo, "commit", "-m", content)
+		return runGit(t, repo, "rev-parse", "HEAD")
+	}
+	first := commitFile("v1\n")
+	url := "file://" + filepath.ToSlash(repo)
+	loadOpts := config.LoadOptions{CacheDir: filepath.Join(dir, "cache")}
+	cfg := &config.Config{Stacks: map[string]config.Stack{"app": {
+		Configs: config.ConfigDefsOrRefs{Defs: map[string]config.ConfigDef{"app": {Source: "git:" + url + "|main|app.conf"}}},
+	}}}
+	r := &reconciler{commits: make(map[string]map[string]string)}
+	var out bytes.Buffer
+
+	config.ForgetResolvedRefs()
+	if _, err := config.FetchGitSource(url, "main", "app.conf", loadOpts); err != nil {
+		t.Fatalf("FetchGitSource: %v", err)
+	}
+	r.reportSourceChanges(&out, "qa", "qa", cfg)
+	second := commitFile("v2\n")
+
+	// Sources the cycle's load did not read again are not fetched to report.
+	config.ForgetResolvedRefs()
+	r.reportSourceChanges(&out, "qa", "qa", cfg)
+	if out.Len() != 0 {
+		t.Fatalf("expected no report without a resolved source, got %q", out.String())
+	}
+
+	if _, err := config.FetchGitSource(url, "main", "app.conf", loadOpts); err != nil {
+		t.Fatalf("FetchGitSource: %v", err)
+	}
+	r.reportSourceChanges(&out, "qa", "qa", cfg)
+	if want := "commit " + first + " -> " + second; !strings.Contains(out.String(), want) {
+		t.Fatalf("expected %q in report, got %q", want, out.String())
+	}
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"sync"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+)
+
+// registryCredentialSource resolves project.registries for the loaded
+// deployment on first use and caches the result. It returns nil when the
+// project declares no registries, leaving the local Docker config in charge.
+func registryCredentialSource(projectCtx *cmdutil.ProjectContext) swarm.RegistryCredentialSource {
+	if len(projectCtx.Config.Project.Registries) == 0 {
+		return nil
+	}
+	var (
+		once  sync.Once
+		creds swarm.RegistryCredentials
+		err   error
+	)
+	return func() (swarm.RegistryCredentials, error) {
+		once.Do(func() {
+			creds, _, err = apply.ResolveRegistryCredentials(projectCtx.Config, projectCtx.Secrets)
+		})
+		return creds, err
+	}
+}
@@ -57,11 +57,16 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&opts.DebugContent, "debug-content", false, "Print rendered config/secret content")
 	rootCmd.PersistentFlags().IntVar(&opts.DebugContentMax, "debug-content-max", 0, "Max bytes of rendered content to print (0 for unlimited)")
 	rootCmd.PersistentFlags().BoolVar(&opts.Debug, "debug", false, "Enable debug output")
-	rootCmd.PersistentFlags().BoolVar(&opts.Prune, "prune", false, "Remove unused managed configs/secrets and prune removed services")
+	rootCmd.PersistentFlags().BoolVar(&opts.Prune, "prune", false, "Remove unused managed configs/secrets/networks and prune removed services")
 	rootCmd.PersistentFlags().BoolVar(&opts.PruneServices, "prune-services", false, "Prune removed services without touching configs/secrets")
+	rootCmd.PersistentFlags().BoolVar(&opts.PruneNetworks, "prune-networks", false, "Remove managed networks that are no longer used without touching configs/secrets")
 	rootCmd.PersistentFlags().IntVar(&opts.Preserve, "preserve", 0, "Preserve the most recent unused configs/secrets when pruning (0 for none)")
 	rootCmd.PersistentFlags().BoolVar(&opts.Confirm, "confirm", false, "Enable confirmation prompts for prune operations")
 	rootCmd.PersistentFlags().BoolVar(&opts.Offline, "offline", false, "Disable remote fetches; use cached sources only")
+	rootCmd.PersistentFlags().BoolVar(&opts.ResolveDigests, "resolve-digests", false, "Resolve service image tags to registry digests and deploy the pinned references")
+	rootCmd.PersistentFlags().BoolVar(&opts.SkipImageCheck, "skip-image-check", false, "Report registry image problems as warnings instead of failing plan and validate --runtime")
+	rootCmd.PersistentFlags().BoolVar(&opts.NoNotify, "no-notify", false, "Do not send project.notifications events")
+	rootCmd.PersistentFlags().StringVar(&opts.Snapshot, "snapshot", "", "Read cluster state from a snapshot file written by snapshot export instead of the Docker API")
 
 	rootCmd.AddCommand(planCmd)
 	rootCmd.AddCommand(diffCmd)
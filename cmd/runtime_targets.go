@@ -89,6 +89,7 @@ func loadValidatedProjectContext(targets *runtimeTargets, deployment string, loa
 		Context:            opts.Context,
 		Offline:            opts.Offline,
 		Debug:              opts.Debug,
+		ClientFactory:      swarmClientFactory(),
 	}
 	if len(targets.partitionFilters) == 1 {
 		projectOpts.Partition = targets.partitionFilters[0]
@@ -105,6 +106,9 @@ func loadValidatedProjectContext(targets *runtimeTargets, deployment string, loa
 	if err := cmdutil.LoadProjectInputs(projectCtx, configPath, projectOpts, loadOpts.includeValues, loadOpts.includeSecrets); err != nil {
 		return nil, err
 	}
+	if loadOpts.includeSecrets {
+		projectCtx.RegistryCredentials = registryCredentialSource(projectCtx)
+	}
 	for _, partition := range targets.partitionFilters {
 		if !cmdutil.PartitionInProject(cfg, partition) {
 			return nil, fmt.Errorf("partition %q not found in project.partitions", partition)
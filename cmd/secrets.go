@@ -47,6 +47,7 @@ var secretsCheckCmd = &cobra.Command{
 			ValuesFiles:        opts.ValuesFiles,
 			Offline:            opts.Offline,
 			Debug:              opts.Debug,
+			ClientFactory:      swarmClientFactory(),
 		}
 		cfg, _, err := cmdutil.LoadProjectConfig(projectOpts)
 		if err != nil {
@@ -121,6 +122,7 @@ var secretsPutCmd = &cobra.Command{
 			Deployment:         deployment,
 			Offline:            opts.Offline,
 			Debug:              opts.Debug,
+			ClientFactory:      swarmClientFactory(),
 		}
 		cfg, _, err := cmdutil.LoadProjectConfig(projectOpts)
 		if err != nil {
@@ -0,0 +1,584 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/state"
+	"github.com/spf13/cobra"
+)
+
+const (
+	serveTokenEnv   = "SWARMCP_API_TOKEN"
+	servePlanMaxLen = 64 << 20
+)
+
+var (
+	serveAddr      string
+	serveTokenFile string
+	serveApply     savedPlanOptions
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve resolve, plan, status, diff and apply as an authenticated JSON API",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireLiveCluster("serve"); err != nil {
+			return err
+		}
+		token, err := loadServeToken(serveTokenFile)
+		if err != nil {
+			return err
+		}
+		targets, err := prepareRuntimeTargets()
+		if err != nil {
+			return err
+		}
+		server := &http.Server{
+			Addr:              serveAddr,
+			Handler:           newAPIServer(cmd, token, targets, serveApply).handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		errCh := make(chan error, 1)
+		go func() { errCh <- server.ListenAndServe() }()
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "serving on %s\n", serveAddr)
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+			// Shutdown waits for in-flight requests, so a running apply finishes.
+			_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "shutting down")
+			return server.Shutdown(context.Background())
+		}
+	},
+}
+
+func loadServeToken(path string) (string, error) {
+	token := os.Getenv(serveTokenEnv)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read token file: %w", err)
+		}
+		token = string(data)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("serve requires an API token (--token-file or %s)", serveTokenEnv)
+	}
+	return token, nil
+}
+
+// apiServer handles the JSON API. Read endpoints run concurrently; applies
+// are serialized per project in-process, on top of the cluster apply lock.
+type apiServer struct {
+	cmd     *cobra.Command
+	token   string
+	targets *runtimeTargets
+	apply   savedPlanOptions
+
+	mu       sync.Mutex
+	applying map[string]struct{}
+}
+
+func newAPIServer(cmd *cobra.Command, token string, targets *runtimeTargets, options savedPlanOptions) *apiServer {
+	return &apiServer{cmd: cmd, token: token, targets: targets, apply: options, applying: make(map[string]struct{})}
+}
+
+func (s *apiServer) handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /v1/resolve", s.handleResolve)
+	api.HandleFunc("GET /v1/plan", s.handlePlan)
+	api.HandleFunc("GET /v1/status", s.handleStatus)
+	api.HandleFunc("GET /v1/diff", s.handleDiff)
+	api.HandleFunc("POST /v1/apply", s.handleApply)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.Handle("/v1/", s.authenticate(api))
+	return mux
+}
+
+func (s *apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="swarmcp"`)
+			writeAPIError(w, apiErrorf(http.StatusUnauthorized, "missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e *apiError) Error() string { return e.err.Error() }
+
+func apiErrorf(status int, format string, args ...any) error {
+	return &apiError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		status = apiErr.status
+	} else if errors.Is(err, apply.ErrLockHeld) {
+		status = http.StatusConflict
+	} else if errors.Is(err, apply.ErrPlanSecretChanged) {
+		// Which secret changed stays in the server's view.
+		status = http.StatusConflict
+		err = fmt.Errorf("%w; re-run plan", apply.ErrPlanSecretChanged)
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	writeJSONBytes(w, status, data)
+}
+
+func writeJSONBytes(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// requestTargets applies the deployment, partition and stack query
+// selectors of a request on top of the selectors the server started with.
+func (s *apiServer) requestTargets(r *http.Request, single bool) (*runtimeTargets, error) {
+	query := r.URL.Query()
+	targets := *s.targets
+	if values := normalizeSelectors(query["deployment"]); len(values) > 0 {
+		targets.deployments = values
+	}
+	if values := normalizeSelectors(query["partition"]); len(values) > 0 {
+		targets.partitionFilters = values
+	}
+	if values := normalizeSelectors(query["stack"]); len(values) > 0 {
+		targets.stackFilters = values
+	}
+	if len(targets.deployments) > 1 {
+		return nil, apiErrorf(http.StatusBadRequest, "select a single deployment")
+	}
+	if single && len(targets.partitionFilters) > 1 {
+		return nil, apiErrorf(http.StatusBadRequest, "select at most one partition")
+	}
+	if single && len(targets.stackFilters) > 1 {
+		return nil, apiErrorf(http.StatusBadRequest, "select at most one stack")
+	}
+	return &targets, nil
+}
+
+func queryBool(r *http.Request, name string) (bool, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, apiErrorf(http.StatusBadRequest, "invalid %s %q", name, raw)
+	}
+	return value, nil
+}
+
+func (s *apiServer) handleResolve(w http.ResponseWriter, r *http.Request) {
+	targets, err := s.requestTargets(r, true)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	model, err := config.LoadResolvedModel(config.ResolvedModelOptions{
+		ConfigPaths:        targets.configPaths,
+		ReleaseConfigPaths: targets.releaseConfigPaths,
+		Deployment:         targets.deployments[0],
+		Partition:          singleSelectorValue(targets.partitionFilters),
+		Stack:              singleSelectorValue(targets.stackFilters),
+		LoadOptions:        config.LoadOptions{Offline: opts.Offline, Debug: opts.Debug},
+	})
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	value := any(model.Model)
+	if path := strings.TrimSpace(r.URL.Query().Get("path")); path != "" {
+		value, err = config.LookupResolvedPath(model.Model, path)
+		if err != nil {
+			writeAPIError(w, &apiError{status: http.StatusNotFound, err: err})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, value)
+}
+
+// apiTarget is a loaded deployment target with its rendered desired state.
+type apiTarget struct {
+	runtimeTarget
+	desired  apply.DesiredState
+	warnings []string
+}
+
+func (s *apiServer) loadTarget(ctx context.Context, targets *runtimeTargets) (apiTarget, error) {
+	deployment := targets.deployments[0]
+	projectCtx, err := loadValidatedProjectContext(targets, deployment, runtimeTargetOptions{includeValues: true, includeSecrets: true})
+	if err != nil {
+		return apiTarget{}, err
+	}
+	cfg := projectCtx.Config
+	partitionFilters := cmdutil.FilterDeploymentPartitions(cfg, targets.partitionFilters)
+	desired, err := apply.BuildDesiredState(cfg, projectCtx.Secrets, projectCtx.Values, partitionFilters, targets.stackFilters, opts.AllowMissing, !opts.NoInfer)
+	if err != nil {
+		return apiTarget{}, err
+	}
+	if err := resolveImageDigests(ctx, projectCtx, &desired, partitionFilters, targets.stackFilters); err != nil {
+		return apiTarget{}, err
+	}
+	warnings := cmdutil.VolumePlacementWarnings(cfg, partitionFilters, targets.stackFilters, opts.Debug)
+	warnings = append(warnings, cmdutil.PlacementWarnings(cfg, partitionFilters, targets.stackFilters)...)
+	return apiTarget{
+		runtimeTarget: runtimeTarget{
+			configPaths:        targets.configPaths,
+			releaseConfigPaths: targets.releaseConfigPaths,
+			configPath:         targets.configPath,
+			deployment:         deployment,
+			partitionFilters:   partitionFilters,
+			stackFilters:       targets.stackFilters,
+			projectCtx:         projectCtx,
+		},
+		desired:  desired,
+		warnings: warnings,
+	}, nil
+}
+
+func (s *apiServer) handlePlan(w http.ResponseWriter, r *http.Request) {
+	targets, err := s.requestTargets(r, true)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	planOpts := opts
+	for name, field := range map[string]*bool{"prune": &planOpts.Prune, "prune_services": &planOpts.PruneServices, "prune_networks": &planOpts.PruneNetworks} {
+		if *field, err = queryBool(r, name); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+	}
+	target, err := s.loadTarget(r.Context(), targets)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	imageWarnings, err := checkServiceImages(r.Context(), target.projectCtx, target.desired, target.partitionFilters, target.stackFilters)
+	if err != nil {
+		writeAPIError(w, &apiError{status: http.StatusUnprocessableEntity, err: err})
+		return
+	}
+	planFile, err := buildPlanFile(r.Context(), s.cmd, targets, target.projectCtx, target.desired, target.partitionFilters, target.stackFilters, planOpts, false)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	planFile.Warnings = append(planFile.Warnings, imageWarnings...)
+	data, err := apply.MarshalPlanFileJSON(planFile)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSONBytes(w, http.StatusOK, data)
+}
+
+type apiStatusResponse struct {
+	Project        string             `json:"project"`
+	Deployment     string             `json:"deployment,omitempty"`
+	Warnings       []string           `json:"warnings,omitempty"`
+	MissingSecrets []string           `json:"missing_secrets,omitempty"`
+	Status         apply.StatusReport `json:"status"`
+}
+
+func (s *apiServer) buildStatus(r *http.Request) (apiTarget, apply.StatusReport, error) {
+	targets, err := s.requestTargets(r, false)
+	if err != nil {
+		return apiTarget{}, apply.StatusReport{}, err
+	}
+	target, err := s.loadTarget(r.Context(), targets)
+	if err != nil {
+		return apiTarget{}, apply.StatusReport{}, err
+	}
+	cfg := target.projectCtx.Config
+	client, err := target.projectCtx.SwarmClient()
+	if err != nil {
+		return apiTarget{}, apply.StatusReport{}, err
+	}
+	preserve, err := resolvePreserve(s.cmd, cfg, opts)
+	if err != nil {
+		return apiTarget{}, apply.StatusReport{}, err
+	}
+	report, err := apply.BuildStatus(r.Context(), client, cfg, target.desired, target.projectCtx.Values, target.partitionFilters, target.stackFilters, !opts.NoInfer, preserve)
+	if err != nil {
+		return apiTarget{}, apply.StatusReport{}, err
+	}
+	// Rendered payloads stay on the server; the names and labels identify them.
+	for i := range report.MissingConfigs {
+		report.MissingConfigs[i].Data = nil
+	}
+	for i := range report.MissingSecrets {
+		report.MissingSecrets[i].Data = nil
+	}
+	sortServiceStates(report.Services)
+	sortConfigSpecs(report.MissingConfigs)
+	sortSecretSpecs(report.MissingSecrets)
+	sortConfigs(report.StaleConfigs)
+	sortSecrets(report.StaleSecrets)
+	sortDriftItems(report.DriftConfigs)
+	sortDriftItems(report.DriftSecrets)
+	return target, report, nil
+}
+
+func (s *apiServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	target, report, err := s.buildStatus(r)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	cfg := target.projectCtx.Config
+	writeJSON(w, http.StatusOK, apiStatusResponse{
+		Project:        cfg.Project.Name,
+		Deployment:     cfg.Project.Deployment,
+		Warnings:       target.warnings,
+		MissingSecrets: sortedStrings(target.desired.Missing),
+		Status:         report,
+	})
+}
+
+type apiServiceChange struct {
+	Service string   `json:"service"`
+	Diffs   []string `json:"diffs,omitempty"`
+}
+
+type apiDiffResponse struct {
+	Project          string               `json:"project"`
+	Deployment       string               `json:"deployment,omitempty"`
+	Warnings         []string             `json:"warnings,omitempty"`
+	ConfigsToCreate  []string             `json:"configs_to_create"`
+	SecretsToCreate  []string             `json:"secrets_to_create"`
+	NetworksToCreate []string             `json:"networks_to_create"`
+	ConfigsToDelete  []string             `json:"configs_to_delete"`
+	SecretsToDelete  []string             `json:"secrets_to_delete"`
+	NetworksToDelete []string             `json:"networks_to_delete"`
+	ConfigsDrift     []apply.DriftItem    `json:"configs_drift"`
+	SecretsDrift     []apply.DriftItem    `json:"secrets_drift"`
+	NetworksDrift    []apply.NetworkDrift `json:"networks_drift"`
+	ServicesToUpdate []apiServiceChange   `json:"services_to_update"`
+	ServicesMissing  []string             `json:"services_missing"`
+	SkippedDeletes   apply.SkippedDeletes `json:"skipped_deletes"`
+}
+
+func (s *apiServer) handleDiff(w http.ResponseWriter, r *http.Request) {
+	target, report, err := s.buildStatus(r)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	cfg := target.projectCtx.Config
+	resp := apiDiffResponse{
+		Project:          cfg.Project.Name,
+		Deployment:       cfg.Project.Deployment,
+		Warnings:         target.warnings,
+		ConfigsToCreate:  []string{},
+		SecretsToCreate:  []string{},
+		NetworksToCreate: []string{},
+		ConfigsToDelete:  []string{},
+		SecretsToDelete:  []string{},
+		NetworksToDelete: []string{},
+		ConfigsDrift:     append([]apply.DriftItem{}, report.DriftConfigs...),
+		SecretsDrift:     append([]apply.DriftItem{}, report.DriftSecrets...),
+		NetworksDrift:    append([]apply.NetworkDrift{}, report.DriftNetworks...),
+		ServicesToUpdate: []apiServiceChange{},
+		ServicesMissing:  []string{},
+		SkippedDeletes:   report.SkippedDeletes,
+	}
+	for _, item := range report.MissingConfigs {
+		resp.ConfigsToCreate = append(resp.ConfigsToCreate, item.Name)
+	}
+	for _, item := range report.MissingSecrets {
+		resp.SecretsToCreate = append(resp.SecretsToCreate, item.Name)
+	}
+	for _, item := range report.MissingNetworks {
+		resp.NetworksToCreate = append(resp.NetworksToCreate, item.Name)
+	}
+	for _, item := range report.StaleConfigs {
+		resp.ConfigsToDelete = append(resp.ConfigsToDelete, item.Name)
+	}
+	for _, item := range report.StaleSecrets {
+		resp.SecretsToDelete = append(resp.SecretsToDelete, item.Name)
+	}
+	for _, item := range report.StaleNetworks {
+		resp.NetworksToDelete = append(resp.NetworksToDelete, item.Name)
+	}
+	sort.Strings(resp.NetworksToCreate)
+	sort.Strings(resp.NetworksToDelete)
+	changed, missing := splitServiceStates(report.Services)
+	for _, state := range changed {
+		resp.ServicesToUpdate = append(resp.ServicesToUpdate, apiServiceChange{
+			Service: cmdutil.ServiceScopeLabel(state.Stack, state.Partition, state.Service),
+			Diffs:   state.IntentDiffs,
+		})
+	}
+	for _, state := range missing {
+		resp.ServicesMissing = append(resp.ServicesMissing, cmdutil.ServiceScopeLabel(state.Stack, state.Partition, state.Service))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type apiApplyResponse struct {
+	Project    string            `json:"project"`
+	Deployment string            `json:"deployment,omitempty"`
+	Release    string            `json:"release,omitempty"`
+	Summary    state.PlanSummary `json:"summary"`
+}
+
+func (s *apiServer) handleApply(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, servePlanMaxLen))
+	if err != nil {
+		writeAPIError(w, &apiError{status: http.StatusBadRequest, err: fmt.Errorf("read plan: %w", err)})
+		return
+	}
+	planFile, err := apply.ParsePlanFile(data)
+	if err != nil {
+		writeAPIError(w, &apiError{status: http.StatusBadRequest, err: fmt.Errorf("parse plan: %w", err)})
+		return
+	}
+	if err := apply.ValidatePlanFile(planFile); err != nil {
+		writeAPIError(w, &apiError{status: http.StatusBadRequest, err: err})
+		return
+	}
+	query := r.URL.Query()
+	for name, planned := range map[string]string{"deployment": planFile.Deployment, "partition": planFile.Partition, "stack": planFile.Stack} {
+		if selected := strings.TrimSpace(query.Get(name)); selected != "" && selected != planned {
+			writeAPIError(w, apiErrorf(http.StatusBadRequest, "plan %s is %q; request selected %q", name, planned, selected))
+			return
+		}
+	}
+	projectCtx, err := s.applyTarget(planFile)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	contextName := projectCtx.ContextName
+	if !s.beginApply(planFile.Project) {
+		writeAPIError(w, apiErrorf(http.StatusConflict, "an apply for project %q is already running", planFile.Project))
+		return
+	}
+	defer s.endApply(planFile.Project)
+	summary, releaseID, err := applySavedPlan(s.cmd, planFile, "", contextName, projectCtx, s.apply, true, "summary", true)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiApplyResponse{
+		Project:    planFile.Project,
+		Deployment: planFile.Deployment,
+		Release:    releaseID,
+		Summary:    summary,
+	})
+}
+
+// applyTarget loads the server's project context for the deployment a plan
+// artifact was made for. The plan must target a deployment the server serves
+// and match that deployment's project and context; the context comes from
+// the server's config (or --context), never from the artifact alone.
+func (s *apiServer) applyTarget(planFile apply.PlanFile) (*cmdutil.ProjectContext, error) {
+	deployments, err := s.servedDeployments()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(deployments, planFile.Deployment) {
+		return nil, apiErrorf(http.StatusForbidden, "plan deployment %q is not served here (deployments: %s)", planFile.Deployment, strings.Join(deployments, ", "))
+	}
+	projectCtx, err := loadValidatedProjectContext(s.targets, planFile.Deployment, runtimeTargetOptions{includeSecrets: true})
+	if err != nil {
+		return nil, err
+	}
+	cfg := projectCtx.Config
+	if cfg.Project.Name != planFile.Project || cfg.Project.Deployment != planFile.Deployment {
+		return nil, apiErrorf(http.StatusForbidden, "plan targets project %q deployment %q; server serves project %q deployment %q", planFile.Project, planFile.Deployment, cfg.Project.Name, cfg.Project.Deployment)
+	}
+	if planFile.Context != projectCtx.ContextName {
+		return nil, apiErrorf(http.StatusForbidden, "plan context is %q; server resolves deployment %q to context %q", planFile.Context, cfg.Project.Deployment, projectCtx.ContextName)
+	}
+	return projectCtx, nil
+}
+
+// servedDeployments lists the deployments the server was started for: the
+// --deployment selection, or else the deployments its config declares.
+func (s *apiServer) servedDeployments() ([]string, error) {
+	if !slices.Equal(s.targets.deployments, []string{""}) {
+		return s.targets.deployments, nil
+	}
+	projectCtx, err := loadValidatedProjectContext(s.targets, "", runtimeTargetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cfg := projectCtx.Config
+	if len(cfg.Project.Deployments) > 0 {
+		return cfg.Project.Deployments, nil
+	}
+	return []string{cfg.Project.Deployment}, nil
+}
+
+func (s *apiServer) beginApply(project string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.applying[project]; busy {
+		return false
+	}
+	s.applying[project] = struct{}{}
+	return true
+}
+
+func (s *apiServer) endApply(project string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.applying, project)
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveTokenFile, "token-file", "", "File holding the bearer token clients must send (default: $"+serveTokenEnv+")")
+	serveCmd.Flags().BoolVar(&serveApply.serial, "serial", false, "Deploy services one at a time within each rollout batch")
+	serveCmd.Flags().BoolVar(&serveApply.noRollback, "no-rollback", false, "Leave services that fail their health check on the new spec")
+	serveCmd.Flags().BoolVar(&serveApply.canary, "canary", false, "Deploy every service update to a canary service first")
+	serveCmd.Flags().DurationVar(&serveApply.lockTimeout, "lock-timeout", 0, "How long an apply waits for a conflicting apply lock to be released (0 fails immediately)")
+	serveCmd.Flags().DurationVar(&serveApply.lockTTL, "lock-ttl", apply.DefaultLockTTL, "Lease duration of the apply lock; expired locks are taken over by the next apply")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/apply"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"github.com/cmmoran/swarmcp/internal/swarm/swarmsim"
+)
+
+const testAPIProject = `
+project:
+  name: demo
+  deployment: prod
+stacks:
+  app:
+    mode: shared
+    services:
+      web:
+        image: nginx:1
+        replicas: 1
+`
+
+func newTestAPIServer(t *testing.T) (*httptest.Server, *apiServer, *swarmsim.Simulator) {
+	t.Helper()
+	return newTestAPIServerWithProject(t, testAPIProject)
+}
+
+func newTestAPIServerWithProject(t *testing.T, projectYAML string) (*httptest.Server, *apiServer, *swarmsim.Simulator) {
+	t.Helper()
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project.yaml")
+	if err := os.WriteFile(project, []byte(projectYAML), 0o600); err != nil {
+		t.Fatalf("write project: %v", err)
+	}
+	sim := swarmsim.New()
+	if _, err := sim.CreateNetwork(context.Background(), swarm.NetworkSpec{Name: "demo_app", Driver: "overlay"}); err != nil {
+		t.Fatalf("CreateNetwork: %v", err)
+	}
+	prevPaths, prevOffline, prevClient := opts.ConfigPaths, opts.Offline, newSwarmClient
+	t.Cleanup(func() {
+		opts.ConfigPaths, opts.Offline, newSwarmClient = prevPaths, prevOffline, prevClient
+	})
+	opts.ConfigPaths = []string{project}
+	opts.Offline = true
+	newSwarmClient = func(string) (swarm.Client, error) { return sim, nil }
+
+	targets, err := prepareRuntimeTargets()
+	if err != nil {
+		t.Fatalf("prepareRuntimeTargets: %v", err)
+	}
+	api := newAPIServer(serveCmd, "s3cret", targets, savedPlanOptions{lockTTL: apply.DefaultLockTTL})
+	server := httptest.NewServer(api.handler())
+	t.Cleanup(server.Close)
+	return server, api, sim
+}
+
+func apiRequest(t *testing.T, server *httptest.Server, method, path string, body io.Reader) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, body)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return resp.StatusCode, data
+}
+
+func TestServeRequiresToken(t *testing.T) {
+	server, _, _ := newTestAPIServer(t)
+	resp, err := server.Client().Get(server.URL + "/v1/status")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", resp.StatusCode)
+	}
+	resp, err = server.Client().Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("GET healthz: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected unauthenticated healthz, got %d", resp.StatusCode)
+	}
+}
+
+func TestServePlanApplyStatus(t *testing.T) {
+	server, api, sim := newTestAPIServer(t)
+
+	code, body := apiRequest(t, server, http.MethodGet, "/v1/diff", nil)
+	if code != http.StatusOK || !strings.Contains(string(body), `"services_missing":["app/web"]`) {
+		t.Fatalf("unexpected diff %d: %s", code, body)
+	}
+
+	code, planBody := apiRequest(t, server, http.MethodGet, "/v1/plan?deployment=prod", nil)
+	if code != http.StatusOK {
+		t.Fatalf("plan failed %d: %s", code, planBody)
+	}
+	planFile, err := apply.ParsePlanFile(planBody)
+	if err != nil {
+		t.Fatalf("ParsePlanFile: %v", err)
+	}
+	if planFile.Project != "demo" || planFile.Deployment != "prod" || len(planFile.Plan.StackDeploys) != 1 {
+		t.Fatalf("unexpected plan file: %#v", planFile)
+	}
+
+	code, body = apiRequest(t, server, http.MethodPost, "/v1/apply?deployment=qa", strings.NewReader(string(planBody)))
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected selector mismatch to be rejected, got %d: %s", code, body)
+	}
+
+	api.beginApply("demo")
+	code, body = apiRequest(t, server, http.MethodPost, "/v1/apply", strings.NewReader(string(planBody)))
+	api.endApply("demo")
+	if code != http.StatusConflict {
+		t.Fatalf("expected concurrent apply to conflict, got %d: %s", code, body)
+	}
+
+	code, body = apiRequest(t, server, http.MethodPost, "/v1/apply", strings.NewReader(string(planBody)))
+	if code != http.StatusOK {
+		t.Fatalf("apply failed %d: %s", code, body)
+	}
+	var applied apiApplyResponse
+	if err := json.Unmarshal(body, &applied); err != nil {
+		t.Fatalf("decode apply response: %v", err)
+	}
+	if applied.Summary.StacksDeployed != 1 || applied.Release == "" {
+		t.Fatalf("unexpected apply response: %s", body)
+	}
+	if _, ok := sim.Service("demo_app_web"); !ok {
+		t.Fatalf("expected service to be deployed, got %v", sim.Operations())
+	}
+
+	code, body = apiRequest(t, server, http.MethodGet, "/v1/status", nil)
+	if code != http.StatusOK {
+		t.Fatalf("status failed %d: %s", code, body)
+	}
+	var status apiStatusResponse
+	if err := json.Unmarshal(body, &status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if len(status.Status.Services) != 1 || status.Status.Services[0].Missing || !status.Status.Services[0].IntentMatch {
+		t.Fatalf("unexpected status after apply: %s", body)
+	}
+}
+
+func TestServeResolveAndSelectors(t *testing.T) {
+	server, _, _ := newTestAPIServer(t)
+	code, body := apiRequest(t, server, http.MethodGet, "/v1/resolve?path=project.name", nil)
+	if code != http.StatusOK || strings.TrimSpace(string(body)) != `"demo"` {
+		t.Fatalf("unexpected resolve %d: %s", code, body)
+	}
+	code, body = apiRequest(t, server, http.MethodGet, "/v1/plan?stack=app&stack=other", nil)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected multiple stacks to be rejected for plan, got %d: %s", code, body)
+	}
+}
+
+func TestServeApplyRejectsPlansForOtherTargets(t *testing.T) {
+	server, _, sim := newTestAPIServer(t)
+	code, planBody := apiRequest(t, server, http.MethodGet, "/v1/plan", nil)
+	if code != http.StatusOK {
+		t.Fatalf("plan failed %d: %s", code, planBody)
+	}
+	for name, edit := range map[string]func(*apply.PlanFile){
+		"project":    func(planFile *apply.PlanFile) { planFile.Project = "other" },
+		"deployment": func(planFile *apply.PlanFile) { planFile.Deployment = "staging" },
+		"context":    func(planFile *apply.PlanFile) { planFile.Context = "elsewhere" },
+	} {
+		planFile, err := apply.ParsePlanFile(planBody)
+		if err != nil {
+			t.Fatalf("ParsePlanFile: %v", err)
+		}
+		edit(&planFile)
+		data, err := apply.MarshalPlanFileJSON(planFile)
+		if err != nil {
+			t.Fatalf("MarshalPlanFileJSON: %v", err)
+		}
+		code, body := apiRequest(t, server, http.MethodPost, "/v1/apply", strings.NewReader(string(data)))
+		if code != http.StatusForbidden {
+			t.Fatalf("expected plan with foreign %s to be rejected, got %d: %s", name, code, body)
+		}
+	}
+	if _, ok := sim.Service("demo_app_web"); ok {
+		t.Fatalf("expected no service to be deployed, got %v", sim.Operations())
+	}
+}
+
+func TestWriteAPIErrorHidesChangedSecretDetails(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writeAPIError(recorder, fmt.Errorf("secret source %q: dependency %q: %w", "db_password", "db_password", apply.ErrPlanSecretChanged))
+	if recorder.Code != http.StatusConflict || strings.Contains(recorder.Body.String(), "db_password") {
+		t.Fatalf("unexpected error response %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
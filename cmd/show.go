@@ -59,13 +59,24 @@ func printPlanFileSummary(out interface {
 	_, _ = fmt.Fprintf(out, "inputs: %d\n", len(planFile.Inputs))
 	_, _ = fmt.Fprintf(out, "source inputs: %d\n", len(planFile.SourceInputs))
 	_, _ = fmt.Fprintf(out, "assumptions: %d\n", planAssumptionCount(planFile.Plan.Assumptions))
-	_, _ = fmt.Fprintf(out, "networks to create: %d\nconfigs to create: %d\nsecrets to create: %d\nstacks to deploy: %d\nconfigs to delete: %d\nsecrets to delete: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\n", planSummary.NetworksCreated, planSummary.ConfigsCreated, planSummary.SecretsCreated, planSummary.StacksDeployed, planSummary.ConfigsRemoved, planSummary.SecretsRemoved, planSummary.ConfigsSkipped, planSummary.SecretsSkipped)
+	_, _ = fmt.Fprintf(out, "networks to create: %d\nconfigs to create: %d\nsecrets to create: %d\nstacks to deploy: %d\nconfigs to delete: %d\nsecrets to delete: %d\nnetworks to delete: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\nnetworks skipped (in use): %d\n", planSummary.NetworksCreated, planSummary.ConfigsCreated, planSummary.SecretsCreated, planSummary.StacksDeployed, planSummary.ConfigsRemoved, planSummary.SecretsRemoved, planSummary.NetworksRemoved, planSummary.ConfigsSkipped, planSummary.SecretsSkipped, planSummary.NetworksSkipped)
 	if len(stackNames) > 0 {
 		_, _ = fmt.Fprintln(out, "stacks:")
 		for _, name := range stackNames {
 			_, _ = fmt.Fprintf(out, "  - %s\n", name)
 		}
 	}
+	if len(planFile.Plan.DeleteNetworks) > 0 {
+		_, _ = fmt.Fprintln(out, "networks to delete:")
+		for _, net := range planFile.Plan.DeleteNetworks {
+			_, _ = fmt.Fprintf(out, "  - %s\n", net.Name)
+		}
+	}
+	if rollout, err := apply.BuildRollout(planFile.Plan.StackDeploys); err == nil {
+		printRolloutBatches(out, rollout)
+	}
+	printJobSteps(out, planFile.Plan.StackDeploys)
+	printCanaryRollouts(out, planFile.Plan.StackDeploys)
 	if len(planFile.SecretSources) > 0 {
 		_, _ = fmt.Fprintln(out, "secret sources:")
 		for _, source := range planFile.SecretSources {
@@ -94,6 +105,7 @@ func planAssumptionCount(assumptions apply.PlanAssumptions) int {
 		len(assumptions.AbsentServices) +
 		len(assumptions.PresentConfigs) +
 		len(assumptions.PresentSecrets) +
+		len(assumptions.PresentNetworks) +
 		len(assumptions.PresentServices)
 }
 
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotOnce   sync.Once
+	snapshotLoaded swarm.Snapshot
+	snapshotErr    error
+)
+
+// newSwarmClient connects to the Docker API of a context; tests replace it.
+var newSwarmClient = swarm.NewClient
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Capture cluster state for offline plan/diff/status",
+}
+
+var snapshotExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Write services, configs (with content), secret metadata, networks and nodes to a file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if opts.Snapshot != "" {
+			return fmt.Errorf("snapshot export reads the live cluster and cannot run against --snapshot")
+		}
+		targets, err := prepareRuntimeTargets()
+		if err != nil {
+			return err
+		}
+		if len(targets.deployments) > 1 {
+			return fmt.Errorf("snapshot export requires a single deployment target")
+		}
+		out := cmd.OutOrStdout()
+		return forEachRuntimeTarget(out, targets, runtimeTargetOptions{}, func(target runtimeTarget) error {
+			client, err := target.projectCtx.SwarmClient()
+			if err != nil {
+				return err
+			}
+			snap, err := swarm.CaptureSnapshot(context.Background(), client, target.projectCtx.ContextName)
+			if err != nil {
+				return err
+			}
+			if err := swarm.WriteSnapshot(args[0], snap); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(out, "snapshot OK: %s\nservices: %d\nconfigs: %d\nsecrets: %d\nnetworks: %d\nnodes: %d\n", args[0], len(snap.Services), len(snap.Configs), len(snap.Secrets), len(snap.Networks), len(snap.Nodes))
+			return nil
+		})
+	},
+}
+
+// swarmClientFactory returns the client constructor for runtime commands:
+// the Docker API of the selected context, or the --snapshot file when set.
+func swarmClientFactory() func(string) (swarm.Client, error) {
+	if opts.Snapshot == "" {
+		return newSwarmClient
+	}
+	return func(string) (swarm.Client, error) {
+		snapshotOnce.Do(func() {
+			snapshotLoaded, snapshotErr = swarm.ReadSnapshot(opts.Snapshot)
+		})
+		if snapshotErr != nil {
+			return nil, snapshotErr
+		}
+		return swarm.NewSnapshotClient(snapshotLoaded), nil
+	}
+}
+
+// requireLiveCluster rejects cluster mutations when --snapshot is set.
+func requireLiveCluster(command string) error {
+	if opts.Snapshot != "" {
+		return fmt.Errorf("%s changes the cluster and cannot run against --snapshot", command)
+	}
+	return nil
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotExportCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/swarm"
+)
+
+func TestSwarmClientFactoryUsesSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.json")
+	snap := swarm.Snapshot{APIVersion: swarm.SnapshotAPIVersion, Nodes: []swarm.Node{{ID: "n1", Hostname: "worker-1"}}}
+	if err := swarm.WriteSnapshot(path, snap); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+	prev := opts.Snapshot
+	opts.Snapshot = path
+	t.Cleanup(func() { opts.Snapshot = prev })
+
+	client, err := swarmClientFactory()("ignored")
+	if err != nil {
+		t.Fatalf("factory: %v", err)
+	}
+	nodes, err := client.ListNodes(context.Background())
+	if err != nil || len(nodes) != 1 || nodes[0].Hostname != "worker-1" {
+		t.Fatalf("unexpected nodes %#v, %v", nodes, err)
+	}
+	if err := requireLiveCluster("apply"); err == nil {
+		t.Fatalf("expected apply to be rejected against a snapshot")
+	}
+}
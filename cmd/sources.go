@@ -163,6 +163,7 @@ func loadSourceEntries(cmd *cobra.Command) ([]sourceEntry, error) {
 		Partition:          partition,
 		Offline:            opts.Offline,
 		Debug:              opts.Debug,
+		ClientFactory:      swarmClientFactory(),
 	}
 	cfg, _, err := cmdutil.LoadProjectConfig(projectOpts)
 	if err != nil {
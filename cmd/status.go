@@ -9,6 +9,7 @@ import (
 
 	"github.com/cmmoran/swarmcp/internal/apply"
 	"github.com/cmmoran/swarmcp/internal/cmdutil"
+	"github.com/cmmoran/swarmcp/internal/swarm"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +21,9 @@ var statusCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if metricsAddr != "" {
+			return runStatusMetrics(cmd, targets)
+		}
 		out := cmd.OutOrStdout()
 		return forEachRuntimeTarget(out, targets, runtimeTargetOptions{includeValues: true, includeSecrets: true}, func(target runtimeTarget) error {
 			cfg := target.projectCtx.Config
@@ -27,26 +31,28 @@ var statusCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
-
-			client, err := target.projectCtx.SwarmClient()
-			if err != nil {
+			if err := resolveImageDigests(context.Background(), target.projectCtx, &desired, target.partitionFilters, target.stackFilters); err != nil {
 				return err
 			}
 
-			ctx := context.Background()
-			preserve, err := resolvePreserve(cmd, cfg, opts)
+			client, err := target.projectCtx.SwarmClient()
 			if err != nil {
 				return err
 			}
-			report, err := apply.BuildStatus(ctx, client, cfg, desired, target.projectCtx.Values, target.partitionFilters, target.stackFilters, !opts.NoInfer, preserve)
+
+			report, err := buildTargetStatus(context.Background(), cmd, target.projectCtx, desired, target.partitionFilters, target.stackFilters, client)
 			if err != nil {
 				return err
 			}
 
 			warnings := cmdutil.VolumePlacementWarnings(cfg, target.partitionFilters, target.stackFilters, opts.Debug)
+			warnings = append(warnings, cmdutil.PlacementWarnings(cfg, target.partitionFilters, target.stackFilters)...)
 			sortServiceStates(report.Services)
 			cmdutil.PrintWarnings(out, warnings)
-			_, _ = fmt.Fprintf(out, "status OK\nconfigs missing: %d\nsecrets missing: %d\nnetworks missing: %d\nconfigs stale: %d\nsecrets stale: %d\nconfigs drift: %d\nsecrets drift: %d\nconfigs preserved: %d\nsecrets preserved: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\n", len(report.MissingConfigs), len(report.MissingSecrets), len(report.MissingNetworks), len(report.StaleConfigs), len(report.StaleSecrets), len(report.DriftConfigs), len(report.DriftSecrets), report.Preserved.ConfigsPreserved, report.Preserved.SecretsPreserved, report.SkippedDeletes.Configs, report.SkippedDeletes.Secrets)
+			_, _ = fmt.Fprintf(out, "status OK\nconfigs missing: %d\nsecrets missing: %d\nnetworks missing: %d\nconfigs stale: %d\nsecrets stale: %d\nnetworks stale: %d\nconfigs drift: %d\nsecrets drift: %d\nconfigs preserved: %d\nsecrets preserved: %d\nconfigs skipped (in use): %d\nsecrets skipped (in use): %d\nnetworks skipped (in use): %d\n", len(report.MissingConfigs), len(report.MissingSecrets), len(report.MissingNetworks), len(report.StaleConfigs), len(report.StaleSecrets), len(report.StaleNetworks), len(report.DriftConfigs), len(report.DriftSecrets), report.Preserved.ConfigsPreserved, report.Preserved.SecretsPreserved, report.SkippedDeletes.Configs, report.SkippedDeletes.Secrets, report.SkippedDeletes.Networks)
+			printStaleNetworks(out, "networks stale:", report.StaleNetworks)
+			printNetworkDrift(out, report.DriftNetworks)
+			printNetworkConflicts(out, report.NetworkConflicts)
 			printServiceSummary(out, report.Services)
 			printServiceStates(out, report.Services)
 			if opts.Debug {
@@ -64,6 +70,14 @@ var statusCmd = &cobra.Command{
 	},
 }
 
+func buildTargetStatus(ctx context.Context, cmd *cobra.Command, projectCtx *cmdutil.ProjectContext, desired apply.DesiredState, partitionFilters, stackFilters []string, client swarm.Client) (apply.StatusReport, error) {
+	preserve, err := resolvePreserve(cmd, projectCtx.Config, opts)
+	if err != nil {
+		return apply.StatusReport{}, err
+	}
+	return apply.BuildStatus(ctx, client, projectCtx.Config, desired, projectCtx.Values, partitionFilters, stackFilters, !opts.NoInfer, preserve)
+}
+
 func sortServiceStates(states []apply.ServiceState) {
 	sort.Slice(states, func(i, j int) bool {
 		if states[i].Stack != states[j].Stack {
@@ -123,10 +137,14 @@ func formatServiceState(state apply.ServiceState) string {
 	if !state.MountsMatch {
 		mounts = "changed"
 	}
+	canary := ""
+	if state.Canary != nil {
+		canary = fmt.Sprintf(" canary=(%s image=%s health=%s)", state.Canary.Name, state.Canary.Image, state.Canary.Health)
+	}
 	if state.Desired < 0 || state.Running < 0 {
-		return fmt.Sprintf("%s intent=%s%s mounts=%s health=%s%s", scope, intent, diff, mounts, state.Health, unmanaged)
+		return fmt.Sprintf("%s intent=%s%s mounts=%s health=%s%s%s", scope, intent, diff, mounts, state.Health, unmanaged, canary)
 	}
-	return fmt.Sprintf("%s intent=%s%s mounts=%s health=%s desired=%d running=%d%s", scope, intent, diff, mounts, state.Health, state.Desired, state.Running, unmanaged)
+	return fmt.Sprintf("%s intent=%s%s mounts=%s health=%s desired=%d running=%d%s%s", scope, intent, diff, mounts, state.Health, state.Desired, state.Running, unmanaged, canary)
 }
 
 func printServiceIntentDetails(out io.Writer, states []apply.ServiceState) {
@@ -145,3 +163,33 @@ func printServiceIntentDetails(out io.Writer, states []apply.ServiceState) {
 		}
 	}
 }
+
+func printNetworkDrift(out io.Writer, drifts []apply.NetworkDrift) {
+	if len(drifts) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(out, "networks drift: %d\n", len(drifts))
+	for _, drift := range drifts {
+		_, _ = fmt.Fprintf(out, "  - %s\n", apply.FormatNetworkDrift(drift))
+	}
+}
+
+func printNetworkConflicts(out io.Writer, conflicts []apply.NetworkConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(out, "network subnet conflicts: %d\n", len(conflicts))
+	for _, conflict := range conflicts {
+		_, _ = fmt.Fprintf(out, "  - %s\n", apply.FormatNetworkConflict(conflict))
+	}
+}
+
+func printStaleNetworks(out io.Writer, heading string, networks []swarm.Network) {
+	if len(networks) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(out, heading)
+	for _, net := range networks {
+		_, _ = fmt.Fprintf(out, "  - %s\n", net.Name)
+	}
+}
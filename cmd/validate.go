@@ -1,16 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/cmmoran/swarmcp/internal/apply"
 	"github.com/cmmoran/swarmcp/internal/cmdutil"
 	"github.com/spf13/cobra"
 )
 
+var validateRuntime bool
+
 var validateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate configuration and templates",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if validateRuntime {
+			return validateRuntimeTargets(cmd)
+		}
 		configPath, err := primaryConfigPath()
 		if err != nil {
 			return err
@@ -32,6 +39,7 @@ var validateCmd = &cobra.Command{
 			Partition:          partition,
 			Offline:            opts.Offline,
 			Debug:              opts.Debug,
+			ClientFactory:      swarmClientFactory(),
 		}
 		cfg, _, err := cmdutil.LoadProjectConfig(projectOpts)
 		if err != nil {
@@ -45,3 +53,32 @@ var validateCmd = &cobra.Command{
 		return nil
 	},
 }
+
+// validateRuntimeTargets renders the desired state of every selected
+// deployment with its values and secrets and checks the service images
+// against the registry.
+func validateRuntimeTargets(cmd *cobra.Command) error {
+	targets, err := prepareRuntimeTargets()
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	return forEachRuntimeTarget(out, targets, runtimeTargetOptions{includeValues: true, includeSecrets: true}, func(target runtimeTarget) error {
+		cfg := target.projectCtx.Config
+		desired, err := apply.BuildDesiredState(cfg, target.projectCtx.Secrets, target.projectCtx.Values, target.partitionFilters, target.stackFilters, opts.AllowMissing, !opts.NoInfer)
+		if err != nil {
+			return err
+		}
+		warnings, err := checkServiceImages(context.Background(), target.projectCtx, desired, target.partitionFilters, target.stackFilters)
+		if err != nil {
+			return err
+		}
+		cmdutil.PrintWarnings(out, warnings)
+		_, _ = fmt.Fprintln(out, "config OK")
+		return nil
+	})
+}
+
+func init() {
+	validateCmd.Flags().BoolVar(&validateRuntime, "runtime", false, "Also render desired state with values/secrets and check service images against the registry")
+}
@@ -17,8 +17,85 @@ type BindPathRequirement struct {
 	Constraints []string
 }
 
+// NamedVolumeRequirement is a driver-backed volume a service mounts; the
+// driver creates it on each node that runs a task.
+type NamedVolumeRequirement struct {
+	Scope   templates.Scope
+	Name    string
+	Driver  string
+	Options map[string]string
+	Subpath string
+	Target  string
+}
+
 func PlanBindPaths(cfg *config.Config, values any, partitionFilters []string, stackFilters []string) ([]BindPathRequirement, error) {
 	var out []BindPathRequirement
+	err := planServiceMounts(cfg, values, partitionFilters, stackFilters, func(scope templates.Scope, mounts []mount.Mount, constraints []string) {
+		for _, m := range mounts {
+			if m.Type != mount.TypeBind {
+				continue
+			}
+			out = append(out, BindPathRequirement{
+				Scope:       scope,
+				Source:      m.Source,
+				Target:      m.Target,
+				Constraints: constraints,
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Source != out[j].Source {
+			return out[i].Source < out[j].Source
+		}
+		if out[i].Target != out[j].Target {
+			return out[i].Target < out[j].Target
+		}
+		if out[i].Scope.Stack != out[j].Scope.Stack {
+			return out[i].Scope.Stack < out[j].Scope.Stack
+		}
+		return out[i].Scope.Service < out[j].Scope.Service
+	})
+	return out, nil
+}
+
+func PlanNamedVolumes(cfg *config.Config, values any, partitionFilters []string, stackFilters []string) ([]NamedVolumeRequirement, error) {
+	var out []NamedVolumeRequirement
+	err := planServiceMounts(cfg, values, partitionFilters, stackFilters, func(scope templates.Scope, mounts []mount.Mount, _ []string) {
+		for _, m := range mounts {
+			if m.Type != mount.TypeVolume || m.VolumeOptions == nil || m.VolumeOptions.DriverConfig == nil {
+				continue
+			}
+			out = append(out, NamedVolumeRequirement{
+				Scope:   scope,
+				Name:    m.Source,
+				Driver:  m.VolumeOptions.DriverConfig.Name,
+				Options: m.VolumeOptions.DriverConfig.Options,
+				Subpath: m.VolumeOptions.Subpath,
+				Target:  m.Target,
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		if out[i].Scope.Service != out[j].Scope.Service {
+			return out[i].Scope.Service < out[j].Scope.Service
+		}
+		return out[i].Target < out[j].Target
+	})
+	return out, nil
+}
+
+// planServiceMounts renders the volume mounts of every selected service and
+// passes them with the service placement constraints to fn.
+func planServiceMounts(cfg *config.Config, values any, partitionFilters []string, stackFilters []string, fn func(scope templates.Scope, mounts []mount.Mount, constraints []string)) error {
 	for stackName, stack := range cfg.Stacks {
 		if len(stackFilters) > 0 && !selectorContains(stackFilters, stackName) {
 			continue
@@ -33,7 +110,7 @@ func PlanBindPaths(cfg *config.Config, values any, partitionFilters []string, st
 		for _, partitionName := range partitions {
 			services, err := cfg.StackServices(stackName, partitionName)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			if len(services) == 0 {
 				continue
@@ -52,47 +129,24 @@ func PlanBindPaths(cfg *config.Config, values any, partitionFilters []string, st
 					NetworksShared:   config.NetworksSharedString(cfg, partitionName),
 					NetworkEphemeral: networkEphemeral,
 				}
-				// Bind path planning should not fail on missing refs in templates.
+				// Mount planning should not fail on missing refs in templates.
 				_, engine, templateScope, templateData := render.NewServiceTemplateEngine(cfg, scope, values, true, nil)
 				renderedService, err := render.RenderServiceTemplates(engine, templateScope, templateData, service)
 				if err != nil {
-					return nil, err
+					return err
 				}
 				mounts, err := desiredVolumeMounts(cfg, engine, templateData, stackName, stack, partitionName, serviceName, renderedService)
 				if err != nil {
-					return nil, err
+					return err
 				}
 				if len(mounts) == 0 {
 					continue
 				}
-				constraints := desiredPlacementConstraints(cfg, stackName, stack, partitionName, serviceName, renderedService)
-				for _, m := range mounts {
-					if m.Type != mount.TypeBind {
-						continue
-					}
-					out = append(out, BindPathRequirement{
-						Scope:       scope,
-						Source:      m.Source,
-						Target:      m.Target,
-						Constraints: constraints,
-					})
-				}
+				fn(scope, mounts, desiredPlacementConstraints(cfg, stackName, stack, partitionName, serviceName, renderedService))
 			}
 		}
 	}
-	sort.Slice(out, func(i, j int) bool {
-		if out[i].Source != out[j].Source {
-			return out[i].Source < out[j].Source
-		}
-		if out[i].Target != out[j].Target {
-			return out[i].Target < out[j].Target
-		}
-		if out[i].Scope.Stack != out[j].Scope.Stack {
-			return out[i].Scope.Stack < out[j].Scope.Stack
-		}
-		return out[i].Scope.Service < out[j].Scope.Service
-	})
-	return out, nil
+	return nil
 }
 
 func formatBindPathLine(scope templates.Scope, source, target string) string {
@@ -105,3 +159,11 @@ func formatBindPathLine(scope templates.Scope, source, target string) string {
 func FormatBindPathLine(req BindPathRequirement) string {
 	return formatBindPathLine(req.Scope, req.Source, req.Target)
 }
+
+func FormatNamedVolumeLine(req NamedVolumeRequirement) string {
+	source := req.Name + " [" + formatDriver(req.Driver, req.Options) + "]"
+	if req.Subpath != "" {
+		source += " subpath=" + req.Subpath
+	}
+	return formatBindPathLine(req.Scope, source, req.Target)
+}
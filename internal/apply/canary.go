@@ -0,0 +1,228 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+const canaryOfLabel = "swarmcp.io/canary-of"
+
+const (
+	CanaryPromoted = "promoted"
+	CanaryAborted  = "aborted"
+)
+
+// CanaryPolicy is the resolved canary setting of a compose service.
+type CanaryPolicy struct {
+	Replicas int    `yaml:"replicas" json:"replicas"`
+	Window   string `yaml:"window,omitempty" json:"window,omitempty"`
+}
+
+func canaryPolicy(canary *config.Canary) CanaryPolicy {
+	policy := CanaryPolicy{Replicas: config.DefaultCanaryReplicas}
+	if canary == nil {
+		return policy
+	}
+	if canary.Replicas > 0 {
+		policy.Replicas = canary.Replicas
+	}
+	policy.Window = canary.Window
+	return policy
+}
+
+func canaryServiceName(namespace string, key string) string {
+	return namespace + "_" + key + "_canary"
+}
+
+// WithCanaryRollout returns copies of stacks in which every service without
+// canary settings of its own uses the default canary policy, as requested by
+// apply --canary.
+func WithCanaryRollout(stacks []StackDeploy) []StackDeploy {
+	out := make([]StackDeploy, len(stacks))
+	for i, deploy := range stacks {
+		canaries := make(map[string]CanaryPolicy, len(deploy.Services))
+		for key := range deploy.Services {
+			canaries[key] = canaryPolicy(nil)
+		}
+		for key, policy := range deploy.Canaries {
+			canaries[key] = policy
+		}
+		deploy.Canaries = canaries
+		out[i] = deploy
+	}
+	return out
+}
+
+// runCanary deploys the new spec of a service as a separate canary service
+// with the canary replica count, waits for it to become healthy and keeps
+// watching it for the canary window. A failed canary is removed right away;
+// a promoted one is removed by removeCanary once the main service update
+// completes.
+func runCanary(ctx context.Context, client swarm.Client, state *stackRollout, key string, policy CanaryPolicy, inventory deployInventory) error {
+	namespace := state.deploy.Name
+	name := canaryServiceName(namespace, key)
+	window, err := config.ParseCanaryWindow(policy.Window)
+	if err != nil {
+		return fmt.Errorf("canary %s: %w", name, err)
+	}
+	timeout, err := config.ParseHealthTimeout(state.deploy.HealthTimeouts[key])
+	if err != nil {
+		return fmt.Errorf("canary %s: %w", name, err)
+	}
+	planned, err := state.serviceIntent(key)
+	if err != nil {
+		return fmt.Errorf("canary %s: %w", name, err)
+	}
+	intent := canaryServiceIntent(planned, policy.Replicas, namespace+"_"+key)
+	if err := removeServiceByName(ctx, client, name); err != nil {
+		return fmt.Errorf("canary %s: remove previous canary: %w", name, err)
+	}
+	spec := applyIntentToSpec(dockerapi.ServiceSpec{Annotations: dockerapi.Annotations{Name: name}}, intent)
+	spec = applyStackNamespace(spec, namespace, key, intent.Image)
+	id, err := createService(ctx, client, ServiceCreate{
+		Name:    name,
+		Spec:    spec,
+		Configs: intent.Configs,
+		Secrets: intent.Secrets,
+	}, inventory.configIDs, inventory.secretIDs)
+	if err != nil {
+		return fmt.Errorf("canary %s: %w", name, err)
+	}
+	if err := watchCanary(ctx, client, name, timeout, window); err != nil {
+		if rmErr := client.RemoveService(context.WithoutCancel(ctx), id); rmErr != nil {
+			err = fmt.Errorf("%w; remove canary: %v", err, rmErr)
+		}
+		return fmt.Errorf("canary %s: %w", name, err)
+	}
+	return nil
+}
+
+// canaryServiceIntent derives the canary from the new service spec: same
+// image, labels, configs, secrets, volumes, networks and placement, running
+// the canary replica count without published ports so it cannot clash with
+// the main service. owner is the name of the service the canary belongs to.
+func canaryServiceIntent(planned serviceIntent, replicas int, owner string) serviceIntent {
+	intent := planned
+	intent.Labels = cloneLabels(planned.Labels)
+	if intent.Labels == nil {
+		intent.Labels = make(map[string]string, 1)
+	}
+	intent.Labels[canaryOfLabel] = owner
+	intent.Ports = nil
+	intent.Mode = "replicated"
+	intent.Replicas = uint64(max(replicas, 1))
+	return intent
+}
+
+// watchCanary waits until the canary is healthy and then requires it to stay
+// healthy for the whole window.
+func watchCanary(ctx context.Context, client swarm.Client, name string, timeout time.Duration, window time.Duration) error {
+	failures := waitForServiceHealth(ctx, client, map[string]pendingService{name: {timeout: timeout}})
+	if err := failures[name]; err != nil {
+		return err
+	}
+	deadline := time.Now().Add(window)
+	for {
+		svc, tasks, err := lookupServiceTasks(ctx, client, name)
+		if err != nil {
+			return err
+		}
+		healthy, status, err := serviceHealthy(svc, tasks)
+		if err != nil {
+			return fmt.Errorf("failed during canary window: %w", err)
+		}
+		if !healthy {
+			return fmt.Errorf("unhealthy during canary window (%s)", status)
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("watching canary: %w", ctx.Err())
+		case <-time.After(min(deployPollInterval, remaining)):
+		}
+	}
+}
+
+func lookupServiceTasks(ctx context.Context, client swarm.Client, name string) (swarm.Service, []swarm.Task, error) {
+	services, err := client.ListServices(ctx)
+	if err != nil {
+		return swarm.Service{}, nil, err
+	}
+	for _, svc := range services {
+		if svc.Name != name {
+			continue
+		}
+		tasks, err := client.ListServiceTasks(ctx, svc.ID)
+		return svc, tasks, err
+	}
+	return swarm.Service{}, nil, fmt.Errorf("service %q not found", name)
+}
+
+// isCanary reports whether a service is a canary. Canaries carry the labels
+// of the service they belong to and must not be mistaken for it.
+func isCanary(labels map[string]string) bool {
+	return labels[canaryOfLabel] != ""
+}
+
+// removeOrphanedCanaries removes the canaries of a stack that an interrupted
+// apply left behind. It runs before any service of the stack is rolled out,
+// so every canary found belongs to an earlier run.
+func removeOrphanedCanaries(ctx context.Context, client swarm.Client, state *stackRollout, inventory deployInventory) error {
+	namespace := state.deploy.Name
+	names := make([]string, 0, len(inventory.services))
+	for name := range inventory.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		svc := inventory.services[name]
+		owner := svc.Labels[canaryOfLabel]
+		if owner == "" {
+			continue
+		}
+		if svc.Labels[stackNamespaceLabel] != namespace && !ownsCanary(state.deploy, owner) {
+			continue
+		}
+		entry := ServiceDeployResult{Name: svc.Name, Action: ServiceActionRemove}
+		if err := client.RemoveService(ctx, svc.ID); err != nil {
+			entry.Error = err.Error()
+			state.result.Services = append(state.result.Services, entry)
+			return fmt.Errorf("remove orphaned canary %q: %w", svc.Name, err)
+		}
+		state.result.Services = append(state.result.Services, entry)
+	}
+	return nil
+}
+
+// ownsCanary matches canaries created before they joined the stack
+// namespace by the service they belong to.
+func ownsCanary(deploy StackDeploy, owner string) bool {
+	key, ok := strings.CutPrefix(owner, deploy.Name+"_")
+	if !ok {
+		return false
+	}
+	_, ok = deploy.Services[key]
+	return ok
+}
+
+// removeCanary removes the canary of a promoted service once the update of
+// the main service completed, successfully or not.
+func removeCanary(ctx context.Context, client swarm.Client, namespace string, key string, entry *ServiceDeployResult) {
+	if err := removeServiceByName(context.WithoutCancel(ctx), client, canaryServiceName(namespace, key)); err != nil {
+		message := "remove canary: " + err.Error()
+		if entry.Error != "" {
+			message = entry.Error + "; " + message
+		}
+		entry.Error = message
+	}
+}
@@ -0,0 +1,177 @@
+package apply
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+// ServiceIntent is the planned spec of a stack service as written to plan
+// artifacts, the serve API and release records. Its keys are part of the
+// plan artifact schema (PlanFileAPIVersion); the engine converts it to its
+// internal intent when it deploys. Healthcheck, policies, resources, the log
+// driver and volumes are Docker Engine API types and keep their API shape.
+type ServiceIntent struct {
+	Image          string                          `yaml:"image" json:"image"`
+	Command        []string                        `yaml:"command,omitempty" json:"command,omitempty"`
+	Args           []string                        `yaml:"args,omitempty" json:"args,omitempty"`
+	Workdir        string                          `yaml:"workdir,omitempty" json:"workdir,omitempty"`
+	Env            []string                        `yaml:"env,omitempty" json:"env,omitempty"`
+	Ports          []PortIntent                    `yaml:"ports,omitempty" json:"ports,omitempty"`
+	EndpointMode   string                          `yaml:"endpoint_mode,omitempty" json:"endpoint_mode,omitempty"`
+	Mode           string                          `yaml:"mode" json:"mode"`
+	Replicas       uint64                          `yaml:"replicas,omitempty" json:"replicas,omitempty"`
+	Labels         map[string]string               `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Constraints    []string                        `yaml:"constraints,omitempty" json:"constraints,omitempty"`
+	Preferences    []string                        `yaml:"preferences,omitempty" json:"preferences,omitempty"`
+	MaxReplicas    uint64                          `yaml:"max_replicas_per_node,omitempty" json:"max_replicas_per_node,omitempty"`
+	Platforms      []string                        `yaml:"platforms,omitempty" json:"platforms,omitempty"`
+	Healthcheck    *container.HealthConfig         `yaml:"healthcheck,omitempty" json:"healthcheck,omitempty"`
+	RestartPolicy  *dockerapi.RestartPolicy        `yaml:"restart_policy,omitempty" json:"restart_policy,omitempty"`
+	UpdateConfig   *dockerapi.UpdateConfig         `yaml:"update_config,omitempty" json:"update_config,omitempty"`
+	RollbackConfig *dockerapi.UpdateConfig         `yaml:"rollback_config,omitempty" json:"rollback_config,omitempty"`
+	Resources      *dockerapi.ResourceRequirements `yaml:"resources,omitempty" json:"resources,omitempty"`
+	LogDriver      *dockerapi.Driver               `yaml:"log_driver,omitempty" json:"log_driver,omitempty"`
+	Configs        []ServiceMount                  `yaml:"configs,omitempty" json:"configs,omitempty"`
+	Secrets        []ServiceMount                  `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	Volumes        []mount.Mount                   `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	Networks       []string                        `yaml:"networks,omitempty" json:"networks,omitempty"`
+	Runtime        RuntimeIntent                   `yaml:"runtime,omitempty" json:"runtime,omitzero"`
+}
+
+// PortIntent is a published port of a ServiceIntent.
+type PortIntent struct {
+	Target    uint32                          `yaml:"target" json:"target"`
+	Published uint32                          `yaml:"published,omitempty" json:"published,omitempty"`
+	Protocol  dockerapi.PortConfigProtocol    `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+	Mode      dockerapi.PortConfigPublishMode `yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// RuntimeIntent holds the container runtime options of a ServiceIntent.
+// StopGracePeriod is a Go duration string.
+type RuntimeIntent struct {
+	User            string            `yaml:"user,omitempty" json:"user,omitempty"`
+	Hostname        string            `yaml:"hostname,omitempty" json:"hostname,omitempty"`
+	Init            *bool             `yaml:"init,omitempty" json:"init,omitempty"`
+	ReadOnly        bool              `yaml:"read_only,omitempty" json:"read_only,omitempty"`
+	StopSignal      string            `yaml:"stop_signal,omitempty" json:"stop_signal,omitempty"`
+	StopGracePeriod string            `yaml:"stop_grace_period,omitempty" json:"stop_grace_period,omitempty"`
+	Tmpfs           []string          `yaml:"tmpfs,omitempty" json:"tmpfs,omitempty"`
+	CapAdd          []string          `yaml:"cap_add,omitempty" json:"cap_add,omitempty"`
+	CapDrop         []string          `yaml:"cap_drop,omitempty" json:"cap_drop,omitempty"`
+	Sysctls         map[string]string `yaml:"sysctls,omitempty" json:"sysctls,omitempty"`
+	Ulimits         []string          `yaml:"ulimits,omitempty" json:"ulimits,omitempty"`
+	Hosts           []string          `yaml:"extra_hosts,omitempty" json:"extra_hosts,omitempty"`
+	DNS             []string          `yaml:"dns,omitempty" json:"dns,omitempty"`
+	DNSSearch       []string          `yaml:"dns_search,omitempty" json:"dns_search,omitempty"`
+	DNSOptions      []string          `yaml:"dns_opt,omitempty" json:"dns_opt,omitempty"`
+}
+
+func exportServiceIntent(intent serviceIntent) ServiceIntent {
+	out := ServiceIntent{
+		Image:          intent.Image,
+		Command:        intent.Command,
+		Args:           intent.Args,
+		Workdir:        intent.Workdir,
+		Env:            intent.Env,
+		EndpointMode:   intent.EndpointMode,
+		Mode:           intent.Mode,
+		Replicas:       intent.Replicas,
+		Labels:         intent.Labels,
+		Constraints:    intent.Constraints,
+		Preferences:    intent.Preferences,
+		MaxReplicas:    intent.MaxReplicas,
+		Platforms:      intent.Platforms,
+		Healthcheck:    intent.Healthcheck,
+		RestartPolicy:  intent.RestartPolicy,
+		UpdateConfig:   intent.UpdateConfig,
+		RollbackConfig: intent.RollbackConfig,
+		Resources:      intent.Resources,
+		LogDriver:      intent.LogDriver,
+		Configs:        intent.Configs,
+		Secrets:        intent.Secrets,
+		Volumes:        intent.Volumes,
+		Networks:       intent.Networks,
+		Runtime: RuntimeIntent{
+			User:       intent.Runtime.User,
+			Hostname:   intent.Runtime.Hostname,
+			Init:       intent.Runtime.Init,
+			ReadOnly:   intent.Runtime.ReadOnly,
+			StopSignal: intent.Runtime.StopSignal,
+			Tmpfs:      intent.Runtime.Tmpfs,
+			CapAdd:     intent.Runtime.CapAdd,
+			CapDrop:    intent.Runtime.CapDrop,
+			Sysctls:    intent.Runtime.Sysctls,
+			Ulimits:    intent.Runtime.Ulimits,
+			Hosts:      intent.Runtime.Hosts,
+			DNS:        intent.Runtime.DNS,
+			DNSSearch:  intent.Runtime.DNSSearch,
+			DNSOptions: intent.Runtime.DNSOptions,
+		},
+	}
+	for _, port := range intent.Ports {
+		out.Ports = append(out.Ports, PortIntent(port))
+	}
+	if intent.Runtime.StopGracePeriod != nil {
+		out.Runtime.StopGracePeriod = intent.Runtime.StopGracePeriod.String()
+	}
+	return out
+}
+
+func (i ServiceIntent) serviceIntent() (serviceIntent, error) {
+	out := serviceIntent{
+		Image:          i.Image,
+		Command:        i.Command,
+		Args:           i.Args,
+		Workdir:        i.Workdir,
+		Env:            i.Env,
+		EndpointMode:   i.EndpointMode,
+		Mode:           i.Mode,
+		Replicas:       i.Replicas,
+		Labels:         i.Labels,
+		Constraints:    i.Constraints,
+		Preferences:    i.Preferences,
+		MaxReplicas:    i.MaxReplicas,
+		Platforms:      i.Platforms,
+		Healthcheck:    i.Healthcheck,
+		RestartPolicy:  i.RestartPolicy,
+		UpdateConfig:   i.UpdateConfig,
+		RollbackConfig: i.RollbackConfig,
+		Resources:      i.Resources,
+		LogDriver:      i.LogDriver,
+		Configs:        i.Configs,
+		Secrets:        i.Secrets,
+		Volumes:        i.Volumes,
+		Networks:       i.Networks,
+		Runtime: runtimeIntent{
+			User:       i.Runtime.User,
+			Hostname:   i.Runtime.Hostname,
+			Init:       i.Runtime.Init,
+			ReadOnly:   i.Runtime.ReadOnly,
+			StopSignal: i.Runtime.StopSignal,
+			Tmpfs:      i.Runtime.Tmpfs,
+			CapAdd:     i.Runtime.CapAdd,
+			CapDrop:    i.Runtime.CapDrop,
+			Sysctls:    i.Runtime.Sysctls,
+			Ulimits:    i.Runtime.Ulimits,
+			Hosts:      i.Runtime.Hosts,
+			DNS:        i.Runtime.DNS,
+			DNSSearch:  i.Runtime.DNSSearch,
+			DNSOptions: i.Runtime.DNSOptions,
+		},
+	}
+	for _, port := range i.Ports {
+		out.Ports = append(out.Ports, portIntent(port))
+	}
+	if i.Runtime.StopGracePeriod != "" {
+		period, err := time.ParseDuration(i.Runtime.StopGracePeriod)
+		if err != nil {
+			return serviceIntent{}, fmt.Errorf("runtime.stop_grace_period: %w", err)
+		}
+		out.Runtime.StopGracePeriod = &period
+	}
+	return out, nil
+}
@@ -13,6 +13,9 @@ type DesiredState struct {
 	Networks []swarm.NetworkSpec
 	Defs     []render.RenderedDef
 	Missing  []string
+	// ImageDigests maps rendered service images to their digest-pinned
+	// references when image resolution is enabled.
+	ImageDigests map[string]string
 }
 
 func BuildDesiredState(cfg *config.Config, store *secrets.Store, values any, partitionFilters []string, stackFilters []string, allowMissing bool, infer bool) (DesiredState, error) {
@@ -0,0 +1,134 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+)
+
+// ImageDigestResolver pins an image reference to the digest its tag points
+// at; swarm.ImageResolver implements it against the registry.
+type ImageDigestResolver interface {
+	ResolveDigest(ctx context.Context, image string) (string, error)
+}
+
+// ServiceImage is the rendered image of one selected service together with
+// the placement constraints the service is scheduled under.
+type ServiceImage struct {
+	Stack       string
+	Partition   string
+	Service     string
+	Image       string
+	Constraints []string
+}
+
+// ServiceImages returns the rendered images of the selected services, sorted
+// and deduplicated.
+func ServiceImages(cfg *config.Config, desired DesiredState, values any, partitionFilters []string, stackFilters []string, infer bool) ([]string, error) {
+	targets, err := ServiceImageTargets(cfg, desired, values, partitionFilters, stackFilters, infer)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	for _, target := range targets {
+		seen[target.Image] = struct{}{}
+	}
+	return sortedKeys(seen), nil
+}
+
+// ServiceImageTargets returns the image and placement constraints of every
+// selected service with an image, pinned to desired.ImageDigests when
+// resolved.
+func ServiceImageTargets(cfg *config.Config, desired DesiredState, values any, partitionFilters []string, stackFilters []string, infer bool) ([]ServiceImage, error) {
+	expected, err := expectedServices(cfg, partitionFilters, stackFilters)
+	if err != nil {
+		return nil, err
+	}
+	index := buildDefIndex(desired.Defs)
+	var out []ServiceImage
+	for _, svc := range expected {
+		build, err := buildServiceIntent(cfg, svc.Stack, cfg.Stacks[svc.Stack], svc.Partition, svc.Name, svc.Service, values, infer, index)
+		if err != nil {
+			return nil, err
+		}
+		build.pinImage(desired.ImageDigests)
+		image := strings.TrimSpace(build.Rendered.Image)
+		if image == "" {
+			continue
+		}
+		out = append(out, ServiceImage{
+			Stack:       svc.Stack,
+			Partition:   svc.Partition,
+			Service:     svc.Name,
+			Image:       image,
+			Constraints: build.Constraints,
+		})
+	}
+	return out, nil
+}
+
+// ResolveImageDigests resolves every image tag and returns the pinned
+// reference per image. Images that already carry a digest are skipped.
+func ResolveImageDigests(ctx context.Context, resolver ImageDigestResolver, images []string) (map[string]string, error) {
+	digests := make(map[string]string, len(images))
+	var errs []error
+	for _, image := range images {
+		if imageHasDigest(image) {
+			continue
+		}
+		pinned, err := resolver.ResolveDigest(ctx, image)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		digests[image] = pinned
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return digests, nil
+}
+
+// UnpinnedImages returns the images that neither carry a digest nor have a
+// resolved one.
+func UnpinnedImages(images []string, digests map[string]string) []string {
+	var out []string
+	for _, image := range images {
+		if imageHasDigest(image) {
+			continue
+		}
+		if _, ok := digests[image]; !ok {
+			out = append(out, image)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// RequireImageDigests fails when the selected deployment requires digest
+// pinning by project.policy.require_image_digests and an image is unpinned.
+func RequireImageDigests(cfg *config.Config, images []string, digests map[string]string) error {
+	if !cfg.RequiresImageDigests() {
+		return nil
+	}
+	if unpinned := UnpinnedImages(images, digests); len(unpinned) > 0 {
+		return fmt.Errorf("deployment %q requires image digests (project.policy.require_image_digests); unpinned: %s", cfg.Project.Deployment, strings.Join(unpinned, ", "))
+	}
+	return nil
+}
+
+func imageHasDigest(image string) bool {
+	return strings.Contains(image, "@sha256:")
+}
+
+// pinImage swaps the rendered image for its resolved digest reference.
+func (b *serviceIntentBuild) pinImage(digests map[string]string) {
+	if pinned, ok := digests[b.Rendered.Image]; ok {
+		b.Rendered.Image = pinned
+		b.Intent.Image = pinned
+	}
+}
@@ -0,0 +1,79 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type fakeImageResolver map[string]string
+
+func (f fakeImageResolver) ResolveDigest(ctx context.Context, image string) (string, error) {
+	if pinned, ok := f[image]; ok {
+		return pinned, nil
+	}
+	return "", fmt.Errorf("resolve image %q: manifest not found", image)
+}
+
+const pinnedWeb = "nginx:1@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestResolveImageDigestsPinsPlanAndIntent(t *testing.T) {
+	cfg := simulatorConfig(map[string]string{"web": "nginx:1"})
+	images, err := ServiceImages(cfg, DesiredState{}, nil, nil, nil, false)
+	if err != nil || len(images) != 1 || images[0] != "nginx:1" {
+		t.Fatalf("unexpected images %v, %v", images, err)
+	}
+	digests, err := ResolveImageDigests(context.Background(), fakeImageResolver{"nginx:1": pinnedWeb}, images)
+	if err != nil {
+		t.Fatalf("ResolveImageDigests: %v", err)
+	}
+	desired := DesiredState{ImageDigests: digests}
+
+	sim := newStackSimulator(t)
+	plan, err := BuildPlan(context.Background(), sim, cfg, desired, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if plan.ImageDigests["nginx:1"] != pinnedWeb || !strings.Contains(string(plan.StackDeploys[0].Compose), pinnedWeb) {
+		t.Fatalf("expected pinned image in plan, got %v\n%s", plan.ImageDigests, plan.StackDeploys[0].Compose)
+	}
+	if _, err := Apply(context.Background(), sim, plan, nil, false, true, 1, true, "plain", false); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	svc, _ := sim.Service("proj_app_web")
+	if image := svc.Spec.TaskTemplate.ContainerSpec.Image; image != pinnedWeb {
+		t.Fatalf("expected deployed digest, got %s", image)
+	}
+
+	plan, err = BuildPlan(context.Background(), sim, cfg, desired, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.StackDeploys) != 0 {
+		t.Fatalf("expected no changes for the same digest, got %d stack deploys", len(plan.StackDeploys))
+	}
+	report, err := BuildStatus(context.Background(), sim, cfg, desired, nil, nil, nil, false, 0)
+	if err != nil || !report.Services[0].IntentMatch {
+		t.Fatalf("expected status to match pinned intent, got %+v, %v", report.Services, err)
+	}
+}
+
+func TestRequireImageDigests(t *testing.T) {
+	cfg := simulatorConfig(map[string]string{"web": "nginx:1", "api": "api@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"})
+	cfg.Project.Deployments = []string{"dev", "prod"}
+	cfg.Project.Deployment = "prod"
+	cfg.Project.Policy.RequireImageDigests = []string{"prod"}
+	images := []string{"api@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "nginx:1"}
+
+	if err := RequireImageDigests(cfg, images, nil); err == nil || !strings.Contains(err.Error(), "unpinned: nginx:1") {
+		t.Fatalf("expected unpinned nginx:1, got %v", err)
+	}
+	if err := RequireImageDigests(cfg, images, map[string]string{"nginx:1": pinnedWeb}); err != nil {
+		t.Fatalf("expected resolved digests to satisfy policy, got %v", err)
+	}
+	cfg.Project.Deployment = "dev"
+	if err := RequireImageDigests(cfg, images, nil); err != nil {
+		t.Fatalf("expected dev to allow tags, got %v", err)
+	}
+}
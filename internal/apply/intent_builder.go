@@ -8,14 +8,20 @@ import (
 )
 
 type serviceIntentBuild struct {
-	Rendered     config.Service
-	ConfigMounts []ServiceMount
-	SecretMounts []ServiceMount
-	VolumeMounts []mount.Mount
-	Networks     []string
-	Labels       map[string]string
-	Constraints  []string
-	Intent       serviceIntent
+	Rendered       config.Service
+	ConfigMounts   []ServiceMount
+	SecretMounts   []ServiceMount
+	VolumeMounts   []mount.Mount
+	Networks       []string
+	Labels         map[string]string
+	Constraints    []string
+	RestartPolicy  *config.RestartPolicy
+	UpdatePolicy   *config.UpdatePolicy
+	RollbackPolicy *config.UpdatePolicy
+	Resources      *config.Resources
+	Logging        *config.Logging
+	HealthTimeout  string
+	Intent         serviceIntent
 }
 
 func buildServiceIntent(cfg *config.Config, stackName string, stack config.Stack, partitionName string, serviceName string, service config.Service, values any, infer bool, defIndex map[defKey]string) (serviceIntentBuild, error) {
@@ -101,19 +107,45 @@ func buildServiceIntent(cfg *config.Config, stackName string, stack config.Stack
 		config.StackPartitionRollbackConfig(stack, partitionName),
 		renderedService.RollbackConfig,
 	)
-	intent, err := intentFromConfig(renderedService, labels, constraints, configMounts, secretMounts, volumeMounts, serviceNetworks, restartPolicy, updatePolicy, rollbackPolicy)
+	resources := config.MergeResources(
+		cfg.Project.Resources,
+		stack.Resources,
+		config.StackPartitionResources(stack, partitionName),
+		renderedService.Resources,
+	)
+	logging, err := render.RenderTemplateLogging(engine, scope, data, config.MergeLogging(
+		cfg.Project.Defaults.Logging,
+		stack.Logging,
+		config.StackPartitionLogging(stack, partitionName),
+		service.Logging,
+	))
+	if err != nil {
+		return serviceIntentBuild{}, err
+	}
+	intent, err := intentFromConfig(renderedService, labels, constraints, configMounts, secretMounts, volumeMounts, serviceNetworks, restartPolicy, updatePolicy, rollbackPolicy, resources, logging)
 	if err != nil {
 		return serviceIntentBuild{}, err
 	}
 
 	return serviceIntentBuild{
-		Rendered:     renderedService,
-		ConfigMounts: configMounts,
-		SecretMounts: secretMounts,
-		VolumeMounts: volumeMounts,
-		Networks:     serviceNetworks,
-		Labels:       labels,
-		Constraints:  constraints,
-		Intent:       intent,
+		Rendered:       renderedService,
+		ConfigMounts:   configMounts,
+		SecretMounts:   secretMounts,
+		VolumeMounts:   volumeMounts,
+		Networks:       serviceNetworks,
+		Labels:         labels,
+		Constraints:    constraints,
+		RestartPolicy:  restartPolicy,
+		UpdatePolicy:   updatePolicy,
+		RollbackPolicy: rollbackPolicy,
+		Resources:      resources,
+		Logging:        logging,
+		HealthTimeout: config.ResolveHealthTimeout(
+			cfg.Project.HealthTimeout,
+			stack.HealthTimeout,
+			config.StackPartitionHealthTimeout(stack, partitionName),
+			renderedService.HealthTimeout,
+		),
+		Intent: intent,
 	}, nil
 }
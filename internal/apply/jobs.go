@@ -0,0 +1,256 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/render"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"github.com/docker/docker/api/types/container"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+const (
+	jobOwnerLabel = "swarmcp.io/job-owner"
+	jobNameLabel  = "swarmcp.io/job"
+	jobPhaseLabel = "swarmcp.io/job-phase"
+)
+
+// jobLogTail limits how many log lines of a job are kept in apply output.
+const jobLogTail = 200
+
+// jobCleanupTimeout bounds collecting a job's logs and removing its service,
+// which still happens when the apply was cancelled while the job ran.
+const jobCleanupTimeout = 30 * time.Second
+
+// JobStep is a fully rendered lifecycle job. Jobs run with the image and
+// runtime attachments of the service they belong to.
+type JobStep struct {
+	Phase   string            `yaml:"phase" json:"phase"`
+	Name    string            `yaml:"name" json:"name"`
+	Command []string          `yaml:"command" json:"command"`
+	Args    []string          `yaml:"args,omitempty" json:"args,omitempty"`
+	Workdir string            `yaml:"workdir,omitempty" json:"workdir,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Timeout string            `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Cleanup string            `yaml:"cleanup" json:"cleanup"`
+}
+
+type JobResult struct {
+	Phase    string `yaml:"phase" json:"phase"`
+	Name     string `yaml:"name" json:"name"`
+	Service  string `yaml:"service" json:"service"`
+	ExitCode int    `yaml:"exit_code" json:"exit_code"`
+	Error    string `yaml:"error,omitempty" json:"error,omitempty"`
+	Logs     string `yaml:"logs,omitempty" json:"logs,omitempty"`
+}
+
+func buildJobSteps(jobs config.ServiceJobs) ([]JobStep, error) {
+	var steps []JobStep
+	for _, phase := range jobs.Phases() {
+		for _, job := range phase.Jobs {
+			cleanup, err := config.NormalizeJobCleanup(job.Cleanup)
+			if err != nil {
+				return nil, fmt.Errorf("job %q: %w", job.Name, err)
+			}
+			steps = append(steps, JobStep{
+				Phase:   phase.Phase,
+				Name:    job.Name,
+				Command: cloneStrings(job.Command),
+				Args:    cloneStrings(job.Args),
+				Workdir: job.Workdir,
+				Env:     cloneLabels(job.Env),
+				Timeout: job.Timeout,
+				Cleanup: cleanup,
+			})
+		}
+	}
+	return steps, nil
+}
+
+func jobStepsForPhase(steps []JobStep, phase string) []JobStep {
+	var out []JobStep
+	for _, step := range steps {
+		if step.Phase == phase {
+			out = append(out, step)
+		}
+	}
+	return out
+}
+
+func jobServiceName(namespace string, key string, job string) string {
+	return namespace + "_" + key + "_job_" + job
+}
+
+// WithoutJobs returns copies of stacks with their lifecycle jobs removed, as
+// used by rollback unless --run-jobs is set.
+func WithoutJobs(stacks []StackDeploy) []StackDeploy {
+	out := make([]StackDeploy, len(stacks))
+	for i, deploy := range stacks {
+		deploy.Jobs = nil
+		out[i] = deploy
+	}
+	return out
+}
+
+// runServiceJobs runs the jobs of one phase in order and stops at the first
+// failure.
+func runServiceJobs(ctx context.Context, client swarm.Client, state *stackRollout, key string, phase string, inventory deployInventory) ([]JobResult, error) {
+	var results []JobResult
+	for _, step := range jobStepsForPhase(state.deploy.Jobs[key], phase) {
+		result := runJob(ctx, client, state, key, step, inventory)
+		results = append(results, result)
+		if result.Error != "" {
+			return results, fmt.Errorf("%s job %q: %s", phase, step.Name, result.Error)
+		}
+	}
+	return results, nil
+}
+
+func runJob(ctx context.Context, client swarm.Client, state *stackRollout, key string, step JobStep, inventory deployInventory) JobResult {
+	namespace := state.deploy.Name
+	name := jobServiceName(namespace, key, step.Name)
+	result := JobResult{Phase: step.Phase, Name: step.Name, Service: name}
+	timeout, err := config.ParseJobTimeout(step.Timeout)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	owner, err := state.serviceIntent(key)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	intent, err := jobServiceIntent(owner, step)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	intent.Labels = jobLabels(owner.Labels, namespace+"_"+key, step)
+	if err := removeServiceByName(ctx, client, name); err != nil {
+		result.Error = fmt.Sprintf("remove previous run: %v", err)
+		return result
+	}
+	spec := applyIntentToSpec(dockerapi.ServiceSpec{Annotations: dockerapi.Annotations{Name: name}}, intent)
+	id, err := createService(ctx, client, ServiceCreate{
+		Name:    name,
+		Spec:    spec,
+		Configs: intent.Configs,
+		Secrets: intent.Secrets,
+	}, inventory.configIDs, inventory.secretIDs)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	exitCode, err := waitForJob(ctx, client, id, timeout)
+	result.ExitCode = exitCode
+	if err != nil {
+		result.Error = err.Error()
+	}
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobCleanupTimeout)
+	defer cancel()
+	if logs, err := client.ServiceLogs(cleanupCtx, id, jobLogTail); err == nil {
+		result.Logs = strings.TrimRight(string(logs), "\n")
+	}
+	if step.Cleanup == config.JobCleanupAlways || (step.Cleanup == config.JobCleanupSuccess && result.Error == "") {
+		if err := client.RemoveService(cleanupCtx, id); err != nil && result.Error == "" {
+			result.Error = fmt.Sprintf("cleanup: %v", err)
+		}
+	}
+	return result
+}
+
+// jobLabels marks a job service as managed by the project and stack instance
+// of the service it belongs to. The service label is left out so that plan
+// and status never take the job for its owner.
+func jobLabels(owner map[string]string, ownerName string, step JobStep) map[string]string {
+	labels := map[string]string{
+		jobOwnerLabel: ownerName,
+		jobNameLabel:  step.Name,
+		jobPhaseLabel: step.Phase,
+	}
+	for _, key := range []string{render.LabelManaged, render.LabelProject, render.LabelStack, render.LabelPartition} {
+		if value, ok := owner[key]; ok {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// jobServiceIntent derives a one-shot replicated-job from the owning service:
+// same image, configs, secrets, volumes, networks, placement, resources,
+// runtime options and logging, with the job's command and environment and
+// without ports, service labels, healthcheck or update policies.
+func jobServiceIntent(owner serviceIntent, step JobStep) (serviceIntent, error) {
+	intent := owner
+	intent.Command = cloneStrings(step.Command)
+	intent.Args = cloneStrings(step.Args)
+	if step.Workdir != "" {
+		intent.Workdir = step.Workdir
+	}
+	if len(step.Env) > 0 {
+		env := make(map[string]string, len(owner.Env)+len(step.Env))
+		for _, entry := range owner.Env {
+			key, value, _ := strings.Cut(entry, "=")
+			env[key] = value
+		}
+		for key, value := range step.Env {
+			env[key] = value
+		}
+		intent.Env = envSlice(env)
+	}
+	intent.Ports = nil
+	intent.EndpointMode = ""
+	intent.Labels = nil
+	intent.Mode = "replicated-job"
+	intent.Replicas = 1
+	intent.Healthcheck = &container.HealthConfig{Test: []string{"NONE"}}
+	intent.RestartPolicy = &dockerapi.RestartPolicy{Condition: dockerapi.RestartPolicyConditionNone}
+	intent.UpdateConfig = nil
+	intent.RollbackConfig = nil
+	return intent, nil
+}
+
+func removeServiceByName(ctx context.Context, client swarm.Client, name string) error {
+	services, err := client.ListServices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, svc := range services {
+		if svc.Name == name {
+			return client.RemoveService(ctx, svc.ID)
+		}
+	}
+	return nil
+}
+
+// waitForJob polls the job's task until it completes or fails and returns the
+// container exit code.
+func waitForJob(ctx context.Context, client swarm.Client, id string, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		tasks, err := client.ListServiceTasks(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		for _, task := range tasks {
+			switch task.State {
+			case dockerapi.TaskStateComplete:
+				return task.ExitCode, nil
+			case dockerapi.TaskStateFailed, dockerapi.TaskStateRejected, dockerapi.TaskStateShutdown:
+				return task.ExitCode, fmt.Errorf("task %s: %s", task.State, taskFailure(task))
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return 0, fmt.Errorf("did not complete within %s", timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("waiting for job: %w", ctx.Err())
+		case <-time.After(deployPollInterval):
+		}
+	}
+}
@@ -14,6 +14,12 @@ func isManagedProject(labels map[string]string, projectName string) bool {
 	return labels[render.LabelProject] == projectName
 }
 
+// isReleaseRecord reports whether a managed config is a release ledger entry
+// rather than a rendered config.
+func isReleaseRecord(labels map[string]string) bool {
+	return labels[render.LabelRelease] != ""
+}
+
 func configLabelDrift(expected, actual map[string]string, projectName string) string {
 	if len(actual) == 0 {
 		return "labels missing"
@@ -0,0 +1,441 @@
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/render"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+)
+
+const (
+	DefaultLockTTL   = 30 * time.Minute
+	lockNamePrefix   = "swarmcp-lock"
+	lockPollInterval = 2 * time.Second
+)
+
+// ErrLockHeld is returned when an overlapping apply lock is held by someone
+// else and the wait timeout has elapsed.
+var ErrLockHeld = errors.New("apply lock held")
+
+// ErrLockLost is returned by Lease.Check once the lease was broken, expired
+// or taken over by another apply.
+var ErrLockLost = errors.New("apply lock lost")
+
+// LockScope identifies what an apply lock protects. A project-wide lock
+// (empty partition) overlaps every partition lock of the same project.
+type LockScope struct {
+	Project   string
+	Partition string
+}
+
+func (s LockScope) Name() string {
+	if s.Partition == "" {
+		return fmt.Sprintf("%s.%s", lockNamePrefix, s.Project)
+	}
+	return fmt.Sprintf("%s.%s.%s", lockNamePrefix, s.Project, s.Partition)
+}
+
+// generationName names the lock config of a renewed lease. Generations
+// never reuse a name, so the next config can be created while the previous
+// one still holds the lock.
+func (s LockScope) generationName(generation int) string {
+	return fmt.Sprintf("%s.r%d", s.Name(), generation)
+}
+
+func (s LockScope) String() string {
+	if s.Partition == "" {
+		return fmt.Sprintf("project %q", s.Project)
+	}
+	return fmt.Sprintf("project %q partition %q", s.Project, s.Partition)
+}
+
+func (s LockScope) Overlaps(other LockScope) bool {
+	if s.Project != other.Project {
+		return false
+	}
+	return s.Partition == "" || other.Partition == "" || s.Partition == other.Partition
+}
+
+// LockInfo is the lease payload stored in the lock config.
+type LockInfo struct {
+	Project   string    `json:"project"`
+	Partition string    `json:"partition,omitempty"`
+	Holder    string    `json:"holder"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+	TTL       string    `json:"ttl"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (i LockInfo) Scope() LockScope {
+	return LockScope{Project: i.Project, Partition: i.Partition}
+}
+
+type ClusterLock struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	Info      LockInfo
+}
+
+func (l ClusterLock) Expired(now time.Time) bool {
+	return !l.Info.ExpiresAt.IsZero() && now.After(l.Info.ExpiresAt)
+}
+
+func (l ClusterLock) Describe() string {
+	return fmt.Sprintf("%s held by %s (command %q, started %s, expires %s)", l.Info.Scope(), l.Info.Holder, l.Info.Command, l.Info.StartedAt.Format(time.RFC3339), l.Info.ExpiresAt.Format(time.RFC3339))
+}
+
+// Lease is an acquired apply lock. It is renewed in the background until
+// released; release it when the apply finishes.
+type Lease struct {
+	client swarm.Client
+	ttl    time.Duration
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu         sync.Mutex
+	Lock       ClusterLock
+	generation int
+	// stale holds earlier generations whose removal failed; they are
+	// retried on the next renewal and on release.
+	stale []ClusterLock
+	lost  error
+}
+
+func newLease(client swarm.Client, lock ClusterLock, ttl time.Duration) *Lease {
+	return &Lease{client: client, ttl: ttl, Lock: lock, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (l *Lease) Release(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.removeStale(ctx)
+	if err := l.client.RemoveLock(ctx, l.Lock.ID); err != nil {
+		return fmt.Errorf("release lock %s: %w", l.Lock.Name, err)
+	}
+	return nil
+}
+
+// Check reports whether the lease is still held: its lock config must still
+// exist and must not have expired. A nil lease always passes.
+func (l *Lease) Check(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.verify(ctx); err != nil {
+		return err
+	}
+	if l.Lock.Expired(time.Now()) {
+		l.lost = fmt.Errorf("%w: %s expired at %s", ErrLockLost, l.Lock.Name, l.Lock.Info.ExpiresAt.Format(time.RFC3339))
+	}
+	return l.lost
+}
+
+// keepAlive renews the lease every third of its TTL until it is released or
+// lost. Failed renewals are retried on the next tick; the lease stays valid
+// until it expires.
+func (l *Lease) keepAlive() {
+	defer close(l.done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-l.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	ticker := time.NewTicker(max(l.ttl/3, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.renew(ctx); errors.Is(err, ErrLockLost) {
+				return
+			}
+		}
+	}
+}
+
+// renew replaces the lock config with one carrying a new expiry. Swarm
+// configs are immutable, so the replacement is created under the next
+// generation name before the old config is removed: the lock is held
+// throughout and competing applies keep backing off.
+func (l *Lease) renew(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.verify(ctx); err != nil {
+		return err
+	}
+	l.removeStale(ctx)
+	now := time.Now().UTC()
+	info := l.Lock.Info
+	info.TTL = l.ttl.String()
+	info.ExpiresAt = now.Add(l.ttl)
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	scope := info.Scope()
+	l.generation++
+	name := scope.generationName(l.generation)
+	id, err := l.client.CreateLock(ctx, swarm.LockSpec{Name: name, Labels: lockLabels(scope), Data: data})
+	if err != nil {
+		// The current config still holds the lock; retry on the next tick.
+		return fmt.Errorf("renew lock %s: %w", l.Lock.Name, err)
+	}
+	ignore := []string{l.Lock.ID}
+	for _, lock := range l.stale {
+		ignore = append(ignore, lock.ID)
+	}
+	if held, ok, err := overlappingLock(ctx, l.client, scope, id, ignore...); err != nil || ok {
+		_ = l.client.RemoveLock(ctx, id)
+		if err != nil {
+			return fmt.Errorf("renew lock %s: %w", l.Lock.Name, err)
+		}
+		l.lost = fmt.Errorf("%w: renew %s: taken over by %s", ErrLockLost, l.Lock.Name, held.Describe())
+		return l.lost
+	}
+	previous := l.Lock
+	l.Lock = ClusterLock{ID: id, Name: name, CreatedAt: now, Info: info}
+	if err := l.client.RemoveLock(ctx, previous.ID); err != nil {
+		l.stale = append(l.stale, previous)
+		return fmt.Errorf("renew lock %s: remove %s: %w", name, previous.Name, err)
+	}
+	return nil
+}
+
+// removeStale retries removing earlier generations. Callers hold l.mu.
+func (l *Lease) removeStale(ctx context.Context) {
+	remaining := l.stale[:0]
+	for _, lock := range l.stale {
+		if err := l.client.RemoveLock(ctx, lock.ID); err != nil {
+			remaining = append(remaining, lock)
+		}
+	}
+	l.stale = remaining
+}
+
+// verify confirms the lease's lock config still exists. Callers hold l.mu.
+func (l *Lease) verify(ctx context.Context) error {
+	if l.lost != nil {
+		return l.lost
+	}
+	locks, err := ListLocks(ctx, l.client, l.Lock.Info.Project)
+	if err != nil {
+		return fmt.Errorf("check lock %s: %w", l.Lock.Name, err)
+	}
+	for _, lock := range locks {
+		if lock.ID == l.Lock.ID {
+			return nil
+		}
+	}
+	l.lost = fmt.Errorf("%w: %s was removed", ErrLockLost, l.Lock.Name)
+	return l.lost
+}
+
+// LockHolder describes the current process as user@host (pid N).
+func LockHolder() string {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		name = current.Username
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+}
+
+// ListLocks returns the apply locks held for project, sorted by name.
+func ListLocks(ctx context.Context, client swarm.Client, project string) ([]ClusterLock, error) {
+	locks, err := client.ListLocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []ClusterLock
+	for _, lock := range locks {
+		if lock.Labels[render.LabelProject] != project {
+			continue
+		}
+		out = append(out, decodeLock(lock))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// AcquireLock takes the apply lock for scope, waiting up to timeout for an
+// overlapping lock to be released or to expire. Expired locks are removed.
+// The returned lease renews itself until it is released.
+func AcquireLock(ctx context.Context, client swarm.Client, scope LockScope, command string, ttl time.Duration, timeout time.Duration) (*Lease, error) {
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		lease, held, err := tryAcquireLock(ctx, client, scope, command, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if lease != nil {
+			go lease.keepAlive()
+			return lease, nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrLockHeld, held.Describe())
+		}
+		timer := time.NewTimer(min(remaining, lockPollInterval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// BreakLock forcibly removes the lock held at exactly scope.
+func BreakLock(ctx context.Context, client swarm.Client, scope LockScope) (ClusterLock, error) {
+	locks, err := ListLocks(ctx, client, scope.Project)
+	if err != nil {
+		return ClusterLock{}, err
+	}
+	for _, lock := range locks {
+		if lock.Info.Scope() != scope {
+			continue
+		}
+		if err := client.RemoveLock(ctx, lock.ID); err != nil {
+			return ClusterLock{}, fmt.Errorf("break lock %s: %w", lock.Name, err)
+		}
+		return lock, nil
+	}
+	return ClusterLock{}, fmt.Errorf("no lock held for %s", scope)
+}
+
+func tryAcquireLock(ctx context.Context, client swarm.Client, scope LockScope, command string, ttl time.Duration) (*Lease, ClusterLock, error) {
+	now := time.Now().UTC()
+	if held, ok, err := overlappingLock(ctx, client, scope, ""); err != nil || ok {
+		return nil, held, err
+	}
+	info := LockInfo{
+		Project:   scope.Project,
+		Partition: scope.Partition,
+		Holder:    LockHolder(),
+		Command:   command,
+		StartedAt: now,
+		TTL:       ttl.String(),
+		ExpiresAt: now.Add(ttl),
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, ClusterLock{}, err
+	}
+	id, err := client.CreateLock(ctx, swarm.LockSpec{Name: scope.Name(), Labels: lockLabels(scope), Data: data})
+	if err != nil {
+		if errors.Is(err, swarm.ErrLockExists) {
+			held, _, err := overlappingLock(ctx, client, scope, "")
+			if err == nil && held.Name == "" {
+				held = ClusterLock{Name: scope.Name(), Info: LockInfo{Project: scope.Project, Partition: scope.Partition, Holder: "unknown"}}
+			}
+			return nil, held, err
+		}
+		return nil, ClusterLock{}, fmt.Errorf("create lock %s: %w", scope.Name(), err)
+	}
+	// A project lock and a partition lock have different names, so both
+	// creates can succeed concurrently. The older lock wins; the newer one
+	// backs off.
+	held, ok, err := overlappingLock(ctx, client, scope, id)
+	if err != nil || ok {
+		_ = client.RemoveLock(ctx, id)
+		return nil, held, err
+	}
+	return newLease(client, ClusterLock{ID: id, Name: scope.Name(), CreatedAt: now, Info: info}, ttl), ClusterLock{}, nil
+}
+
+func lockLabels(scope LockScope) map[string]string {
+	labels := map[string]string{render.LabelProject: scope.Project}
+	if scope.Partition != "" {
+		labels[render.LabelPartition] = scope.Partition
+	}
+	return labels
+}
+
+// overlappingLock finds a live lock overlapping scope, removing expired locks
+// along the way. When ownID is set, only locks older than that lock count.
+// Locks listed in ignore are the caller's own and never count.
+func overlappingLock(ctx context.Context, client swarm.Client, scope LockScope, ownID string, ignore ...string) (ClusterLock, bool, error) {
+	locks, err := ListLocks(ctx, client, scope.Project)
+	if err != nil {
+		return ClusterLock{}, false, err
+	}
+	var own *ClusterLock
+	for i := range locks {
+		if locks[i].ID == ownID {
+			own = &locks[i]
+		}
+	}
+	now := time.Now()
+	for _, lock := range locks {
+		if lock.ID == ownID || slices.Contains(ignore, lock.ID) || !lock.Info.Scope().Overlaps(scope) {
+			continue
+		}
+		if lock.Expired(now) {
+			if err := client.RemoveLock(ctx, lock.ID); err != nil {
+				return ClusterLock{}, false, fmt.Errorf("remove expired lock %s: %w", lock.Name, err)
+			}
+			continue
+		}
+		if own != nil && !lockOlder(lock, *own) {
+			continue
+		}
+		return lock, true, nil
+	}
+	return ClusterLock{}, false, nil
+}
+
+// lockOlder orders locks by when their lease started, which renewals keep,
+// then by when their config was created.
+func lockOlder(left, right ClusterLock) bool {
+	if !left.Info.StartedAt.Equal(right.Info.StartedAt) {
+		return left.Info.StartedAt.Before(right.Info.StartedAt)
+	}
+	if !left.CreatedAt.Equal(right.CreatedAt) {
+		return left.CreatedAt.Before(right.CreatedAt)
+	}
+	return left.ID < right.ID
+}
+
+func decodeLock(lock swarm.Lock) ClusterLock {
+	out := ClusterLock{ID: lock.ID, Name: lock.Name, CreatedAt: lock.CreatedAt}
+	if err := json.Unmarshal(lock.Data, &out.Info); err != nil || strings.TrimSpace(out.Info.Project) == "" {
+		// Unreadable payloads still block: fall back to the labels and treat
+		// the lock as held until broken.
+		out.Info = LockInfo{
+			Project:   lock.Labels[render.LabelProject],
+			Partition: lock.Labels[render.LabelPartition],
+			Holder:    "unknown",
+			StartedAt: lock.CreatedAt,
+		}
+	}
+	return out
+}
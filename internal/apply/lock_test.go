@@ -0,0 +1,208 @@
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/render"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+)
+
+func TestAcquireLockRejectsOverlappingScopes(t *testing.T) {
+	client := &fakeClient{}
+	ctx := context.Background()
+
+	lease, err := AcquireLock(ctx, client, LockScope{Project: "demo", Partition: "dev"}, "apply", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquire partition lock: %v", err)
+	}
+	if _, err := AcquireLock(ctx, client, LockScope{Project: "demo", Partition: "qa"}, "apply", time.Minute, 0); err != nil {
+		t.Fatalf("acquire disjoint partition lock: %v", err)
+	}
+	if _, err := AcquireLock(ctx, client, LockScope{Project: "demo"}, "apply", time.Minute, 0); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("expected project lock to be blocked, got %v", err)
+	}
+	if _, err := AcquireLock(ctx, client, LockScope{Project: "demo", Partition: "dev"}, "apply", time.Minute, 0); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("expected same partition lock to be blocked, got %v", err)
+	}
+	if _, err := AcquireLock(ctx, client, LockScope{Project: "other"}, "apply", time.Minute, 0); err != nil {
+		t.Fatalf("acquire other project lock: %v", err)
+	}
+
+	if err := lease.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	locks, err := ListLocks(ctx, client, "demo")
+	if err != nil {
+		t.Fatalf("list locks: %v", err)
+	}
+	if len(locks) != 1 || locks[0].Info.Partition != "qa" {
+		t.Fatalf("expected only the qa lock to remain, got %#v", locks)
+	}
+}
+
+func TestAcquireLockRemovesExpiredLock(t *testing.T) {
+	started := time.Now().Add(-time.Hour).UTC()
+	data, err := json.Marshal(LockInfo{Project: "demo", Holder: "ci", Command: "apply", StartedAt: started, TTL: "30m0s", ExpiresAt: started.Add(30 * time.Minute)})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	client := &fakeClient{locks: []swarm.Lock{{
+		ID:        "stale",
+		Name:      LockScope{Project: "demo"}.Name(),
+		Labels:    map[string]string{swarm.LabelLock: "true", render.LabelProject: "demo"},
+		Data:      data,
+		CreatedAt: started,
+	}}}
+
+	lease, err := AcquireLock(context.Background(), client, LockScope{Project: "demo", Partition: "dev"}, "apply", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if len(client.locks) != 1 || client.locks[0].ID != lease.Lock.ID {
+		t.Fatalf("expected expired lock to be replaced, got %#v", client.locks)
+	}
+}
+
+func TestAcquireLockWaitsForTimeout(t *testing.T) {
+	client := &fakeClient{}
+	ctx := context.Background()
+	if _, err := AcquireLock(ctx, client, LockScope{Project: "demo"}, "apply", time.Minute, 0); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	start := time.Now()
+	_, err := AcquireLock(ctx, client, LockScope{Project: "demo"}, "apply", time.Minute, 50*time.Millisecond)
+	if !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("expected lock held error, got %v", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatalf("expected acquire to wait for the timeout")
+	}
+}
+
+func TestBreakLock(t *testing.T) {
+	client := &fakeClient{}
+	ctx := context.Background()
+	if _, err := AcquireLock(ctx, client, LockScope{Project: "demo", Partition: "dev"}, "apply", time.Minute, 0); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := BreakLock(ctx, client, LockScope{Project: "demo"}); err == nil {
+		t.Fatalf("expected break of unheld project lock to fail")
+	}
+	broken, err := BreakLock(ctx, client, LockScope{Project: "demo", Partition: "dev"})
+	if err != nil {
+		t.Fatalf("break: %v", err)
+	}
+	if broken.Info.Command != "apply" || len(client.locks) != 0 {
+		t.Fatalf("unexpected break result %#v, remaining %#v", broken, client.locks)
+	}
+}
+
+func TestLeaseRenewExtendsExpiry(t *testing.T) {
+	client := &fakeClient{}
+	ctx := context.Background()
+	lease, err := AcquireLock(ctx, client, LockScope{Project: "demo"}, "apply", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	before := lease.Lock.Info.ExpiresAt
+	time.Sleep(time.Millisecond)
+	if err := lease.renew(ctx); err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	locks, err := ListLocks(ctx, client, "demo")
+	if err != nil {
+		t.Fatalf("list locks: %v", err)
+	}
+	if len(locks) != 1 || !locks[0].Info.ExpiresAt.After(before) || !locks[0].Info.StartedAt.Equal(lease.Lock.Info.StartedAt) {
+		t.Fatalf("expected renewed lock with a later expiry, got %#v", locks)
+	}
+	if err := lease.Check(ctx); err != nil {
+		t.Fatalf("expected renewed lease to be held, got %v", err)
+	}
+}
+
+func TestLeaseCheckDetectsBrokenLock(t *testing.T) {
+	client := &fakeClient{}
+	ctx := context.Background()
+	lease, err := AcquireLock(ctx, client, LockScope{Project: "demo"}, "apply", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := BreakLock(ctx, client, LockScope{Project: "demo"}); err != nil {
+		t.Fatalf("break: %v", err)
+	}
+	if _, err := AcquireLock(ctx, client, LockScope{Project: "demo"}, "apply", time.Minute, 0); err != nil {
+		t.Fatalf("acquire after break: %v", err)
+	}
+	if err := lease.Check(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected lost lease, got %v", err)
+	}
+	if err := lease.renew(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("expected renew of lost lease to fail, got %v", err)
+	}
+	if len(client.locks) != 1 {
+		t.Fatalf("expected the new holder's lock to survive, got %#v", client.locks)
+	}
+}
+
+// competingLockClient tries to take the project lock for another apply
+// before every lock create and remove the lease makes.
+type competingLockClient struct {
+	*fakeClient
+	t        *testing.T
+	attempts int
+}
+
+func (c *competingLockClient) competeFor(ctx context.Context) {
+	c.attempts++
+	if lease, _, err := tryAcquireLock(ctx, c.fakeClient, LockScope{Project: "demo"}, "other apply", time.Minute); err != nil || lease != nil {
+		c.t.Fatalf("expected competing acquire to back off during renewal, got lease %v err %v", lease, err)
+	}
+}
+
+func (c *competingLockClient) CreateLock(ctx context.Context, spec swarm.LockSpec) (string, error) {
+	c.competeFor(ctx)
+	id, err := c.fakeClient.CreateLock(ctx, spec)
+	c.competeFor(ctx)
+	return id, err
+}
+
+func (c *competingLockClient) RemoveLock(ctx context.Context, id string) error {
+	c.competeFor(ctx)
+	err := c.fakeClient.RemoveLock(ctx, id)
+	c.competeFor(ctx)
+	return err
+}
+
+func TestLeaseRenewKeepsLockHeldAgainstCompetingAcquire(t *testing.T) {
+	ctx := context.Background()
+	client := &competingLockClient{fakeClient: &fakeClient{}, t: t}
+	lease, err := AcquireLock(ctx, client.fakeClient, LockScope{Project: "demo", Partition: "dev"}, "apply", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	lease.client = client
+	for range 2 {
+		if err := lease.renew(ctx); err != nil {
+			t.Fatalf("renew: %v", err)
+		}
+	}
+	if client.attempts == 0 {
+		t.Fatalf("expected competing acquires during renewal")
+	}
+	if err := lease.Check(ctx); err != nil {
+		t.Fatalf("expected lease to survive competing acquires, got %v", err)
+	}
+	locks, err := ListLocks(ctx, client, "demo")
+	if err != nil {
+		t.Fatalf("list locks: %v", err)
+	}
+	scope := LockScope{Project: "demo", Partition: "dev"}
+	if len(locks) != 1 || locks[0].ID != lease.Lock.ID || locks[0].Name != scope.generationName(2) {
+		t.Fatalf("expected only the renewed lock to remain, got %#v", locks)
+	}
+}
@@ -0,0 +1,71 @@
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+type composeLogging struct {
+	Driver  string            `yaml:"driver"`
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+func swarmLogDriver(logging *config.Logging) (*dockerapi.Driver, error) {
+	if logging == nil {
+		return nil, nil
+	}
+	if logging.Driver == "" {
+		if len(logging.Options) > 0 {
+			return nil, fmt.Errorf("logging.options: a logging driver is required")
+		}
+		return nil, nil
+	}
+	return &dockerapi.Driver{Name: logging.Driver, Options: cloneLabels(logging.Options)}, nil
+}
+
+func composeLoggingSpec(logging *config.Logging) *composeLogging {
+	if logging == nil || logging.Driver == "" {
+		return nil
+	}
+	return &composeLogging{Driver: logging.Driver, Options: cloneLabels(logging.Options)}
+}
+
+func cloneLogDriver(driver *dockerapi.Driver) *dockerapi.Driver {
+	if driver == nil || driver.Name == "" {
+		return nil
+	}
+	return &dockerapi.Driver{Name: driver.Name, Options: cloneLabels(driver.Options)}
+}
+
+func logDriversEqual(left, right *dockerapi.Driver) bool {
+	return formatLogDriver(left) == formatLogDriver(right)
+}
+
+func formatLogDriver(driver *dockerapi.Driver) string {
+	driver = cloneLogDriver(driver)
+	if driver == nil {
+		return "{}"
+	}
+	return formatDriver(driver.Name, driver.Options)
+}
+
+// formatDriver renders a driver name with its options sorted by key.
+func formatDriver(name string, options map[string]string) string {
+	if len(options) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+"="+options[key])
+	}
+	return name + " {" + strings.Join(parts, ", ") + "}"
+}
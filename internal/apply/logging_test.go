@@ -0,0 +1,71 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func TestBuildStackDeploysMergesLogging(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:       "proj",
+			Partitions: []string{"dev"},
+			Defaults: config.ProjectDefaults{
+				Logging: &config.Logging{Driver: "json-file", Options: map[string]string{"max-size": "10m"}},
+			},
+		},
+		Stacks: map[string]config.Stack{
+			"app": {
+				Mode: "partitioned",
+				Partitions: map[string]config.StackPartition{
+					"dev": {Logging: &config.Logging{Options: map[string]string{"max-file": "3"}}},
+				},
+				Services: map[string]config.Service{
+					"api": {Image: "api:latest"},
+					"web": {
+						Image:   "web:latest",
+						Logging: &config.Logging{Driver: "gelf", Options: map[string]string{"tag": "{service}"}},
+					},
+				},
+			},
+		},
+	}
+	deploys, err := BuildStackDeploys(cfg, DesiredState{}, nil, nil, nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildStackDeploys: %v", err)
+	}
+	if len(deploys) != 1 {
+		t.Fatalf("expected one stack deploy, got %d", len(deploys))
+	}
+	want := map[string]string{
+		"api": "json-file {max-file=3, max-size=10m}",
+		"web": "gelf {tag=web}",
+	}
+	for name, expected := range want {
+		if got := formatLogDriver(deploys[0].Services[name].LogDriver); got != expected {
+			t.Fatalf("unexpected %s logging %q, want %q", name, got, expected)
+		}
+	}
+}
+
+func TestIntentDiffsLogging(t *testing.T) {
+	current := serviceIntent{LogDriver: &dockerapi.Driver{Name: "json-file", Options: map[string]string{"max-size": "10m"}}}
+	desired := serviceIntent{LogDriver: &dockerapi.Driver{Name: "json-file", Options: map[string]string{"max-size": "10m"}}}
+	if diffs := intentDiffs(current, desired); stringSliceContains(diffs, "logging") {
+		t.Fatalf("expected matching log drivers, got %v", diffs)
+	}
+	desired.LogDriver.Options["max-size"] = "50m"
+	diffs := intentDiffs(current, desired)
+	if !stringSliceContains(diffs, "logging") {
+		t.Fatalf("expected logging diff, got %v", diffs)
+	}
+	details := intentDetails(current, desired, diffs)
+	if len(details) != 1 || details[0].Current != "json-file {max-size=10m}" || details[0].Desired != "json-file {max-size=50m}" {
+		t.Fatalf("unexpected details: %+v", details)
+	}
+	if _, err := swarmLogDriver(&config.Logging{Options: map[string]string{"tag": "api"}}); err == nil {
+		t.Fatalf("expected options without a driver to fail")
+	}
+}
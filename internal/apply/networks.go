@@ -1,11 +1,15 @@
 package apply
 
 import (
+	"context"
 	"fmt"
+	"net/netip"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/render"
 	"github.com/cmmoran/swarmcp/internal/sliceutil"
 	"github.com/cmmoran/swarmcp/internal/swarm"
 )
@@ -16,6 +20,7 @@ func buildDesiredNetworks(cfg *config.Config, partitionFilters []string, stackFi
 		return nil
 	}
 	attachable := cfg.Project.Defaults.Networks.Attachable
+	subnets, _ := config.NetworkSubnets(cfg)
 	out := make([]swarm.NetworkSpec, 0, len(serviceNetworks))
 	names := make([]string, 0, len(serviceNetworks))
 	for name := range serviceNetworks {
@@ -27,16 +32,269 @@ func buildDesiredNetworks(cfg *config.Config, partitionFilters []string, stackFi
 		if name == egressNetworkName(cfg) {
 			internal = false
 		}
+		options, ipam := networkOptions(cfg, name, subnets)
 		out = append(out, swarm.NetworkSpec{
 			Name:       name,
 			Driver:     "overlay",
 			Attachable: attachable,
 			Internal:   internal,
+			Labels:     managedNetworkLabels(cfg.Project.Name),
+			Options:    options,
+			IPAM:       ipam,
 		})
 	}
 	return out
 }
 
+func managedNetworkLabels(projectName string) map[string]string {
+	return map[string]string{
+		render.LabelManaged: "true",
+		render.LabelProject: projectName,
+	}
+}
+
+// staleNetworks returns the project's managed networks that no service of
+// the current deployment uses anymore, and how many of those were skipped
+// because services or containers are still attached. Network lists do not
+// report containers, so every candidate is inspected.
+func staleNetworks(ctx context.Context, client swarm.Client, cfg *config.Config, existing []swarm.Network, services []swarm.Service) ([]swarm.Network, int, error) {
+	desired := allServiceNetworks(cfg)
+	attached := attachedNetworkNames(existing, services)
+	var stale []swarm.Network
+	skipped := 0
+	for _, net := range existing {
+		if !isManagedProject(net.Labels, cfg.Project.Name) {
+			continue
+		}
+		if _, ok := desired[net.Name]; ok {
+			continue
+		}
+		if _, ok := attached[net.Name]; ok {
+			skipped++
+			continue
+		}
+		containers, err := client.NetworkContainers(ctx, net.ID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("inspect network %q: %w", net.Name, err)
+		}
+		if containers > 0 {
+			skipped++
+			continue
+		}
+		stale = append(stale, net)
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+	return stale, skipped, nil
+}
+
+// allServiceNetworks collects every network used by the deployment,
+// ignoring partition and stack selectors, including ephemeral networks.
+func allServiceNetworks(cfg *config.Config) map[string]struct{} {
+	out := make(map[string]struct{})
+	for stackName, stack := range cfg.Stacks {
+		if !cfg.StackSelectedForRuntime(stackName, nil) {
+			continue
+		}
+		partitions := []string{""}
+		if stack.Mode == "partitioned" && len(cfg.Project.Partitions) > 0 {
+			partitions = cfg.StackRuntimePartitions(stackName, nil)
+		}
+		for _, partitionName := range partitions {
+			services, err := cfg.StackServices(stackName, partitionName)
+			if err != nil {
+				continue
+			}
+			for serviceName, service := range services {
+				for _, network := range desiredServiceNetworks(cfg, stackName, stack.Mode, partitionName, serviceName, service) {
+					out[network] = struct{}{}
+				}
+			}
+		}
+	}
+	return out
+}
+
+func attachedNetworkNames(networks []swarm.Network, services []swarm.Service) map[string]struct{} {
+	targets := buildNetworkTargetIndex(networks)
+	out := make(map[string]struct{})
+	for _, svc := range services {
+		for _, attachment := range svc.Spec.TaskTemplate.Networks {
+			if name, ok := targets[attachment.Target]; ok {
+				out[name] = struct{}{}
+			}
+		}
+	}
+	return out
+}
+
+const (
+	networkOptionEncrypted = "encrypted"
+	networkOptionMTU       = "com.docker.network.driver.mtu"
+)
+
+// networkOptions translates the effective network settings into overlay
+// driver options and IPAM config. Networks without pinned IPAM use their
+// address pool subnet, if any.
+func networkOptions(cfg *config.Config, name string, subnets map[string]string) (map[string]string, []swarm.NetworkIPAM) {
+	settings := config.NetworkSettingsFor(cfg, name)
+	options := make(map[string]string, len(settings.DriverOpts)+2)
+	for key, value := range settings.DriverOpts {
+		options[key] = value
+	}
+	if settings.Encrypted != nil && *settings.Encrypted {
+		options[networkOptionEncrypted] = ""
+	}
+	if settings.MTU > 0 {
+		options[networkOptionMTU] = strconv.Itoa(settings.MTU)
+	}
+	if len(options) == 0 {
+		options = nil
+	}
+	var ipam []swarm.NetworkIPAM
+	for _, entry := range settings.IPAM {
+		ipam = append(ipam, swarm.NetworkIPAM{
+			Subnet:  strings.TrimSpace(entry.Subnet),
+			Gateway: strings.TrimSpace(entry.Gateway),
+			IPRange: strings.TrimSpace(entry.IPRange),
+		})
+	}
+	if len(ipam) == 0 && subnets[name] != "" {
+		ipam = []swarm.NetworkIPAM{{Subnet: subnets[name]}}
+	}
+	return options, ipam
+}
+
+// NetworkDrifts compares desired networks with the existing networks of the
+// same name; missing networks are not reported.
+func NetworkDrifts(desired []swarm.NetworkSpec, existing []swarm.Network) []NetworkDrift {
+	byName := make(map[string]swarm.Network, len(existing))
+	for _, net := range existing {
+		byName[net.Name] = net
+	}
+	var out []NetworkDrift
+	for _, net := range desired {
+		current, ok := byName[net.Name]
+		if !ok {
+			continue
+		}
+		if diffs := networkDrift(net, current); len(diffs) > 0 {
+			out = append(out, NetworkDrift{Name: net.Name, Diffs: diffs})
+		}
+	}
+	return out
+}
+
+// networkDrift lists the settings of an existing network that differ from
+// the desired spec. Swarm networks are immutable, so any drift means the
+// network has to be recreated. Options Docker adds on its own and IPAM
+// fields left to Docker are not compared.
+func networkDrift(desired swarm.NetworkSpec, existing swarm.Network) []string {
+	var diffs []string
+	if desired.Internal != existing.Internal {
+		diffs = append(diffs, "internal")
+	}
+	if desired.Attachable != existing.Attachable {
+		diffs = append(diffs, "attachable")
+	}
+	_, wantEncrypted := desired.Options[networkOptionEncrypted]
+	_, hasEncrypted := existing.Options[networkOptionEncrypted]
+	if wantEncrypted != hasEncrypted {
+		diffs = append(diffs, "encrypted")
+	}
+	keys := make([]string, 0, len(desired.Options))
+	for key := range desired.Options {
+		if key != networkOptionEncrypted {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value, ok := existing.Options[key]; !ok || value != desired.Options[key] {
+			if key == networkOptionMTU {
+				diffs = append(diffs, "mtu")
+				continue
+			}
+			diffs = append(diffs, "driver_opts."+key)
+		}
+	}
+	if len(desired.IPAM) > 0 && !networkIPAMMatches(desired.IPAM, existing.IPAM) {
+		diffs = append(diffs, "ipam")
+	}
+	return diffs
+}
+
+func networkIPAMMatches(desired []swarm.NetworkIPAM, existing []swarm.NetworkIPAM) bool {
+	if len(desired) != len(existing) {
+		return false
+	}
+	for _, want := range desired {
+		found := false
+		for _, have := range existing {
+			if want.Subnet != have.Subnet {
+				continue
+			}
+			if want.Gateway != "" && want.Gateway != have.Gateway {
+				continue
+			}
+			if want.IPRange != "" && want.IPRange != have.IPRange {
+				continue
+			}
+			found = true
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatNetworkDrift renders a drifted network for status output.
+func FormatNetworkDrift(drift NetworkDrift) string {
+	return fmt.Sprintf("%s (%s) recreate required", drift.Name, strings.Join(drift.Diffs, ", "))
+}
+
+// NetworkSubnetConflicts reports desired networks that do not exist yet
+// whose subnets overlap the subnet of an existing network.
+func NetworkSubnetConflicts(desired []swarm.NetworkSpec, existing []swarm.Network) []NetworkConflict {
+	byName := make(map[string]struct{}, len(existing))
+	for _, net := range existing {
+		byName[net.Name] = struct{}{}
+	}
+	var out []NetworkConflict
+	for _, net := range desired {
+		if _, ok := byName[net.Name]; ok {
+			continue
+		}
+		for _, entry := range net.IPAM {
+			subnet, err := netip.ParsePrefix(entry.Subnet)
+			if err != nil {
+				continue
+			}
+			for _, current := range existing {
+				for _, raw := range current.Subnets {
+					other, err := netip.ParsePrefix(raw)
+					if err != nil || !subnet.Overlaps(other) {
+						continue
+					}
+					out = append(out, NetworkConflict{
+						Name:           net.Name,
+						Subnet:         entry.Subnet,
+						Existing:       current.Name,
+						ExistingSubnet: raw,
+					})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// FormatNetworkConflict renders a subnet conflict for status output.
+func FormatNetworkConflict(conflict NetworkConflict) string {
+	return fmt.Sprintf("%s (%s) overlaps existing network %s (%s)", conflict.Name, conflict.Subnet, conflict.Existing, conflict.ExistingSubnet)
+}
+
 func collectServiceNetworks(cfg *config.Config, partitionFilters []string, stackFilters []string) map[string]struct{} {
 	out := make(map[string]struct{})
 	for stackName, stack := range cfg.Stacks {
@@ -87,7 +345,7 @@ func desiredServiceExternalNetworks(cfg *config.Config, stackName string, stackM
 		networks = append(networks, config.SharedNetworkNames(cfg, partitionName)...)
 	}
 	if partitionName != "" {
-		internal := internalNetworkName(cfg, partitionName)
+		internal := config.InternalNetworkName(cfg, partitionName)
 		if internal != "" {
 			networks = append(networks, internal)
 		}
@@ -123,14 +381,6 @@ func stackNetworkName(projectName, stackName, mode, partition string) string {
 	return config.StackInstanceName(projectName, stackName, partition, mode)
 }
 
-func internalNetworkName(cfg *config.Config, partition string) string {
-	base := strings.TrimSpace(cfg.Project.Defaults.Networks.Internal)
-	if base == "" {
-		base = fmt.Sprintf("%s_<partition>_internal", cfg.Project.Name)
-	}
-	return config.RenderNetworkTemplate(base, cfg.Project.Name, partition)
-}
-
 func egressNetworkName(cfg *config.Config) string {
 	base := strings.TrimSpace(cfg.Project.Defaults.Networks.Egress)
 	if base == "" {
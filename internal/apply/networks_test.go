@@ -194,3 +194,110 @@ func networkNames(specs []swarm.NetworkSpec) []string {
 	}
 	return out
 }
+
+func TestNetworkOptionsAndDrift(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:       "primary",
+			Partitions: []string{"dev", "qa"},
+			Defaults: config.ProjectDefaults{
+				Networks: config.NetworkDefaults{
+					Encrypted:  true,
+					MTU:        1450,
+					DriverOpts: map[string]string{"com.docker.network.driver.overlay.vxlanid_list": "4097"},
+					Settings: map[string]config.NetworkSettings{
+						"<project>_<partition>_internal": {
+							IPAM: []config.NetworkIPAM{{Subnet: "10.20.0.0/24", Gateway: "10.20.0.1"}},
+						},
+						"primary_egress": {Encrypted: new(false)},
+					},
+				},
+			},
+		},
+	}
+
+	options, ipam := networkOptions(cfg, "primary_dev_internal", nil)
+	wantOptions := map[string]string{
+		"encrypted":                     "",
+		"com.docker.network.driver.mtu": "1450",
+		"com.docker.network.driver.overlay.vxlanid_list": "4097",
+	}
+	if !reflect.DeepEqual(options, wantOptions) {
+		t.Fatalf("unexpected options: %#v", options)
+	}
+	if !reflect.DeepEqual(ipam, []swarm.NetworkIPAM{{Subnet: "10.20.0.0/24", Gateway: "10.20.0.1"}}) {
+		t.Fatalf("unexpected ipam: %#v", ipam)
+	}
+	if options, _ := networkOptions(cfg, "primary_egress", nil); hasOption(options, networkOptionEncrypted) {
+		t.Fatalf("expected egress network to be unencrypted, got %#v", options)
+	}
+
+	desired := swarm.NetworkSpec{Name: "primary_dev_internal", Internal: true, Options: options, IPAM: ipam}
+	existing := swarm.Network{
+		Name:     "primary_dev_internal",
+		Internal: true,
+		Options: map[string]string{
+			"encrypted":                     "",
+			"com.docker.network.driver.mtu": "1450",
+			"com.docker.network.driver.overlay.vxlanid_list": "4097",
+		},
+		IPAM: []swarm.NetworkIPAM{{Subnet: "10.20.0.0/24", Gateway: "10.20.0.1"}},
+	}
+	if diffs := networkDrift(desired, existing); len(diffs) != 0 {
+		t.Fatalf("expected no drift, got %v", diffs)
+	}
+	existing.Options = map[string]string{"com.docker.network.driver.overlay.vxlanid_list": "4097"}
+	existing.IPAM = []swarm.NetworkIPAM{{Subnet: "10.0.3.0/24", Gateway: "10.0.3.1"}}
+	drifts := NetworkDrifts([]swarm.NetworkSpec{desired}, []swarm.Network{existing})
+	if len(drifts) != 1 || !reflect.DeepEqual(drifts[0].Diffs, []string{"encrypted", "mtu", "ipam"}) {
+		t.Fatalf("unexpected drift: %#v", drifts)
+	}
+}
+
+func hasOption(options map[string]string, key string) bool {
+	_, ok := options[key]
+	return ok
+}
+
+func TestDesiredNetworksUseAddressPool(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:       "demo",
+			Partitions: []string{"dev"},
+			Defaults: config.ProjectDefaults{
+				Networks: config.NetworkDefaults{
+					AddressPool: &config.NetworkAddressPool{Base: "10.80.0.0/16", Size: 24},
+				},
+			},
+		},
+		Stacks: map[string]config.Stack{
+			"app": {Mode: "partitioned", Services: map[string]config.Service{"web": {Image: "web"}}},
+		},
+	}
+	subnets, err := config.NetworkSubnets(cfg)
+	if err != nil {
+		t.Fatalf("NetworkSubnets: %v", err)
+	}
+	desired := buildDesiredNetworks(cfg, nil, nil)
+	if len(desired) != 2 {
+		t.Fatalf("unexpected networks: %v", networkNames(desired))
+	}
+	for _, net := range desired {
+		if len(net.IPAM) != 1 || net.IPAM[0].Subnet != subnets[net.Name] {
+			t.Fatalf("expected %s to use pool subnet %s, got %#v", net.Name, subnets[net.Name], net.IPAM)
+		}
+	}
+
+	existing := []swarm.Network{
+		{Name: desired[0].Name, Subnets: []string{desired[0].IPAM[0].Subnet}},
+		{Name: "corp", Subnets: []string{"10.80.0.0/16"}},
+	}
+	conflicts := NetworkSubnetConflicts(desired, existing)
+	if len(conflicts) != 1 || conflicts[0].Name != desired[1].Name || conflicts[0].Existing != "corp" {
+		t.Fatalf("unexpected conflicts: %#v", conflicts)
+	}
+	want := desired[1].Name + " (" + desired[1].IPAM[0].Subnet + ") overlaps existing network corp (10.80.0.0/16)"
+	if got := FormatNetworkConflict(conflicts[0]); got != want {
+		t.Fatalf("unexpected conflict line: %q", got)
+	}
+}
@@ -0,0 +1,77 @@
+package apply
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/notify"
+	"github.com/cmmoran/swarmcp/internal/secrets"
+)
+
+// ResolveNotificationSinks resolves project.notifications, looking up
+// url_secret through the secrets file or secrets engine.
+func ResolveNotificationSinks(cfg *config.Config, store *secrets.Store) ([]notify.Sink, error) {
+	return resolveNotificationSinks(cfg, store, slices.Sorted(maps.Keys(cfg.Project.Notifications)))
+}
+
+// PlanNotificationSources lists project.notifications by name and type for a
+// plan artifact. Targets, commands and headers are not recorded; apply
+// rebuilds the sinks from the project config it loads.
+func PlanNotificationSources(cfg *config.Config) []PlanNotificationSource {
+	if len(cfg.Project.Notifications) == 0 {
+		return nil
+	}
+	sources := make([]PlanNotificationSource, 0, len(cfg.Project.Notifications))
+	for _, name := range slices.Sorted(maps.Keys(cfg.Project.Notifications)) {
+		sources = append(sources, PlanNotificationSource{Name: name, Type: cfg.Project.Notifications[name].Type})
+	}
+	return sources
+}
+
+// ResolvePlanNotificationSinks resolves the sinks a plan artifact names from
+// the loaded project config. Names the project no longer declares are
+// returned as missing.
+func ResolvePlanNotificationSinks(cfg *config.Config, store *secrets.Store, planFile PlanFile) ([]notify.Sink, []string, error) {
+	var names, missing []string
+	for _, source := range planFile.NotificationSources {
+		if _, ok := cfg.Project.Notifications[source.Name]; ok {
+			names = append(names, source.Name)
+		} else {
+			missing = append(missing, source.Name)
+		}
+	}
+	sinks, err := resolveNotificationSinks(cfg, store, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sinks, missing, nil
+}
+
+func resolveNotificationSinks(cfg *config.Config, store *secrets.Store, names []string) ([]notify.Sink, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var resolve func(name string) (secrets.ResolvedSecret, PlanSecretDependency, error)
+	sinks := make([]notify.Sink, 0, len(names))
+	for _, name := range names {
+		notification := cfg.Project.Notifications[name]
+		sink := notify.Sink{Name: name, Notification: notification, URL: notification.URL, Dir: cfg.BaseDir}
+		if notification.URLSecret != "" {
+			if resolve == nil {
+				var err error
+				if resolve, err = projectSecretResolver(cfg, store); err != nil {
+					return nil, err
+				}
+			}
+			resolved, _, err := resolve(notification.URLSecret)
+			if err != nil {
+				return nil, fmt.Errorf("project.notifications.%s.url_secret: %w", name, err)
+			}
+			sink.URL = resolved.Value
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
@@ -0,0 +1,148 @@
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+type composePlacementPreference struct {
+	Spread string `yaml:"spread"`
+}
+
+type composePlatform struct {
+	OS           string `yaml:"os"`
+	Architecture string `yaml:"architecture,omitempty"`
+}
+
+func composePlacementSpec(constraints []string, placement config.Placement) *composePlacement {
+	out := &composePlacement{Constraints: cloneStrings(constraints)}
+	for _, spread := range placementPreferences(placement.Preferences) {
+		out.Preferences = append(out.Preferences, composePlacementPreference{Spread: spread})
+	}
+	if placement.MaxReplicasPerNode != nil && *placement.MaxReplicasPerNode > 0 {
+		out.MaxReplicasPerNode = uint64(*placement.MaxReplicasPerNode)
+	}
+	for _, platform := range placement.Platforms {
+		out.Platforms = append(out.Platforms, composePlatform{
+			OS:           strings.TrimSpace(platform.OS),
+			Architecture: strings.TrimSpace(platform.Arch),
+		})
+	}
+	if len(out.Constraints) == 0 && len(out.Preferences) == 0 && out.MaxReplicasPerNode == 0 && len(out.Platforms) == 0 {
+		return nil
+	}
+	return out
+}
+
+func placementPreferences(prefs []config.PlacementPreference) []string {
+	var out []string
+	for _, pref := range prefs {
+		if spread := strings.TrimSpace(pref.Spread); spread != "" {
+			out = append(out, spread)
+		}
+	}
+	return out
+}
+
+// placementPlatforms formats platforms as os[/arch] so they compare and
+// print like the docker CLI shows them.
+func placementPlatforms(platforms []config.NodePlatform) []string {
+	var out []string
+	for _, platform := range platforms {
+		osName := strings.TrimSpace(platform.OS)
+		if osName == "" {
+			continue
+		}
+		if arch := strings.TrimSpace(platform.Arch); arch != "" {
+			osName += "/" + arch
+		}
+		out = append(out, osName)
+	}
+	return out
+}
+
+func preferencesFromSpec(prefs []dockerapi.PlacementPreference) []string {
+	var out []string
+	for _, pref := range prefs {
+		if pref.Spread != nil && pref.Spread.SpreadDescriptor != "" {
+			out = append(out, pref.Spread.SpreadDescriptor)
+		}
+	}
+	return out
+}
+
+func platformsFromSpec(platforms []dockerapi.Platform) []string {
+	var out []string
+	for _, platform := range platforms {
+		if platform.OS == "" {
+			continue
+		}
+		value := platform.OS
+		if platform.Architecture != "" {
+			value += "/" + platform.Architecture
+		}
+		out = append(out, value)
+	}
+	return out
+}
+
+func applyPlacement(current *dockerapi.Placement, intent serviceIntent) *dockerapi.Placement {
+	if len(intent.Constraints) == 0 && len(intent.Preferences) == 0 && intent.MaxReplicas == 0 && len(intent.Platforms) == 0 {
+		return nil
+	}
+	out := &dockerapi.Placement{
+		Constraints: cloneStrings(intent.Constraints),
+		MaxReplicas: intent.MaxReplicas,
+	}
+	for _, spread := range intent.Preferences {
+		out.Preferences = append(out.Preferences, dockerapi.PlacementPreference{
+			Spread: &dockerapi.SpreadOver{SpreadDescriptor: spread},
+		})
+	}
+	for _, platform := range intent.Platforms {
+		osName, arch, _ := strings.Cut(platform, "/")
+		out.Platforms = append(out.Platforms, dockerapi.Platform{OS: osName, Architecture: arch})
+	}
+	return out
+}
+
+// placementEqual compares placement intents. Platforms are only compared when
+// desired declares them: otherwise swarm fills them from the image manifest.
+func placementEqual(current, desired serviceIntent) bool {
+	if !stringSlicesEqual(current.Constraints, desired.Constraints) {
+		return false
+	}
+	if !stringSlicesEqual(current.Preferences, desired.Preferences) {
+		return false
+	}
+	if current.MaxReplicas != desired.MaxReplicas {
+		return false
+	}
+	if len(desired.Platforms) == 0 {
+		return true
+	}
+	return stringSlicesEqual(canonicalizeStringSlice(current.Platforms), canonicalizeStringSlice(desired.Platforms))
+}
+
+func formatPlacement(intent serviceIntent) string {
+	var parts []string
+	if len(intent.Constraints) > 0 {
+		parts = append(parts, "constraints="+formatStringSliceSorted(intent.Constraints))
+	}
+	if len(intent.Preferences) > 0 {
+		parts = append(parts, "spread="+formatStringSlice(intent.Preferences))
+	}
+	if intent.MaxReplicas > 0 {
+		parts = append(parts, fmt.Sprintf("max_replicas_per_node=%d", intent.MaxReplicas))
+	}
+	if len(intent.Platforms) > 0 {
+		platforms := cloneStrings(intent.Platforms)
+		sort.Strings(platforms)
+		parts = append(parts, "platforms="+formatStringSlice(platforms))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -0,0 +1,64 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func TestPlacementRoundTrip(t *testing.T) {
+	service := config.Service{
+		Image: "api:latest",
+		Placement: config.Placement{
+			Preferences:        []config.PlacementPreference{{Spread: "node.labels.zone"}},
+			MaxReplicasPerNode: new(2),
+			Platforms:          []config.NodePlatform{{OS: "linux", Arch: "arm64"}, {OS: "linux"}},
+		},
+	}
+	intent, err := intentFromConfig(service, nil, []string{"node.role==worker"}, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("intentFromConfig: %v", err)
+	}
+	spec := applyIntentToSpec(dockerapi.ServiceSpec{}, intent)
+	placement := spec.TaskTemplate.Placement
+	if placement == nil || placement.MaxReplicas != 2 || len(placement.Preferences) != 1 || placement.Preferences[0].Spread.SpreadDescriptor != "node.labels.zone" {
+		t.Fatalf("unexpected placement: %+v", placement)
+	}
+	if len(placement.Platforms) != 2 || placement.Platforms[0] != (dockerapi.Platform{OS: "linux", Architecture: "arm64"}) || placement.Platforms[1] != (dockerapi.Platform{OS: "linux"}) {
+		t.Fatalf("unexpected platforms: %+v", placement.Platforms)
+	}
+	if !intentEqual(intentFromSpec(spec, nil), intent) {
+		t.Fatalf("expected spec round trip to match intent")
+	}
+
+}
+
+func TestIntentDiffsPlacement(t *testing.T) {
+	current := serviceIntent{
+		Constraints: []string{"node.role==worker"},
+		Platforms:   []string{"linux/amd64", "linux/arm64"},
+	}
+	desired := serviceIntent{Constraints: []string{"node.role==worker"}}
+	if diffs := intentDiffs(current, desired); stringSliceContains(diffs, "placement") {
+		t.Fatalf("expected image-derived platforms to be ignored when none are declared, got %v", diffs)
+	}
+
+	desired.Preferences = []string{"node.labels.zone"}
+	desired.MaxReplicas = 1
+	diffs := intentDiffs(current, desired)
+	if !stringSliceContains(diffs, "placement") {
+		t.Fatalf("expected placement diff, got %v", diffs)
+	}
+	details := intentDetails(current, desired, []string{"placement"})
+	if len(details) != 1 || details[0].Desired != "{constraints=[node.role==worker], spread=[node.labels.zone], max_replicas_per_node=1}" {
+		t.Fatalf("unexpected details: %+v", details)
+	}
+
+	current.Preferences = desired.Preferences
+	current.MaxReplicas = desired.MaxReplicas
+	desired.Platforms = []string{"linux/arm64"}
+	if intentEqual(current, desired) {
+		t.Fatalf("expected declared platforms to be compared")
+	}
+}
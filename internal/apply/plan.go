@@ -2,6 +2,7 @@ package apply
 
 import (
 	"context"
+	"fmt"
 	"sort"
 
 	"github.com/cmmoran/swarmcp/internal/config"
@@ -15,15 +16,18 @@ type Plan struct {
 	CreateNetworks []swarm.NetworkSpec `yaml:"create_networks,omitempty" json:"create_networks,omitempty"`
 	DeleteConfigs  []swarm.Config      `yaml:"delete_configs,omitempty" json:"delete_configs,omitempty"`
 	DeleteSecrets  []swarm.Secret      `yaml:"delete_secrets,omitempty" json:"delete_secrets,omitempty"`
+	DeleteNetworks []swarm.Network     `yaml:"delete_networks,omitempty" json:"delete_networks,omitempty"`
 	SkippedDeletes SkippedDeletes      `yaml:"skipped_deletes,omitempty" json:"skipped_deletes,omitempty"`
 	StackDeploys   []StackDeploy       `yaml:"stack_deploys,omitempty" json:"stack_deploys,omitempty"`
 	PruneStacks    []string            `yaml:"prune_stacks,omitempty" json:"prune_stacks,omitempty"`
+	ImageDigests   map[string]string   `yaml:"image_digests,omitempty" json:"image_digests,omitempty"`
 	Assumptions    PlanAssumptions     `yaml:"assumptions,omitempty" json:"assumptions,omitempty"`
 }
 
 type SkippedDeletes struct {
-	Configs int `yaml:"configs,omitempty" json:"configs,omitempty"`
-	Secrets int `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	Configs  int `yaml:"configs,omitempty" json:"configs,omitempty"`
+	Secrets  int `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	Networks int `yaml:"networks,omitempty" json:"networks,omitempty"`
 }
 
 type PlanAssumptions struct {
@@ -33,6 +37,7 @@ type PlanAssumptions struct {
 	AbsentServices  []string             `yaml:"absent_services,omitempty" json:"absent_services,omitempty"`
 	PresentConfigs  []ResourceAssumption `yaml:"present_configs,omitempty" json:"present_configs,omitempty"`
 	PresentSecrets  []ResourceAssumption `yaml:"present_secrets,omitempty" json:"present_secrets,omitempty"`
+	PresentNetworks []ResourceAssumption `yaml:"present_networks,omitempty" json:"present_networks,omitempty"`
 	PresentServices []ServiceAssumption  `yaml:"present_services,omitempty" json:"present_services,omitempty"`
 }
 
@@ -133,8 +138,11 @@ func BuildPlan(ctx context.Context, client swarm.Client, cfg *config.Config, des
 		}
 		plan.CreateNetworks = append(plan.CreateNetworks, net)
 	}
+	if conflicts := NetworkSubnetConflicts(plan.CreateNetworks, existingNetworks); len(conflicts) > 0 {
+		return Plan{}, fmt.Errorf("network subnet conflict: %s", FormatNetworkConflict(conflicts[0]))
+	}
 	for _, cfg := range existingConfigs {
-		if !isManagedProject(cfg.Labels, projectName) {
+		if !isManagedProject(cfg.Labels, projectName) || isReleaseRecord(cfg.Labels) {
 			continue
 		}
 		if _, ok := inUseConfigIDs[cfg.ID]; ok {
@@ -159,6 +167,10 @@ func BuildPlan(ctx context.Context, client swarm.Client, cfg *config.Config, des
 		}
 		plan.DeleteSecrets = append(plan.DeleteSecrets, sec)
 	}
+	plan.DeleteNetworks, plan.SkippedDeletes.Networks, err = staleNetworks(ctx, client, cfg, existingNetworks, existingServices)
+	if err != nil {
+		return Plan{}, err
+	}
 
 	creates, updates, err := buildServiceChanges(cfg, desired, values, existingServices, networkTargets, partitionFilters, stackFilters, infer)
 	if err != nil {
@@ -177,6 +189,15 @@ func BuildPlan(ctx context.Context, client swarm.Client, cfg *config.Config, des
 			}
 		}
 	}
+	// Deploying a stack removes the canaries an interrupted apply left
+	// behind, so stacks with one count as affected.
+	for _, svc := range existingServices {
+		if isCanary(svc.Labels) && isManagedProject(svc.Labels, projectName) {
+			if name := stackNameFromService(svc); name != "" {
+				affected[name] = struct{}{}
+			}
+		}
+	}
 	if len(affected) > 0 {
 		filter := affected
 		stackDeploys, err := BuildStackDeploys(cfg, desired, values, partitionFilters, stackFilters, filter, creates, updates, infer)
@@ -188,7 +209,7 @@ func BuildPlan(ctx context.Context, client swarm.Client, cfg *config.Config, des
 
 	pruneStacks := make(map[string]struct{})
 	for _, svc := range existingServices {
-		if !isManagedProject(svc.Labels, projectName) {
+		if !isManagedProject(svc.Labels, projectName) || isCanary(svc.Labels) {
 			continue
 		}
 		stack := svc.Labels[render.LabelStack]
@@ -227,6 +248,9 @@ func BuildPlan(ctx context.Context, client swarm.Client, cfg *config.Config, des
 		plan.PruneStacks = sortedKeys(pruneStacks)
 	}
 	plan.Assumptions = buildPlanAssumptions(plan, creates, existingServices)
+	if len(desired.ImageDigests) > 0 {
+		plan.ImageDigests = desired.ImageDigests
+	}
 
 	return plan, nil
 }
@@ -248,6 +272,9 @@ func buildPlanAssumptions(plan Plan, creates []ServiceCreate, existingServices [
 	for _, sec := range plan.DeleteSecrets {
 		out.PresentSecrets = append(out.PresentSecrets, ResourceAssumption{Name: sec.Name, ID: sec.ID})
 	}
+	for _, net := range plan.DeleteNetworks {
+		out.PresentNetworks = append(out.PresentNetworks, ResourceAssumption{Name: net.Name, ID: net.ID})
+	}
 	for _, create := range creates {
 		out.AbsentServices = append(out.AbsentServices, create.Name)
 	}
@@ -310,6 +337,12 @@ func normalizePlanAssumptions(in PlanAssumptions) PlanAssumptions {
 		}
 		return in.PresentSecrets[i].Name < in.PresentSecrets[j].Name
 	})
+	sort.Slice(in.PresentNetworks, func(i, j int) bool {
+		if in.PresentNetworks[i].Name == in.PresentNetworks[j].Name {
+			return in.PresentNetworks[i].ID < in.PresentNetworks[j].ID
+		}
+		return in.PresentNetworks[i].Name < in.PresentNetworks[j].Name
+	})
 	sort.Slice(in.PresentServices, func(i, j int) bool {
 		if in.PresentServices[i].Name == in.PresentServices[j].Name {
 			return in.PresentServices[i].ID < in.PresentServices[j].ID
@@ -345,36 +378,44 @@ func sortedKeys(values map[string]struct{}) []string {
 	return out
 }
 
-func Apply(ctx context.Context, client swarm.Client, plan Plan, contextName string, pruneServices bool, stackParallel int, noUI bool, outputMode string, outputExplicit bool) error {
+// Apply executes plan and returns the per-stack deploy results, including
+// the failed ones when a stack deploy fails.
+func Apply(ctx context.Context, client swarm.Client, plan Plan, lease *Lease, pruneServices bool, rollback bool, stackParallel int, noUI bool, outputMode string, outputExplicit bool) ([]StackDeployResult, error) {
 	for _, net := range plan.CreateNetworks {
 		if _, err := client.CreateNetwork(ctx, net); err != nil {
-			return err
+			return nil, err
 		}
 	}
 	for _, cfg := range plan.CreateConfigs {
 		if _, err := client.CreateConfig(ctx, cfg); err != nil {
-			return err
+			return nil, err
 		}
 	}
 	for _, sec := range plan.CreateSecrets {
 		if _, err := client.CreateSecret(ctx, sec); err != nil {
-			return err
+			return nil, err
 		}
 	}
-	if err := DeployStacks(ctx, plan.StackDeploys, contextName, pruneServices, stackParallel, noUI, outputMode, outputExplicit); err != nil {
-		return err
+	results, err := DeployStacks(ctx, client, plan.StackDeploys, lease, pruneServices, rollback, stackParallel, noUI, outputMode, outputExplicit)
+	if err != nil {
+		return results, err
 	}
 	for _, cfg := range plan.DeleteConfigs {
 		if err := client.RemoveConfig(ctx, cfg.ID); err != nil {
-			return err
+			return results, err
 		}
 	}
 	for _, sec := range plan.DeleteSecrets {
 		if err := client.RemoveSecret(ctx, sec.ID); err != nil {
-			return err
+			return results, err
+		}
+	}
+	for _, net := range plan.DeleteNetworks {
+		if err := client.RemoveNetwork(ctx, net.ID); err != nil {
+			return results, fmt.Errorf("remove network %q: %w", net.Name, err)
 		}
 	}
-	return nil
+	return results, nil
 }
 
 func collectInUseIDs(services []swarm.Service, configIDs map[string]string, secretIDs map[string]string) (map[string]struct{}, map[string]struct{}) {
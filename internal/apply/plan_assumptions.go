@@ -32,6 +32,7 @@ func ValidatePlanAssumptions(ctx context.Context, client swarm.Client, assumptio
 	configIDs := configIDsByName(configs)
 	secretIDs := secretIDsByName(secrets)
 	inUseConfigIDs, inUseSecretIDs := collectInUseIDs(services, configIDs, secretIDs)
+	attachedNetworks := attachedNetworkNames(networks, services)
 
 	for _, name := range assumptions.AbsentConfigs {
 		if _, ok := configByName[name]; ok {
@@ -77,6 +78,25 @@ func ValidatePlanAssumptions(ctx context.Context, client swarm.Client, assumptio
 			return fmt.Errorf("plan assumption failed: secret %q is now in use", expected.Name)
 		}
 	}
+	for _, expected := range assumptions.PresentNetworks {
+		current, ok := networkByName[expected.Name]
+		if !ok {
+			return fmt.Errorf("plan assumption failed: network %q no longer exists", expected.Name)
+		}
+		if current.ID != expected.ID {
+			return fmt.Errorf("plan assumption failed: network %q id changed: got %s want %s", expected.Name, current.ID, expected.ID)
+		}
+		if _, ok := attachedNetworks[current.Name]; ok {
+			return fmt.Errorf("plan assumption failed: network %q is now in use", expected.Name)
+		}
+		containers, err := client.NetworkContainers(ctx, current.ID)
+		if err != nil {
+			return fmt.Errorf("inspect network %q: %w", expected.Name, err)
+		}
+		if containers > 0 {
+			return fmt.Errorf("plan assumption failed: network %q is now in use by %d container(s)", expected.Name, containers)
+		}
+	}
 	for _, expected := range assumptions.PresentServices {
 		current, ok := serviceByName[expected.Name]
 		if !ok {
@@ -107,6 +127,7 @@ func finalizedPlanAssumptions(plan Plan) PlanAssumptions {
 		AbsentServices:  current.AbsentServices,
 		PresentConfigs:  filterResourceAssumptions(current.PresentConfigs, configResourceKeys(plan.DeleteConfigs)),
 		PresentSecrets:  filterResourceAssumptions(current.PresentSecrets, secretResourceKeys(plan.DeleteSecrets)),
+		PresentNetworks: filterResourceAssumptions(current.PresentNetworks, networkResourceKeys(plan.DeleteNetworks)),
 		PresentServices: filterServiceAssumptions(current.PresentServices, deployedStacks),
 	}
 	return normalizePlanAssumptions(out)
@@ -241,3 +262,11 @@ func secretResourceKeys(secrets []swarm.Secret) map[ResourceAssumption]struct{}
 	}
 	return out
 }
+
+func networkResourceKeys(networks []swarm.Network) map[ResourceAssumption]struct{} {
+	out := make(map[ResourceAssumption]struct{}, len(networks))
+	for _, net := range networks {
+		out[ResourceAssumption{Name: net.Name, ID: net.ID}] = struct{}{}
+	}
+	return out
+}
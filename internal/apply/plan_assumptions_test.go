@@ -78,6 +78,43 @@ func TestValidatePlanAssumptionsRejectsDeletedConfigNowInUse(t *testing.T) {
 	}
 }
 
+func TestValidatePlanAssumptionsRejectsDeletedNetworkNowInUse(t *testing.T) {
+	client := &fakeClient{
+		networks: []swarm.Network{{Name: "primary_old_internal", ID: "net-1"}},
+		services: []swarm.Service{{
+			Name: "primary_core_api",
+			Spec: dockerapi.ServiceSpec{
+				TaskTemplate: dockerapi.TaskSpec{
+					Networks: []dockerapi.NetworkAttachmentConfig{{Target: "primary_old_internal"}},
+				},
+			},
+		}},
+	}
+	assumptions := PlanAssumptions{
+		PresentNetworks: []ResourceAssumption{{Name: "primary_old_internal", ID: "net-1"}},
+	}
+
+	err := ValidatePlanAssumptions(context.Background(), client, assumptions)
+	if err == nil || !strings.Contains(err.Error(), "network \"primary_old_internal\" is now in use") {
+		t.Fatalf("expected in-use network assumption failure, got %v", err)
+	}
+}
+
+func TestValidatePlanAssumptionsRejectsDeletedNetworkWithContainers(t *testing.T) {
+	client := &fakeClient{
+		networks:          []swarm.Network{{Name: "primary_old_internal", ID: "net-1"}},
+		networkContainers: map[string]int{"net-1": 2},
+	}
+	assumptions := PlanAssumptions{
+		PresentNetworks: []ResourceAssumption{{Name: "primary_old_internal", ID: "net-1"}},
+	}
+
+	err := ValidatePlanAssumptions(context.Background(), client, assumptions)
+	if err == nil || !strings.Contains(err.Error(), "network \"primary_old_internal\" is now in use by 2 container(s)") {
+		t.Fatalf("expected attached container assumption failure, got %v", err)
+	}
+}
+
 func TestValidatePlanAssumptionsAcceptsMatchingState(t *testing.T) {
 	client := &fakeClient{
 		configs:  []swarm.Config{{Name: "cfg-old", ID: "cfg-1"}},
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -22,6 +23,11 @@ const (
 	PlanSecretModeMixed     = "mixed"
 )
 
+// ErrPlanSecretChanged is returned when a secret resolved while applying a
+// plan artifact no longer matches the hash recorded at plan time. Errors
+// wrapping it never carry the hashes.
+var ErrPlanSecretChanged = errors.New("secret changed since the plan was made")
+
 func OmitReplayableSecretPayloads(plan *Plan, sources []PlanSecretSource) {
 	sourceByName := planSecretSourcesByName(sources)
 	for i := range plan.CreateSecrets {
@@ -99,7 +105,7 @@ func SetPlanSecretMode(planFile *PlanFile) {
 
 func ValidatePlanFile(planFile PlanFile) error {
 	if planFile.APIVersion != PlanFileAPIVersion {
-		return fmt.Errorf("unsupported plan api_version %q", planFile.APIVersion)
+		return fmt.Errorf("unsupported plan api_version %q (expected %q; re-run plan)", planFile.APIVersion, PlanFileAPIVersion)
 	}
 	mode := NormalizedPlanSecretMode(planFile)
 	switch mode {
@@ -160,6 +166,7 @@ func planHasOperations(plan Plan) bool {
 		len(plan.CreateNetworks) > 0 ||
 		len(plan.DeleteConfigs) > 0 ||
 		len(plan.DeleteSecrets) > 0 ||
+		len(plan.DeleteNetworks) > 0 ||
 		len(plan.StackDeploys) > 0
 }
 
@@ -170,6 +177,7 @@ func planAssumptionCount(assumptions PlanAssumptions) int {
 		len(assumptions.AbsentServices) +
 		len(assumptions.PresentConfigs) +
 		len(assumptions.PresentSecrets) +
+		len(assumptions.PresentNetworks) +
 		len(assumptions.PresentServices)
 }
 
@@ -203,7 +211,7 @@ func resolvePlanSecretSource(ctx context.Context, planFile PlanFile, source Plan
 			return "", fmt.Errorf("dependency %q: %w", dep.Name, err)
 		}
 		if got := secretValueHash(resolved.Value); got != dep.Hash {
-			return "", fmt.Errorf("dependency %q hash mismatch: got %s want %s", dep.Name, got, dep.Hash)
+			return "", fmt.Errorf("dependency %q: %w", dep.Name, ErrPlanSecretChanged)
 		}
 		return resolved.Value, nil
 	}
@@ -262,7 +270,7 @@ func resolvePlanSecretRecipe(ctx context.Context, planFile PlanFile, source Plan
 		return "", err
 	}
 	if got := secretValueHash(rendered); got != source.Recipe.RenderedHash {
-		return "", fmt.Errorf("rendered hash mismatch: got %s want %s", got, source.Recipe.RenderedHash)
+		return "", fmt.Errorf("rendered value: %w", ErrPlanSecretChanged)
 	}
 	return rendered, nil
 }
@@ -275,7 +283,7 @@ func resolvePlanSecretRecipeDependencies(ctx context.Context, planFile PlanFile,
 			return nil, fmt.Errorf("dependency %q: %w", dep.Name, err)
 		}
 		if got := secretValueHash(resolved.Value); got != dep.Hash {
-			return nil, fmt.Errorf("dependency %q hash mismatch: got %s want %s", dep.Name, got, dep.Hash)
+			return nil, fmt.Errorf("dependency %q: %w", dep.Name, ErrPlanSecretChanged)
 		}
 		out[planRecipeSecretKey(dep.Scope, dep.Name)] = resolved.Value
 		out[planRecipeSecretKey(PlanScope{}, dep.Name)] = resolved.Value
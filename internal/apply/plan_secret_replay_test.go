@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -170,8 +171,8 @@ func TestResolvePlanSecretPayloadsRejectsHashMismatch(t *testing.T) {
 		},
 	}
 
-	if err := ResolvePlanSecretPayloads(context.Background(), &planFile); err == nil {
-		t.Fatalf("expected hash mismatch")
+	if err := ResolvePlanSecretPayloads(context.Background(), &planFile); !errors.Is(err, ErrPlanSecretChanged) {
+		t.Fatalf("expected changed secret, got %v", err)
 	}
 }
 
@@ -2,7 +2,9 @@ package apply
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/cmmoran/swarmcp/internal/config"
 	"github.com/cmmoran/swarmcp/internal/render"
@@ -15,6 +17,24 @@ type fakeClient struct {
 	secrets  []swarm.Secret
 	services []swarm.Service
 	networks []swarm.Network
+	locks    []swarm.Lock
+	lockSeq  int
+
+	// networkContainers counts standalone containers by network ID.
+	networkContainers map[string]int
+
+	configData map[string][]byte
+
+	createdNetworks []swarm.NetworkSpec
+	createdServices []dockerapi.ServiceSpec
+	updatedServices []dockerapi.ServiceSpec
+	removedServices []string
+	removedNetworks []string
+	removedConfigs  []string
+	createErrors    map[string]error
+	taskStates      map[string]dockerapi.TaskState
+	rolledBack      []string
+	serviceLogs     map[string]string
 }
 
 func (f *fakeClient) ListConfigs(ctx context.Context) ([]swarm.Config, error) {
@@ -26,7 +46,13 @@ func (f *fakeClient) ListSecrets(ctx context.Context) ([]swarm.Secret, error) {
 }
 
 func (f *fakeClient) CreateConfig(ctx context.Context, spec swarm.ConfigSpec) (string, error) {
-	return "config-id", nil
+	id := "config-" + spec.Name
+	f.configs = append(f.configs, swarm.Config{ID: id, Name: spec.Name, Labels: spec.Labels, CreatedAt: time.Now()})
+	if f.configData == nil {
+		f.configData = make(map[string][]byte)
+	}
+	f.configData[id] = spec.Data
+	return id, nil
 }
 
 func (f *fakeClient) CreateSecret(ctx context.Context, spec swarm.SecretSpec) (string, error) {
@@ -37,27 +63,88 @@ func (f *fakeClient) ListServices(ctx context.Context) ([]swarm.Service, error)
 	return f.services, nil
 }
 
+func (f *fakeClient) ListServiceTasks(ctx context.Context, serviceID string) ([]swarm.Task, error) {
+	for _, svc := range f.services {
+		if svc.ID != serviceID {
+			continue
+		}
+		state := dockerapi.TaskStateRunning
+		desired := dockerapi.TaskStateRunning
+		if svc.Spec.Mode.ReplicatedJob != nil {
+			state = dockerapi.TaskStateComplete
+			desired = dockerapi.TaskStateComplete
+		}
+		if override, ok := f.taskStates[svc.Name]; ok {
+			state = override
+		}
+		replicas := 1
+		if svc.Spec.Mode.Replicated != nil && svc.Spec.Mode.Replicated.Replicas != nil {
+			replicas = int(*svc.Spec.Mode.Replicated.Replicas)
+		}
+		tasks := make([]swarm.Task, 0, replicas)
+		for slot := 1; slot <= replicas; slot++ {
+			tasks = append(tasks, swarm.Task{
+				ServiceID:    svc.ID,
+				Slot:         slot,
+				Spec:         svc.Spec.TaskTemplate,
+				DesiredState: desired,
+				State:        state,
+				Err:          "task: " + string(state),
+			})
+		}
+		return tasks, nil
+	}
+	return nil, nil
+}
+
+func (f *fakeClient) ServiceLogs(ctx context.Context, serviceID string, tail int) ([]byte, error) {
+	for _, svc := range f.services {
+		if svc.ID == serviceID {
+			return []byte(f.serviceLogs[svc.Name]), nil
+		}
+	}
+	return nil, nil
+}
+
 func (f *fakeClient) ListNetworks(ctx context.Context) ([]swarm.Network, error) {
 	return f.networks, nil
 }
 
+func (f *fakeClient) NetworkContainers(ctx context.Context, id string) (int, error) {
+	return f.networkContainers[id], nil
+}
+
 func (f *fakeClient) ListNodes(ctx context.Context) ([]swarm.Node, error) {
 	return nil, nil
 }
 
 func (f *fakeClient) ConfigContent(ctx context.Context, id string) ([]byte, error) {
-	return nil, nil
+	return f.configData[id], nil
 }
 
 func (f *fakeClient) CreateNetwork(ctx context.Context, spec swarm.NetworkSpec) (string, error) {
+	f.createdNetworks = append(f.createdNetworks, spec)
+	f.networks = append(f.networks, swarm.Network{ID: "network-id", Name: spec.Name, Driver: spec.Driver})
 	return "network-id", nil
 }
 
 func (f *fakeClient) CreateService(ctx context.Context, spec dockerapi.ServiceSpec) (string, error) {
-	return "service-id", nil
+	if err := f.createErrors[spec.Annotations.Name]; err != nil {
+		return "", err
+	}
+	f.createdServices = append(f.createdServices, spec)
+	f.services = append(f.services, swarm.Service{
+		ID:      "service-id-" + spec.Annotations.Name,
+		Name:    spec.Annotations.Name,
+		Labels:  spec.Annotations.Labels,
+		Spec:    spec,
+		Version: 1,
+	})
+	return "service-id-" + spec.Annotations.Name, nil
 }
 
 func (f *fakeClient) RemoveConfig(ctx context.Context, id string) error {
+	f.removedConfigs = append(f.removedConfigs, id)
 	return nil
 }
 
@@ -65,7 +152,64 @@ func (f *fakeClient) RemoveSecret(ctx context.Context, id string) error {
 	return nil
 }
 
+func (f *fakeClient) RemoveNetwork(ctx context.Context, id string) error {
+	f.removedNetworks = append(f.removedNetworks, id)
+	return nil
+}
+
+func (f *fakeClient) ListLocks(ctx context.Context) ([]swarm.Lock, error) {
+	return append([]swarm.Lock(nil), f.locks...), nil
+}
+
+func (f *fakeClient) CreateLock(ctx context.Context, spec swarm.LockSpec) (string, error) {
+	for _, lock := range f.locks {
+		if lock.Name == spec.Name {
+			return "", fmt.Errorf("%w: %s", swarm.ErrLockExists, spec.Name)
+		}
+	}
+	f.lockSeq++
+	id := fmt.Sprintf("lock-%s-%d", spec.Name, f.lockSeq)
+	f.locks = append(f.locks, swarm.Lock{ID: id, Name: spec.Name, Labels: spec.Labels, Data: spec.Data, CreatedAt: time.Now()})
+	return id, nil
+}
+
+func (f *fakeClient) RemoveLock(ctx context.Context, id string) error {
+	for i, lock := range f.locks {
+		if lock.ID == id {
+			f.locks = append(f.locks[:i], f.locks[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeClient) RemoveService(ctx context.Context, id string) error {
+	f.removedServices = append(f.removedServices, id)
+	return nil
+}
+
 func (f *fakeClient) UpdateService(ctx context.Context, service swarm.Service, spec dockerapi.ServiceSpec) error {
+	f.updatedServices = append(f.updatedServices, spec)
+	for i := range f.services {
+		if f.services[i].ID == service.ID {
+			f.services[i].PreviousSpec = new(f.services[i].Spec)
+			f.services[i].Labels = spec.Annotations.Labels
+			f.services[i].Spec = spec
+			f.services[i].Version++
+		}
+	}
+	return nil
+}
+
+func (f *fakeClient) RollbackService(ctx context.Context, service swarm.Service) error {
+	f.rolledBack = append(f.rolledBack, service.Name)
+	for i := range f.services {
+		if f.services[i].ID == service.ID && f.services[i].PreviousSpec != nil {
+			f.services[i].Spec = *f.services[i].PreviousSpec
+			f.services[i].PreviousSpec = nil
+			f.services[i].Version++
+		}
+	}
 	return nil
 }
 
@@ -130,6 +274,55 @@ func TestBuildPlanFiltersExistingNamesAndDeletesStale(t *testing.T) {
 	}
 }
 
+func TestBuildPlanDeletesUnusedManagedNetworks(t *testing.T) {
+	managed := map[string]string{"swarmcp.io/managed": "true", "swarmcp.io/project": "primary"}
+	client := &fakeClient{
+		networks: []swarm.Network{
+			{Name: "primary_core", ID: "net-1", Labels: managed},
+			{Name: "primary_old_internal", ID: "net-2", Labels: managed},
+			{Name: "primary_core_svc_gone", ID: "net-3", Labels: managed},
+			{Name: "primary_busy", ID: "net-4", Labels: managed},
+			{Name: "corp", ID: "net-5"},
+		},
+		networkContainers: map[string]int{"net-4": 1},
+		services: []swarm.Service{{
+			Name: "legacy",
+			Spec: dockerapi.ServiceSpec{
+				TaskTemplate: dockerapi.TaskSpec{
+					Networks: []dockerapi.NetworkAttachmentConfig{{Target: "net-3"}},
+				},
+			},
+		}},
+	}
+	cfg := &config.Config{
+		Project: config.Project{Name: "primary"},
+		Stacks: map[string]config.Stack{
+			"core": {Mode: "shared", Services: map[string]config.Service{"api": {Image: "api"}}},
+		},
+	}
+
+	plan, err := BuildPlan(context.Background(), client, cfg, DesiredState{}, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.DeleteNetworks) != 1 || plan.DeleteNetworks[0].Name != "primary_old_internal" {
+		t.Fatalf("unexpected network deletes: %#v", plan.DeleteNetworks)
+	}
+	if plan.SkippedDeletes.Networks != 2 {
+		t.Fatalf("expected 2 in-use networks skipped, got %d", plan.SkippedDeletes.Networks)
+	}
+	if got := plan.Assumptions.PresentNetworks; len(got) != 1 || got[0].Name != "primary_old_internal" || got[0].ID != "net-2" {
+		t.Fatalf("unexpected present network assumptions: %#v", got)
+	}
+
+	if _, err := Apply(context.Background(), client, Plan{DeleteNetworks: plan.DeleteNetworks}, nil, false, false, 0, true, "plain", false); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(client.removedNetworks) != 1 || client.removedNetworks[0] != "net-2" {
+		t.Fatalf("unexpected removed networks: %#v", client.removedNetworks)
+	}
+}
+
 func TestBuildPlanDedupesDesiredNames(t *testing.T) {
 	client := &fakeClient{}
 	cfg := &config.Config{
@@ -1,31 +1,39 @@
 package apply
 
 import (
+	"encoding/json"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cmmoran/swarmcp/internal/config"
 	"go.yaml.in/yaml/v4"
 )
 
-const PlanFileAPIVersion = "swarmcp.plan.v1"
+// PlanFileAPIVersion versions the plan artifact schema, including the
+// ServiceIntent recorded for every stack service.
+const PlanFileAPIVersion = "swarmcp.plan.v2"
 
 type PlanFile struct {
-	APIVersion    string             `yaml:"api_version"`
-	GeneratedAt   string             `yaml:"generated_at"`
-	ToolVersion   string             `yaml:"tool_version,omitempty"`
-	Project       string             `yaml:"project"`
-	Deployment    string             `yaml:"deployment,omitempty"`
-	Partition     string             `yaml:"partition,omitempty"`
-	Stack         string             `yaml:"stack,omitempty"`
-	Context       string             `yaml:"context,omitempty"`
-	PruneServices bool               `yaml:"prune_services,omitempty"`
-	Secrets       PlanSecrets        `yaml:"secrets"`
-	Inputs        []PlanInput        `yaml:"inputs,omitempty"`
-	SourceInputs  []PlanSourceInput  `yaml:"source_inputs,omitempty"`
-	Warnings      []string           `yaml:"warnings,omitempty"`
-	SecretSources []PlanSecretSource `yaml:"secret_sources,omitempty"`
-	Plan          Plan               `yaml:"plan"`
+	APIVersion          string                   `yaml:"api_version"`
+	GeneratedAt         string                   `yaml:"generated_at"`
+	ToolVersion         string                   `yaml:"tool_version,omitempty"`
+	Project             string                   `yaml:"project"`
+	Deployment          string                   `yaml:"deployment,omitempty"`
+	Partition           string                   `yaml:"partition,omitempty"`
+	Stack               string                   `yaml:"stack,omitempty"`
+	Context             string                   `yaml:"context,omitempty"`
+	LockPartition       string                   `yaml:"lock_partition,omitempty"`
+	PruneServices       bool                     `yaml:"prune_services,omitempty"`
+	ReleaseHistory      *int                     `yaml:"release_history,omitempty"`
+	Secrets             PlanSecrets              `yaml:"secrets"`
+	Inputs              []PlanInput              `yaml:"inputs,omitempty"`
+	SourceInputs        []PlanSourceInput        `yaml:"source_inputs,omitempty"`
+	Warnings            []string                 `yaml:"warnings,omitempty"`
+	SecretSources       []PlanSecretSource       `yaml:"secret_sources,omitempty"`
+	RegistrySources     []PlanRegistrySource     `yaml:"registry_sources,omitempty"`
+	NotificationSources []PlanNotificationSource `yaml:"notification_sources,omitempty"`
+	Plan                Plan                     `yaml:"plan"`
 }
 
 type PlanSecrets struct {
@@ -33,19 +41,19 @@ type PlanSecrets struct {
 }
 
 type PlanInput struct {
-	Kind   string `yaml:"kind"`
-	Path   string `yaml:"path"`
-	SHA256 string `yaml:"sha256"`
+	Kind   string `yaml:"kind" json:"kind"`
+	Path   string `yaml:"path" json:"path"`
+	SHA256 string `yaml:"sha256" json:"sha256"`
 }
 
 type PlanSourceInput struct {
-	Kind    string `yaml:"kind"`
-	Origin  string `yaml:"origin,omitempty"`
-	URL     string `yaml:"url"`
-	Ref     string `yaml:"ref,omitempty"`
-	Commit  string `yaml:"commit"`
-	Path    string `yaml:"path,omitempty"`
-	Subtree string `yaml:"subtree"`
+	Kind    string `yaml:"kind" json:"kind"`
+	Origin  string `yaml:"origin,omitempty" json:"origin,omitempty"`
+	URL     string `yaml:"url" json:"url"`
+	Ref     string `yaml:"ref,omitempty" json:"ref,omitempty"`
+	Commit  string `yaml:"commit" json:"commit"`
+	Path    string `yaml:"path,omitempty" json:"path,omitempty"`
+	Subtree string `yaml:"subtree" json:"subtree"`
 }
 
 type PlanSecretSource struct {
@@ -56,6 +64,21 @@ type PlanSecretSource struct {
 	Dependencies []PlanSecretDependency `yaml:"dependencies"`
 }
 
+type PlanRegistrySource struct {
+	Host           string                `yaml:"host"`
+	Username       string                `yaml:"username,omitempty"`
+	UsernameSecret *PlanSecretDependency `yaml:"username_secret,omitempty"`
+	PasswordSecret PlanSecretDependency  `yaml:"password_secret"`
+}
+
+// PlanNotificationSource names a notification sink of the project. Only the
+// name and type are recorded; the sink itself comes from the project config
+// loaded at apply time.
+type PlanNotificationSource struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type,omitempty"`
+}
+
 type PlanSecretRecipe struct {
 	Source       string `yaml:"source"`
 	RenderedHash string `yaml:"rendered_hash"`
@@ -99,17 +122,37 @@ func NewPlanFile(toolVersion string, project string, deployment string, partitio
 }
 
 func WritePlanFile(path string, plan PlanFile) error {
+	data, err := MarshalPlanFile(plan)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// MarshalPlanFile encodes a plan artifact as YAML, filling in the API version
+// and generation time when unset.
+func MarshalPlanFile(plan PlanFile) ([]byte, error) {
 	if plan.APIVersion == "" {
 		plan.APIVersion = PlanFileAPIVersion
 	}
 	if plan.GeneratedAt == "" {
 		plan.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
 	}
-	data, err := yaml.Marshal(plan)
+	return yaml.Marshal(plan)
+}
+
+// MarshalPlanFileJSON encodes a plan artifact as JSON with the same field
+// names as the YAML artifact, so either form can be passed to ParsePlanFile.
+func MarshalPlanFileJSON(plan PlanFile) ([]byte, error) {
+	data, err := MarshalPlanFile(plan)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return os.WriteFile(path, data, 0o600)
+	var generic any
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
 }
 
 func ReadPlanFile(path string) (PlanFile, error) {
@@ -117,9 +160,34 @@ func ReadPlanFile(path string) (PlanFile, error) {
 	if err != nil {
 		return PlanFile{}, err
 	}
+	return ParsePlanFile(data)
+}
+
+// ParsePlanFile decodes a YAML or JSON plan artifact.
+func ParsePlanFile(data []byte) (PlanFile, error) {
 	var plan PlanFile
 	if err := yaml.Unmarshal(data, &plan); err != nil {
 		return PlanFile{}, err
 	}
 	return plan, nil
 }
+
+// PlanLockScope derives the apply lock scope of a plan artifact from what it
+// changes instead of trusting its lock_partition: the partition lock only
+// covers plans whose stacks all belong to that partition and that delete or
+// prune nothing.
+func PlanLockScope(planFile PlanFile) LockScope {
+	scope := LockScope{Project: planFile.Project}
+	plan := planFile.Plan
+	if planFile.LockPartition == "" || len(plan.DeleteConfigs) > 0 || len(plan.DeleteSecrets) > 0 || len(plan.DeleteNetworks) > 0 || len(plan.PruneStacks) > 0 {
+		return scope
+	}
+	prefix := planFile.Project + "_" + planFile.LockPartition + "_"
+	for _, deploy := range plan.StackDeploys {
+		if !strings.HasPrefix(deploy.Name, prefix) {
+			return scope
+		}
+	}
+	scope.Partition = planFile.LockPartition
+	return scope
+}
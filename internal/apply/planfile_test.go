@@ -2,7 +2,10 @@ package apply
 
 import (
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cmmoran/swarmcp/internal/swarm"
 )
@@ -83,3 +86,93 @@ func TestPlanFileRoundTrip(t *testing.T) {
 		t.Fatalf("unexpected source inputs: %#v", got.SourceInputs)
 	}
 }
+
+func TestPlanFileJSONRoundTrip(t *testing.T) {
+	want := NewPlanFile("test-version", "demo", "prod", "", "", "prod-context", false, Plan{
+		CreateConfigs: []swarm.ConfigSpec{{Name: "app_cfg_abcd1234", Data: []byte{0xff, 0x00, 'a'}}},
+		StackDeploys:  []StackDeploy{{Name: "demo_core", Compose: []byte("services: {}\n")}},
+	})
+	data, err := MarshalPlanFileJSON(want)
+	if err != nil {
+		t.Fatalf("MarshalPlanFileJSON: %v", err)
+	}
+	if !strings.Contains(string(data), `"api_version":"swarmcp.plan.v2"`) || !strings.Contains(string(data), `"stack_deploys":`) {
+		t.Fatalf("expected artifact field names in JSON, got %s", data)
+	}
+	got, err := ParsePlanFile(data)
+	if err != nil {
+		t.Fatalf("ParsePlanFile: %v", err)
+	}
+	if got.Project != "demo" || got.Context != "prod-context" {
+		t.Fatalf("unexpected plan metadata: %#v", got)
+	}
+	if string(got.Plan.CreateConfigs[0].Data) != "\xff\x00a" || string(got.Plan.StackDeploys[0].Compose) != "services: {}\n" {
+		t.Fatalf("unexpected payloads after JSON round trip: %#v", got.Plan)
+	}
+}
+
+func TestPlanLockScopeIgnoresPartitionForProjectWideChanges(t *testing.T) {
+	partitionOnly := PlanFile{Project: "demo", LockPartition: "dev", Plan: Plan{
+		StackDeploys: []StackDeploy{{Name: "demo_dev_app"}},
+	}}
+	if scope := PlanLockScope(partitionOnly); scope.Partition != "dev" {
+		t.Fatalf("expected partition lock, got %s", scope)
+	}
+	for name, edit := range map[string]func(*Plan){
+		"shared stack":    func(plan *Plan) { plan.StackDeploys = append(plan.StackDeploys, StackDeploy{Name: "demo_core"}) },
+		"other partition": func(plan *Plan) { plan.StackDeploys = append(plan.StackDeploys, StackDeploy{Name: "demo_qa_app"}) },
+		"network delete":  func(plan *Plan) { plan.DeleteNetworks = []swarm.Network{{Name: "demo_core"}} },
+		"config delete":   func(plan *Plan) { plan.DeleteConfigs = []swarm.Config{{Name: "demo_cfg"}} },
+		"secret delete":   func(plan *Plan) { plan.DeleteSecrets = []swarm.Secret{{Name: "demo_sec"}} },
+		"stack prune":     func(plan *Plan) { plan.PruneStacks = []string{"demo_core"} },
+	} {
+		planFile := partitionOnly
+		planFile.Plan.StackDeploys = slices.Clone(partitionOnly.Plan.StackDeploys)
+		edit(&planFile.Plan)
+		if scope := PlanLockScope(planFile); scope.Partition != "" {
+			t.Fatalf("expected project lock for a plan with a %s, got %s", name, scope)
+		}
+	}
+}
+
+func TestPlanFileRecordsServiceIntentsWithSchemaKeys(t *testing.T) {
+	period := 30 * time.Second
+	intent := serviceIntent{
+		Image:        "nginx:1",
+		Mode:         "replicated",
+		Replicas:     2,
+		EndpointMode: "dnsrr",
+		MaxReplicas:  1,
+		Ports:        []portIntent{{Target: 80, Published: 8080, Protocol: "tcp", Mode: "ingress"}},
+		Runtime:      runtimeIntent{ReadOnly: true, StopGracePeriod: &period},
+	}
+	planFile := NewPlanFile("test", "demo", "", "", "", "", false, Plan{StackDeploys: []StackDeploy{{
+		Name:     "demo_app",
+		Services: map[string]ServiceIntent{"web": exportServiceIntent(intent)},
+	}}})
+	data, err := MarshalPlanFile(planFile)
+	if err != nil {
+		t.Fatalf("MarshalPlanFile: %v", err)
+	}
+	for _, key := range []string{"endpoint_mode: dnsrr", "max_replicas_per_node: 1", "read_only: true", "stop_grace_period: 30s", "published: 8080"} {
+		if !strings.Contains(string(data), key) {
+			t.Fatalf("expected %q in plan artifact, got:\n%s", key, data)
+		}
+	}
+	parsed, err := ParsePlanFile(data)
+	if err != nil {
+		t.Fatalf("ParsePlanFile: %v", err)
+	}
+	got, err := parsed.Plan.StackDeploys[0].Services["web"].serviceIntent()
+	if err != nil {
+		t.Fatalf("serviceIntent: %v", err)
+	}
+	if diffs := intentDiffs(got, intent); len(diffs) > 0 {
+		t.Fatalf("service intent changed in the round trip: %v", diffs)
+	}
+
+	parsed.APIVersion = "swarmcp.plan.v1"
+	if err := ValidatePlanFile(parsed); err == nil || !strings.Contains(err.Error(), "re-run plan") {
+		t.Fatalf("expected v1 plan artifacts to be rejected, got %v", err)
+	}
+}
@@ -8,9 +8,9 @@ import (
 )
 
 type PruneResult struct {
-	PreserveCount    int
-	ConfigsPreserved int
-	SecretsPreserved int
+	PreserveCount    int `json:"preserve_count"`
+	ConfigsPreserved int `json:"configs_preserved"`
+	SecretsPreserved int `json:"secrets_preserved"`
 }
 
 func PrunePlan(plan Plan, preserve int) (Plan, PruneResult) {
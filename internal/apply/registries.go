@@ -0,0 +1,126 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/secrets"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"github.com/cmmoran/swarmcp/internal/templates"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// ResolveRegistryCredentials resolves project.registries for the selected
+// deployment through the secrets file or secrets engine. The returned
+// sources record where each secret came from so a plan artifact can resolve
+// the same credentials again without carrying them.
+func ResolveRegistryCredentials(cfg *config.Config, store *secrets.Store) (swarm.RegistryCredentials, []PlanRegistrySource, error) {
+	registries := cfg.DeploymentRegistries()
+	if len(registries) == 0 {
+		return nil, nil, nil
+	}
+	resolve, err := projectSecretResolver(cfg, store)
+	if err != nil {
+		return nil, nil, err
+	}
+	creds := make(swarm.RegistryCredentials, len(registries))
+	sources := make([]PlanRegistrySource, 0, len(registries))
+	for _, host := range slices.Sorted(maps.Keys(registries)) {
+		entry := registries[host]
+		source := PlanRegistrySource{Host: host, Username: entry.Username}
+		username := entry.Username
+		if entry.UsernameSecret != "" {
+			resolved, dep, err := resolve(entry.UsernameSecret)
+			if err != nil {
+				return nil, nil, fmt.Errorf("project.registries.%s.username_secret: %w", host, err)
+			}
+			username = resolved.Value
+			source.UsernameSecret = &dep
+		}
+		password, dep, err := resolve(entry.PasswordSecret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("project.registries.%s.password_secret: %w", host, err)
+		}
+		source.PasswordSecret = dep
+		creds[host] = registry.AuthConfig{Username: username, Password: password.Value}
+		sources = append(sources, source)
+	}
+	return creds, sources, nil
+}
+
+// projectSecretResolver resolves project-scoped secrets of the selected
+// deployment and describes each as a plan dependency for later replay.
+func projectSecretResolver(cfg *config.Config, store *secrets.Store) (func(name string) (secrets.ResolvedSecret, PlanSecretDependency, error), error) {
+	resolver, err := secrets.NewResolver(cfg, store, false)
+	if err != nil {
+		return nil, err
+	}
+	metadataResolver, ok := resolver.(secrets.MetadataResolver)
+	if !ok {
+		return nil, fmt.Errorf("secrets resolver does not report metadata")
+	}
+	scope := templates.Scope{Project: cfg.Project.Name, Deployment: cfg.Project.Deployment}
+	return func(name string) (secrets.ResolvedSecret, PlanSecretDependency, error) {
+		resolved, err := metadataResolver.ValueWithMetadata(scope, name)
+		if err != nil {
+			return secrets.ResolvedSecret{}, PlanSecretDependency{}, err
+		}
+		return resolved, PlanSecretDependency{
+			Name:     name,
+			Scope:    planScope(scope),
+			Hash:     secretValueHash(resolved.Value),
+			Provider: resolved.Metadata.Provider,
+			Addr:     resolved.Metadata.Addr,
+			Auth:     planAuth(resolved.Metadata.Auth),
+			Mount:    resolved.Metadata.Mount,
+			Path:     resolved.Metadata.Path,
+			Key:      resolved.Metadata.Key,
+			Version:  resolved.Metadata.Version,
+		}, nil
+	}, nil
+}
+
+// resolvePlanSecret resolves a secret recorded in a plan artifact, failing
+// when its value changed since the plan was made.
+func resolvePlanSecret(ctx context.Context, planFile PlanFile, dep PlanSecretDependency, fileStoreByPath map[string]*secrets.Store) (string, error) {
+	resolved, err := resolvePlanSecretDependency(ctx, planFile, dep, fileStoreByPath)
+	if err != nil {
+		return "", err
+	}
+	if got := secretValueHash(resolved.Value); got != dep.Hash {
+		return "", fmt.Errorf("secret %q: %w", dep.Name, ErrPlanSecretChanged)
+	}
+	return resolved.Value, nil
+}
+
+// ResolvePlanRegistryCredentials resolves the registry credentials recorded
+// in a plan artifact, failing when a secret changed since the plan was made.
+func ResolvePlanRegistryCredentials(ctx context.Context, planFile PlanFile) (swarm.RegistryCredentials, error) {
+	if len(planFile.RegistrySources) == 0 {
+		return nil, nil
+	}
+	fileStoreByPath := map[string]*secrets.Store{}
+	resolve := func(dep PlanSecretDependency) (string, error) {
+		return resolvePlanSecret(ctx, planFile, dep, fileStoreByPath)
+	}
+	creds := make(swarm.RegistryCredentials, len(planFile.RegistrySources))
+	for _, source := range planFile.RegistrySources {
+		username := source.Username
+		if source.UsernameSecret != nil {
+			value, err := resolve(*source.UsernameSecret)
+			if err != nil {
+				return nil, fmt.Errorf("plan registry %q username: %w", source.Host, err)
+			}
+			username = value
+		}
+		password, err := resolve(source.PasswordSecret)
+		if err != nil {
+			return nil, fmt.Errorf("plan registry %q password: %w", source.Host, err)
+		}
+		creds[source.Host] = registry.AuthConfig{Username: username, Password: password}
+	}
+	return creds, nil
+}
@@ -0,0 +1,77 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/secrets"
+)
+
+func TestRegistryCredentialsReplayFromPlanFile(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:        "proj",
+			Deployments: []string{"dev", "prod"},
+			Deployment:  "prod",
+			Registries: map[string]config.RegistryAuth{
+				"registry.example.com": {
+					Username:       "ci",
+					PasswordSecret: "registry_password",
+					Deployments: map[string]config.RegistryAuth{
+						"prod": {UsernameSecret: "prod_registry_user", PasswordSecret: "prod_registry_password"},
+					},
+				},
+			},
+		},
+	}
+	secretsPath := filepath.Join(t.TempDir(), "secrets.yaml")
+	if err := os.WriteFile(secretsPath, []byte("values:\n  prod_registry_user: puller\n  prod_registry_password: s3cret\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	store, err := secrets.Load(secretsPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	creds, sources, err := ResolveRegistryCredentials(cfg, store)
+	if err != nil {
+		t.Fatalf("ResolveRegistryCredentials: %v", err)
+	}
+	if auth := creds["registry.example.com"]; auth.Username != "puller" || auth.Password != "s3cret" {
+		t.Fatalf("unexpected credentials %+v", auth)
+	}
+	if len(sources) != 1 || sources[0].PasswordSecret.Provider != "file" || sources[0].UsernameSecret == nil {
+		t.Fatalf("unexpected sources %#v", sources)
+	}
+
+	hash, err := fileSHA256(secretsPath)
+	if err != nil {
+		t.Fatalf("fileSHA256: %v", err)
+	}
+	planFile := PlanFile{
+		Inputs:          []PlanInput{{Kind: "secrets", Path: secretsPath, SHA256: hash}},
+		RegistrySources: sources,
+	}
+	replayed, err := ResolvePlanRegistryCredentials(context.Background(), planFile)
+	if err != nil {
+		t.Fatalf("ResolvePlanRegistryCredentials: %v", err)
+	}
+	if auth := replayed["registry.example.com"]; auth.Username != "puller" || auth.Password != "s3cret" {
+		t.Fatalf("unexpected replayed credentials %+v", auth)
+	}
+
+	planFile.RegistrySources[0].PasswordSecret.Hash = "sha256:stale"
+	if _, err := ResolvePlanRegistryCredentials(context.Background(), planFile); !errors.Is(err, ErrPlanSecretChanged) {
+		t.Fatalf("expected changed secret, got %v", err)
+	}
+
+	cfg.Project.Deployment = "dev"
+	if _, _, err := ResolveRegistryCredentials(cfg, store); err == nil || !strings.Contains(err.Error(), "project.registries.registry.example.com.password_secret") {
+		t.Fatalf("expected missing dev secret error, got %v", err)
+	}
+}
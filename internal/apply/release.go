@@ -0,0 +1,451 @@
+package apply
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/render"
+	"github.com/cmmoran/swarmcp/internal/state"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"go.yaml.in/yaml/v4"
+)
+
+const (
+	releaseNamePrefix = "swarmcp-release"
+	// releasePartSize is Swarm's limit on the data of a single config.
+	releasePartSize = 500 * 1024
+)
+
+// Release is a ledger entry recorded in the cluster after a successful apply.
+// StackDeploys hold the full compose intent of every stack instance the apply
+// deployed, so a release can be re-deployed as long as the configs and
+// secrets it references still exist.
+type Release struct {
+	ID           string            `json:"id"`
+	Project      string            `json:"project"`
+	Deployment   string            `json:"deployment,omitempty"`
+	Context      string            `json:"context,omitempty"`
+	Partitions   []string          `json:"partitions,omitempty"`
+	StackFilters []string          `json:"stack_filters,omitempty"`
+	Command      string            `json:"command"`
+	Holder       string            `json:"holder"`
+	ToolVersion  string            `json:"tool_version,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	PlanFile     string            `json:"plan_file,omitempty"`
+	RollbackOf   string            `json:"rollback_of,omitempty"`
+	Summary      state.PlanSummary `json:"summary"`
+	Inputs       []PlanInput       `json:"inputs,omitempty"`
+	SourceInputs []PlanSourceInput `json:"source_inputs,omitempty"`
+	Stacks       []ReleaseStack    `json:"stacks,omitempty"`
+	Images       map[string]string `json:"images,omitempty"`
+	StackDeploys []StackDeploy     `json:"stack_deploys,omitempty"`
+}
+
+// ReleaseStack identifies a deployed stack instance by logical stack and
+// partition ("" for shared stacks).
+type ReleaseStack struct {
+	Name      string `json:"name"`
+	Stack     string `json:"stack"`
+	Partition string `json:"partition,omitempty"`
+}
+
+// NewRelease builds a release entry for the stacks deployed by plan. The
+// caller fills in targeting, inputs and the plan summary.
+func NewRelease(project string, plan Plan) (Release, error) {
+	id, err := newReleaseID(time.Now().UTC())
+	if err != nil {
+		return Release{}, err
+	}
+	release := Release{
+		ID:           id,
+		Project:      project,
+		Holder:       LockHolder(),
+		CreatedAt:    time.Now().UTC(),
+		StackDeploys: plan.StackDeploys,
+	}
+	for _, deploy := range plan.StackDeploys {
+		var compose composeFile
+		if err := yaml.Unmarshal(deploy.Compose, &compose); err != nil {
+			return Release{}, fmt.Errorf("stack %q: parse compose: %w", deploy.Name, err)
+		}
+		entry := ReleaseStack{Name: deploy.Name}
+		for _, key := range slices.Sorted(maps.Keys(compose.Services)) {
+			svc := compose.Services[key]
+			if release.Images == nil {
+				release.Images = make(map[string]string)
+			}
+			release.Images[deploy.Name+"/"+key] = svc.Image
+			if svc.Deploy != nil && entry.Stack == "" {
+				entry.Stack = svc.Deploy.Labels[render.LabelStack]
+				entry.Partition = svc.Deploy.Labels[render.LabelPartition]
+			}
+		}
+		if entry.Partition == "none" {
+			entry.Partition = ""
+		}
+		release.Stacks = append(release.Stacks, entry)
+	}
+	return release, nil
+}
+
+func newReleaseID(now time.Time) (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return now.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix), nil
+}
+
+func ReleaseConfigName(project string, id string) string {
+	return fmt.Sprintf("%s.%s.%s", releaseNamePrefix, project, id)
+}
+
+// Touches reports whether the release deployed the given logical stack and
+// partition; empty arguments match anything.
+func (r Release) Touches(stack string, partition string) bool {
+	for _, entry := range r.Stacks {
+		if stack != "" && entry.Stack != stack {
+			continue
+		}
+		if partition != "" && entry.Partition != partition {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Filter narrows the release to the stack instances matching the given
+// logical stacks and partitions. Shared stacks match any partition filter.
+func (r Release) Filter(stacks []string, partitions []string) Release {
+	if len(stacks) == 0 && len(partitions) == 0 {
+		return r
+	}
+	keep := make(map[string]struct{})
+	var kept []ReleaseStack
+	for _, entry := range r.Stacks {
+		if len(stacks) > 0 && !selectorContains(stacks, entry.Stack) {
+			continue
+		}
+		if len(partitions) > 0 && entry.Partition != "" && !selectorContains(partitions, entry.Partition) {
+			continue
+		}
+		keep[entry.Name] = struct{}{}
+		kept = append(kept, entry)
+	}
+	out := r
+	out.Stacks = kept
+	out.StackDeploys = nil
+	for _, deploy := range r.StackDeploys {
+		if _, ok := keep[deploy.Name]; ok {
+			out.StackDeploys = append(out.StackDeploys, deploy)
+		}
+	}
+	out.Images = nil
+	for key, image := range r.Images {
+		name, _, _ := strings.Cut(key, "/")
+		if _, ok := keep[name]; !ok {
+			continue
+		}
+		if out.Images == nil {
+			out.Images = make(map[string]string)
+		}
+		out.Images[key] = image
+	}
+	return out
+}
+
+// RecordRelease stores the release as a managed, gzip-compressed Swarm
+// config. Records larger than Swarm's config size limit are split across
+// numbered part configs; the first config is created last, so a record is
+// only listed once all of its parts exist. Release configs are never pruned
+// as unused configs.
+func RecordRelease(ctx context.Context, client swarm.Client, release Release) error {
+	payload, err := json.Marshal(release)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(payload); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	parts := splitReleaseData(buf.Bytes())
+	name := ReleaseConfigName(release.Project, release.ID)
+	var created []string
+	for i := len(parts) - 1; i >= 0; i-- {
+		labels := map[string]string{
+			render.LabelManaged: "true",
+			render.LabelProject: release.Project,
+			render.LabelRelease: release.ID,
+		}
+		partName := name
+		if i > 0 {
+			partName = fmt.Sprintf("%s.part%d", name, i)
+			labels[render.LabelReleasePart] = strconv.Itoa(i)
+		} else if len(parts) > 1 {
+			labels[render.LabelReleaseParts] = strconv.Itoa(len(parts))
+		}
+		id, err := client.CreateConfig(ctx, swarm.ConfigSpec{
+			Name:   partName,
+			Labels: labels,
+			Data:   parts[i],
+		})
+		if err != nil {
+			for _, id := range created {
+				_ = client.RemoveConfig(ctx, id)
+			}
+			return fmt.Errorf("record release %s: %w", release.ID, err)
+		}
+		created = append(created, id)
+	}
+	return nil
+}
+
+func splitReleaseData(data []byte) [][]byte {
+	var parts [][]byte
+	for len(data) > releasePartSize {
+		parts = append(parts, data[:releasePartSize])
+		data = data[releasePartSize:]
+	}
+	return append(parts, data)
+}
+
+// isReleasePart reports whether a release config holds a continuation part
+// rather than the first part of a record.
+func isReleasePart(labels map[string]string) bool {
+	return labels[render.LabelReleasePart] != ""
+}
+
+// PruneReleases removes the oldest release entries of project so that at most
+// keep remain; keep 0 retains every entry. It returns the number removed.
+func PruneReleases(ctx context.Context, client swarm.Client, project string, keep int) (int, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+	configs, err := client.ListConfigs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var records []swarm.Config
+	parts := make(map[string][]swarm.Config)
+	for _, cfg := range configs {
+		if !isManagedProject(cfg.Labels, project) || !isReleaseRecord(cfg.Labels) {
+			continue
+		}
+		if isReleasePart(cfg.Labels) {
+			id := cfg.Labels[render.LabelRelease]
+			parts[id] = append(parts[id], cfg)
+			continue
+		}
+		records = append(records, cfg)
+	}
+	if len(records) <= keep {
+		return 0, nil
+	}
+	// Release IDs start with their UTC timestamp, so they sort by age.
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Labels[render.LabelRelease] > records[j].Labels[render.LabelRelease]
+	})
+	removed := 0
+	for _, cfg := range records[keep:] {
+		id := cfg.Labels[render.LabelRelease]
+		if err := client.RemoveConfig(ctx, cfg.ID); err != nil {
+			return removed, fmt.Errorf("remove release %s: %w", id, err)
+		}
+		for _, part := range parts[id] {
+			if err := client.RemoveConfig(ctx, part.ID); err != nil {
+				return removed, fmt.Errorf("remove release %s: %w", id, err)
+			}
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// ListReleases returns the recorded releases of project, newest first.
+func ListReleases(ctx context.Context, client swarm.Client, project string) ([]Release, error) {
+	configs, err := client.ListConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parts := make(map[string]map[string]string)
+	for _, cfg := range configs {
+		if !isManagedProject(cfg.Labels, project) || !isReleasePart(cfg.Labels) {
+			continue
+		}
+		id := cfg.Labels[render.LabelRelease]
+		if parts[id] == nil {
+			parts[id] = make(map[string]string)
+		}
+		parts[id][cfg.Labels[render.LabelReleasePart]] = cfg.ID
+	}
+	var out []Release
+	for _, cfg := range configs {
+		if !isManagedProject(cfg.Labels, project) || !isReleaseRecord(cfg.Labels) || isReleasePart(cfg.Labels) {
+			continue
+		}
+		data, err := readReleaseRecord(ctx, client, cfg, parts[cfg.Labels[render.LabelRelease]])
+		if err != nil {
+			return nil, fmt.Errorf("read release %s: %w", cfg.Name, err)
+		}
+		release, err := decodeRelease(data)
+		if err != nil {
+			return nil, fmt.Errorf("read release %s: %w", cfg.Name, err)
+		}
+		out = append(out, release)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out, nil
+}
+
+// readReleaseRecord returns the data of a release record, joining the
+// continuation parts (config IDs by part number) of a split record.
+func readReleaseRecord(ctx context.Context, client swarm.Client, head swarm.Config, parts map[string]string) ([]byte, error) {
+	data, err := client.ConfigContent(ctx, head.ID)
+	if err != nil {
+		return nil, err
+	}
+	count := 1
+	if raw := head.Labels[render.LabelReleaseParts]; raw != "" {
+		if count, err = strconv.Atoi(raw); err != nil {
+			return nil, fmt.Errorf("invalid %s label %q", render.LabelReleaseParts, raw)
+		}
+	}
+	for i := 1; i < count; i++ {
+		id, ok := parts[strconv.Itoa(i)]
+		if !ok {
+			return nil, fmt.Errorf("part %d of %d is missing", i, count)
+		}
+		part, err := client.ConfigContent(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, part...)
+	}
+	return data, nil
+}
+
+func decodeRelease(data []byte) (Release, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return Release{}, err
+	}
+	defer func() { _ = reader.Close() }()
+	payload, err := io.ReadAll(reader)
+	if err != nil {
+		return Release{}, err
+	}
+	var release Release
+	if err := json.Unmarshal(payload, &release); err != nil {
+		return Release{}, err
+	}
+	return release, nil
+}
+
+// RollbackPlan returns a plan that re-deploys the release's stack intent. It
+// fails when configs, secrets or networks referenced by the release have been
+// pruned. Stack-scoped networks are recreated by the deploy and not checked.
+func RollbackPlan(ctx context.Context, client swarm.Client, release Release) (Plan, error) {
+	if len(release.StackDeploys) == 0 {
+		return Plan{}, fmt.Errorf("release %s deployed no stacks", release.ID)
+	}
+	configs, err := client.ListConfigs(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	secrets, err := client.ListSecrets(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	networks, err := client.ListNetworks(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	configByName := configsByName(configs)
+	secretByName := secretsByName(secrets)
+	networkNames := make(map[string]struct{}, len(networks))
+	for _, network := range networks {
+		networkNames[network.Name] = struct{}{}
+	}
+	var missing []string
+	for _, deploy := range release.StackDeploys {
+		var compose composeFile
+		if err := yaml.Unmarshal(deploy.Compose, &compose); err != nil {
+			return Plan{}, fmt.Errorf("stack %q: parse compose: %w", deploy.Name, err)
+		}
+		for key, ref := range compose.Configs {
+			name := externalName(key, ref)
+			if _, ok := configByName[name]; !ok {
+				missing = append(missing, "config "+name)
+			}
+		}
+		for key, ref := range compose.Secrets {
+			name := externalName(key, ref)
+			if _, ok := secretByName[name]; !ok {
+				missing = append(missing, "secret "+name)
+			}
+		}
+		for _, name := range rollbackNetworks(deploy, compose) {
+			if _, ok := networkNames[name]; !ok {
+				missing = append(missing, "network "+name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		missing = sortedUniqueStrings(missing)
+		return Plan{}, fmt.Errorf("release %s references resources that no longer exist (pruned?): %s", release.ID, strings.Join(missing, ", "))
+	}
+	return Plan{StackDeploys: release.StackDeploys}, nil
+}
+
+// rollbackNetworks returns the networks the services of a stack deploy
+// attach to that the deploy does not create itself: external networks, which
+// include the project's managed networks. Stack-scoped networks are created
+// as <stack>_<key> and skipped.
+func rollbackNetworks(deploy StackDeploy, compose composeFile) []string {
+	created := make(map[string]struct{}, len(compose.Networks))
+	var names []string
+	for key, network := range compose.Networks {
+		if network.External {
+			names = append(names, firstNonEmpty(network.Name, key))
+			continue
+		}
+		created[deploy.Name+"_"+key] = struct{}{}
+	}
+	for _, intent := range deploy.Services {
+		for _, name := range intent.Networks {
+			if _, ok := created[name]; !ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func externalName(key string, ref composeExternal) string {
+	if ref.Name != "" {
+		return ref.Name
+	}
+	return key
+}
@@ -0,0 +1,208 @@
+package apply
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/render"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+)
+
+func TestRecordAndListReleases(t *testing.T) {
+	cfg := labelConfig()
+	deploys, err := BuildStackDeploys(cfg, DesiredState{}, nil, []string{"dev"}, nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildStackDeploys: %v", err)
+	}
+	release, err := NewRelease("proj", Plan{StackDeploys: deploys})
+	if err != nil {
+		t.Fatalf("NewRelease: %v", err)
+	}
+	if len(release.Stacks) != 1 || release.Stacks[0].Stack != "app" || release.Stacks[0].Partition != "dev" {
+		t.Fatalf("unexpected release stacks: %#v", release.Stacks)
+	}
+	if got := release.Images[deploys[0].Name+"/web"]; got != "nginx:latest" {
+		t.Fatalf("expected recorded image, got %q", got)
+	}
+	if !release.Touches("app", "dev") || release.Touches("app", "qa") || release.Touches("core", "") {
+		t.Fatalf("unexpected Touches result for %#v", release.Stacks)
+	}
+
+	client := &fakeClient{}
+	if err := RecordRelease(context.Background(), client, release); err != nil {
+		t.Fatalf("RecordRelease: %v", err)
+	}
+	if len(client.configs) != 1 || client.configs[0].Labels[render.LabelRelease] != release.ID {
+		t.Fatalf("expected release config, got %#v", client.configs)
+	}
+	releases, err := ListReleases(context.Background(), client, "proj")
+	if err != nil {
+		t.Fatalf("ListReleases: %v", err)
+	}
+	if len(releases) != 1 || releases[0].ID != release.ID || len(releases[0].StackDeploys) != 1 {
+		t.Fatalf("unexpected releases: %#v", releases)
+	}
+	if string(releases[0].StackDeploys[0].Compose) != string(deploys[0].Compose) {
+		t.Fatalf("expected compose payload to round-trip")
+	}
+
+	plan, err := BuildPlan(context.Background(), client, cfg, DesiredState{}, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.DeleteConfigs) != 0 {
+		t.Fatalf("expected release config to be excluded from pruning, got %#v", plan.DeleteConfigs)
+	}
+}
+
+func TestPruneReleasesKeepsNewest(t *testing.T) {
+	client := &fakeClient{}
+	ids := []string{"20261016T120000Z-aaaaaa", "20261017T090000Z-bbbbbb", "20261015T080000Z-cccccc"}
+	for _, id := range ids {
+		if err := RecordRelease(context.Background(), client, Release{ID: id, Project: "proj"}); err != nil {
+			t.Fatalf("RecordRelease: %v", err)
+		}
+	}
+	client.configs = append(client.configs, swarm.Config{ID: "other", Labels: map[string]string{render.LabelManaged: "true", render.LabelProject: "other", render.LabelRelease: "20200101T000000Z-000000"}})
+
+	if removed, err := PruneReleases(context.Background(), client, "proj", 0); err != nil || removed != 0 {
+		t.Fatalf("expected keep 0 to retain every release, got %d (%v)", removed, err)
+	}
+	removed, err := PruneReleases(context.Background(), client, "proj", 2)
+	if err != nil {
+		t.Fatalf("PruneReleases: %v", err)
+	}
+	want := []string{"config-" + ReleaseConfigName("proj", "20261015T080000Z-cccccc")}
+	if removed != 1 || !reflect.DeepEqual(client.removedConfigs, want) {
+		t.Fatalf("expected the oldest release to be removed, got %d %v", removed, client.removedConfigs)
+	}
+}
+
+func TestRecordReleaseSplitsRecordsOverTheConfigSizeLimit(t *testing.T) {
+	release := Release{ID: "20261017T090000Z-aaaaaa", Project: "proj", Images: make(map[string]string)}
+	seed := make([]byte, 32)
+	for i := range 40000 {
+		sum := sha256.Sum256(append(seed, byte(i), byte(i>>8)))
+		release.Images[fmt.Sprintf("proj_app/svc%d", i)] = hex.EncodeToString(sum[:])
+	}
+	client := &fakeClient{}
+	if err := RecordRelease(context.Background(), client, release); err != nil {
+		t.Fatalf("RecordRelease: %v", err)
+	}
+	if len(client.configs) < 2 {
+		t.Fatalf("expected the record to be split, got %d config(s)", len(client.configs))
+	}
+	for _, cfg := range client.configs {
+		if size := len(client.configData[cfg.ID]); size > releasePartSize {
+			t.Fatalf("config %s holds %d bytes, over the %d byte limit", cfg.Name, size, releasePartSize)
+		}
+	}
+	if head := client.configs[len(client.configs)-1]; head.Name != ReleaseConfigName("proj", release.ID) {
+		t.Fatalf("expected the first part to be created last, got %s", head.Name)
+	}
+
+	releases, err := ListReleases(context.Background(), client, "proj")
+	if err != nil {
+		t.Fatalf("ListReleases: %v", err)
+	}
+	if len(releases) != 1 || !reflect.DeepEqual(releases[0].Images, release.Images) {
+		t.Fatalf("expected the split record to round-trip, got %d release(s)", len(releases))
+	}
+
+	if err := RecordRelease(context.Background(), client, Release{ID: "20261017T100000Z-bbbbbb", Project: "proj"}); err != nil {
+		t.Fatalf("RecordRelease: %v", err)
+	}
+	parts := len(client.configs) - 1
+	removed, err := PruneReleases(context.Background(), client, "proj", 1)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected one release removed, got %d (%v)", removed, err)
+	}
+	if len(client.removedConfigs) != parts {
+		t.Fatalf("expected all %d parts removed, got %v", parts, client.removedConfigs)
+	}
+}
+
+func TestWithoutJobsDropsLifecycleJobs(t *testing.T) {
+	stacks := []StackDeploy{{Name: "proj_app", Jobs: map[string][]JobStep{"api": {{Name: "migrate", Phase: "before_update"}}}}}
+	stripped := WithoutJobs(stacks)
+	if len(stripped[0].Jobs) != 0 || len(stacks[0].Jobs) != 1 {
+		t.Fatalf("expected a copy without jobs, got %#v (original %#v)", stripped, stacks)
+	}
+}
+
+func TestRollbackPlanRequiresPreservedResources(t *testing.T) {
+	compose := []byte(`services:
+  web:
+    image: nginx:1.0
+    configs:
+      - source: app
+    secrets:
+      - source: token
+configs:
+  app:
+    external: true
+    name: proj_app_abc123
+secrets:
+  token:
+    external: true
+    name: proj_token_def456
+networks:
+  proj_internal:
+    external: true
+    name: proj_internal
+  web_ephemeral:
+    internal: true
+`)
+	services := map[string]ServiceIntent{"web": {Image: "nginx:1.0", Mode: "replicated", Networks: []string{"proj_internal", "proj_app_web_ephemeral"}}}
+	release := Release{ID: "r1", StackDeploys: []StackDeploy{{Name: "proj_app", Compose: compose, Services: services}}}
+	client := &fakeClient{configs: []swarm.Config{{ID: "c1", Name: "proj_app_abc123"}}}
+
+	_, err := RollbackPlan(context.Background(), client, release)
+	if err == nil || !strings.Contains(err.Error(), "secret proj_token_def456") || !strings.Contains(err.Error(), "network proj_internal") {
+		t.Fatalf("expected missing secret and network error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "web_ephemeral") {
+		t.Fatalf("expected stack-scoped networks to be recreated, not required, got %v", err)
+	}
+
+	client.secrets = []swarm.Secret{{ID: "s1", Name: "proj_token_def456"}}
+	client.networks = []swarm.Network{{ID: "n1", Name: "proj_internal"}}
+	plan, err := RollbackPlan(context.Background(), client, release)
+	if err != nil {
+		t.Fatalf("RollbackPlan: %v", err)
+	}
+	if len(plan.StackDeploys) != 1 || plan.StackDeploys[0].Name != "proj_app" {
+		t.Fatalf("unexpected rollback plan: %#v", plan)
+	}
+}
+
+func TestReleaseFilter(t *testing.T) {
+	release := Release{
+		Stacks: []ReleaseStack{
+			{Name: "proj_core", Stack: "core"},
+			{Name: "proj_app_dev", Stack: "app", Partition: "dev"},
+			{Name: "proj_app_qa", Stack: "app", Partition: "qa"},
+		},
+		Images: map[string]string{"proj_app_dev/web": "nginx:1", "proj_core/db": "postgres:16"},
+		StackDeploys: []StackDeploy{
+			{Name: "proj_core"},
+			{Name: "proj_app_dev"},
+			{Name: "proj_app_qa"},
+		},
+	}
+	filtered := release.Filter([]string{"app"}, []string{"dev"})
+	if len(filtered.StackDeploys) != 1 || filtered.StackDeploys[0].Name != "proj_app_dev" {
+		t.Fatalf("unexpected filtered deploys: %#v", filtered.StackDeploys)
+	}
+	if len(filtered.Images) != 1 || filtered.Images["proj_app_dev/web"] != "nginx:1" {
+		t.Fatalf("unexpected filtered images: %#v", filtered.Images)
+	}
+	if shared := release.Filter(nil, []string{"qa"}); len(shared.StackDeploys) != 2 {
+		t.Fatalf("expected shared stack to match any partition, got %#v", shared.StackDeploys)
+	}
+}
@@ -0,0 +1,240 @@
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+	"github.com/docker/go-units"
+)
+
+type composeResources struct {
+	Limits       *composeResourceLimits       `yaml:"limits,omitempty"`
+	Reservations *composeResourceReservations `yaml:"reservations,omitempty"`
+}
+
+type composeResourceLimits struct {
+	CPUs   string `yaml:"cpus,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+	Pids   *int64 `yaml:"pids,omitempty"`
+}
+
+type composeResourceReservations struct {
+	CPUs             string                   `yaml:"cpus,omitempty"`
+	Memory           string                   `yaml:"memory,omitempty"`
+	GenericResources []composeGenericResource `yaml:"generic_resources,omitempty"`
+}
+
+type composeGenericResource struct {
+	DiscreteResourceSpec *composeDiscreteResource `yaml:"discrete_resource_spec,omitempty"`
+	NamedResourceSpec    *composeNamedResource    `yaml:"named_resource_spec,omitempty"`
+}
+
+type composeDiscreteResource struct {
+	Kind  string `yaml:"kind"`
+	Value int64  `yaml:"value"`
+}
+
+type composeNamedResource struct {
+	Kind  string `yaml:"kind"`
+	Value string `yaml:"value"`
+}
+
+func swarmResources(resources *config.Resources) (*dockerapi.ResourceRequirements, error) {
+	if resources == nil {
+		return nil, nil
+	}
+	out := &dockerapi.ResourceRequirements{}
+	if limits := resources.Limits; limits != nil {
+		limit := &dockerapi.Limit{}
+		if limits.CPUs != nil {
+			value, err := config.ParseResourceCPUs("limits", *limits.CPUs)
+			if err != nil {
+				return nil, err
+			}
+			limit.NanoCPUs = value
+		}
+		if limits.Memory != nil {
+			value, err := config.ParseResourceMemory("limits", *limits.Memory)
+			if err != nil {
+				return nil, err
+			}
+			limit.MemoryBytes = value
+		}
+		if limits.Pids != nil {
+			if *limits.Pids < 0 {
+				return nil, fmt.Errorf("resources.limits.pids: must be >= 0")
+			}
+			limit.Pids = *limits.Pids
+		}
+		out.Limits = limit
+	}
+	if reservations := resources.Reservations; reservations != nil {
+		reservation := &dockerapi.Resources{}
+		if reservations.CPUs != nil {
+			value, err := config.ParseResourceCPUs("reservations", *reservations.CPUs)
+			if err != nil {
+				return nil, err
+			}
+			reservation.NanoCPUs = value
+		}
+		if reservations.Memory != nil {
+			value, err := config.ParseResourceMemory("reservations", *reservations.Memory)
+			if err != nil {
+				return nil, err
+			}
+			reservation.MemoryBytes = value
+		}
+		for _, generic := range reservations.GenericResources {
+			kind := strings.TrimSpace(generic.Kind)
+			if kind == "" {
+				return nil, fmt.Errorf("resources.reservations.generic_resources: kind is required")
+			}
+			if generic.Value != nil {
+				reservation.GenericResources = append(reservation.GenericResources, dockerapi.GenericResource{
+					DiscreteResourceSpec: &dockerapi.DiscreteGenericResource{Kind: kind, Value: *generic.Value},
+				})
+				continue
+			}
+			reservation.GenericResources = append(reservation.GenericResources, dockerapi.GenericResource{
+				NamedResourceSpec: &dockerapi.NamedGenericResource{Kind: kind, Value: generic.Named},
+			})
+		}
+		out.Reservations = reservation
+	}
+	return canonicalizeResources(out), nil
+}
+
+func composeResourcesSpec(resources *config.Resources) (*composeResources, error) {
+	converted, err := swarmResources(resources)
+	if err != nil || converted == nil {
+		return nil, err
+	}
+	out := &composeResources{}
+	if limits := converted.Limits; limits != nil {
+		out.Limits = &composeResourceLimits{
+			CPUs:   formatNanoCPUs(limits.NanoCPUs),
+			Memory: formatMemoryBytes(limits.MemoryBytes),
+		}
+		if limits.Pids != 0 {
+			out.Limits.Pids = new(limits.Pids)
+		}
+	}
+	if reservations := converted.Reservations; reservations != nil {
+		out.Reservations = &composeResourceReservations{
+			CPUs:   formatNanoCPUs(reservations.NanoCPUs),
+			Memory: formatMemoryBytes(reservations.MemoryBytes),
+		}
+		for _, generic := range reservations.GenericResources {
+			entry := composeGenericResource{}
+			if generic.DiscreteResourceSpec != nil {
+				entry.DiscreteResourceSpec = &composeDiscreteResource{Kind: generic.DiscreteResourceSpec.Kind, Value: generic.DiscreteResourceSpec.Value}
+			}
+			if generic.NamedResourceSpec != nil {
+				entry.NamedResourceSpec = &composeNamedResource{Kind: generic.NamedResourceSpec.Kind, Value: generic.NamedResourceSpec.Value}
+			}
+			out.Reservations.GenericResources = append(out.Reservations.GenericResources, entry)
+		}
+	}
+	return out, nil
+}
+
+// canonicalizeResources drops empty limits/reservations, which swarm reports
+// for services without resource settings, and orders generic resources.
+func canonicalizeResources(resources *dockerapi.ResourceRequirements) *dockerapi.ResourceRequirements {
+	if resources == nil {
+		return nil
+	}
+	out := &dockerapi.ResourceRequirements{}
+	if limits := resources.Limits; limits != nil && (limits.NanoCPUs != 0 || limits.MemoryBytes != 0 || limits.Pids != 0) {
+		out.Limits = &dockerapi.Limit{NanoCPUs: limits.NanoCPUs, MemoryBytes: limits.MemoryBytes, Pids: limits.Pids}
+	}
+	if reservations := resources.Reservations; reservations != nil && (reservations.NanoCPUs != 0 || reservations.MemoryBytes != 0 || len(reservations.GenericResources) > 0) {
+		generic := append([]dockerapi.GenericResource(nil), reservations.GenericResources...)
+		sort.Slice(generic, func(i, j int) bool { return formatGenericResource(generic[i]) < formatGenericResource(generic[j]) })
+		out.Reservations = &dockerapi.Resources{
+			NanoCPUs:         reservations.NanoCPUs,
+			MemoryBytes:      reservations.MemoryBytes,
+			GenericResources: generic,
+		}
+	}
+	if out.Limits == nil && out.Reservations == nil {
+		return nil
+	}
+	return out
+}
+
+func cloneResources(resources *dockerapi.ResourceRequirements) *dockerapi.ResourceRequirements {
+	return canonicalizeResources(resources)
+}
+
+func resourcesEqual(left, right *dockerapi.ResourceRequirements) bool {
+	return formatResources(left) == formatResources(right)
+}
+
+func formatResources(resources *dockerapi.ResourceRequirements) string {
+	resources = canonicalizeResources(resources)
+	if resources == nil {
+		return "{}"
+	}
+	var parts []string
+	if limits := resources.Limits; limits != nil {
+		parts = append(parts, "limits="+formatResourceValues(limits.NanoCPUs, limits.MemoryBytes, limits.Pids, nil))
+	}
+	if reservations := resources.Reservations; reservations != nil {
+		parts = append(parts, "reservations="+formatResourceValues(reservations.NanoCPUs, reservations.MemoryBytes, 0, reservations.GenericResources))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func formatResourceValues(nanoCPUs int64, memory int64, pids int64, generic []dockerapi.GenericResource) string {
+	var parts []string
+	if nanoCPUs != 0 {
+		parts = append(parts, "cpus="+formatNanoCPUs(nanoCPUs))
+	}
+	if memory != 0 {
+		parts = append(parts, "memory="+formatMemoryBytes(memory))
+	}
+	if pids != 0 {
+		parts = append(parts, fmt.Sprintf("pids=%d", pids))
+	}
+	for _, item := range generic {
+		parts = append(parts, formatGenericResource(item))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func formatGenericResource(resource dockerapi.GenericResource) string {
+	if resource.DiscreteResourceSpec != nil {
+		return fmt.Sprintf("%s=%d", resource.DiscreteResourceSpec.Kind, resource.DiscreteResourceSpec.Value)
+	}
+	if resource.NamedResourceSpec != nil {
+		return resource.NamedResourceSpec.Kind + "=" + resource.NamedResourceSpec.Value
+	}
+	return ""
+}
+
+func formatNanoCPUs(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(value)/1e9, 'f', -1, 64)
+}
+
+func formatMemoryBytes(value int64) string {
+	if value == 0 {
+		return ""
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"G", units.GiB}, {"M", units.MiB}, {"K", units.KiB}} {
+		if value%unit.size == 0 {
+			return fmt.Sprintf("%d%s", value/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(value, 10)
+}
@@ -0,0 +1,56 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func TestSwarmResources(t *testing.T) {
+	resources := &config.Resources{
+		Limits: &config.ResourceLimits{CPUs: new("1.5"), Memory: new("512M"), Pids: new(int64(100))},
+		Reservations: &config.ResourceReservations{
+			CPUs:   new("0.25"),
+			Memory: new("128m"),
+			GenericResources: []config.GenericResource{
+				{Kind: "gpu", Value: new(int64(2))},
+				{Kind: "fpga", Named: "fpga-0"},
+			},
+		},
+	}
+	converted, err := swarmResources(resources)
+	if err != nil {
+		t.Fatalf("swarmResources: %v", err)
+	}
+	if converted.Limits.NanoCPUs != 1_500_000_000 || converted.Limits.MemoryBytes != 512<<20 || converted.Limits.Pids != 100 {
+		t.Fatalf("unexpected limits: %+v", converted.Limits)
+	}
+	if converted.Reservations.NanoCPUs != 250_000_000 || converted.Reservations.MemoryBytes != 128<<20 {
+		t.Fatalf("unexpected reservations: %+v", converted.Reservations)
+	}
+	if got := formatResources(converted); got != "{limits={cpus=1.5, memory=512M, pids=100}, reservations={cpus=0.25, memory=128M, fpga=fpga-0, gpu=2}}" {
+		t.Fatalf("unexpected format: %s", got)
+	}
+
+}
+
+func TestIntentDiffsResources(t *testing.T) {
+	empty := serviceIntent{Resources: &dockerapi.ResourceRequirements{Limits: &dockerapi.Limit{}, Reservations: &dockerapi.Resources{}}}
+	if diffs := intentDiffs(empty, serviceIntent{}); stringSliceContains(diffs, "resources") {
+		t.Fatalf("expected empty swarm resources to match unset resources, got %v", diffs)
+	}
+	current := serviceIntent{Resources: &dockerapi.ResourceRequirements{Limits: &dockerapi.Limit{MemoryBytes: 256 << 20}}}
+	desired := serviceIntent{Resources: &dockerapi.ResourceRequirements{Limits: &dockerapi.Limit{MemoryBytes: 512 << 20}}}
+	diffs := intentDiffs(current, desired)
+	if !stringSliceContains(diffs, "resources") {
+		t.Fatalf("expected resources diff, got %v", diffs)
+	}
+	details := intentDetails(current, desired, diffs)
+	if len(details) != 1 || details[0].Current != "{limits={memory=256M}}" || details[0].Desired != "{limits={memory=512M}}" {
+		t.Fatalf("unexpected details: %+v", details)
+	}
+	if intentEqual(current, desired) {
+		t.Fatalf("expected intents with different limits to differ")
+	}
+}
@@ -0,0 +1,92 @@
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type RolloutService struct {
+	Stack   string `yaml:"stack" json:"stack"`
+	Service string `yaml:"service" json:"service"`
+}
+
+func (s RolloutService) Name() string {
+	return s.Stack + "_" + s.Service
+}
+
+type RolloutBatch struct {
+	Services []RolloutService `yaml:"services" json:"services"`
+}
+
+func (b RolloutBatch) Names() []string {
+	out := make([]string, 0, len(b.Services))
+	for _, svc := range b.Services {
+		out = append(out, svc.Name())
+	}
+	return out
+}
+
+// BuildRollout orders the services of the given stacks into batches. Every
+// service in a batch only depends on services from earlier batches; services
+// outside the deployed set are assumed to be running already.
+func BuildRollout(stacks []StackDeploy) ([]RolloutBatch, error) {
+	nodes := make(map[string]RolloutService)
+	deps := make(map[string][]string)
+	for _, deploy := range stacks {
+		for key := range deploy.Services {
+			svc := RolloutService{Stack: deploy.Name, Service: key}
+			nodes[svc.Name()] = svc
+			deps[svc.Name()] = deploy.DependsOn[key]
+		}
+	}
+
+	remaining := make(map[string]int, len(nodes))
+	dependents := make(map[string][]string)
+	for name := range nodes {
+		for _, dep := range deps[name] {
+			if _, ok := nodes[dep]; !ok || dep == name {
+				continue
+			}
+			remaining[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready []string
+	for name := range nodes {
+		if remaining[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	var batches []RolloutBatch
+	placed := 0
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		batch := RolloutBatch{Services: make([]RolloutService, 0, len(ready))}
+		var next []string
+		for _, name := range ready {
+			batch.Services = append(batch.Services, nodes[name])
+			for _, dependent := range dependents[name] {
+				remaining[dependent]--
+				if remaining[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		placed += len(ready)
+		batches = append(batches, batch)
+		ready = next
+	}
+	if placed != len(nodes) {
+		var blocked []string
+		for name := range nodes {
+			if remaining[name] > 0 {
+				blocked = append(blocked, name)
+			}
+		}
+		sort.Strings(blocked)
+		return nil, fmt.Errorf("depends_on: dependency cycle between %s", strings.Join(blocked, ", "))
+	}
+	return batches, nil
+}
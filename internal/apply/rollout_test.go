@@ -0,0 +1,175 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+)
+
+func TestBuildStackDeploysResolvesDependsOn(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{Name: "proj", Partitions: []string{"dev"}},
+		Stacks: map[string]config.Stack{
+			"core": {
+				Mode: "shared",
+				Services: map[string]config.Service{
+					"db": {Image: "postgres:16"},
+				},
+			},
+			"app": {
+				Mode: "partitioned",
+				Services: map[string]config.Service{
+					"api": {Image: "api:latest", DependsOn: []string{"core/db"}},
+					"web": {Image: "web:latest", DependsOn: []string{"api"}},
+				},
+			},
+		},
+	}
+	deploys, err := BuildStackDeploys(cfg, DesiredState{}, nil, nil, nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildStackDeploys: %v", err)
+	}
+	var app StackDeploy
+	for _, deploy := range deploys {
+		if deploy.Name == "proj_dev_app" {
+			app = deploy
+		}
+	}
+	want := map[string][]string{
+		"api": {"proj_core_db"},
+		"web": {"proj_dev_app_api"},
+	}
+	if !reflect.DeepEqual(app.DependsOn, want) {
+		t.Fatalf("unexpected depends_on %v", app.DependsOn)
+	}
+
+	batches, err := BuildRollout(deploys)
+	if err != nil {
+		t.Fatalf("BuildRollout: %v", err)
+	}
+	got := rolloutNames(batches)
+	expected := [][]string{{"proj_core_db"}, {"proj_dev_app_api"}, {"proj_dev_app_web"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected rollout %v", got)
+	}
+}
+
+func TestBuildRolloutGroupsIndependentServices(t *testing.T) {
+	batches, err := BuildRollout(rolloutTestStacks())
+	if err != nil {
+		t.Fatalf("BuildRollout: %v", err)
+	}
+	got := rolloutNames(batches)
+	expected := [][]string{{"proj_core_cache", "proj_core_db"}, {"proj_app_web"}, {"proj_app_worker"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected rollout %v", got)
+	}
+}
+
+func TestBuildRolloutIgnoresDependenciesOutsidePlan(t *testing.T) {
+	stacks := rolloutTestStacks()[1:]
+	batches, err := BuildRollout(stacks)
+	if err != nil {
+		t.Fatalf("BuildRollout: %v", err)
+	}
+	got := rolloutNames(batches)
+	expected := [][]string{{"proj_app_web"}, {"proj_app_worker"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected rollout %v", got)
+	}
+}
+
+func TestBuildRolloutRejectsCycle(t *testing.T) {
+	stacks := rolloutTestStacks()
+	stacks[0].DependsOn = map[string][]string{"db": {"proj_app_worker"}}
+	if _, err := BuildRollout(stacks); err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+}
+
+func TestRolloutStacksSkipsDependentsOfFailedService(t *testing.T) {
+	client := &fakeClient{createErrors: map[string]error{"proj_core_db": errors.New("boom")}}
+	inventory, err := loadDeployInventory(context.Background(), client)
+	if err != nil {
+		t.Fatalf("loadDeployInventory: %v", err)
+	}
+	results, errs, err := rolloutStacks(context.Background(), client, rolloutTestStacks(), inventory, deployOptions{parallel: 1}, rolloutHooks{})
+	if err != nil {
+		t.Fatalf("rolloutStacks: %v", err)
+	}
+	if len(client.createdServices) != 1 || client.createdServices[0].Annotations.Name != "proj_core_cache" {
+		t.Fatalf("expected only proj_core_cache to be created, got %d creates", len(client.createdServices))
+	}
+	if errs[0] == nil || errs[1] == nil {
+		t.Fatalf("expected both stacks to fail, got %v", errs)
+	}
+	if results[1].Count(ServiceActionSkipped) != 2 {
+		t.Fatalf("expected dependents to be skipped, got %+v", results[1].Services)
+	}
+}
+
+func rolloutTestStacks() []StackDeploy {
+	return []StackDeploy{
+		{
+			Name:    "proj_core",
+			Compose: []byte("services:\n  db:\n    image: postgres:16\n  cache:\n    image: redis:7\n"),
+			Services: map[string]ServiceIntent{
+				"db":    {Image: "postgres:16", Mode: "replicated"},
+				"cache": {Image: "redis:7", Mode: "replicated"},
+			},
+		},
+		{
+			Name:    "proj_app",
+			Compose: []byte("services:\n  web:\n    image: web:latest\n  worker:\n    image: worker:latest\n"),
+			Services: map[string]ServiceIntent{
+				"web":    {Image: "web:latest", Mode: "replicated"},
+				"worker": {Image: "worker:latest", Mode: "replicated"},
+			},
+			DependsOn: map[string][]string{
+				"web":    {"proj_core_db"},
+				"worker": {"proj_app_web"},
+			},
+		},
+	}
+}
+
+func rolloutNames(batches []RolloutBatch) [][]string {
+	out := make([][]string, 0, len(batches))
+	for _, batch := range batches {
+		out = append(out, batch.Names())
+	}
+	return out
+}
+
+func TestRolloutStacksStopsWhenLeaseIsLost(t *testing.T) {
+	client := &fakeClient{}
+	ctx := context.Background()
+	lease, err := AcquireLock(ctx, client, LockScope{Project: "proj"}, "apply", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := BreakLock(ctx, client, LockScope{Project: "proj"}); err != nil {
+		t.Fatalf("break: %v", err)
+	}
+	inventory, err := loadDeployInventory(ctx, client)
+	if err != nil {
+		t.Fatalf("loadDeployInventory: %v", err)
+	}
+	_, errs, err := rolloutStacks(ctx, client, rolloutTestStacks(), inventory, deployOptions{parallel: 1, pruneServices: true, lease: lease}, rolloutHooks{})
+	if err != nil {
+		t.Fatalf("rolloutStacks: %v", err)
+	}
+	if len(client.createdServices) != 0 || len(client.removedServices) != 0 {
+		t.Fatalf("expected no service changes after the lease was lost, got %d creates %d removes", len(client.createdServices), len(client.removedServices))
+	}
+	for i, err := range errs {
+		if !errors.Is(err, ErrLockLost) {
+			t.Fatalf("expected stack %d to fail with a lost lease, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+// runtimeIntent holds container runtime options. Tmpfs mounts, ulimits and
+// hosts are kept in their canonical string forms so they compare and print
+// without further conversion.
+type runtimeIntent struct {
+	User            string
+	Hostname        string
+	Init            *bool
+	ReadOnly        bool
+	StopSignal      string
+	StopGracePeriod *time.Duration
+	Tmpfs           []string
+	CapAdd          []string
+	CapDrop         []string
+	Sysctls         map[string]string
+	Ulimits         []string
+	Hosts           []string
+	DNS             []string
+	DNSSearch       []string
+	DNSOptions      []string
+}
+
+type composeUlimit struct {
+	Soft int64 `yaml:"soft"`
+	Hard int64 `yaml:"hard"`
+}
+
+// runtimeFields lists the diff field names of runtime options in report order.
+var runtimeFields = []struct {
+	name   string
+	format func(runtimeIntent) string
+}{
+	{"user", func(r runtimeIntent) string { return r.User }},
+	{"hostname", func(r runtimeIntent) string { return r.Hostname }},
+	{"init", func(r runtimeIntent) string { return formatOptionalBool(r.Init) }},
+	{"read_only", func(r runtimeIntent) string { return strconv.FormatBool(r.ReadOnly) }},
+	{"stop_signal", func(r runtimeIntent) string { return r.StopSignal }},
+	{"stop_grace_period", func(r runtimeIntent) string { return formatOptionalDuration(r.StopGracePeriod) }},
+	{"tmpfs", func(r runtimeIntent) string { return formatStringSliceSorted(r.Tmpfs) }},
+	{"cap_add", func(r runtimeIntent) string { return formatStringSliceSorted(r.CapAdd) }},
+	{"cap_drop", func(r runtimeIntent) string { return formatStringSliceSorted(r.CapDrop) }},
+	{"sysctls", func(r runtimeIntent) string { return formatLabels(r.Sysctls) }},
+	{"ulimits", func(r runtimeIntent) string { return formatStringSliceSorted(r.Ulimits) }},
+	{"extra_hosts", func(r runtimeIntent) string { return formatStringSliceSorted(r.Hosts) }},
+	{"dns", func(r runtimeIntent) string { return formatStringSlice(r.DNS) }},
+	{"dns_search", func(r runtimeIntent) string { return formatStringSlice(r.DNSSearch) }},
+	{"dns_opt", func(r runtimeIntent) string { return formatStringSliceSorted(r.DNSOptions) }},
+}
+
+func runtimeFromConfig(service config.Service) (runtimeIntent, error) {
+	grace, err := config.ParseStopGracePeriod(service.StopGracePeriod)
+	if err != nil {
+		return runtimeIntent{}, err
+	}
+	out := runtimeIntent{
+		User:            strings.TrimSpace(service.User),
+		Hostname:        strings.TrimSpace(service.Hostname),
+		Init:            service.Init,
+		ReadOnly:        service.ReadOnly,
+		StopSignal:      strings.TrimSpace(service.StopSignal),
+		StopGracePeriod: grace,
+		CapAdd:          cloneStrings(service.CapAdd),
+		CapDrop:         cloneStrings(service.CapDrop),
+		Sysctls:         cloneLabels(service.Sysctls),
+		DNS:             cloneStrings(service.DNS),
+		DNSSearch:       cloneStrings(service.DNSSearch),
+		DNSOptions:      cloneStrings(service.DNSOptions),
+	}
+	for _, entry := range service.Tmpfs {
+		tmpfs, err := config.ParseTmpfs(entry)
+		if err != nil {
+			return runtimeIntent{}, err
+		}
+		out.Tmpfs = append(out.Tmpfs, formatTmpfs(tmpfs.Target, tmpfs.SizeBytes, uint32(tmpfs.Mode)))
+	}
+	for name, limit := range service.Ulimits {
+		out.Ulimits = append(out.Ulimits, formatUlimit(name, limit.Soft, limit.Hard))
+	}
+	sort.Strings(out.Ulimits)
+	for _, entry := range service.ExtraHosts {
+		host, ip, err := config.ParseExtraHost(entry)
+		if err != nil {
+			return runtimeIntent{}, err
+		}
+		out.Hosts = append(out.Hosts, ip+" "+host)
+	}
+	return out, nil
+}
+
+func runtimeFromSpec(spec *dockerapi.ContainerSpec) runtimeIntent {
+	if spec == nil {
+		return runtimeIntent{}
+	}
+	out := runtimeIntent{
+		User:            spec.User,
+		Hostname:        spec.Hostname,
+		Init:            spec.Init,
+		ReadOnly:        spec.ReadOnly,
+		StopSignal:      spec.StopSignal,
+		StopGracePeriod: spec.StopGracePeriod,
+		CapAdd:          cloneStrings(spec.CapabilityAdd),
+		CapDrop:         cloneStrings(spec.CapabilityDrop),
+		Sysctls:         cloneLabels(spec.Sysctls),
+		Hosts:           cloneStrings(spec.Hosts),
+	}
+	for _, m := range spec.Mounts {
+		if m.Type != mount.TypeTmpfs {
+			continue
+		}
+		var size int64
+		var mode uint32
+		if m.TmpfsOptions != nil {
+			size = m.TmpfsOptions.SizeBytes
+			mode = uint32(m.TmpfsOptions.Mode)
+		}
+		out.Tmpfs = append(out.Tmpfs, formatTmpfs(m.Target, size, mode))
+	}
+	for _, limit := range spec.Ulimits {
+		if limit != nil {
+			out.Ulimits = append(out.Ulimits, formatUlimit(limit.Name, limit.Soft, limit.Hard))
+		}
+	}
+	sort.Strings(out.Ulimits)
+	if spec.DNSConfig != nil {
+		out.DNS = cloneStrings(spec.DNSConfig.Nameservers)
+		out.DNSSearch = cloneStrings(spec.DNSConfig.Search)
+		out.DNSOptions = cloneStrings(spec.DNSConfig.Options)
+	}
+	return out
+}
+
+func applyRuntime(spec *dockerapi.ContainerSpec, runtime runtimeIntent) {
+	spec.User = runtime.User
+	spec.Hostname = runtime.Hostname
+	spec.Init = runtime.Init
+	spec.ReadOnly = runtime.ReadOnly
+	spec.StopSignal = runtime.StopSignal
+	spec.StopGracePeriod = runtime.StopGracePeriod
+	spec.CapabilityAdd = cloneStrings(runtime.CapAdd)
+	spec.CapabilityDrop = cloneStrings(runtime.CapDrop)
+	spec.Sysctls = cloneLabels(runtime.Sysctls)
+	spec.Hosts = cloneStrings(runtime.Hosts)
+	spec.DNSConfig = nil
+	if len(runtime.DNS) > 0 || len(runtime.DNSSearch) > 0 || len(runtime.DNSOptions) > 0 {
+		spec.DNSConfig = &dockerapi.DNSConfig{
+			Nameservers: cloneStrings(runtime.DNS),
+			Search:      cloneStrings(runtime.DNSSearch),
+			Options:     cloneStrings(runtime.DNSOptions),
+		}
+	}
+	spec.Ulimits = nil
+	for _, entry := range runtime.Ulimits {
+		name, soft, hard := parseUlimit(entry)
+		spec.Ulimits = append(spec.Ulimits, &container.Ulimit{Name: name, Soft: soft, Hard: hard})
+	}
+	for _, entry := range runtime.Tmpfs {
+		spec.Mounts = append(spec.Mounts, tmpfsMount(entry))
+	}
+}
+
+func runtimeDiffs(current, desired runtimeIntent) []string {
+	current = canonicalizeRuntime(current)
+	desired = canonicalizeRuntime(desired)
+	var diffs []string
+	for _, field := range runtimeFields {
+		if field.format(current) != field.format(desired) {
+			diffs = append(diffs, field.name)
+		}
+	}
+	return diffs
+}
+
+func runtimeDetail(name string, current, desired runtimeIntent) (IntentDetail, bool) {
+	for _, field := range runtimeFields {
+		if field.name == name {
+			return IntentDetail{Field: name, Current: field.format(current), Desired: field.format(desired)}, true
+		}
+	}
+	return IntentDetail{}, false
+}
+
+// canonicalizeRuntime maps values swarm reports for unset options onto their
+// unset form.
+func canonicalizeRuntime(runtime runtimeIntent) runtimeIntent {
+	if runtime.StopGracePeriod != nil && *runtime.StopGracePeriod == 0 {
+		runtime.StopGracePeriod = nil
+	}
+	if len(runtime.Sysctls) == 0 {
+		runtime.Sysctls = nil
+	}
+	return runtime
+}
+
+func composeRuntime(svc *composeService, service config.Service) {
+	svc.User = service.User
+	svc.Hostname = service.Hostname
+	svc.Init = service.Init
+	svc.ReadOnly = service.ReadOnly
+	svc.StopSignal = service.StopSignal
+	svc.StopGracePeriod = service.StopGracePeriod
+	svc.Tmpfs = cloneStrings(service.Tmpfs)
+	svc.CapAdd = cloneStrings(service.CapAdd)
+	svc.CapDrop = cloneStrings(service.CapDrop)
+	svc.Sysctls = cloneLabels(service.Sysctls)
+	svc.ExtraHosts = cloneStrings(service.ExtraHosts)
+	svc.DNS = cloneStrings(service.DNS)
+	svc.DNSSearch = cloneStrings(service.DNSSearch)
+	svc.DNSOpt = cloneStrings(service.DNSOptions)
+	if len(service.Ulimits) > 0 {
+		svc.Ulimits = make(map[string]composeUlimit, len(service.Ulimits))
+		for name, limit := range service.Ulimits {
+			svc.Ulimits[name] = composeUlimit(limit)
+		}
+	}
+}
+
+// withoutTmpfsMounts drops tmpfs mounts, which belong to the runtime intent
+// rather than to volume mounts.
+func withoutTmpfsMounts(mounts []mount.Mount) []mount.Mount {
+	var out []mount.Mount
+	for _, m := range mounts {
+		if m.Type != mount.TypeTmpfs {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func formatTmpfs(target string, size int64, mode uint32) string {
+	var options []string
+	if size > 0 {
+		options = append(options, "size="+formatMemoryBytes(size))
+	}
+	if mode != 0 {
+		options = append(options, "mode="+strconv.FormatUint(uint64(mode), 8))
+	}
+	if len(options) == 0 {
+		return target
+	}
+	return target + ":" + strings.Join(options, ",")
+}
+
+func tmpfsMount(entry string) mount.Mount {
+	parsed, err := config.ParseTmpfs(entry)
+	if err != nil {
+		return mount.Mount{Type: mount.TypeTmpfs, Target: entry}
+	}
+	out := mount.Mount{Type: mount.TypeTmpfs, Target: parsed.Target}
+	if parsed.SizeBytes != 0 || parsed.Mode != 0 {
+		out.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: parsed.SizeBytes, Mode: parsed.Mode}
+	}
+	return out
+}
+
+func formatUlimit(name string, soft int64, hard int64) string {
+	return fmt.Sprintf("%s=%d:%d", name, soft, hard)
+}
+
+func parseUlimit(entry string) (string, int64, int64) {
+	name, limits, _ := strings.Cut(entry, "=")
+	softRaw, hardRaw, _ := strings.Cut(limits, ":")
+	soft, _ := strconv.ParseInt(softRaw, 10, 64)
+	hard, _ := strconv.ParseInt(hardRaw, 10, 64)
+	return name, soft, hard
+}
+
+func formatOptionalBool(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
+
+func formatOptionalDuration(value *time.Duration) string {
+	if value == nil {
+		return ""
+	}
+	return value.String()
+}
@@ -0,0 +1,79 @@
+package apply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/docker/docker/api/types/mount"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func TestRuntimeOptionsRoundTrip(t *testing.T) {
+	service := config.Service{
+		Image:           "api:latest",
+		User:            "1000:1000",
+		Hostname:        "api",
+		Init:            new(true),
+		ReadOnly:        true,
+		StopSignal:      "SIGINT",
+		StopGracePeriod: "30s",
+		Tmpfs:           []string{"/run", "/tmp:size=64m,mode=1777"},
+		CapAdd:          []string{"NET_ADMIN"},
+		CapDrop:         []string{"ALL"},
+		Sysctls:         map[string]string{"net.core.somaxconn": "1024"},
+		Ulimits:         map[string]config.Ulimit{"nofile": {Soft: 20000, Hard: 40000}},
+		ExtraHosts:      []string{"db:10.0.0.5"},
+		DNS:             []string{"10.0.0.2"},
+		DNSSearch:       []string{"svc.local"},
+		DNSOptions:      []string{"ndots:2"},
+	}
+	volumes := []mount.Mount{{Type: mount.TypeVolume, Source: "data", Target: "/data"}}
+	intent, err := intentFromConfig(service, nil, nil, nil, nil, volumes, nil, nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("intentFromConfig: %v", err)
+	}
+	spec := applyIntentToSpec(dockerapi.ServiceSpec{}, intent)
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	if containerSpec.User != "1000:1000" || containerSpec.Init == nil || !*containerSpec.Init || !containerSpec.ReadOnly {
+		t.Fatalf("unexpected container spec: %+v", containerSpec)
+	}
+	if len(containerSpec.Hosts) != 1 || containerSpec.Hosts[0] != "10.0.0.5 db" {
+		t.Fatalf("expected swarm hosts notation, got %v", containerSpec.Hosts)
+	}
+	if len(containerSpec.Mounts) != 3 || containerSpec.Mounts[2].TmpfsOptions == nil || containerSpec.Mounts[2].TmpfsOptions.SizeBytes != 64<<20 {
+		t.Fatalf("unexpected mounts: %+v", containerSpec.Mounts)
+	}
+	if len(containerSpec.Ulimits) != 1 || containerSpec.Ulimits[0].Soft != 20000 || containerSpec.Ulimits[0].Hard != 40000 {
+		t.Fatalf("unexpected ulimits: %+v", containerSpec.Ulimits)
+	}
+	current := intentFromSpec(spec, nil)
+	if len(current.Volumes) != 1 {
+		t.Fatalf("expected tmpfs mounts to stay out of volumes, got %+v", current.Volumes)
+	}
+	if diffs := intentDiffs(current, intent); len(diffs) != 0 {
+		t.Fatalf("expected spec round trip to match intent, got %v", diffs)
+	}
+
+}
+
+func TestIntentDiffsRuntimeOptions(t *testing.T) {
+	current := serviceIntent{Runtime: runtimeIntent{StopGracePeriod: new(time.Duration(0))}}
+	desired := serviceIntent{}
+	if diffs := intentDiffs(current, desired); len(diffs) != 0 {
+		t.Fatalf("expected zero stop grace period to match unset, got %v", diffs)
+	}
+	desired.Runtime.User = "nobody"
+	desired.Runtime.CapDrop = []string{"ALL"}
+	diffs := intentDiffs(current, desired)
+	if !stringSliceContains(diffs, "user") || !stringSliceContains(diffs, "cap_drop") || len(diffs) != 2 {
+		t.Fatalf("unexpected diffs: %v", diffs)
+	}
+	details := intentDetails(current, desired, diffs)
+	if len(details) != 2 || details[0].Desired != "nobody" || details[1].Desired != "[ALL]" {
+		t.Fatalf("unexpected details: %+v", details)
+	}
+	if intentEqual(current, desired) {
+		t.Fatalf("expected runtime differences to be detected")
+	}
+}
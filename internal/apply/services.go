@@ -20,11 +20,11 @@ import (
 )
 
 type ServiceMount struct {
-	Name   string
-	Target string
-	UID    string
-	GID    string
-	Mode   os.FileMode
+	Name   string      `yaml:"name" json:"name"`
+	Target string      `yaml:"target" json:"target"`
+	UID    string      `yaml:"uid,omitempty" json:"uid,omitempty"`
+	GID    string      `yaml:"gid,omitempty" json:"gid,omitempty"`
+	Mode   os.FileMode `yaml:"mode" json:"mode"`
 }
 
 type ServiceUpdate struct {
@@ -84,6 +84,7 @@ func buildServiceChanges(cfg *config.Config, desired DesiredState, values any, s
 				if err != nil {
 					return nil, nil, err
 				}
+				build.pinImage(desired.ImageDigests)
 				desiredIntent := build.Intent
 				if !ok {
 					spec := dockerapi.ServiceSpec{
@@ -131,18 +132,25 @@ type serviceIntent struct {
 	Workdir        string
 	Env            []string
 	Ports          []portIntent
+	EndpointMode   string
 	Mode           string
 	Replicas       uint64
 	Labels         map[string]string
 	Constraints    []string
+	Preferences    []string
+	MaxReplicas    uint64
+	Platforms      []string
 	Healthcheck    *container.HealthConfig
 	RestartPolicy  *dockerapi.RestartPolicy
 	UpdateConfig   *dockerapi.UpdateConfig
 	RollbackConfig *dockerapi.UpdateConfig
+	Resources      *dockerapi.ResourceRequirements
+	LogDriver      *dockerapi.Driver
 	Configs        []ServiceMount
 	Secrets        []ServiceMount
 	Volumes        []mount.Mount
 	Networks       []string
+	Runtime        runtimeIntent
 }
 
 type portIntent struct {
@@ -152,7 +160,7 @@ type portIntent struct {
 	Mode      dockerapi.PortConfigPublishMode
 }
 
-func intentFromConfig(service config.Service, labels map[string]string, constraints []string, configs []ServiceMount, secrets []ServiceMount, volumes []mount.Mount, networks []string, restartPolicy *config.RestartPolicy, updateConfig *config.UpdatePolicy, rollbackConfig *config.UpdatePolicy) (serviceIntent, error) {
+func intentFromConfig(service config.Service, labels map[string]string, constraints []string, configs []ServiceMount, secrets []ServiceMount, volumes []mount.Mount, networks []string, restartPolicy *config.RestartPolicy, updateConfig *config.UpdatePolicy, rollbackConfig *config.UpdatePolicy, resources *config.Resources, logging *config.Logging) (serviceIntent, error) {
 	env := envSlice(service.Env)
 	ports, err := portIntents(service.Ports)
 	if err != nil {
@@ -174,6 +182,18 @@ func intentFromConfig(service config.Service, labels map[string]string, constrai
 	if err != nil {
 		return serviceIntent{}, err
 	}
+	resourceSpec, err := swarmResources(resources)
+	if err != nil {
+		return serviceIntent{}, err
+	}
+	runtime, err := runtimeFromConfig(service)
+	if err != nil {
+		return serviceIntent{}, err
+	}
+	logDriver, err := swarmLogDriver(logging)
+	if err != nil {
+		return serviceIntent{}, err
+	}
 	mode := service.Mode
 	if mode == "" {
 		mode = "replicated"
@@ -182,6 +202,10 @@ func intentFromConfig(service config.Service, labels map[string]string, constrai
 	if mode == "global" {
 		replicas = 0
 	}
+	var maxReplicas uint64
+	if service.Placement.MaxReplicasPerNode != nil && *service.Placement.MaxReplicasPerNode > 0 {
+		maxReplicas = uint64(*service.Placement.MaxReplicasPerNode)
+	}
 	return serviceIntent{
 		Image:          service.Image,
 		Command:        cloneStrings(service.Command),
@@ -189,18 +213,25 @@ func intentFromConfig(service config.Service, labels map[string]string, constrai
 		Workdir:        service.Workdir,
 		Env:            env,
 		Ports:          ports,
+		EndpointMode:   endpointMode(service.EndpointMode),
 		Mode:           mode,
 		Replicas:       replicas,
 		Labels:         cloneLabels(labels),
 		Constraints:    cloneStrings(constraints),
+		Preferences:    placementPreferences(service.Placement.Preferences),
+		MaxReplicas:    maxReplicas,
+		Platforms:      placementPlatforms(service.Placement.Platforms),
 		Healthcheck:    healthcheck,
 		RestartPolicy:  policy,
 		UpdateConfig:   updateSpec,
 		RollbackConfig: rollbackSpec,
+		Resources:      resourceSpec,
+		LogDriver:      logDriver,
 		Configs:        configs,
 		Secrets:        secrets,
 		Volumes:        volumes,
 		Networks:       networks,
+		Runtime:        runtime,
 	}, nil
 }
 
@@ -213,6 +244,9 @@ func intentFromSpec(spec dockerapi.ServiceSpec, networkTargets map[string]string
 	var image string
 	var labels map[string]string
 	var constraints []string
+	var preferences []string
+	var maxReplicas uint64
+	var platforms []string
 	var healthcheck *container.HealthConfig
 	var configs []ServiceMount
 	var secrets []ServiceMount
@@ -226,7 +260,7 @@ func intentFromSpec(spec dockerapi.ServiceSpec, networkTargets map[string]string
 		healthcheck = containerSpec.Healthcheck
 		configs = configMountsFromRefs(containerSpec.Configs)
 		secrets = secretMountsFromRefs(containerSpec.Secrets)
-		mounts = cloneMounts(containerSpec.Mounts)
+		mounts = withoutTmpfsMounts(containerSpec.Mounts)
 	}
 	if spec.Annotations.Labels != nil {
 		labels = cloneLabels(spec.Annotations.Labels)
@@ -235,6 +269,9 @@ func intentFromSpec(spec dockerapi.ServiceSpec, networkTargets map[string]string
 	labels = filterServiceLabels(labels)
 	if spec.TaskTemplate.Placement != nil {
 		constraints = cloneStrings(spec.TaskTemplate.Placement.Constraints)
+		preferences = preferencesFromSpec(spec.TaskTemplate.Placement.Preferences)
+		maxReplicas = spec.TaskTemplate.Placement.MaxReplicas
+		platforms = platformsFromSpec(spec.TaskTemplate.Placement.Platforms)
 	}
 	sort.Strings(env)
 	ports := portIntentsFromSpec(spec.EndpointSpec)
@@ -247,18 +284,25 @@ func intentFromSpec(spec dockerapi.ServiceSpec, networkTargets map[string]string
 		Workdir:        workdir,
 		Env:            env,
 		Ports:          ports,
+		EndpointMode:   endpointModeFromSpec(spec.EndpointSpec),
 		Mode:           mode,
 		Replicas:       replicas,
 		Labels:         labels,
 		Constraints:    constraints,
+		Preferences:    preferences,
+		MaxReplicas:    maxReplicas,
+		Platforms:      platforms,
 		Healthcheck:    healthcheck,
 		RestartPolicy:  cloneRestartPolicy(spec.TaskTemplate.RestartPolicy),
 		UpdateConfig:   cloneUpdateConfig(spec.UpdateConfig),
 		RollbackConfig: cloneUpdateConfig(spec.RollbackConfig),
+		Resources:      cloneResources(spec.TaskTemplate.Resources),
+		LogDriver:      cloneLogDriver(spec.TaskTemplate.LogDriver),
 		Configs:        configs,
 		Secrets:        secrets,
 		Volumes:        mounts,
 		Networks:       networks,
+		Runtime:        runtimeFromSpec(containerSpec),
 	}
 }
 
@@ -274,11 +318,14 @@ func applyIntentToSpec(spec dockerapi.ServiceSpec, intent serviceIntent) dockera
 	spec.TaskTemplate.ContainerSpec.Env = cloneStrings(intent.Env)
 	spec.TaskTemplate.ContainerSpec.Healthcheck = intent.Healthcheck
 	spec.TaskTemplate.ContainerSpec.Mounts = cloneMounts(intent.Volumes)
-	spec.TaskTemplate.Placement = applyPlacement(spec.TaskTemplate.Placement, intent.Constraints)
+	applyRuntime(spec.TaskTemplate.ContainerSpec, intent.Runtime)
+	spec.TaskTemplate.Placement = applyPlacement(spec.TaskTemplate.Placement, intent)
 	spec.TaskTemplate.RestartPolicy = cloneRestartPolicy(intent.RestartPolicy)
 	spec.UpdateConfig = cloneUpdateConfig(intent.UpdateConfig)
 	spec.RollbackConfig = cloneUpdateConfig(intent.RollbackConfig)
-	spec.EndpointSpec = applyPorts(spec.EndpointSpec, intent.Ports)
+	spec.TaskTemplate.Resources = cloneResources(intent.Resources)
+	spec.TaskTemplate.LogDriver = cloneLogDriver(intent.LogDriver)
+	spec.EndpointSpec = applyPorts(spec.EndpointSpec, intent.Ports, intent.EndpointMode)
 	spec.TaskTemplate.Networks = applyNetworks(spec.TaskTemplate.Networks, intent.Networks)
 	spec.Mode = applyMode(spec.Mode, intent.Mode, intent.Replicas)
 	return spec
@@ -299,7 +346,7 @@ func intentEqual(current, desired serviceIntent) bool {
 	if !labelsEqual(current.Labels, desired.Labels) {
 		return false
 	}
-	if !stringSlicesEqual(current.Constraints, desired.Constraints) {
+	if !placementEqual(current, desired) {
 		return false
 	}
 	if !stringSlicesEqual(current.Command, desired.Command) {
@@ -314,6 +361,9 @@ func intentEqual(current, desired serviceIntent) bool {
 	if !portIntentsEqual(current.Ports, desired.Ports) {
 		return false
 	}
+	if endpointMode(current.EndpointMode) != endpointMode(desired.EndpointMode) {
+		return false
+	}
 	if !healthcheckEqual(current.Healthcheck, desired.Healthcheck) {
 		return false
 	}
@@ -326,6 +376,12 @@ func intentEqual(current, desired serviceIntent) bool {
 	if !updateConfigsEqual(current.RollbackConfig, desired.RollbackConfig) {
 		return false
 	}
+	if !resourcesEqual(current.Resources, desired.Resources) {
+		return false
+	}
+	if !logDriversEqual(current.LogDriver, desired.LogDriver) {
+		return false
+	}
 	if !mountSlicesEqual(current.Configs, desired.Configs) {
 		return false
 	}
@@ -338,7 +394,7 @@ func intentEqual(current, desired serviceIntent) bool {
 	if !stringSlicesEqual(current.Networks, desired.Networks) {
 		return false
 	}
-	return true
+	return len(runtimeDiffs(current.Runtime, desired.Runtime)) == 0
 }
 
 type defKey struct {
@@ -573,10 +629,19 @@ func desiredVolumeMounts(cfg *config.Config, engine *templates.Engine, data rend
 			return nil, err
 		}
 	}
+	volumeDefs := cfg.StackVolumeDefs(stackName, partitionName)
 	out := make([]mount.Mount, 0, len(service.Volumes))
 	for _, ref := range service.Volumes {
 		if ref.Name != "" {
-			volumeDef, ok := stack.Volumes[ref.Name]
+			volumeDef, ok := volumeDefs[ref.Name]
+			if volumeDef.UsesDriver() {
+				m, err := driverVolumeMount(engine, scope, data, stack.Mode, ref, volumeDef)
+				if err != nil {
+					return nil, fmt.Errorf("service %q volume %q: %w", serviceName, ref.Name, err)
+				}
+				out = append(out, m)
+				continue
+			}
 			if basePath == "" {
 				return nil, fmt.Errorf("service %q volume %q: project.defaults.volumes.base_path is required", serviceName, ref.Name)
 			}
@@ -673,6 +738,48 @@ func desiredVolumeMounts(cfg *config.Config, engine *templates.Engine, data rend
 	return out, nil
 }
 
+// driverVolumeMount builds the mount for a driver-backed named volume. The
+// driver creates the volume on whichever node runs the task, so no node label
+// constraint applies.
+func driverVolumeMount(engine *templates.Engine, scope templates.Scope, data render.TemplateData, stackMode string, ref config.VolumeRef, def config.VolumeDef) (mount.Mount, error) {
+	target, err := render.RenderTemplateString(engine, scope, data, fmt.Sprintf("volumes.%s.target", ref.Name), firstNonEmpty(ref.Target, def.Target))
+	if err != nil {
+		return mount.Mount{}, err
+	}
+	if target == "" {
+		return mount.Mount{}, fmt.Errorf("target is required")
+	}
+	subpath, err := render.RenderTemplateString(engine, scope, data, fmt.Sprintf("volumes.%s.subpath", ref.Name), firstNonEmpty(def.Subpath, ref.Subpath))
+	if err != nil {
+		return mount.Mount{}, err
+	}
+	driver, err := render.RenderTemplateString(engine, scope, data, fmt.Sprintf("volumes.%s.driver", ref.Name), def.Driver)
+	if err != nil {
+		return mount.Mount{}, err
+	}
+	var options map[string]string
+	if len(def.DriverOpts) > 0 {
+		options = make(map[string]string, len(def.DriverOpts))
+		for key, value := range def.DriverOpts {
+			rendered, err := render.RenderTemplateString(engine, scope, data, fmt.Sprintf("volumes.%s.driver_opts.%s", ref.Name, key), value)
+			if err != nil {
+				return mount.Mount{}, err
+			}
+			options[key] = rendered
+		}
+	}
+	return mount.Mount{
+		Type:     mount.TypeVolume,
+		Source:   config.StackVolumeName(scope.Project, scope.Stack, stackMode, scope.Partition, ref.Name),
+		Target:   templates.ExpandPathTokens(target, scope),
+		ReadOnly: ref.ReadOnly,
+		VolumeOptions: &mount.VolumeOptions{
+			Subpath:      strings.TrimSpace(subpath),
+			DriverConfig: &mount.Driver{Name: strings.TrimSpace(driver), Options: options},
+		},
+	}, nil
+}
+
 func desiredPlacementConstraints(cfg *config.Config, stackName string, stack config.Stack, partitionName string, serviceName string, service config.Service) []string {
 	var constraints []string
 	constraints = append(constraints, service.Placement.Constraints...)
@@ -681,8 +788,10 @@ func desiredPlacementConstraints(cfg *config.Config, stackName string, stack con
 		return uniqueSortedConstraints(constraints)
 	}
 	serviceStandard := config.ServiceStandardName(cfg)
+	volumeDefs := cfg.StackVolumeDefs(stackName, partitionName)
 	for _, ref := range service.Volumes {
 		switch {
+		case ref.Name != "" && volumeDefs[ref.Name].UsesDriver():
 		case ref.Name != "":
 			constraints = append(constraints, volumeConstraint(labelKey, ref.Name))
 		case ref.Standard == serviceStandard:
@@ -878,10 +987,11 @@ func cloneMounts(items []mount.Mount) []mount.Mount {
 	return out
 }
 
-func applyPorts(spec *dockerapi.EndpointSpec, ports []portIntent) *dockerapi.EndpointSpec {
+func applyPorts(spec *dockerapi.EndpointSpec, ports []portIntent, mode string) *dockerapi.EndpointSpec {
 	if spec == nil {
 		spec = &dockerapi.EndpointSpec{}
 	}
+	spec.Mode = dockerapi.ResolutionMode(endpointMode(mode))
 	if len(ports) == 0 {
 		spec.Ports = nil
 		return spec
@@ -899,14 +1009,39 @@ func applyPorts(spec *dockerapi.EndpointSpec, ports []portIntent) *dockerapi.End
 	return spec
 }
 
+// endpointMode returns the swarm endpoint mode; vip is the default.
+func endpointMode(mode string) string {
+	mode = strings.TrimSpace(mode)
+	if mode == "" {
+		return config.EndpointModeVIP
+	}
+	return mode
+}
+
+func endpointModeFromSpec(spec *dockerapi.EndpointSpec) string {
+	if spec == nil {
+		return endpointMode("")
+	}
+	return endpointMode(string(spec.Mode))
+}
+
 func applyMode(mode dockerapi.ServiceMode, desired string, replicas uint64) dockerapi.ServiceMode {
-	if desired == "global" {
+	switch desired {
+	case "global":
 		mode.Global = &dockerapi.GlobalService{}
 		mode.Replicated = nil
+		mode.ReplicatedJob = nil
+		return mode
+	case "replicated-job":
+		concurrent := uint64(1)
+		mode.ReplicatedJob = &dockerapi.ReplicatedJob{MaxConcurrent: &concurrent, TotalCompletions: &replicas}
+		mode.Replicated = nil
+		mode.Global = nil
 		return mode
 	}
 	mode.Replicated = &dockerapi.ReplicatedService{Replicas: &replicas}
 	mode.Global = nil
+	mode.ReplicatedJob = nil
 	return mode
 }
 
@@ -914,6 +1049,12 @@ func modeFromSpec(mode dockerapi.ServiceMode) (string, uint64) {
 	if mode.Global != nil {
 		return "global", 0
 	}
+	if mode.ReplicatedJob != nil {
+		if mode.ReplicatedJob.TotalCompletions != nil {
+			return "replicated-job", *mode.ReplicatedJob.TotalCompletions
+		}
+		return "replicated-job", 0
+	}
 	if mode.Replicated != nil && mode.Replicated.Replicas != nil {
 		return "replicated", *mode.Replicated.Replicas
 	}
@@ -925,25 +1066,70 @@ func volumeMountsEqual(left, right []mount.Mount) bool {
 		return false
 	}
 	for i := range left {
-		if left[i] != right[i] {
+		if left[i].Type != right[i].Type ||
+			left[i].Source != right[i].Source ||
+			left[i].Target != right[i].Target ||
+			left[i].ReadOnly != right[i].ReadOnly {
+			return false
+		}
+		if !volumeOptionsEqual(left[i].VolumeOptions, right[i].VolumeOptions) {
 			return false
 		}
 	}
 	return true
 }
 
+func volumeOptionsEqual(left, right *mount.VolumeOptions) bool {
+	left = normalizeVolumeOptions(left)
+	right = normalizeVolumeOptions(right)
+	if left == nil || right == nil {
+		return left == right
+	}
+	if left.Subpath != right.Subpath {
+		return false
+	}
+	if (left.DriverConfig == nil) != (right.DriverConfig == nil) {
+		return false
+	}
+	if left.DriverConfig == nil {
+		return true
+	}
+	return left.DriverConfig.Name == right.DriverConfig.Name &&
+		labelsEqual(left.DriverConfig.Options, right.DriverConfig.Options)
+}
+
 func normalizeMounts(mounts []mount.Mount) []mount.Mount {
 	if len(mounts) == 0 {
 		return nil
 	}
 	out := make([]mount.Mount, 0, len(mounts))
 	for _, item := range mounts {
-		out = append(out, mount.Mount{
+		normalized := mount.Mount{
 			Type:     item.Type,
 			Source:   item.Source,
 			Target:   item.Target,
 			ReadOnly: item.ReadOnly,
-		})
+		}
+		if item.Type == mount.TypeVolume {
+			normalized.VolumeOptions = normalizeVolumeOptions(item.VolumeOptions)
+		}
+		out = append(out, normalized)
+	}
+	return out
+}
+
+// normalizeVolumeOptions keeps the fields swarmcp manages (subpath and driver
+// config); labels and nocopy set by other tools are ignored.
+func normalizeVolumeOptions(options *mount.VolumeOptions) *mount.VolumeOptions {
+	if options == nil {
+		return nil
+	}
+	out := &mount.VolumeOptions{Subpath: options.Subpath}
+	if options.DriverConfig != nil && options.DriverConfig.Name != "" {
+		out.DriverConfig = &mount.Driver{Name: options.DriverConfig.Name, Options: cloneLabels(options.DriverConfig.Options)}
+	}
+	if out.Subpath == "" && out.DriverConfig == nil {
+		return nil
 	}
 	return out
 }
@@ -1239,19 +1425,10 @@ func stringSlicesEqualSorted(left, right []string) bool {
 	return stringSlicesEqual(leftCopy, rightCopy)
 }
 
-func applyPlacement(current *dockerapi.Placement, constraints []string) *dockerapi.Placement {
-	if len(constraints) == 0 {
-		return nil
-	}
-	return &dockerapi.Placement{
-		Constraints: cloneStrings(constraints),
-	}
-}
-
 func indexServices(services []swarm.Service, projectName string) map[string]swarm.Service {
 	out := make(map[string]swarm.Service)
 	for _, svc := range services {
-		if !isManagedProject(svc.Labels, projectName) {
+		if !isManagedProject(svc.Labels, projectName) || isCanary(svc.Labels) {
 			continue
 		}
 		stack := svc.Labels[render.LabelStack]
@@ -1312,23 +1489,22 @@ func updateService(ctx context.Context, client swarm.Client, update ServiceUpdat
 	return client.UpdateService(ctx, update.Service, spec)
 }
 
-func createService(ctx context.Context, client swarm.Client, create ServiceCreate, configIDs map[string]string, secretIDs map[string]string) error {
+func createService(ctx context.Context, client swarm.Client, create ServiceCreate, configIDs map[string]string, secretIDs map[string]string) (string, error) {
 	spec := create.Spec
 	if spec.TaskTemplate.ContainerSpec == nil {
 		spec.TaskTemplate.ContainerSpec = &dockerapi.ContainerSpec{}
 	}
 	configRefs, err := buildConfigRefs(create.Name, create.Configs, configIDs)
 	if err != nil {
-		return err
+		return "", err
 	}
 	secretRefs, err := buildSecretRefs(create.Name, create.Secrets, secretIDs)
 	if err != nil {
-		return err
+		return "", err
 	}
 	spec.TaskTemplate.ContainerSpec.Configs = configRefs
 	spec.TaskTemplate.ContainerSpec.Secrets = secretRefs
-	_, err = client.CreateService(ctx, spec)
-	return err
+	return client.CreateService(ctx, spec)
 }
 
 func buildConfigRefs(serviceName string, mounts []ServiceMount, configIDs map[string]string) ([]*dockerapi.ConfigReference, error) {
@@ -121,3 +121,21 @@ func TestRenderLabelTemplatesExpandsTokensInKeys(t *testing.T) {
 		t.Fatalf("expected expanded label key, got %v", rendered)
 	}
 }
+
+func TestIntentEndpointModeRoundTrip(t *testing.T) {
+	desired := serviceIntent{Image: "nginx", Mode: "replicated", Replicas: 1, EndpointMode: "dnsrr"}
+	spec := applyIntentToSpec(dockerapi.ServiceSpec{}, desired)
+	if spec.EndpointSpec == nil || spec.EndpointSpec.Mode != dockerapi.ResolutionModeDNSRR {
+		t.Fatalf("expected dnsrr endpoint mode, got %#v", spec.EndpointSpec)
+	}
+
+	current := desired
+	current.EndpointMode = ""
+	diffs := intentDiffs(current, desired)
+	if len(diffs) != 1 || diffs[0] != "endpoint_mode" {
+		t.Fatalf("expected endpoint_mode diff, got %v", diffs)
+	}
+	if !intentEqual(serviceIntent{Image: "nginx"}, serviceIntent{Image: "nginx", EndpointMode: "vip"}) {
+		t.Fatalf("expected empty endpoint mode to equal vip")
+	}
+}
@@ -0,0 +1,231 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	"github.com/cmmoran/swarmcp/internal/swarm/swarmsim"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func simulatorConfig(images map[string]string) *config.Config {
+	services := make(map[string]config.Service, len(images))
+	for name, image := range images {
+		services[name] = config.Service{Image: image, Replicas: 1}
+	}
+	return &config.Config{
+		Project: config.Project{Name: "proj"},
+		Stacks: map[string]config.Stack{
+			"app": {Mode: "shared", Services: services},
+		},
+	}
+}
+
+// newStackSimulator returns a simulator with the stack network that
+// bootstrap networks would have created.
+func newStackSimulator(t *testing.T) *swarmsim.Simulator {
+	t.Helper()
+	sim := swarmsim.New()
+	if _, err := sim.CreateNetwork(context.Background(), swarm.NetworkSpec{Name: "proj_app", Driver: "overlay"}); err != nil {
+		t.Fatalf("CreateNetwork: %v", err)
+	}
+	return sim
+}
+
+func mustApplyPlan(t *testing.T, client *swarmsim.Simulator, cfg *config.Config, rollback bool) (Plan, error) {
+	t.Helper()
+	plan, err := BuildPlan(context.Background(), client, cfg, DesiredState{}, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	_, err = Apply(context.Background(), client, plan, nil, false, rollback, 1, true, "plain", false)
+	return plan, err
+}
+
+func TestSimulatorApplyScenario(t *testing.T) {
+	sim := newStackSimulator(t)
+	cfg := simulatorConfig(map[string]string{"web": "nginx:1", "api": "api:1"})
+	if _, err := mustApplyPlan(t, sim, cfg, true); err != nil {
+		t.Fatalf("initial apply: %v", err)
+	}
+	for _, name := range []string{"proj_app_web", "proj_app_api"} {
+		if !slices.Contains(sim.Operations(), "create service "+name) {
+			t.Fatalf("expected %s to be created, got %v", name, sim.Operations())
+		}
+	}
+
+	report, err := BuildStatus(context.Background(), sim, cfg, DesiredState{}, nil, nil, nil, false, 0)
+	if err != nil {
+		t.Fatalf("BuildStatus: %v", err)
+	}
+	for _, svc := range report.Services {
+		if svc.Missing || !svc.IntentMatch || svc.Running != 1 {
+			t.Fatalf("expected converged service after apply, got %+v", svc)
+		}
+	}
+
+	plan, err := BuildPlan(context.Background(), sim, cfg, DesiredState{}, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if err := ValidatePlanAssumptions(context.Background(), sim, plan.Assumptions); err != nil {
+		t.Fatalf("expected saved plan assumptions to hold: %v", err)
+	}
+}
+
+func TestSimulatorApplyPartialFailureRollsBack(t *testing.T) {
+	sim := newStackSimulator(t)
+	if _, err := mustApplyPlan(t, sim, simulatorConfig(map[string]string{"web": "nginx:1", "api": "api:1"}), true); err != nil {
+		t.Fatalf("initial apply: %v", err)
+	}
+
+	sim.FailImage("api:2", "task: non-zero exit (1)")
+	_, err := mustApplyPlan(t, sim, simulatorConfig(map[string]string{"web": "nginx:2", "api": "api:2"}), true)
+	if err == nil || !strings.Contains(err.Error(), "proj_app") {
+		t.Fatalf("expected failed apply, got %v", err)
+	}
+	api, _ := sim.Service("proj_app_api")
+	if image := api.Spec.TaskTemplate.ContainerSpec.Image; image != "api:1" {
+		t.Fatalf("expected api to be rolled back to api:1, got %s", image)
+	}
+	if !slices.Contains(sim.Operations(), "rollback service proj_app_api") {
+		t.Fatalf("expected rollback of proj_app_api, got %v", sim.Operations())
+	}
+	web, _ := sim.Service("proj_app_web")
+	if image := web.Spec.TaskTemplate.ContainerSpec.Image; image != "nginx:2" {
+		t.Fatalf("expected web to keep nginx:2, got %s", image)
+	}
+}
+
+func TestSimulatorSavedPlanDetectsDrift(t *testing.T) {
+	sim := newStackSimulator(t)
+	cfg := simulatorConfig(map[string]string{"web": "nginx:1"})
+	plan, err := BuildPlan(context.Background(), sim, cfg, DesiredState{}, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if _, err := mustApplyPlan(t, sim, cfg, true); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := ValidatePlanAssumptions(context.Background(), sim, plan.Assumptions); err == nil {
+		t.Fatalf("expected stale saved plan to be rejected after the cluster changed")
+	}
+
+	injected := errors.New("boom")
+	sim.FailNext(swarmsim.OpUpdateService, "proj_app_web", injected)
+	if _, err := mustApplyPlan(t, sim, simulatorConfig(map[string]string{"web": "nginx:2"}), true); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected injected update error, got %v", err)
+	}
+}
+
+func TestSimulatorCanaryRollout(t *testing.T) {
+	previous := deployPollInterval
+	deployPollInterval = time.Millisecond
+	defer func() { deployPollInterval = previous }()
+
+	sim := newStackSimulator(t)
+	if _, err := mustApplyPlan(t, sim, simulatorConfig(map[string]string{"api": "api:1"}), true); err != nil {
+		t.Fatalf("initial apply: %v", err)
+	}
+	canaryConfig := func(image string) *config.Config {
+		cfg := simulatorConfig(map[string]string{"api": image})
+		service := cfg.Stacks["app"].Services["api"]
+		service.Rollout = config.RolloutCanary
+		service.Canary = &config.Canary{Window: "5ms"}
+		cfg.Stacks["app"].Services["api"] = service
+		return cfg
+	}
+
+	sim.FailImage("api:2", "task: non-zero exit (1)")
+	if _, err := mustApplyPlan(t, sim, canaryConfig("api:2"), true); err == nil || !strings.Contains(err.Error(), "canary proj_app_api_canary") {
+		t.Fatalf("expected canary failure, got %v", err)
+	}
+	api, _ := sim.Service("proj_app_api")
+	if image := api.Spec.TaskTemplate.ContainerSpec.Image; image != "api:1" {
+		t.Fatalf("expected api to stay on api:1 after an aborted canary, got %s", image)
+	}
+	if _, ok := sim.Service("proj_app_api_canary"); ok {
+		t.Fatalf("expected aborted canary to be removed")
+	}
+
+	if _, err := mustApplyPlan(t, sim, canaryConfig("api:3"), true); err != nil {
+		t.Fatalf("canary apply: %v", err)
+	}
+	ops := strings.Join(sim.Operations(), "\n")
+	if !strings.Contains(ops, "create service proj_app_api_canary\nupdate service proj_app_api\nremove service proj_app_api_canary") {
+		t.Fatalf("expected canary, promotion and cleanup in order, got %v", sim.Operations())
+	}
+	api, _ = sim.Service("proj_app_api")
+	if image := api.Spec.TaskTemplate.ContainerSpec.Image; image != "api:3" {
+		t.Fatalf("expected api to be promoted to api:3, got %s", image)
+	}
+
+	intent := canaryServiceIntent(serviceIntent{Image: "api:4"}, 1, "proj_app_api")
+	spec := applyIntentToSpec(dockerapi.ServiceSpec{Annotations: dockerapi.Annotations{Name: "proj_app_api_canary"}}, intent)
+	if _, err := sim.CreateService(context.Background(), spec); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	report, err := BuildStatus(context.Background(), sim, canaryConfig("api:3"), DesiredState{}, nil, nil, nil, false, 0)
+	if err != nil {
+		t.Fatalf("BuildStatus: %v", err)
+	}
+	canary := report.Services[0].Canary
+	if canary == nil || canary.Name != "proj_app_api_canary" || canary.Image != "api:4" || canary.Health != "healthy" {
+		t.Fatalf("expected canary in status, got %+v", canary)
+	}
+}
+
+func TestSimulatorApplyRemovesOrphanedCanaries(t *testing.T) {
+	sim := newStackSimulator(t)
+	cfg := simulatorConfig(map[string]string{"api": "api:1", "web": "nginx:1"})
+	if _, err := mustApplyPlan(t, sim, cfg, true); err != nil {
+		t.Fatalf("initial apply: %v", err)
+	}
+	api, _ := sim.Service("proj_app_api")
+	spec := api.Spec
+	spec.Annotations.Name = "proj_app_api_canary"
+	spec.Annotations.Labels = cloneLabels(spec.Annotations.Labels)
+	spec.Annotations.Labels[canaryOfLabel] = "proj_app_api"
+	if _, err := sim.CreateService(context.Background(), spec); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	legacy := applyIntentToSpec(dockerapi.ServiceSpec{Annotations: dockerapi.Annotations{Name: "proj_app_web_canary"}},
+		canaryServiceIntent(serviceIntent{Image: "nginx:2"}, 1, "proj_app_web"))
+	if _, err := sim.CreateService(context.Background(), legacy); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	report, err := BuildStatus(context.Background(), sim, cfg, DesiredState{}, nil, nil, nil, false, 0)
+	if err != nil {
+		t.Fatalf("BuildStatus: %v", err)
+	}
+	for _, svc := range report.Services {
+		if svc.Missing || !svc.IntentMatch {
+			t.Fatalf("expected canary not to be mistaken for its service, got %+v", svc)
+		}
+	}
+
+	plan, err := mustApplyPlan(t, sim, cfg, true)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if len(plan.StackDeploys) != 1 || len(plan.PruneStacks) != 0 {
+		t.Fatalf("expected the stack with an orphaned canary to be deployed, got %+v", plan)
+	}
+	for _, name := range []string{"proj_app_api_canary", "proj_app_web_canary"} {
+		if _, ok := sim.Service(name); ok {
+			t.Fatalf("expected orphaned canary %s to be removed, got %v", name, sim.Operations())
+		}
+	}
+	for _, name := range []string{"proj_app_api", "proj_app_web"} {
+		if slices.Contains(sim.Operations(), "update service "+name) {
+			t.Fatalf("expected %s to stay unchanged, got %v", name, sim.Operations())
+		}
+	}
+}
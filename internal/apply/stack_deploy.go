@@ -1,28 +1,42 @@
 package apply
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/swarm"
 	"github.com/docker/docker/api/types/mount"
 	"go.yaml.in/yaml/v4"
 )
 
 type StackDeploy struct {
-	Name           string   `yaml:"name" json:"name"`
-	Compose        []byte   `yaml:"compose" json:"compose"`
-	ServiceCreates int      `yaml:"service_creates,omitempty" json:"service_creates,omitempty"`
-	ServiceUpdates int      `yaml:"service_updates,omitempty" json:"service_updates,omitempty"`
-	SourceRefs     []string `yaml:"-" json:"-"`
+	Name           string `yaml:"name" json:"name"`
+	Compose        []byte `yaml:"compose" json:"compose"`
+	ServiceCreates int    `yaml:"service_creates,omitempty" json:"service_creates,omitempty"`
+	ServiceUpdates int    `yaml:"service_updates,omitempty" json:"service_updates,omitempty"`
+	// DependsOn maps compose service keys to the swarm service names they
+	// depend on, in this or other stacks.
+	DependsOn map[string][]string `yaml:"depends_on,omitempty" json:"depends_on,omitempty"`
+	// HealthTimeouts maps compose service keys to their resolved
+	// health_timeout when one is configured.
+	HealthTimeouts map[string]string `yaml:"health_timeouts,omitempty" json:"health_timeouts,omitempty"`
+	// Canaries maps compose service keys to their canary settings when
+	// updates go through a canary service first.
+	Canaries map[string]CanaryPolicy `yaml:"canaries,omitempty" json:"canaries,omitempty"`
+	// Jobs maps compose service keys to their lifecycle job steps. Jobs are
+	// not part of the compose payload.
+	Jobs map[string][]JobStep `yaml:"jobs,omitempty" json:"jobs,omitempty"`
+	// Services maps compose service keys to the service intent built during
+	// planning. The engine deploys from these; Compose is their rendering for
+	// review and for networks and volumes.
+	Services   map[string]ServiceIntent `yaml:"services,omitempty" json:"services,omitempty"`
+	SourceRefs []string                 `yaml:"-" json:"-"`
 }
 
 type composeFile struct {
@@ -35,18 +49,34 @@ type composeFile struct {
 }
 
 type composeService struct {
-	Image       string             `yaml:"image"`
-	Entrypoint  []string           `yaml:"entrypoint,omitempty"`
-	Command     []string           `yaml:"command,omitempty"`
-	WorkingDir  string             `yaml:"working_dir,omitempty"`
-	Environment map[string]string  `yaml:"environment,omitempty"`
-	Ports       []composePort      `yaml:"ports,omitempty"`
-	Configs     []composeConfigRef `yaml:"configs,omitempty"`
-	Secrets     []composeSecretRef `yaml:"secrets,omitempty"`
-	Volumes     []composeMount     `yaml:"volumes,omitempty"`
-	Networks    []string           `yaml:"networks,omitempty"`
-	Healthcheck map[string]any     `yaml:"healthcheck,omitempty"`
-	Deploy      *composeDeploy     `yaml:"deploy,omitempty"`
+	Image           string                   `yaml:"image"`
+	Entrypoint      []string                 `yaml:"entrypoint,omitempty"`
+	Command         []string                 `yaml:"command,omitempty"`
+	WorkingDir      string                   `yaml:"working_dir,omitempty"`
+	Environment     map[string]string        `yaml:"environment,omitempty"`
+	User            string                   `yaml:"user,omitempty"`
+	Hostname        string                   `yaml:"hostname,omitempty"`
+	Init            *bool                    `yaml:"init,omitempty"`
+	ReadOnly        bool                     `yaml:"read_only,omitempty"`
+	StopSignal      string                   `yaml:"stop_signal,omitempty"`
+	StopGracePeriod string                   `yaml:"stop_grace_period,omitempty"`
+	Tmpfs           []string                 `yaml:"tmpfs,omitempty"`
+	CapAdd          []string                 `yaml:"cap_add,omitempty"`
+	CapDrop         []string                 `yaml:"cap_drop,omitempty"`
+	Sysctls         map[string]string        `yaml:"sysctls,omitempty"`
+	Ulimits         map[string]composeUlimit `yaml:"ulimits,omitempty"`
+	ExtraHosts      []string                 `yaml:"extra_hosts,omitempty"`
+	DNS             []string                 `yaml:"dns,omitempty"`
+	DNSSearch       []string                 `yaml:"dns_search,omitempty"`
+	DNSOpt          []string                 `yaml:"dns_opt,omitempty"`
+	Ports           []composePort            `yaml:"ports,omitempty"`
+	Configs         []composeConfigRef       `yaml:"configs,omitempty"`
+	Secrets         []composeSecretRef       `yaml:"secrets,omitempty"`
+	Volumes         []composeMount           `yaml:"volumes,omitempty"`
+	Networks        []string                 `yaml:"networks,omitempty"`
+	Healthcheck     map[string]any           `yaml:"healthcheck,omitempty"`
+	Logging         *composeLogging          `yaml:"logging,omitempty"`
+	Deploy          *composeDeploy           `yaml:"deploy,omitempty"`
 }
 
 type composePort struct {
@@ -73,27 +103,48 @@ type composeSecretRef struct {
 }
 
 type composeMount struct {
-	Type     string `yaml:"type"`
-	Source   string `yaml:"source"`
-	Target   string `yaml:"target"`
-	ReadOnly bool   `yaml:"read_only,omitempty"`
+	Type     string              `yaml:"type"`
+	Source   string              `yaml:"source"`
+	Target   string              `yaml:"target"`
+	ReadOnly bool                `yaml:"read_only,omitempty"`
+	Volume   *composeMountVolume `yaml:"volume,omitempty"`
+}
+
+type composeMountVolume struct {
+	Subpath string `yaml:"subpath,omitempty"`
 }
 
 type composeNetwork struct {
-	External   bool   `yaml:"external,omitempty"`
-	Name       string `yaml:"name,omitempty"`
-	Attachable bool   `yaml:"attachable,omitempty"`
-	Internal   bool   `yaml:"internal,omitempty"`
+	External   bool              `yaml:"external,omitempty"`
+	Name       string            `yaml:"name,omitempty"`
+	Attachable bool              `yaml:"attachable,omitempty"`
+	Internal   bool              `yaml:"internal,omitempty"`
+	Labels     map[string]string `yaml:"labels,omitempty"`
+	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+	IPAM       *composeIPAM      `yaml:"ipam,omitempty"`
+}
+
+type composeIPAM struct {
+	Config []swarm.NetworkIPAM `yaml:"config"`
+}
+
+func composeNetworkIPAM(ipam []swarm.NetworkIPAM) *composeIPAM {
+	if len(ipam) == 0 {
+		return nil
+	}
+	return &composeIPAM{Config: ipam}
 }
 
 type composeDeploy struct {
 	Mode           string                `yaml:"mode,omitempty"`
+	EndpointMode   string                `yaml:"endpoint_mode,omitempty"`
 	Replicas       *int                  `yaml:"replicas,omitempty"`
 	Labels         map[string]string     `yaml:"labels,omitempty"`
 	Placement      *composePlacement     `yaml:"placement,omitempty"`
 	RestartPolicy  *composeRestartPolicy `yaml:"restart_policy,omitempty"`
 	UpdateConfig   *composeUpdateConfig  `yaml:"update_config,omitempty"`
 	RollbackConfig *composeUpdateConfig  `yaml:"rollback_config,omitempty"`
+	Resources      *composeResources     `yaml:"resources,omitempty"`
 }
 
 type composeExternal struct {
@@ -102,16 +153,24 @@ type composeExternal struct {
 }
 
 type composePlacement struct {
-	Constraints []string `yaml:"constraints,omitempty"`
+	Constraints        []string                     `yaml:"constraints,omitempty"`
+	Preferences        []composePlacementPreference `yaml:"preferences,omitempty"`
+	MaxReplicasPerNode uint64                       `yaml:"max_replicas_per_node,omitempty"`
+	Platforms          []composePlatform            `yaml:"platforms,omitempty"`
 }
 
 type composeVolume struct {
+	Name       string            `yaml:"name,omitempty"`
 	Driver     string            `yaml:"driver,omitempty"`
 	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
 }
 
 func BuildStackDeploys(cfg *config.Config, desired DesiredState, values any, partitionFilters []string, stackFilters []string, filter map[string]struct{}, creates []ServiceCreate, updates []ServiceUpdate, infer bool) ([]StackDeploy, error) {
 	index := buildDefIndex(desired.Defs)
+	subnets, err := config.NetworkSubnets(cfg)
+	if err != nil {
+		return nil, err
+	}
 	var deploys []StackDeploy
 	changes := map[string]struct {
 		creates int
@@ -167,11 +226,17 @@ func BuildStackDeploys(cfg *config.Config, desired DesiredState, values any, par
 			secrets := make(map[string]composeExternal)
 			networks := make(map[string]composeNetwork)
 			volumes := make(map[string]composeVolume)
+			dependsOn := make(map[string][]string)
+			healthTimeouts := make(map[string]string)
+			canaries := make(map[string]CanaryPolicy)
+			jobs := make(map[string][]JobStep)
+			intents := make(map[string]ServiceIntent, len(services))
 			for serviceName, service := range services {
 				build, err := buildServiceIntent(cfg, stackName, stack, partitionName, serviceName, service, values, infer, index)
 				if err != nil {
 					return nil, err
 				}
+				build.pinImage(desired.ImageDigests)
 				renderedService := build.Rendered
 				configMounts := build.ConfigMounts
 				secretMounts := build.SecretMounts
@@ -179,6 +244,9 @@ func BuildStackDeploys(cfg *config.Config, desired DesiredState, values any, par
 				if err := addComposeVolumes(cfg, stackName, stack, partitionName, serviceName, renderedService, volumes); err != nil {
 					return nil, err
 				}
+				if err := addComposeDriverVolumes(serviceName, volumeMounts, volumes); err != nil {
+					return nil, err
+				}
 				externalNetworks := desiredServiceExternalNetworks(cfg, stackName, stack.Mode, partitionName, serviceName, renderedService)
 				serviceNetworks := append([]string(nil), externalNetworks...)
 				if renderedService.NetworkEphemeral != nil {
@@ -186,14 +254,18 @@ func BuildStackDeploys(cfg *config.Config, desired DesiredState, values any, par
 					if ephemeralKey != "" {
 						serviceNetworks = append(serviceNetworks, ephemeralKey)
 						attachable, internal := ephemeralNetworkSettings(renderedService.NetworkEphemeral)
+						options, ipam := networkOptions(cfg, config.EphemeralNetworkName(cfg, stackName, stack.Mode, partitionName, serviceName), subnets)
 						networks[ephemeralKey] = composeNetwork{
 							Attachable: attachable,
 							Internal:   internal,
+							Labels:     managedNetworkLabels(cfg.Project.Name),
+							DriverOpts: options,
+							IPAM:       composeNetworkIPAM(ipam),
 						}
 					}
 				}
 
-				deploySpec, err := composeDeploySpec(renderedService, build.Constraints, build.Labels, renderedService.RestartPolicy, renderedService.UpdateConfig, renderedService.RollbackConfig)
+				deploySpec, err := composeDeploySpec(renderedService, build.Constraints, build.Labels, build.RestartPolicy, build.UpdatePolicy, build.RollbackPolicy, build.Resources)
 				if err != nil {
 					return nil, err
 				}
@@ -209,9 +281,28 @@ func BuildStackDeploys(cfg *config.Config, desired DesiredState, values any, par
 					Volumes:     composeMounts(volumeMounts),
 					Networks:    serviceNetworks,
 					Healthcheck: renderedService.Healthcheck,
+					Logging:     composeLoggingSpec(build.Logging),
 					Deploy:      deploySpec,
 				}
+				composeRuntime(&composeService, renderedService)
 				compose.Services[serviceName] = composeService
+				intents[serviceName] = exportServiceIntent(build.Intent)
+				if deps := stackServiceDependencies(cfg, deployName, partitionName, renderedService.DependsOn); len(deps) > 0 {
+					dependsOn[serviceName] = deps
+				}
+				if build.HealthTimeout != "" {
+					healthTimeouts[serviceName] = build.HealthTimeout
+				}
+				if renderedService.UsesCanary() {
+					canaries[serviceName] = canaryPolicy(renderedService.Canary)
+				}
+				steps, err := buildJobSteps(renderedService.Jobs)
+				if err != nil {
+					return nil, fmt.Errorf("stack %q service %q: %w", stackName, serviceName, err)
+				}
+				if len(steps) > 0 {
+					jobs[serviceName] = steps
+				}
 
 				for _, configMount := range configMounts {
 					configs[configMount.Name] = composeExternal{External: true, Name: configMount.Name}
@@ -242,8 +333,21 @@ func BuildStackDeploys(cfg *config.Config, desired DesiredState, values any, par
 			deploy := StackDeploy{
 				Name:       deployName,
 				Compose:    raw,
+				Services:   intents,
 				SourceRefs: stackDeploySourceRefs(stack, services),
 			}
+			if len(dependsOn) > 0 {
+				deploy.DependsOn = dependsOn
+			}
+			if len(healthTimeouts) > 0 {
+				deploy.HealthTimeouts = healthTimeouts
+			}
+			if len(canaries) > 0 {
+				deploy.Canaries = canaries
+			}
+			if len(jobs) > 0 {
+				deploy.Jobs = jobs
+			}
 			if entry, ok := changes[deployName]; ok {
 				deploy.ServiceCreates = entry.creates
 				deploy.ServiceUpdates = entry.updates
@@ -254,6 +358,27 @@ func BuildStackDeploys(cfg *config.Config, desired DesiredState, values any, par
 	return deploys, nil
 }
 
+func stackServiceDependencies(cfg *config.Config, namespace string, partitionName string, deps []string) []string {
+	if len(deps) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		stackName, serviceName, cross := config.SplitServiceDependency(dep)
+		if !cross {
+			out = append(out, namespace+"_"+serviceName)
+			continue
+		}
+		stack, ok := cfg.Stacks[stackName]
+		if !ok {
+			continue
+		}
+		out = append(out, config.StackInstanceName(cfg.Project.Name, stackName, partitionName, stack.Mode)+"_"+serviceName)
+	}
+	sort.Strings(out)
+	return out
+}
+
 func stackDeploySourceRefs(stack config.Stack, services map[string]config.Service) []string {
 	seen := map[string]struct{}{}
 	add := func(source string) {
@@ -282,26 +407,19 @@ func ValidateDeployOutputMode(mode string) error {
 	}
 }
 
-func DeployStacks(ctx context.Context, stacks []StackDeploy, contextName string, pruneServices bool, parallel int, noUI bool, outputMode string, outputExplicit bool) error {
+func DeployStacks(ctx context.Context, client swarm.Client, stacks []StackDeploy, lease *Lease, pruneServices bool, rollback bool, parallel int, noUI bool, outputMode string, outputExplicit bool) ([]StackDeployResult, error) {
 	if len(stacks) == 0 {
-		return nil
+		return nil, nil
 	}
-	if parallel < 1 {
-		parallel = len(stacks)
-		if parallel < 1 {
-			parallel = 1
-		}
+	inventory, err := loadDeployInventory(ctx, client)
+	if err != nil {
+		return nil, err
 	}
-
 	mode := resolveDeployOutputMode(outputMode, noUI, outputExplicit)
 
-	var once sync.Once
 	var firstErr error
-	sem := make(chan struct{}, parallel)
-	wg := sync.WaitGroup{}
 	outputs := make(map[string]string, len(stacks))
 	failed := make(map[string]struct{}, len(stacks))
-	outputsMu := sync.Mutex{}
 	statuses := make(map[string]string, len(stacks))
 	for _, deploy := range stacks {
 		statuses[deploy.Name] = "queued"
@@ -315,7 +433,11 @@ func DeployStacks(ctx context.Context, stacks []StackDeploy, contextName string,
 	startedAt := time.Now()
 
 	if mode == "summary" {
-		_, _ = fmt.Fprintf(os.Stdout, "deploy start: stacks=%d parallel=%d\n", len(stacks), parallel)
+		limit := "unbounded"
+		if parallel > 0 {
+			limit = fmt.Sprintf("%d", parallel)
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "deploy start: stacks=%d parallel=%s\n", len(stacks), limit)
 	}
 
 	var ui *stackUI
@@ -362,97 +484,75 @@ func DeployStacks(ctx context.Context, stacks []StackDeploy, contextName string,
 		}()
 	}
 
-	printMutex := sync.Mutex{}
 	trackError := func(name string, err error) {
-		once.Do(func() { firstErr = fmt.Errorf("stack deploy %q: %w", name, err) })
+		if firstErr == nil {
+			firstErr = fmt.Errorf("stack deploy %q: %w", name, err)
+		}
 		if uiEnabled {
 			ui.Update(name, "error")
 		}
 	}
 
-	for _, deploy := range stacks {
-		deploy := deploy
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
+	stackStarted := make(map[string]time.Time, len(stacks))
+	hooks := rolloutHooks{
+		stackStarted: func(name string) {
 			countMu.Lock()
 			queuedCount--
 			runningCount++
 			countMu.Unlock()
-			deployStarted := time.Now()
-
+			stackStarted[name] = time.Now()
 			if uiEnabled {
-				ui.Update(deploy.Name, "running")
+				ui.Update(name, "running")
 			}
-			path, err := writeStackCompose(deploy)
-			if err != nil {
+		},
+		stackFinished: func(index int, result StackDeployResult, err error) {
+			name := stacks[index].Name
+			started, ok := stackStarted[name]
+			if !ok {
 				countMu.Lock()
-				runningCount--
-				failedCount++
+				queuedCount--
+				runningCount++
 				countMu.Unlock()
-				trackError(deploy.Name, err)
-				return
-			}
-			defer func() { _ = os.Remove(path) }()
-
-			args := []string{"stack", "deploy", "--with-registry-auth", "--detach=false"}
-			if pruneServices {
-				args = append(args, "--prune")
+				started = time.Now()
 			}
-			args = append(args, "-c", path, deploy.Name)
-			if contextName != "" {
-				args = append([]string{"--context", contextName}, args...)
+			output := formatStackDeployResult(result)
+			outputs[name] = output
+			if uiEnabled {
+				ui.Detail(name, stackDeployCounts(result))
 			}
-			cmd := exec.CommandContext(ctx, "docker", args...)
-			var buf bytes.Buffer
-			cmd.Stdout = &buf
-			cmd.Stderr = &buf
-			if err := cmd.Run(); err != nil {
-				output := strings.TrimRight(buf.String(), "\n")
-				outputsMu.Lock()
-				outputs[deploy.Name] = output
-				failed[deploy.Name] = struct{}{}
-				outputsMu.Unlock()
+			if err != nil {
+				failed[name] = struct{}{}
 				countMu.Lock()
 				runningCount--
 				failedCount++
 				countMu.Unlock()
-				trackError(deploy.Name, err)
+				trackError(name, err)
 				if mode == "summary" {
-					printMutex.Lock()
-					_, _ = fmt.Fprintf(os.Stdout, "stack %s: failed %s\n", deploy.Name, formatDuration(time.Since(deployStarted)))
-					printMutex.Unlock()
+					_, _ = fmt.Fprintf(os.Stdout, "stack %s: failed %s\n", name, formatDuration(time.Since(started)))
 				}
 				return
 			}
-			output := strings.TrimRight(buf.String(), "\n")
-			outputsMu.Lock()
-			outputs[deploy.Name] = output
-			outputsMu.Unlock()
 			countMu.Lock()
 			runningCount--
 			doneCount++
 			countMu.Unlock()
 			if uiEnabled {
-				ui.Update(deploy.Name, "done")
+				ui.Update(name, "done")
 			} else if mode == "stack" {
-				printMutex.Lock()
 				if output != "" {
-					_, _ = fmt.Fprintf(os.Stdout, "stack %s output:\n%s\n", deploy.Name, output)
+					_, _ = fmt.Fprintf(os.Stdout, "stack %s output:\n%s\n", name, output)
 				}
-				printMutex.Unlock()
 			} else if mode == "summary" {
-				printMutex.Lock()
-				_, _ = fmt.Fprintf(os.Stdout, "stack %s: ok %s\n", deploy.Name, formatDuration(time.Since(deployStarted)))
-				printMutex.Unlock()
+				_, _ = fmt.Fprintf(os.Stdout, "stack %s: ok %s %s\n", name, formatDuration(time.Since(started)), stackDeployCounts(result))
 			}
-		}()
+		},
+	}
+	opts := deployOptions{pruneServices: pruneServices, rollback: rollback, parallel: parallel, lease: lease}
+	results, _, err := rolloutStacks(ctx, client, stacks, inventory, opts, hooks)
+	if err != nil {
+		firstErr = err
 	}
 
-	wg.Wait()
 	if heartbeatDone != nil {
 		close(heartbeatDone)
 	}
@@ -478,9 +578,62 @@ func DeployStacks(ctx context.Context, stacks []StackDeploy, contextName string,
 			}
 			_, _ = fmt.Fprintf(os.Stdout, "stack %s output:\n%s\n", deploy.Name, output)
 		}
-		return firstErr
+		return results, firstErr
 	}
-	return nil
+	return results, nil
+}
+
+// Output returns the per-network, per-service and per-job lines printed for a
+// stack deploy.
+func (r StackDeployResult) Output() string {
+	return formatStackDeployResult(r)
+}
+
+func formatStackDeployResult(result StackDeployResult) string {
+	lines := make([]string, 0, len(result.NetworksCreated)+len(result.Services)+1)
+	for _, name := range result.NetworksCreated {
+		lines = append(lines, fmt.Sprintf("network %s: created", name))
+	}
+	for _, svc := range result.Services {
+		switch {
+		case svc.Action == ServiceActionSkipped:
+			lines = append(lines, fmt.Sprintf("service %s: skipped (%s)", svc.Name, svc.Error))
+		case svc.Error != "" && svc.RolledBack:
+			lines = append(lines, fmt.Sprintf("service %s: %s failed: %s (rolled back)", svc.Name, svc.Action, svc.Error))
+		case svc.Error != "":
+			lines = append(lines, fmt.Sprintf("service %s: %s failed: %s", svc.Name, svc.Action, svc.Error))
+		default:
+			lines = append(lines, fmt.Sprintf("service %s: %s", svc.Name, svc.Action))
+		}
+		if svc.Canary != "" {
+			lines = append(lines, fmt.Sprintf("  canary %s_canary: %s", svc.Name, svc.Canary))
+		}
+		for _, job := range svc.Jobs {
+			lines = append(lines, formatJobResult(job)...)
+		}
+	}
+	if result.Error != "" && (len(result.Services) == 0 || result.Services[len(result.Services)-1].Error == "") {
+		lines = append(lines, "error: "+result.Error)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatJobResult(job JobResult) []string {
+	status := fmt.Sprintf("exit %d", job.ExitCode)
+	if job.Error != "" {
+		status = "failed: " + job.Error
+	}
+	lines := []string{fmt.Sprintf("  job %s/%s: %s", job.Phase, job.Name, status)}
+	if job.Logs != "" {
+		for _, line := range strings.Split(job.Logs, "\n") {
+			lines = append(lines, "    | "+line)
+		}
+	}
+	return lines
+}
+
+func stackDeployCounts(result StackDeployResult) string {
+	return fmt.Sprintf("created=%d updated=%d unchanged=%d removed=%d", result.Count(ServiceActionCreate), result.Count(ServiceActionUpdate), result.Count(ServiceActionUnchanged), result.Count(ServiceActionRemove))
 }
 
 func resolveDeployOutputMode(mode string, noUI bool, outputExplicit bool) string {
@@ -512,6 +665,7 @@ type stackUI struct {
 	inited   bool
 	started  map[string]time.Time
 	ended    map[string]time.Time
+	details  map[string]string
 }
 
 func newStackUI(out *os.File, statuses map[string]string) *stackUI {
@@ -526,7 +680,17 @@ func newStackUI(out *os.File, statuses map[string]string) *stackUI {
 		statuses: statuses,
 		started:  make(map[string]time.Time),
 		ended:    make(map[string]time.Time),
+		details:  make(map[string]string),
+	}
+}
+
+func (ui *stackUI) Detail(name, detail string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if _, ok := ui.statuses[name]; !ok {
+		return
 	}
+	ui.details[name] = detail
 }
 
 func (ui *stackUI) Update(name, status string) {
@@ -567,7 +731,11 @@ func (ui *stackUI) renderLocked() {
 		if elapsed != "" {
 			elapsed = " " + elapsed
 		}
-		_, _ = fmt.Fprintf(ui.out, "\x1b[2K[%s] %s%s\n", status, name, elapsed)
+		detail := ui.details[name]
+		if detail != "" {
+			detail = " " + detail
+		}
+		_, _ = fmt.Fprintf(ui.out, "\x1b[2K[%s] %s%s%s\n", status, name, elapsed, detail)
 	}
 }
 
@@ -612,31 +780,15 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("(%dh%dm)", hours, mins)
 }
 
-func writeStackCompose(deploy StackDeploy) (string, error) {
-	dir := os.TempDir()
-	base := fmt.Sprintf("swarmcp-%s-", deploy.Name)
-	file, err := os.CreateTemp(dir, base)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = file.Close() }()
-	if _, err := file.Write(deploy.Compose); err != nil {
-		return "", err
-	}
-	if err := file.Sync(); err != nil {
-		return "", err
-	}
-	return filepath.Clean(file.Name()), nil
-}
-
-func composeDeploySpec(service config.Service, constraints []string, labels map[string]string, restartPolicy *config.RestartPolicy, updatePolicy *config.UpdatePolicy, rollbackPolicy *config.UpdatePolicy) (*composeDeploy, error) {
+func composeDeploySpec(service config.Service, constraints []string, labels map[string]string, restartPolicy *config.RestartPolicy, updatePolicy *config.UpdatePolicy, rollbackPolicy *config.UpdatePolicy, resources *config.Resources) (*composeDeploy, error) {
 	mode := strings.TrimSpace(strings.ToLower(service.Mode))
 	if mode == "" {
 		mode = "replicated"
 	}
 	deploy := &composeDeploy{
-		Mode:   mode,
-		Labels: labels,
+		Mode:         mode,
+		EndpointMode: strings.TrimSpace(service.EndpointMode),
+		Labels:       labels,
 	}
 	if restartPolicy != nil {
 		policy, err := composeRestartPolicySpec(restartPolicy)
@@ -659,12 +811,15 @@ func composeDeploySpec(service config.Service, constraints []string, labels map[
 		}
 		deploy.RollbackConfig = configSpec
 	}
+	resourceSpec, err := composeResourcesSpec(resources)
+	if err != nil {
+		return nil, err
+	}
+	deploy.Resources = resourceSpec
 	if mode != "global" {
 		deploy.Replicas = new(service.Replicas)
 	}
-	if len(constraints) > 0 {
-		deploy.Placement = &composePlacement{Constraints: cloneStrings(constraints)}
-	}
+	deploy.Placement = composePlacementSpec(constraints, service.Placement)
 	return deploy, nil
 }
 
@@ -752,12 +907,16 @@ func composeMounts(mounts []mount.Mount) []composeMount {
 	}
 	out := make([]composeMount, 0, len(mounts))
 	for _, m := range mounts {
-		out = append(out, composeMount{
+		entry := composeMount{
 			Type:     string(m.Type),
 			Source:   m.Source,
 			Target:   m.Target,
 			ReadOnly: m.ReadOnly,
-		})
+		}
+		if m.VolumeOptions != nil && m.VolumeOptions.Subpath != "" {
+			entry.Volume = &composeMountVolume{Subpath: m.VolumeOptions.Subpath}
+		}
+		out = append(out, entry)
 	}
 	sort.Slice(out, func(i, j int) bool {
 		if out[i].Type != out[j].Type {
@@ -776,12 +935,13 @@ func addComposeVolumes(cfg *config.Config, stackName string, stack config.Stack,
 		return nil
 	}
 	basePath := strings.TrimSpace(cfg.Project.Defaults.Volumes.BasePath)
+	volumeDefs := cfg.StackVolumeDefs(stackName, partitionName)
 	for _, ref := range service.Volumes {
 		if ref.Name == "" {
 			continue
 		}
-		volumeDef, ok := stack.Volumes[ref.Name]
-		if !ok {
+		volumeDef, ok := volumeDefs[ref.Name]
+		if !ok || volumeDef.UsesDriver() {
 			continue
 		}
 		if basePath == "" {
@@ -816,3 +976,26 @@ func addComposeVolumes(cfg *config.Config, stackName string, stack config.Stack,
 	}
 	return nil
 }
+
+// addComposeDriverVolumes declares driver-backed volumes under their full
+// Docker name so the stack namespace is not applied a second time.
+func addComposeDriverVolumes(serviceName string, mounts []mount.Mount, volumes map[string]composeVolume) error {
+	for _, m := range mounts {
+		if m.Type != mount.TypeVolume || m.VolumeOptions == nil || m.VolumeOptions.DriverConfig == nil {
+			continue
+		}
+		volume := composeVolume{
+			Name:       m.Source,
+			Driver:     m.VolumeOptions.DriverConfig.Name,
+			DriverOpts: cloneLabels(m.VolumeOptions.DriverConfig.Options),
+		}
+		if existing, ok := volumes[m.Source]; ok {
+			if existing.Driver != volume.Driver || !labelsEqual(existing.DriverOpts, volume.DriverOpts) {
+				return fmt.Errorf("service %q volume %q: driver options differ between services", serviceName, m.Source)
+			}
+			continue
+		}
+		volumes[m.Source] = volume
+	}
+	return nil
+}
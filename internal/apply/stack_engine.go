@@ -0,0 +1,746 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+	"go.yaml.in/yaml/v4"
+)
+
+const (
+	stackNamespaceLabel = "com.docker.stack.namespace"
+	stackImageLabel     = "com.docker.stack.image"
+)
+
+const (
+	ServiceActionCreate    = "create"
+	ServiceActionUpdate    = "update"
+	ServiceActionUnchanged = "unchanged"
+	ServiceActionRemove    = "remove"
+	ServiceActionSkipped   = "skipped"
+)
+
+var deployPollInterval = 2 * time.Second
+
+type ServiceDeployResult struct {
+	Name       string      `yaml:"name" json:"name"`
+	Action     string      `yaml:"action" json:"action"`
+	Error      string      `yaml:"error,omitempty" json:"error,omitempty"`
+	RolledBack bool        `yaml:"rolled_back,omitempty" json:"rolled_back,omitempty"`
+	Canary     string      `yaml:"canary,omitempty" json:"canary,omitempty"`
+	Jobs       []JobResult `yaml:"jobs,omitempty" json:"jobs,omitempty"`
+}
+
+type StackDeployResult struct {
+	Name            string                `yaml:"name" json:"name"`
+	NetworksCreated []string              `yaml:"networks_created,omitempty" json:"networks_created,omitempty"`
+	Services        []ServiceDeployResult `yaml:"services,omitempty" json:"services,omitempty"`
+	Error           string                `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+func (r StackDeployResult) Count(action string) int {
+	count := 0
+	for _, svc := range r.Services {
+		if svc.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+type deployInventory struct {
+	configIDs      map[string]string
+	secretIDs      map[string]string
+	networkNames   map[string]struct{}
+	networkTargets map[string]string
+	services       map[string]swarm.Service
+}
+
+func loadDeployInventory(ctx context.Context, client swarm.Client) (deployInventory, error) {
+	configs, err := client.ListConfigs(ctx)
+	if err != nil {
+		return deployInventory{}, err
+	}
+	secrets, err := client.ListSecrets(ctx)
+	if err != nil {
+		return deployInventory{}, err
+	}
+	networks, err := client.ListNetworks(ctx)
+	if err != nil {
+		return deployInventory{}, err
+	}
+	services, err := client.ListServices(ctx)
+	if err != nil {
+		return deployInventory{}, err
+	}
+	inventory := deployInventory{
+		configIDs:      make(map[string]string, len(configs)),
+		secretIDs:      make(map[string]string, len(secrets)),
+		networkNames:   make(map[string]struct{}, len(networks)),
+		networkTargets: buildNetworkTargetIndex(networks),
+		services:       make(map[string]swarm.Service, len(services)),
+	}
+	for _, cfg := range configs {
+		inventory.configIDs[cfg.Name] = cfg.ID
+	}
+	for _, sec := range secrets {
+		inventory.secretIDs[sec.Name] = sec.ID
+	}
+	for _, net := range networks {
+		inventory.networkNames[net.Name] = struct{}{}
+	}
+	for _, svc := range services {
+		inventory.services[svc.Name] = svc
+	}
+	return inventory, nil
+}
+
+type deployOptions struct {
+	pruneServices bool
+	rollback      bool
+	parallel      int
+	lease         *Lease
+}
+
+type rolloutHooks struct {
+	stackStarted  func(name string)
+	stackFinished func(index int, result StackDeployResult, err error)
+}
+
+type stackRollout struct {
+	index        int
+	deploy       StackDeploy
+	compose      composeFile
+	networkNames map[string]string
+	result       StackDeployResult
+	err          error
+	remaining    int
+	started      bool
+	finished     bool
+}
+
+func (s *stackRollout) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// serviceIntent returns the planned intent of a compose service of the stack.
+func (s *stackRollout) serviceIntent(key string) (serviceIntent, error) {
+	planned, ok := s.deploy.Services[key]
+	if !ok {
+		return serviceIntent{}, fmt.Errorf("stack %q has no service intent for %q; re-run plan", s.deploy.Name, key)
+	}
+	intent, err := planned.serviceIntent()
+	if err != nil {
+		return serviceIntent{}, fmt.Errorf("stack %q service %q: %w", s.deploy.Name, key, err)
+	}
+	return intent, nil
+}
+
+type rolloutItem struct {
+	state   *stackRollout
+	service RolloutService
+}
+
+func deployStack(ctx context.Context, client swarm.Client, deploy StackDeploy, inventory deployInventory, pruneServices bool) (StackDeployResult, error) {
+	opts := deployOptions{pruneServices: pruneServices, rollback: true, parallel: 1}
+	results, errs, err := rolloutStacks(ctx, client, []StackDeploy{deploy}, inventory, opts, rolloutHooks{})
+	if err != nil {
+		return StackDeployResult{Name: deploy.Name}, err
+	}
+	return results[0], errs[0]
+}
+
+// rolloutStacks deploys services batch by batch in dependency order. Each
+// batch must be healthy before the next one starts; failed updates are rolled
+// back individually and services whose dependencies failed are skipped while
+// unrelated services keep going.
+func rolloutStacks(ctx context.Context, client swarm.Client, stacks []StackDeploy, inventory deployInventory, opts deployOptions, hooks rolloutHooks) ([]StackDeployResult, []error, error) {
+	batches, err := BuildRollout(stacks)
+	if err != nil {
+		return nil, nil, err
+	}
+	states := make(map[string]*stackRollout, len(stacks))
+	for index, deploy := range stacks {
+		states[deploy.Name] = &stackRollout{index: index, deploy: deploy, result: StackDeployResult{Name: deploy.Name}}
+	}
+	for _, batch := range batches {
+		for _, svc := range batch.Services {
+			states[svc.Stack].remaining++
+		}
+	}
+	finish := func(state *stackRollout) {
+		state.finished = true
+		sort.Slice(state.result.Services, func(i, j int) bool { return state.result.Services[i].Name < state.result.Services[j].Name })
+		if state.err == nil && opts.pruneServices {
+			state.fail(pruneStackServices(ctx, client, state, inventory))
+		}
+		if state.err != nil {
+			state.result.Error = state.err.Error()
+		}
+		if hooks.stackFinished != nil {
+			hooks.stackFinished(state.index, state.result, state.err)
+		}
+	}
+
+	failed := make(map[string]struct{})
+	for _, batch := range batches {
+		// A lost lease means another apply may be running; stop before
+		// touching more services.
+		if err := opts.lease.Check(ctx); err != nil {
+			for _, state := range states {
+				if !state.finished {
+					state.fail(err)
+				}
+			}
+			break
+		}
+		var items []rolloutItem
+		for _, svc := range batch.Services {
+			state := states[svc.Stack]
+			if !state.started {
+				state.started = true
+				if hooks.stackStarted != nil {
+					hooks.stackStarted(state.deploy.Name)
+				}
+				if err := prepareStackRollout(ctx, client, state, inventory); err != nil {
+					state.fail(err)
+					finish(state)
+				}
+			}
+			if state.finished {
+				failed[svc.Name()] = struct{}{}
+				continue
+			}
+			if dep := failedDependency(state.deploy.DependsOn[svc.Service], failed); dep != "" {
+				failed[svc.Name()] = struct{}{}
+				state.result.Services = append(state.result.Services, ServiceDeployResult{
+					Name:   svc.Name(),
+					Action: ServiceActionSkipped,
+					Error:  fmt.Sprintf("dependency %s failed", dep),
+				})
+				state.fail(fmt.Errorf("service %q: skipped: dependency %q failed", svc.Name(), dep))
+				continue
+			}
+			items = append(items, rolloutItem{state: state, service: svc})
+		}
+
+		entries := deployRolloutItems(ctx, client, items, inventory, opts.parallel)
+		pending := make(map[string]pendingService)
+		for i, item := range items {
+			name := item.service.Name()
+			if entries[i].Error != "" || entries[i].Action == ServiceActionUnchanged {
+				continue
+			}
+			timeout, err := config.ParseHealthTimeout(item.state.deploy.HealthTimeouts[item.service.Service])
+			if err != nil {
+				entries[i].Error = err.Error()
+				continue
+			}
+			entry := pendingService{timeout: timeout}
+			if entries[i].Action == ServiceActionUpdate {
+				entry.since = inventory.services[name].Version
+			}
+			pending[name] = entry
+		}
+		failures := waitForServiceHealth(ctx, client, pending)
+		runParallel(len(items), opts.parallel, func(i int) {
+			completeRolloutItem(ctx, client, items[i], &entries[i], failures[entries[i].Name], opts.rollback, inventory)
+		})
+		for i, item := range items {
+			entry := entries[i]
+			if entry.Error != "" {
+				failed[entry.Name] = struct{}{}
+				item.state.fail(fmt.Errorf("service %q: %s", entry.Name, entry.Error))
+			}
+			item.state.result.Services = append(item.state.result.Services, entry)
+		}
+
+		for _, svc := range batch.Services {
+			state := states[svc.Stack]
+			if state.finished {
+				continue
+			}
+			state.remaining--
+			if state.remaining == 0 {
+				finish(state)
+			}
+		}
+	}
+
+	results := make([]StackDeployResult, len(stacks))
+	errs := make([]error, len(stacks))
+	for _, state := range states {
+		if !state.finished {
+			finish(state)
+		}
+		results[state.index] = state.result
+		errs[state.index] = state.err
+	}
+	return results, errs, nil
+}
+
+func prepareStackRollout(ctx context.Context, client swarm.Client, state *stackRollout, inventory deployInventory) error {
+	if err := yaml.Unmarshal(state.deploy.Compose, &state.compose); err != nil {
+		return fmt.Errorf("parse compose: %w", err)
+	}
+	networkNames, created, err := ensureStackNetworks(ctx, client, state.deploy.Name, state.compose, inventory)
+	state.networkNames = networkNames
+	state.result.NetworksCreated = created
+	if err != nil {
+		return err
+	}
+	return removeOrphanedCanaries(ctx, client, state, inventory)
+}
+
+// deployRolloutItems creates or updates the services of a batch. Services
+// that change run their before_update jobs first; updates with a canary
+// policy are only applied once their canary passed.
+func deployRolloutItems(ctx context.Context, client swarm.Client, items []rolloutItem, inventory deployInventory, parallel int) []ServiceDeployResult {
+	entries := make([]ServiceDeployResult, len(items))
+	runParallel(len(items), parallel, func(i int) {
+		state := items[i].state
+		key := items[i].service.Service
+		entry := ServiceDeployResult{Name: items[i].service.Name()}
+		change, err := planStackService(state, key, inventory)
+		entry.Action = change.action
+		if err == nil && change.action != ServiceActionUnchanged {
+			var jobs []JobResult
+			jobs, err = runServiceJobs(ctx, client, state, key, config.JobPhaseBeforeUpdate, inventory)
+			entry.Jobs = append(entry.Jobs, jobs...)
+			if policy, ok := state.deploy.Canaries[key]; ok && err == nil && change.action == ServiceActionUpdate {
+				entry.Canary = CanaryPromoted
+				if err = runCanary(ctx, client, state, key, policy, inventory); err != nil {
+					entry.Canary = CanaryAborted
+				}
+			}
+			if err == nil {
+				err = applyStackServiceChange(ctx, client, change, inventory)
+			}
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		entries[i] = entry
+	})
+	return entries
+}
+
+// completeRolloutItem runs the after_update jobs of a healthy service. When
+// the service did not become healthy or an after_update job failed, updates
+// are rolled back and the on_rollback jobs run. The canary of a promoted
+// service is removed last.
+func completeRolloutItem(ctx context.Context, client swarm.Client, item rolloutItem, entry *ServiceDeployResult, healthErr error, rollback bool, inventory deployInventory) {
+	key := item.service.Service
+	if entry.Canary == CanaryPromoted {
+		defer removeCanary(ctx, client, item.state.deploy.Name, key, entry)
+	}
+	if entry.Error != "" || entry.Action == ServiceActionUnchanged {
+		return
+	}
+	if healthErr != nil {
+		entry.Error = healthErr.Error()
+	} else {
+		jobs, err := runServiceJobs(ctx, client, item.state, key, config.JobPhaseAfterUpdate, inventory)
+		entry.Jobs = append(entry.Jobs, jobs...)
+		if err == nil {
+			return
+		}
+		entry.Error = err.Error()
+	}
+	if !rollback || entry.Action != ServiceActionUpdate {
+		return
+	}
+	if err := rollbackService(ctx, client, entry.Name); err != nil {
+		entry.Error += "; rollback failed: " + err.Error()
+		return
+	}
+	entry.RolledBack = true
+	jobs, err := runServiceJobs(ctx, client, item.state, key, config.JobPhaseOnRollback, inventory)
+	entry.Jobs = append(entry.Jobs, jobs...)
+	if err != nil {
+		entry.Error += "; " + err.Error()
+	}
+}
+
+// runParallel calls fn for 0..n-1 with at most parallel calls in flight;
+// parallel < 1 means unbounded.
+func runParallel(n int, parallel int, fn func(i int)) {
+	if parallel < 1 || parallel > n {
+		parallel = n
+	}
+	sem := make(chan struct{}, max(parallel, 1))
+	wg := sync.WaitGroup{}
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+func pruneStackServices(ctx context.Context, client swarm.Client, state *stackRollout, inventory deployInventory) error {
+	namespace := state.deploy.Name
+	for _, svc := range stackNamespaceServices(inventory.services, namespace) {
+		if _, ok := state.deploy.Services[strings.TrimPrefix(svc.Name, namespace+"_")]; ok {
+			continue
+		}
+		entry := ServiceDeployResult{Name: svc.Name, Action: ServiceActionRemove}
+		if err := client.RemoveService(ctx, svc.ID); err != nil {
+			entry.Error = err.Error()
+			state.result.Services = append(state.result.Services, entry)
+			return fmt.Errorf("remove service %q: %w", svc.Name, err)
+		}
+		state.result.Services = append(state.result.Services, entry)
+	}
+	return nil
+}
+
+func failedDependency(deps []string, failed map[string]struct{}) string {
+	for _, dep := range deps {
+		if _, ok := failed[dep]; ok {
+			return dep
+		}
+	}
+	return ""
+}
+
+func ensureStackNetworks(ctx context.Context, client swarm.Client, namespace string, compose composeFile, inventory deployInventory) (map[string]string, []string, error) {
+	names := make(map[string]string, len(compose.Networks))
+	var created []string
+	keys := make([]string, 0, len(compose.Networks))
+	for key := range compose.Networks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		network := compose.Networks[key]
+		if network.External {
+			name := firstNonEmpty(network.Name, key)
+			if _, ok := inventory.networkNames[name]; !ok {
+				return names, created, fmt.Errorf("network %q is declared as external, but could not be found", name)
+			}
+			names[key] = name
+			continue
+		}
+		name := namespace + "_" + key
+		names[key] = name
+		if _, ok := inventory.networkNames[name]; ok {
+			continue
+		}
+		labels := map[string]string{stackNamespaceLabel: namespace}
+		for key, value := range network.Labels {
+			labels[key] = value
+		}
+		spec := swarm.NetworkSpec{
+			Name:       name,
+			Driver:     "overlay",
+			Attachable: network.Attachable,
+			Internal:   network.Internal,
+			Labels:     labels,
+			Options:    network.DriverOpts,
+		}
+		if network.IPAM != nil {
+			spec.IPAM = network.IPAM.Config
+		}
+		if _, err := client.CreateNetwork(ctx, spec); err != nil {
+			return names, created, fmt.Errorf("create network %q: %w", name, err)
+		}
+		created = append(created, name)
+	}
+	return names, created, nil
+}
+
+type stackServiceChange struct {
+	action string
+	create ServiceCreate
+	update ServiceUpdate
+}
+
+func planStackService(state *stackRollout, key string, inventory deployInventory) (stackServiceChange, error) {
+	namespace := state.deploy.Name
+	name := namespace + "_" + key
+	intent, err := state.serviceIntent(key)
+	if err != nil {
+		return stackServiceChange{action: ServiceActionCreate}, err
+	}
+	existing, ok := inventory.services[name]
+	if !ok {
+		spec := applyIntentToSpec(dockerapi.ServiceSpec{Annotations: dockerapi.Annotations{Name: name}}, intent)
+		spec = applyStackNamespace(spec, namespace, key, intent.Image)
+		return stackServiceChange{action: ServiceActionCreate, create: ServiceCreate{
+			Name:    name,
+			Spec:    spec,
+			Configs: intent.Configs,
+			Secrets: intent.Secrets,
+		}}, nil
+	}
+	current := intentFromSpec(existing.Spec, inventory.networkTargets)
+	if intentEqual(current, intent) && existing.Labels[stackNamespaceLabel] == namespace {
+		return stackServiceChange{action: ServiceActionUnchanged}, nil
+	}
+	spec := applyIntentToSpec(existing.Spec, intent)
+	spec = applyStackNamespace(spec, namespace, key, intent.Image)
+	return stackServiceChange{action: ServiceActionUpdate, update: ServiceUpdate{
+		Service: existing,
+		Spec:    spec,
+		Configs: intent.Configs,
+		Secrets: intent.Secrets,
+	}}, nil
+}
+
+func applyStackServiceChange(ctx context.Context, client swarm.Client, change stackServiceChange, inventory deployInventory) error {
+	switch change.action {
+	case ServiceActionCreate:
+		_, err := createService(ctx, client, change.create, inventory.configIDs, inventory.secretIDs)
+		return err
+	case ServiceActionUpdate:
+		return updateService(ctx, client, change.update, inventory.configIDs, inventory.secretIDs)
+	}
+	return nil
+}
+
+func applyStackNamespace(spec dockerapi.ServiceSpec, namespace string, key string, image string) dockerapi.ServiceSpec {
+	labels := cloneLabels(spec.Annotations.Labels)
+	if labels == nil {
+		labels = make(map[string]string, 2)
+	}
+	labels[stackNamespaceLabel] = namespace
+	labels[stackImageLabel] = image
+	spec.Annotations.Labels = labels
+	if spec.TaskTemplate.ContainerSpec != nil {
+		containerLabels := cloneLabels(spec.TaskTemplate.ContainerSpec.Labels)
+		if containerLabels == nil {
+			containerLabels = make(map[string]string, 1)
+		}
+		containerLabels[stackNamespaceLabel] = namespace
+		spec.TaskTemplate.ContainerSpec.Labels = containerLabels
+	}
+	for i := range spec.TaskTemplate.Networks {
+		spec.TaskTemplate.Networks[i].Aliases = []string{key}
+	}
+	return spec
+}
+
+func stackNamespaceServices(services map[string]swarm.Service, namespace string) []swarm.Service {
+	var out []swarm.Service
+	for _, svc := range services {
+		if svc.Labels[stackNamespaceLabel] == namespace && !isCanary(svc.Labels) {
+			out = append(out, svc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+type pendingService struct {
+	since   uint64
+	timeout time.Duration
+}
+
+// waitForServiceHealth polls until every pending service reports all desired
+// replicas running and healthy, and returns the services that failed or did
+// not become healthy within their timeout, keyed by name.
+func waitForServiceHealth(ctx context.Context, client swarm.Client, pending map[string]pendingService) map[string]error {
+	failures := make(map[string]error)
+	if len(pending) == 0 {
+		return failures
+	}
+	failAll := func(err error) map[string]error {
+		for name := range pending {
+			failures[name] = err
+		}
+		return failures
+	}
+	started := time.Now()
+	progress := make(map[string]string, len(pending))
+	for {
+		services, err := client.ListServices(ctx)
+		if err != nil {
+			return failAll(err)
+		}
+		for _, svc := range services {
+			entry, ok := pending[svc.Name]
+			if !ok || svc.Version <= entry.since {
+				continue
+			}
+			tasks, err := client.ListServiceTasks(ctx, svc.ID)
+			if err != nil {
+				failures[svc.Name] = err
+				delete(pending, svc.Name)
+				continue
+			}
+			healthy, status, err := serviceHealthy(svc, tasks)
+			progress[svc.Name] = status
+			if err != nil {
+				failures[svc.Name] = err
+				delete(pending, svc.Name)
+				continue
+			}
+			if healthy {
+				delete(pending, svc.Name)
+			}
+		}
+		elapsed := time.Since(started)
+		for name, entry := range pending {
+			if elapsed < entry.timeout {
+				continue
+			}
+			status := progress[name]
+			if status == "" {
+				status = "service not updated"
+			}
+			failures[name] = fmt.Errorf("not healthy within %s (%s)", entry.timeout, status)
+			delete(pending, name)
+		}
+		if len(pending) == 0 {
+			return failures
+		}
+		select {
+		case <-ctx.Done():
+			return failAll(fmt.Errorf("waiting for healthy replicas: %w", ctx.Err()))
+		case <-time.After(deployPollInterval):
+		}
+	}
+}
+
+// serviceHealthy reports whether all desired replicas of the service's
+// current spec are running. Swarm only marks a task running once its
+// healthcheck passes, so running tasks are healthy ones. Any task of the
+// current spec that failed during this rollout fails the service.
+func serviceHealthy(svc swarm.Service, tasks []swarm.Task) (bool, string, error) {
+	since := svc.CreatedAt
+	updating := false
+	if svc.UpdateStatus != nil {
+		switch svc.UpdateStatus.State {
+		case dockerapi.UpdateStateUpdating, dockerapi.UpdateStateRollbackStarted:
+			updating = true
+		case dockerapi.UpdateStatePaused, dockerapi.UpdateStateRollbackPaused:
+			return false, "", fmt.Errorf("update %s: %s", svc.UpdateStatus.State, svc.UpdateStatus.Message)
+		case dockerapi.UpdateStateRollbackCompleted:
+			return false, "", fmt.Errorf("update rolled back by swarm: %s", svc.UpdateStatus.Message)
+		}
+		if svc.UpdateStatus.StartedAt != nil {
+			since = *svc.UpdateStatus.StartedAt
+		}
+	}
+	current := svc.Spec.TaskTemplate.ContainerSpec
+	running := 0
+	for _, task := range tasks {
+		if !taskRunsSpec(task.Spec.ContainerSpec, current) {
+			continue
+		}
+		switch task.State {
+		case dockerapi.TaskStateFailed, dockerapi.TaskStateRejected:
+			if !task.CreatedAt.Before(since) {
+				return false, "", fmt.Errorf("task %s.%d %s: %s", svc.Name, task.Slot, task.State, taskFailure(task))
+			}
+		case dockerapi.TaskStateRunning:
+			if task.DesiredState == dockerapi.TaskStateRunning {
+				running++
+			}
+		}
+	}
+	desired := desiredReplicas(svc, tasks)
+	status := fmt.Sprintf("running %d/%d", running, desired)
+	if updating {
+		return false, status, nil
+	}
+	return running >= desired, status, nil
+}
+
+// taskRunsSpec reports whether a task was created from the current container
+// spec. Only the fields swarmcp sets are compared, so defaults the daemon
+// fills in, such as isolation, init or a resolved image digest, do not hide
+// the tasks of the current spec.
+func taskRunsSpec(task *dockerapi.ContainerSpec, current *dockerapi.ContainerSpec) bool {
+	if task == nil || current == nil {
+		return task == current
+	}
+	got := containerSpecIntent(task)
+	want := containerSpecIntent(current)
+	if !imagesMatch(got.Image, want.Image) {
+		return false
+	}
+	got.Image = want.Image
+	if want.Runtime.Init == nil {
+		got.Runtime.Init = nil
+	}
+	return intentEqual(got, want)
+}
+
+func containerSpecIntent(spec *dockerapi.ContainerSpec) serviceIntent {
+	return intentFromSpec(dockerapi.ServiceSpec{TaskTemplate: dockerapi.TaskSpec{ContainerSpec: spec}}, nil)
+}
+
+// imagesMatch compares image references, ignoring a digest that was pinned on
+// only one side.
+func imagesMatch(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	if imageHasDigest(a) == imageHasDigest(b) {
+		return false
+	}
+	return imageWithoutDigest(a) == imageWithoutDigest(b)
+}
+
+func imageWithoutDigest(image string) string {
+	name, _, _ := strings.Cut(image, "@")
+	return name
+}
+
+func desiredReplicas(svc swarm.Service, tasks []swarm.Task) int {
+	if replicated := svc.Spec.Mode.Replicated; replicated != nil && replicated.Replicas != nil {
+		return int(*replicated.Replicas)
+	}
+	if svc.Status != nil {
+		return int(svc.Status.DesiredTasks)
+	}
+	desired := 0
+	for _, task := range tasks {
+		if task.DesiredState == dockerapi.TaskStateRunning {
+			desired++
+		}
+	}
+	return desired
+}
+
+func taskFailure(task swarm.Task) string {
+	message := firstNonEmpty(task.Err, task.Message)
+	if task.ExitCode != 0 {
+		message = fmt.Sprintf("%s (exit code %d)", message, task.ExitCode)
+	}
+	return message
+}
+
+// rollbackService restores the previous spec of a service whose update did
+// not become healthy. Other services in the batch are left as deployed.
+func rollbackService(ctx context.Context, client swarm.Client, name string) error {
+	services, err := client.ListServices(ctx)
+	if err != nil {
+		return err
+	}
+	for _, svc := range services {
+		if svc.Name != name {
+			continue
+		}
+		if svc.PreviousSpec == nil {
+			return fmt.Errorf("no previous spec")
+		}
+		return client.RollbackService(ctx, svc)
+	}
+	return fmt.Errorf("service not found")
+}
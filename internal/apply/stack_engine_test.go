@@ -0,0 +1,551 @@
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/render"
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func TestStackDeployCarriesServiceIntent(t *testing.T) {
+	cases := []struct {
+		name      string
+		cfg       *config.Config
+		partition string
+	}{
+		{name: "minimal", cfg: minimalConfig()},
+		{name: "policy", cfg: policyConfig()},
+		{name: "labels", cfg: labelConfig(), partition: "dev"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stack := tc.cfg.Stacks["app"]
+			build, err := buildServiceIntent(tc.cfg, "app", stack, tc.partition, "web", stack.Services["web"], nil, false, map[defKey]string{})
+			if err != nil {
+				t.Fatalf("buildServiceIntent: %v", err)
+			}
+			var partitionFilters []string
+			if tc.partition != "" {
+				partitionFilters = []string{tc.partition}
+			}
+			deploys, err := BuildStackDeploys(tc.cfg, DesiredState{}, nil, partitionFilters, nil, nil, nil, nil, false)
+			if err != nil {
+				t.Fatalf("BuildStackDeploys: %v", err)
+			}
+			raw, err := MarshalPlanFile(NewPlanFile("test", "proj", "", "", "", "", false, Plan{StackDeploys: deploys}))
+			if err != nil {
+				t.Fatalf("MarshalPlanFile: %v", err)
+			}
+			planFile, err := ParsePlanFile(raw)
+			if err != nil {
+				t.Fatalf("ParsePlanFile: %v", err)
+			}
+			release, err := json.Marshal(Release{StackDeploys: deploys})
+			if err != nil {
+				t.Fatalf("marshal release: %v", err)
+			}
+			var recorded Release
+			if err := json.Unmarshal(release, &recorded); err != nil {
+				t.Fatalf("unmarshal release: %v", err)
+			}
+			for source, stacks := range map[string][]StackDeploy{"build": deploys, "plan file": planFile.Plan.StackDeploys, "release": recorded.StackDeploys} {
+				planned, ok := stacks[0].Services["web"]
+				if !ok {
+					t.Fatalf("%s: missing service intent", source)
+				}
+				intent, err := planned.serviceIntent()
+				if err != nil {
+					t.Fatalf("%s: %v", source, err)
+				}
+				if diffs := intentDiffs(intent, build.Intent); len(diffs) > 0 {
+					t.Fatalf("%s: stack intent drifted from service intent: %v", source, diffs)
+				}
+			}
+		})
+	}
+}
+
+func TestDeployStackCreatesNamespacedServices(t *testing.T) {
+	client := stackTestClient()
+	deploy := mustStackDeploy(t, minimalConfig())
+
+	result := mustDeployStack(t, client, deploy, false)
+	if len(client.createdServices) != 1 {
+		t.Fatalf("expected 1 service create, got %d", len(client.createdServices))
+	}
+	spec := client.createdServices[0]
+	if spec.Annotations.Name != "proj_app_web" {
+		t.Fatalf("unexpected service name %q", spec.Annotations.Name)
+	}
+	if spec.Annotations.Labels[stackNamespaceLabel] != "proj_app" {
+		t.Fatalf("expected stack namespace label, got %v", spec.Annotations.Labels)
+	}
+	if spec.Annotations.Labels[stackImageLabel] != "nginx:latest" {
+		t.Fatalf("expected stack image label, got %v", spec.Annotations.Labels)
+	}
+	if spec.TaskTemplate.ContainerSpec.Labels[stackNamespaceLabel] != "proj_app" {
+		t.Fatalf("expected container namespace label")
+	}
+	if result.Count(ServiceActionCreate) != 1 {
+		t.Fatalf("expected create result, got %+v", result.Services)
+	}
+
+	result = mustDeployStack(t, client, deploy, false)
+	if len(client.updatedServices) != 0 {
+		t.Fatalf("expected converged service to be left alone, got %d updates", len(client.updatedServices))
+	}
+	if result.Count(ServiceActionUnchanged) != 1 {
+		t.Fatalf("expected unchanged result, got %+v", result.Services)
+	}
+}
+
+func TestDeployStackUpdatesChangedServices(t *testing.T) {
+	client := stackTestClient()
+	cfg := minimalConfig()
+	mustDeployStack(t, client, mustStackDeploy(t, cfg), false)
+
+	service := cfg.Stacks["app"].Services["web"]
+	service.Image = "nginx:1.27"
+	cfg.Stacks["app"].Services["web"] = service
+	result := mustDeployStack(t, client, mustStackDeploy(t, cfg), false)
+	if len(client.updatedServices) != 1 {
+		t.Fatalf("expected 1 service update, got %d", len(client.updatedServices))
+	}
+	if got := client.updatedServices[0].TaskTemplate.ContainerSpec.Image; got != "nginx:1.27" {
+		t.Fatalf("unexpected updated image %q", got)
+	}
+	if result.Count(ServiceActionUpdate) != 1 {
+		t.Fatalf("expected update result, got %+v", result.Services)
+	}
+}
+
+func TestDeployStackPrunesRemovedServices(t *testing.T) {
+	client := stackTestClient()
+	client.services = []swarm.Service{
+		{ID: "old-id", Name: "proj_app_old", Labels: map[string]string{stackNamespaceLabel: "proj_app"}, Version: 3},
+		{ID: "other-id", Name: "proj_other_api", Labels: map[string]string{stackNamespaceLabel: "proj_other"}, Version: 3},
+	}
+	deploy := mustStackDeploy(t, minimalConfig())
+
+	mustDeployStack(t, client, deploy, false)
+	if len(client.removedServices) != 0 {
+		t.Fatalf("expected no removals without prune, got %v", client.removedServices)
+	}
+	result := mustDeployStack(t, client, deploy, true)
+	if len(client.removedServices) != 1 || client.removedServices[0] != "old-id" {
+		t.Fatalf("expected only proj_app_old to be removed, got %v", client.removedServices)
+	}
+	if result.Count(ServiceActionRemove) != 1 {
+		t.Fatalf("expected remove result, got %+v", result.Services)
+	}
+}
+
+func TestDeployStackCreatesStackNetworks(t *testing.T) {
+	client := stackTestClient()
+	cfg := minimalConfig()
+	service := cfg.Stacks["app"].Services["web"]
+	service.NetworkEphemeral = &config.ServiceNetworkEphemeral{}
+	cfg.Stacks["app"].Services["web"] = service
+
+	result := mustDeployStack(t, client, mustStackDeploy(t, cfg), false)
+	if len(client.createdNetworks) != 1 {
+		t.Fatalf("expected 1 network create, got %d", len(client.createdNetworks))
+	}
+	network := client.createdNetworks[0]
+	if network.Labels[stackNamespaceLabel] != "proj_app" || network.Driver != "overlay" {
+		t.Fatalf("unexpected stack network spec %+v", network)
+	}
+	if len(result.NetworksCreated) != 1 || result.NetworksCreated[0] != network.Name {
+		t.Fatalf("expected network in result, got %v", result.NetworksCreated)
+	}
+	var attached bool
+	for _, attachment := range client.createdServices[0].TaskTemplate.Networks {
+		if len(attachment.Aliases) != 1 || attachment.Aliases[0] != "web" {
+			t.Fatalf("expected service alias on %q, got %v", attachment.Target, attachment.Aliases)
+		}
+		if attachment.Target == network.Name {
+			attached = true
+		}
+	}
+	if !attached {
+		t.Fatalf("expected service attached to %q", network.Name)
+	}
+}
+
+func TestDeployStackRejectsMissingExternalNetwork(t *testing.T) {
+	client := &fakeClient{}
+	deploy := StackDeploy{
+		Name:    "proj_app",
+		Compose: []byte("services:\n  web:\n    image: nginx:latest\n    networks: [shared]\nnetworks:\n  shared:\n    external: true\n    name: proj_shared\n"),
+		Services: map[string]ServiceIntent{
+			"web": {Image: "nginx:latest", Mode: "replicated", Networks: []string{"proj_shared"}},
+		},
+	}
+	inventory, err := loadDeployInventory(context.Background(), client)
+	if err != nil {
+		t.Fatalf("loadDeployInventory: %v", err)
+	}
+	if _, err := deployStack(context.Background(), client, deploy, inventory, false); err == nil {
+		t.Fatalf("expected missing external network error")
+	}
+	if len(client.createdServices) != 0 {
+		t.Fatalf("expected no service creates, got %d", len(client.createdServices))
+	}
+}
+
+func stackTestClient() *fakeClient {
+	return &fakeClient{
+		networks: []swarm.Network{{ID: "net-app", Name: "proj_app", Driver: "overlay"}},
+	}
+}
+
+func mustStackDeploy(t *testing.T, cfg *config.Config) StackDeploy {
+	t.Helper()
+	deploys, err := BuildStackDeploys(cfg, DesiredState{}, nil, nil, nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildStackDeploys: %v", err)
+	}
+	if len(deploys) != 1 {
+		t.Fatalf("expected 1 stack deploy, got %d", len(deploys))
+	}
+	return deploys[0]
+}
+
+func mustDeployStack(t *testing.T, client *fakeClient, deploy StackDeploy, prune bool) StackDeployResult {
+	t.Helper()
+	inventory, err := loadDeployInventory(context.Background(), client)
+	if err != nil {
+		t.Fatalf("loadDeployInventory: %v", err)
+	}
+	result, err := deployStack(context.Background(), client, deploy, inventory, prune)
+	if err != nil {
+		t.Fatalf("deployStack: %v", err)
+	}
+	return result
+}
+
+func TestServiceHealthyRequiresAllReplicasRunning(t *testing.T) {
+	replicas := uint64(2)
+	container := &dockerapi.ContainerSpec{Image: "nginx:latest"}
+	svc := swarm.Service{
+		Name: "proj_app_web",
+		Spec: dockerapi.ServiceSpec{
+			Mode:         dockerapi.ServiceMode{Replicated: &dockerapi.ReplicatedService{Replicas: &replicas}},
+			TaskTemplate: dockerapi.TaskSpec{ContainerSpec: container},
+		},
+	}
+	task := func(state dockerapi.TaskState, image string) swarm.Task {
+		return swarm.Task{
+			Spec:         dockerapi.TaskSpec{ContainerSpec: &dockerapi.ContainerSpec{Image: image}},
+			DesiredState: dockerapi.TaskStateRunning,
+			State:        state,
+			Err:          "unhealthy container",
+		}
+	}
+
+	healthy, status, err := serviceHealthy(svc, []swarm.Task{task(dockerapi.TaskStateRunning, "nginx:latest"), task(dockerapi.TaskStateStarting, "nginx:latest")})
+	if err != nil || healthy || status != "running 1/2" {
+		t.Fatalf("expected starting replica to block health, got healthy=%v status=%q err=%v", healthy, status, err)
+	}
+	healthy, _, err = serviceHealthy(svc, []swarm.Task{task(dockerapi.TaskStateRunning, "nginx:latest"), task(dockerapi.TaskStateRunning, "nginx:1.0")})
+	if err != nil || healthy {
+		t.Fatalf("expected replicas of the previous spec to be ignored, got healthy=%v err=%v", healthy, err)
+	}
+	healthy, _, err = serviceHealthy(svc, []swarm.Task{task(dockerapi.TaskStateRunning, "nginx:latest"), task(dockerapi.TaskStateRunning, "nginx:latest")})
+	if err != nil || !healthy {
+		t.Fatalf("expected all replicas running to be healthy, got healthy=%v err=%v", healthy, err)
+	}
+	if _, _, err = serviceHealthy(svc, []swarm.Task{task(dockerapi.TaskStateFailed, "nginx:latest")}); err == nil {
+		t.Fatalf("expected failed task to fail the service")
+	}
+
+	defaulted := func(state dockerapi.TaskState) swarm.Task {
+		entry := task(state, "nginx:latest@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+		entry.Spec.ContainerSpec.Isolation = "default"
+		entry.Spec.ContainerSpec.Init = new(false)
+		return entry
+	}
+	healthy, _, err = serviceHealthy(svc, []swarm.Task{defaulted(dockerapi.TaskStateRunning), defaulted(dockerapi.TaskStateRunning)})
+	if err != nil || !healthy {
+		t.Fatalf("expected daemon defaults to keep tasks on the current spec, got healthy=%v err=%v", healthy, err)
+	}
+}
+
+func TestDeployStackRollsBackUnhealthyUpdate(t *testing.T) {
+	for _, rollback := range []bool{true, false} {
+		client := stackTestClient()
+		cfg := minimalConfig()
+		mustDeployStack(t, client, mustStackDeploy(t, cfg), false)
+
+		service := cfg.Stacks["app"].Services["web"]
+		service.Image = "nginx:broken"
+		service.Replicas = 1
+		cfg.Stacks["app"].Services["web"] = service
+		client.taskStates = map[string]dockerapi.TaskState{"proj_app_web": dockerapi.TaskStateFailed}
+		inventory, err := loadDeployInventory(context.Background(), client)
+		if err != nil {
+			t.Fatalf("loadDeployInventory: %v", err)
+		}
+		opts := deployOptions{rollback: rollback, parallel: 1}
+		results, errs, err := rolloutStacks(context.Background(), client, []StackDeploy{mustStackDeploy(t, cfg)}, inventory, opts, rolloutHooks{})
+		if err != nil {
+			t.Fatalf("rolloutStacks: %v", err)
+		}
+		if errs[0] == nil {
+			t.Fatalf("expected unhealthy update to fail the stack")
+		}
+		entry := results[0].Services[0]
+		if entry.Action != ServiceActionUpdate || entry.RolledBack != rollback {
+			t.Fatalf("rollback=%v: unexpected result %+v", rollback, entry)
+		}
+		if rollback && (len(client.rolledBack) != 1 || client.rolledBack[0] != "proj_app_web") {
+			t.Fatalf("expected proj_app_web to be rolled back, got %v", client.rolledBack)
+		}
+		if !rollback && len(client.rolledBack) != 0 {
+			t.Fatalf("expected no rollback with rollback disabled, got %v", client.rolledBack)
+		}
+	}
+}
+
+func TestDeployStackHealthTimeout(t *testing.T) {
+	previous := deployPollInterval
+	deployPollInterval = time.Millisecond
+	defer func() { deployPollInterval = previous }()
+
+	client := stackTestClient()
+	client.taskStates = map[string]dockerapi.TaskState{"proj_app_web": dockerapi.TaskStateStarting}
+	cfg := minimalConfig()
+	service := cfg.Stacks["app"].Services["web"]
+	service.Replicas = 1
+	service.HealthTimeout = "5ms"
+	cfg.Stacks["app"].Services["web"] = service
+	deploy := mustStackDeploy(t, cfg)
+	if deploy.HealthTimeouts["web"] != "5ms" {
+		t.Fatalf("expected health timeout in stack deploy, got %v", deploy.HealthTimeouts)
+	}
+
+	inventory, err := loadDeployInventory(context.Background(), client)
+	if err != nil {
+		t.Fatalf("loadDeployInventory: %v", err)
+	}
+	result, err := deployStack(context.Background(), client, deploy, inventory, false)
+	if err == nil || !strings.Contains(err.Error(), "not healthy within 5ms (running 0/1)") {
+		t.Fatalf("expected health timeout error, got %v", err)
+	}
+	if result.Services[0].RolledBack {
+		t.Fatalf("expected new service not to be rolled back")
+	}
+}
+
+func TestDeployStackRunsServiceJobs(t *testing.T) {
+	client := stackTestClient()
+	client.serviceLogs = map[string]string{"proj_app_web_job_migrate": "migrated 3 tables\n"}
+	cfg := minimalConfig()
+	service := cfg.Stacks["app"].Services["web"]
+	service.Env = map[string]string{"MODE": "serve", "LEVEL": "info"}
+	service.Jobs = config.ServiceJobs{
+		BeforeUpdate: []config.Job{{Name: "migrate", Command: []string{"/app/migrate"}, Env: map[string]string{"MODE": "migrate"}}},
+		AfterUpdate:  []config.Job{{Name: "warm", Command: []string{"/app/warm"}, Cleanup: "never"}},
+	}
+	cfg.Stacks["app"].Services["web"] = service
+	deploy := mustStackDeploy(t, cfg)
+	if steps := deploy.Jobs["web"]; len(steps) != 2 || steps[0].Phase != config.JobPhaseBeforeUpdate || steps[1].Cleanup != config.JobCleanupNever {
+		t.Fatalf("unexpected job steps: %+v", deploy.Jobs)
+	}
+
+	result := mustDeployStack(t, client, deploy, false)
+	var order []string
+	for _, spec := range client.createdServices {
+		order = append(order, spec.Annotations.Name)
+	}
+	if strings.Join(order, ",") != "proj_app_web_job_migrate,proj_app_web,proj_app_web_job_warm" {
+		t.Fatalf("unexpected create order: %v", order)
+	}
+	job := client.createdServices[0]
+	if job.Mode.ReplicatedJob == nil || job.TaskTemplate.RestartPolicy.Condition != dockerapi.RestartPolicyConditionNone {
+		t.Fatalf("expected one-shot replicated job, got %+v", job.Mode)
+	}
+	if job.Labels[stackNamespaceLabel] != "" || len(job.EndpointSpec.Ports) != 0 {
+		t.Fatalf("job must not join the stack namespace or publish ports")
+	}
+	if job.Labels[render.LabelManaged] != "true" || job.Labels[render.LabelProject] != "proj" || job.Labels[render.LabelStack] != "app" || job.Labels[jobOwnerLabel] != "proj_app_web" {
+		t.Fatalf("expected managed project and stack labels on the job, got %v", job.Labels)
+	}
+	if _, ok := job.Labels[render.LabelService]; ok {
+		t.Fatalf("job must not carry the service label of its owner, got %v", job.Labels)
+	}
+	if env := strings.Join(job.TaskTemplate.ContainerSpec.Env, ","); env != "LEVEL=info,MODE=migrate" {
+		t.Fatalf("unexpected job env: %s", env)
+	}
+	if len(client.removedServices) != 1 || client.removedServices[0] != "service-id-proj_app_web_job_migrate" {
+		t.Fatalf("expected only the migrate job to be cleaned up, got %v", client.removedServices)
+	}
+	jobs := result.Services[0].Jobs
+	if len(jobs) != 2 || jobs[0].Logs != "migrated 3 tables" || jobs[1].Phase != config.JobPhaseAfterUpdate {
+		t.Fatalf("unexpected job results: %+v", jobs)
+	}
+}
+
+// cancellingJobClient cancels the apply while a job runs and records whether
+// the job's cleanup still had a live context.
+type cancellingJobClient struct {
+	*fakeClient
+	cancel     context.CancelFunc
+	cleanupErr []error
+}
+
+func (c *cancellingJobClient) ListServiceTasks(ctx context.Context, serviceID string) ([]swarm.Task, error) {
+	c.cancel()
+	return c.fakeClient.ListServiceTasks(ctx, serviceID)
+}
+
+func (c *cancellingJobClient) RemoveService(ctx context.Context, id string) error {
+	c.cleanupErr = append(c.cleanupErr, ctx.Err())
+	return c.fakeClient.RemoveService(ctx, id)
+}
+
+func TestRunJobCleansUpAfterCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &cancellingJobClient{fakeClient: stackTestClient(), cancel: cancel}
+	client.taskStates = map[string]dockerapi.TaskState{"proj_app_web_job_migrate": dockerapi.TaskStateRunning}
+	cfg := minimalConfig()
+	service := cfg.Stacks["app"].Services["web"]
+	service.Jobs = config.ServiceJobs{
+		BeforeUpdate: []config.Job{{Name: "migrate", Command: []string{"/app/migrate"}, Cleanup: "always"}},
+	}
+	cfg.Stacks["app"].Services["web"] = service
+	deploy := mustStackDeploy(t, cfg)
+	inventory, err := loadDeployInventory(context.Background(), client)
+	if err != nil {
+		t.Fatalf("loadDeployInventory: %v", err)
+	}
+
+	result := runJob(ctx, client, &stackRollout{deploy: deploy}, "web", deploy.Jobs["web"][0], inventory)
+	if !strings.Contains(result.Error, "context canceled") {
+		t.Fatalf("expected the cancelled wait to fail the job, got %+v", result)
+	}
+	if len(client.cleanupErr) != 1 || client.cleanupErr[0] != nil {
+		t.Fatalf("expected cleanup to run on a live context, got %v", client.cleanupErr)
+	}
+}
+
+func TestDeployStackBeforeUpdateJobFailureKeepsService(t *testing.T) {
+	client := stackTestClient()
+	client.taskStates = map[string]dockerapi.TaskState{"proj_app_web_job_migrate": dockerapi.TaskStateFailed}
+	cfg := minimalConfig()
+	service := cfg.Stacks["app"].Services["web"]
+	service.Jobs = config.ServiceJobs{
+		BeforeUpdate: []config.Job{{Name: "migrate", Command: []string{"/app/migrate"}}},
+	}
+	cfg.Stacks["app"].Services["web"] = service
+
+	inventory, err := loadDeployInventory(context.Background(), client)
+	if err != nil {
+		t.Fatalf("loadDeployInventory: %v", err)
+	}
+	result, err := deployStack(context.Background(), client, mustStackDeploy(t, cfg), inventory, false)
+	if err == nil || !strings.Contains(err.Error(), `before_update job "migrate"`) {
+		t.Fatalf("expected before_update job failure, got %v", err)
+	}
+	if len(client.createdServices) != 1 {
+		t.Fatalf("expected only the job service to be created, got %d services", len(client.createdServices))
+	}
+	if len(client.removedServices) != 0 {
+		t.Fatalf("expected failed job to be kept for inspection, got %v", client.removedServices)
+	}
+	if jobs := result.Services[0].Jobs; len(jobs) != 1 || jobs[0].Error == "" {
+		t.Fatalf("unexpected job results: %+v", jobs)
+	}
+}
+
+func TestDeployStackCanaryPromotes(t *testing.T) {
+	previous := deployPollInterval
+	deployPollInterval = time.Millisecond
+	defer func() { deployPollInterval = previous }()
+
+	client := stackTestClient()
+	cfg := minimalConfig()
+	service := cfg.Stacks["app"].Services["web"]
+	service.Replicas = 3
+	service.Ports = []config.Port{{Target: 80, Published: 8080}}
+	service.Rollout = config.RolloutCanary
+	service.Canary = &config.Canary{Replicas: 2, Window: "5ms"}
+	cfg.Stacks["app"].Services["web"] = service
+	mustDeployStack(t, client, mustStackDeploy(t, cfg), false)
+	if len(client.createdServices) != 1 {
+		t.Fatalf("expected new service to be created without a canary, got %d creates", len(client.createdServices))
+	}
+
+	service.Image = "nginx:1.27"
+	cfg.Stacks["app"].Services["web"] = service
+	deploy := mustStackDeploy(t, cfg)
+	if policy := deploy.Canaries["web"]; policy.Replicas != 2 || policy.Window != "5ms" {
+		t.Fatalf("unexpected canary policy: %+v", deploy.Canaries)
+	}
+	result := mustDeployStack(t, client, deploy, false)
+	canary := client.createdServices[1]
+	if canary.Annotations.Name != "proj_app_web_canary" || canary.Labels[canaryOfLabel] != "proj_app_web" {
+		t.Fatalf("unexpected canary service %q labels %v", canary.Annotations.Name, canary.Labels)
+	}
+	if canary.TaskTemplate.ContainerSpec.Image != "nginx:1.27" || *canary.Mode.Replicated.Replicas != 2 {
+		t.Fatalf("expected 2 canary replicas of the new image, got %+v", canary.Mode)
+	}
+	if canary.Labels[stackNamespaceLabel] != "proj_app" || canary.Labels[render.LabelManaged] != "true" || canary.Labels[render.LabelService] != "web" {
+		t.Fatalf("expected canary to keep the service and stack namespace labels, got %v", canary.Labels)
+	}
+	if canary.EndpointSpec != nil && len(canary.EndpointSpec.Ports) != 0 {
+		t.Fatalf("canary must not publish ports")
+	}
+	if len(client.updatedServices) != 1 || client.updatedServices[0].TaskTemplate.ContainerSpec.Image != "nginx:1.27" {
+		t.Fatalf("expected main service to be updated after the canary, got %d updates", len(client.updatedServices))
+	}
+	if len(client.removedServices) != 1 || client.removedServices[0] != "service-id-proj_app_web_canary" {
+		t.Fatalf("expected canary to be removed after promotion, got %v", client.removedServices)
+	}
+	if entry := result.Services[0]; entry.Canary != CanaryPromoted || entry.Error != "" {
+		t.Fatalf("unexpected result %+v", entry)
+	}
+}
+
+func TestDeployStackCanaryAborts(t *testing.T) {
+	previous := deployPollInterval
+	deployPollInterval = time.Millisecond
+	defer func() { deployPollInterval = previous }()
+
+	client := stackTestClient()
+	cfg := minimalConfig()
+	mustDeployStack(t, client, mustStackDeploy(t, cfg), false)
+
+	service := cfg.Stacks["app"].Services["web"]
+	service.Image = "nginx:broken"
+	cfg.Stacks["app"].Services["web"] = service
+	stacks := WithCanaryRollout([]StackDeploy{mustStackDeploy(t, cfg)})
+	if policy := stacks[0].Canaries["web"]; policy.Replicas != config.DefaultCanaryReplicas {
+		t.Fatalf("expected default canary policy, got %+v", stacks[0].Canaries)
+	}
+	client.taskStates = map[string]dockerapi.TaskState{"proj_app_web_canary": dockerapi.TaskStateFailed}
+	inventory, err := loadDeployInventory(context.Background(), client)
+	if err != nil {
+		t.Fatalf("loadDeployInventory: %v", err)
+	}
+	result, err := deployStack(context.Background(), client, stacks[0], inventory, false)
+	if err == nil || !strings.Contains(err.Error(), "canary proj_app_web_canary: task proj_app_web_canary.1 failed") {
+		t.Fatalf("expected canary failure, got %v", err)
+	}
+	if len(client.updatedServices) != 0 {
+		t.Fatalf("expected main service to stay on its spec, got %d updates", len(client.updatedServices))
+	}
+	if len(client.removedServices) != 1 || client.removedServices[0] != "service-id-proj_app_web_canary" {
+		t.Fatalf("expected failed canary to be removed, got %v", client.removedServices)
+	}
+	if entry := result.Services[0]; entry.Canary != CanaryAborted || entry.RolledBack {
+		t.Fatalf("unexpected result %+v", entry)
+	}
+}
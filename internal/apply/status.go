@@ -13,56 +13,85 @@ import (
 )
 
 type ServiceState struct {
-	Stack         string
-	Partition     string
-	Service       string
-	Missing       bool
-	MountsMatch   bool
-	IntentMatch   bool
-	IntentDiffs   []string
-	IntentDetails []IntentDetail
-	IntentCurrent *ServiceIntentSnapshot
-	IntentDesired *ServiceIntentSnapshot
-	Unmanaged     []string
-	Desired       int
-	Running       int
-	Health        string
+	Stack         string                 `json:"stack"`
+	Partition     string                 `json:"partition"`
+	Service       string                 `json:"service"`
+	Missing       bool                   `json:"missing"`
+	MountsMatch   bool                   `json:"mounts_match"`
+	IntentMatch   bool                   `json:"intent_match"`
+	IntentDiffs   []string               `json:"intent_diffs,omitempty"`
+	IntentDetails []IntentDetail         `json:"intent_details,omitempty"`
+	IntentCurrent *ServiceIntentSnapshot `json:"intent_current,omitempty"`
+	IntentDesired *ServiceIntentSnapshot `json:"intent_desired,omitempty"`
+	Unmanaged     []string               `json:"unmanaged,omitempty"`
+	Desired       int                    `json:"desired"`
+	Running       int                    `json:"running"`
+	Health        string                 `json:"health"`
+	Canary        *CanaryState           `json:"canary,omitempty"`
+}
+
+// CanaryState describes a canary service that runs next to a service while
+// a canary rollout is in progress, or that an interrupted apply left behind.
+type CanaryState struct {
+	Name    string `json:"name"`
+	Image   string `json:"image"`
+	Desired int    `json:"desired"`
+	Running int    `json:"running"`
+	Health  string `json:"health"`
 }
 
 type ServiceIntentSnapshot struct {
-	Image   string
-	Labels  map[string]string
-	Env     []string
-	Command []string
-	Args    []string
-	Configs []ServiceMount
-	Secrets []ServiceMount
-	Volumes []mount.Mount
+	Image   string            `json:"image"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Env     []string          `json:"env,omitempty"`
+	Command []string          `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Configs []ServiceMount    `json:"configs,omitempty"`
+	Secrets []ServiceMount    `json:"secrets,omitempty"`
+	Volumes []mount.Mount     `json:"volumes,omitempty"`
 }
 
 type IntentDetail struct {
-	Field   string
-	Current string
-	Desired string
+	Field   string `json:"field"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
 }
 
 type DriftItem struct {
-	Name   string
-	Labels map[string]string
-	Reason string
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Reason string            `json:"reason"`
+}
+
+// NetworkDrift is an existing network whose settings differ from config.
+type NetworkDrift struct {
+	Name  string   `json:"name"`
+	Diffs []string `json:"diffs,omitempty"`
+}
+
+// NetworkConflict is a network to be created whose subnet overlaps an
+// existing network.
+type NetworkConflict struct {
+	Name           string `json:"name"`
+	Subnet         string `json:"subnet"`
+	Existing       string `json:"existing"`
+	ExistingSubnet string `json:"existing_subnet"`
 }
 
 type StatusReport struct {
-	MissingConfigs  []swarm.ConfigSpec
-	MissingSecrets  []swarm.SecretSpec
-	MissingNetworks []swarm.NetworkSpec
-	StaleConfigs    []swarm.Config
-	StaleSecrets    []swarm.Secret
-	DriftConfigs    []DriftItem
-	DriftSecrets    []DriftItem
-	Preserved       PruneResult
-	SkippedDeletes  SkippedDeletes
-	Services        []ServiceState
+	MissingConfigs   []swarm.ConfigSpec  `json:"missing_configs"`
+	MissingSecrets   []swarm.SecretSpec  `json:"missing_secrets"`
+	MissingNetworks  []swarm.NetworkSpec `json:"missing_networks"`
+	DriftNetworks    []NetworkDrift      `json:"drift_networks"`
+	NetworkConflicts []NetworkConflict   `json:"network_conflicts"`
+	StaleConfigs     []swarm.Config      `json:"stale_configs"`
+	StaleSecrets     []swarm.Secret      `json:"stale_secrets"`
+	StaleNetworks    []swarm.Network     `json:"stale_networks"`
+	DriftConfigs     []DriftItem         `json:"drift_configs"`
+	DriftSecrets     []DriftItem         `json:"drift_secrets"`
+	Preserved        PruneResult         `json:"preserved"`
+	SkippedDeletes   SkippedDeletes      `json:"skipped_deletes"`
+	Services         []ServiceState      `json:"services"`
 }
 
 func BuildStatus(ctx context.Context, client swarm.Client, cfg *config.Config, desired DesiredState, values any, partitionFilters []string, stackFilters []string, infer bool, preserve int) (StatusReport, error) {
@@ -99,10 +128,10 @@ func BuildStatus(ctx context.Context, client swarm.Client, cfg *config.Config, d
 		existingSecretByName[sec.Name] = sec
 		secretIDs[sec.Name] = sec.ID
 	}
-	networkNames := make(map[string]struct{}, len(existingNetworks))
+	networkByName := make(map[string]swarm.Network, len(existingNetworks))
 	networkTargets := buildNetworkTargetIndex(existingNetworks)
 	for _, net := range existingNetworks {
-		networkNames[net.Name] = struct{}{}
+		networkByName[net.Name] = net
 	}
 
 	inUseConfigIDs, inUseSecretIDs := collectInUseIDs(existingServices, configIDs, secretIDs)
@@ -142,12 +171,14 @@ func BuildStatus(ctx context.Context, client swarm.Client, cfg *config.Config, d
 		}
 	}
 	for _, net := range desired.Networks {
-		if _, ok := networkNames[net.Name]; !ok {
+		if _, ok := networkByName[net.Name]; !ok {
 			report.MissingNetworks = append(report.MissingNetworks, net)
 		}
 	}
+	report.DriftNetworks = NetworkDrifts(desired.Networks, existingNetworks)
+	report.NetworkConflicts = NetworkSubnetConflicts(desired.Networks, existingNetworks)
 	for _, cfg := range existingConfigs {
-		if !isManagedProject(cfg.Labels, projectName) {
+		if !isManagedProject(cfg.Labels, projectName) || isReleaseRecord(cfg.Labels) {
 			continue
 		}
 		if _, ok := inUseConfigIDs[cfg.ID]; ok {
@@ -172,11 +203,16 @@ func BuildStatus(ctx context.Context, client swarm.Client, cfg *config.Config, d
 		}
 		report.StaleSecrets = append(report.StaleSecrets, sec)
 	}
+	report.StaleNetworks, report.SkippedDeletes.Networks, err = staleNetworks(ctx, client, cfg, existingNetworks, existingServices)
+	if err != nil {
+		return StatusReport{}, err
+	}
 	if preserve > 0 {
 		report.StaleConfigs, report.StaleSecrets, report.Preserved = PruneStaleResources(report.StaleConfigs, report.StaleSecrets, preserve)
 	}
 
 	serviceIndex := indexServices(existingServices, cfg.Project.Name)
+	canaryIndex := indexCanaries(existingServices)
 	defIndex := buildDefIndex(desired.Defs)
 	expectedServices, err := expectedServices(cfg, partitionFilters, stackFilters)
 	if err != nil {
@@ -187,6 +223,7 @@ func BuildStatus(ctx context.Context, client swarm.Client, cfg *config.Config, d
 		if err != nil {
 			return StatusReport{}, err
 		}
+		build.pinImage(desired.ImageDigests)
 
 		key := serviceKey{
 			project:   cfg.Project.Name,
@@ -222,6 +259,9 @@ func BuildStatus(ctx context.Context, client swarm.Client, cfg *config.Config, d
 		state.Unmanaged = unmanagedSpecDiffs(current.Spec)
 		state.Desired, state.Running = serviceStatusCounts(current.Status)
 		state.Health = serviceHealth(state.Desired, state.Running)
+		if canary, ok := canaryIndex[current.Name]; ok {
+			state.Canary = canaryState(canary)
+		}
 		report.Services = append(report.Services, state)
 	}
 
@@ -244,7 +284,7 @@ func intentDiffs(current, desired serviceIntent) []string {
 	if !labelsEqual(current.Labels, desired.Labels) {
 		diffs = append(diffs, "labels")
 	}
-	if !stringSlicesEqual(current.Constraints, desired.Constraints) {
+	if !placementEqual(current, desired) {
 		diffs = append(diffs, "placement")
 	}
 	if !stringSlicesEqual(current.Command, desired.Command) {
@@ -259,6 +299,9 @@ func intentDiffs(current, desired serviceIntent) []string {
 	if !portIntentsEqual(current.Ports, desired.Ports) {
 		diffs = append(diffs, "ports")
 	}
+	if endpointMode(current.EndpointMode) != endpointMode(desired.EndpointMode) {
+		diffs = append(diffs, "endpoint_mode")
+	}
 	if !healthcheckEqual(current.Healthcheck, desired.Healthcheck) {
 		diffs = append(diffs, "healthcheck")
 	}
@@ -271,6 +314,12 @@ func intentDiffs(current, desired serviceIntent) []string {
 	if !updateConfigsEqual(current.RollbackConfig, desired.RollbackConfig) {
 		diffs = append(diffs, "rollback_config")
 	}
+	if !resourcesEqual(current.Resources, desired.Resources) {
+		diffs = append(diffs, "resources")
+	}
+	if !logDriversEqual(current.LogDriver, desired.LogDriver) {
+		diffs = append(diffs, "logging")
+	}
 	if !mountSlicesEqual(current.Configs, desired.Configs) {
 		diffs = append(diffs, "configs")
 	}
@@ -283,18 +332,38 @@ func intentDiffs(current, desired serviceIntent) []string {
 	if !stringSlicesEqual(current.Networks, desired.Networks) {
 		diffs = append(diffs, "networks")
 	}
+	diffs = append(diffs, runtimeDiffs(current.Runtime, desired.Runtime)...)
 	return diffs
 }
 
+// unmanagedSpecDiffs names spec fields that were set outside swarmcp and that
+// updates carry over unchanged because no project key controls them.
 func unmanagedSpecDiffs(spec dockerapi.ServiceSpec) []string {
+	containerSpec := spec.TaskTemplate.ContainerSpec
+	if containerSpec == nil {
+		return nil
+	}
 	var diffs []string
-	if spec.TaskTemplate.Resources != nil {
-		if spec.TaskTemplate.Resources.Limits != nil || spec.TaskTemplate.Resources.Reservations != nil {
-			diffs = append(diffs, "resources")
+	for key := range containerSpec.Labels {
+		if key != stackNamespaceLabel {
+			diffs = append(diffs, "container_labels")
+			break
 		}
 	}
-	if spec.TaskTemplate.Placement != nil && len(spec.TaskTemplate.Placement.Preferences) > 0 {
-		diffs = append(diffs, "placement_prefs")
+	if len(containerSpec.Groups) > 0 {
+		diffs = append(diffs, "groups")
+	}
+	if containerSpec.Privileges != nil {
+		diffs = append(diffs, "privileges")
+	}
+	if containerSpec.TTY || containerSpec.OpenStdin {
+		diffs = append(diffs, "tty")
+	}
+	if !containerSpec.Isolation.IsDefault() {
+		diffs = append(diffs, "isolation")
+	}
+	if containerSpec.OomScoreAdj != 0 {
+		diffs = append(diffs, "oom_score_adj")
 	}
 	return diffs
 }
@@ -325,8 +394,8 @@ func intentDetails(current, desired serviceIntent, diffs []string) []IntentDetai
 		case "placement":
 			details = append(details, IntentDetail{
 				Field:   diff,
-				Current: formatStringSlice(current.Constraints),
-				Desired: formatStringSlice(desired.Constraints),
+				Current: formatPlacement(current),
+				Desired: formatPlacement(desired),
 			})
 		case "command":
 			details = append(details, IntentDetail{
@@ -376,6 +445,24 @@ func intentDetails(current, desired serviceIntent, diffs []string) []IntentDetai
 				Current: formatUpdateConfig(current.RollbackConfig),
 				Desired: formatUpdateConfig(desired.RollbackConfig),
 			})
+		case "resources":
+			details = append(details, IntentDetail{
+				Field:   diff,
+				Current: formatResources(current.Resources),
+				Desired: formatResources(desired.Resources),
+			})
+		case "endpoint_mode":
+			details = append(details, IntentDetail{
+				Field:   diff,
+				Current: endpointMode(current.EndpointMode),
+				Desired: endpointMode(desired.EndpointMode),
+			})
+		case "logging":
+			details = append(details, IntentDetail{
+				Field:   diff,
+				Current: formatLogDriver(current.LogDriver),
+				Desired: formatLogDriver(desired.LogDriver),
+			})
 		case "configs":
 			details = append(details, IntentDetail{
 				Field:   diff,
@@ -400,6 +487,10 @@ func intentDetails(current, desired serviceIntent, diffs []string) []IntentDetai
 				Current: formatStringSliceSorted(current.Networks),
 				Desired: formatStringSliceSorted(desired.Networks),
 			})
+		default:
+			if detail, ok := runtimeDetail(diff, current.Runtime, desired.Runtime); ok {
+				details = append(details, detail)
+			}
 		}
 	}
 	if len(details) == 0 {
@@ -492,11 +583,25 @@ func formatVolumeMounts(mounts []mount.Mount) string {
 		if mount.ReadOnly {
 			entry += ":ro"
 		}
+		if options := normalizeVolumeOptions(mount.VolumeOptions); options != nil {
+			entry += formatVolumeOptions(options)
+		}
 		parts = append(parts, entry)
 	}
 	return "[" + strings.Join(parts, ", ") + "]"
 }
 
+func formatVolumeOptions(options *mount.VolumeOptions) string {
+	var parts []string
+	if options.DriverConfig != nil {
+		parts = append(parts, "driver="+formatDriver(options.DriverConfig.Name, options.DriverConfig.Options))
+	}
+	if options.Subpath != "" {
+		parts = append(parts, "subpath="+options.Subpath)
+	}
+	return " (" + strings.Join(parts, " ") + ")"
+}
+
 func formatPorts(ports []portIntent) string {
 	if len(ports) == 0 {
 		return "[]"
@@ -552,6 +657,27 @@ func expectedServices(cfg *config.Config, partitionFilters []string, stackFilter
 	return services, nil
 }
 
+// indexCanaries maps service names to their canary service.
+func indexCanaries(services []swarm.Service) map[string]swarm.Service {
+	out := make(map[string]swarm.Service)
+	for _, svc := range services {
+		if owner := svc.Labels[canaryOfLabel]; owner != "" {
+			out[owner] = svc
+		}
+	}
+	return out
+}
+
+func canaryState(svc swarm.Service) *CanaryState {
+	state := &CanaryState{Name: svc.Name}
+	if spec := svc.Spec.TaskTemplate.ContainerSpec; spec != nil {
+		state.Image = spec.Image
+	}
+	state.Desired, state.Running = serviceStatusCounts(svc.Status)
+	state.Health = serviceHealth(state.Desired, state.Running)
+	return state
+}
+
 func serviceStatusCounts(status *dockerapi.ServiceStatus) (int, int) {
 	if status == nil {
 		return -1, -1
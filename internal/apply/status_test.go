@@ -0,0 +1,27 @@
+package apply
+
+import (
+	"slices"
+	"testing"
+
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func TestUnmanagedSpecDiffsReportsFieldsSwarmcpCarriesOver(t *testing.T) {
+	spec := dockerapi.ServiceSpec{TaskTemplate: dockerapi.TaskSpec{
+		ContainerSpec: &dockerapi.ContainerSpec{Image: "nginx:1", Isolation: "default", Labels: map[string]string{stackNamespaceLabel: "proj_app"}},
+		Placement:     &dockerapi.Placement{Preferences: []dockerapi.PlacementPreference{{Spread: &dockerapi.SpreadOver{SpreadDescriptor: "node.labels.zone"}}}},
+	}}
+	if diffs := unmanagedSpecDiffs(spec); len(diffs) != 0 {
+		t.Fatalf("expected managed fields to be ignored, got %v", diffs)
+	}
+
+	spec.TaskTemplate.ContainerSpec.Labels["team"] = "web"
+	spec.TaskTemplate.ContainerSpec.Privileges = &dockerapi.Privileges{NoNewPrivileges: true}
+	spec.TaskTemplate.ContainerSpec.TTY = true
+	spec.TaskTemplate.ContainerSpec.OomScoreAdj = 500
+	want := []string{"container_labels", "privileges", "tty", "oom_score_adj"}
+	if diffs := unmanagedSpecDiffs(spec); !slices.Equal(diffs, want) {
+		t.Fatalf("expected %v, got %v", want, diffs)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/cmmoran/swarmcp/internal/render"
 	"github.com/cmmoran/swarmcp/internal/templates"
 	"github.com/docker/docker/api/types/mount"
+	"go.yaml.in/yaml/v4"
 )
 
 func TestDesiredVolumeMountsServiceScoped(t *testing.T) {
@@ -68,6 +69,7 @@ func TestDesiredVolumeMountsStackScoped(t *testing.T) {
 			"db": {Target: "/var/lib/db"},
 		},
 	}
+	cfg.Stacks = map[string]config.Stack{"core": stack}
 	service := config.Service{
 		Volumes: []config.VolumeRef{
 			{Name: "db", Target: "/db"},
@@ -168,3 +170,75 @@ func TestDesiredVolumeMountsServiceStandardOverrides(t *testing.T) {
 		t.Fatalf("unexpected target: %#v", mounts[0].Target)
 	}
 }
+
+func TestBuildStackDeploysDriverVolume(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:       "primary",
+			Deployment: "dev",
+			Defaults: config.ProjectDefaults{
+				Volumes: config.VolumeDefaults{NodeLabelKey: "swarmcp.volume"},
+			},
+		},
+		Stacks: map[string]config.Stack{
+			"data": {
+				Mode: "shared",
+				Volumes: map[string]config.VolumeDef{
+					"pg": {
+						Target:     "/var/lib/postgresql/data",
+						Driver:     "local",
+						DriverOpts: map[string]string{"type": "nfs", "o": "addr=10.0.0.5", "device": ":/exports/{stack}"},
+					},
+				},
+				Overlays: config.StackOverlays{
+					Deployments: map[string]config.OverlayStack{
+						"dev": {Volumes: map[string]config.VolumeDef{"pg": {DriverOpts: map[string]string{"o": "addr=10.1.0.5"}}}},
+					},
+				},
+				Services: map[string]config.Service{
+					"db": {
+						Image:   "postgres:16",
+						Volumes: []config.VolumeRef{{Name: "pg", Subpath: "main"}},
+					},
+				},
+			},
+		},
+	}
+	deploys, err := BuildStackDeploys(cfg, DesiredState{}, nil, nil, nil, nil, nil, nil, false)
+	if err != nil {
+		t.Fatalf("BuildStackDeploys: %v", err)
+	}
+	var compose composeFile
+	if err := yaml.Unmarshal(deploys[0].Compose, &compose); err != nil {
+		t.Fatalf("parse compose: %v", err)
+	}
+	volume, ok := compose.Volumes["primary_data_pg"]
+	if !ok || volume.Name != "primary_data_pg" || volume.Driver != "local" || volume.DriverOpts["o"] != "addr=10.1.0.5" || volume.DriverOpts["device"] != ":/exports/data" {
+		t.Fatalf("unexpected compose volumes: %#v", compose.Volumes)
+	}
+	intent := deploys[0].Services["db"]
+	if len(intent.Constraints) != 0 {
+		t.Fatalf("expected no volume label constraints, got %v", intent.Constraints)
+	}
+	want := mount.Mount{
+		Type:   mount.TypeVolume,
+		Source: "primary_data_pg",
+		Target: "/var/lib/postgresql/data",
+		VolumeOptions: &mount.VolumeOptions{
+			Subpath:      "main",
+			DriverConfig: &mount.Driver{Name: "local", Options: volume.DriverOpts},
+		},
+	}
+	if !volumeMountsEqual(intent.Volumes, []mount.Mount{want}) {
+		t.Fatalf("unexpected volume mounts: %s", formatVolumeMounts(intent.Volumes))
+	}
+
+	changed := want
+	changed.VolumeOptions = &mount.VolumeOptions{
+		Subpath:      "main",
+		DriverConfig: &mount.Driver{Name: "local", Options: map[string]string{"type": "nfs"}},
+	}
+	if volumeMountsEqual(intent.Volumes, []mount.Mount{changed}) {
+		t.Fatalf("expected driver option change to be detected")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/cmmoran/swarmcp/internal/config"
 	"github.com/cmmoran/swarmcp/internal/sliceutil"
 	"github.com/cmmoran/swarmcp/internal/swarm"
 	"github.com/cmmoran/swarmcp/internal/templates"
@@ -13,6 +14,7 @@ import (
 
 type swarmNetworkResolver struct {
 	contextName string
+	factory     func(string) (swarm.Client, error)
 	client      swarm.Client
 	clientErr   error
 	loaded      bool
@@ -20,8 +22,28 @@ type swarmNetworkResolver struct {
 	all         []string
 }
 
-func ConfigureTemplateNetworkResolver(contextName string) {
-	templates.SetNetworkCIDRResolver(&swarmNetworkResolver{contextName: contextName})
+// ConfigureTemplateNetworkResolver resolves template network lookups against
+// the cluster of contextName and lets the address pool keep the subnets of
+// networks that already exist there. The cluster is only contacted on use.
+func ConfigureTemplateNetworkResolver(cfg *config.Config, contextName string, factory func(string) (swarm.Client, error)) {
+	if factory == nil {
+		factory = swarm.NewClient
+	}
+	resolver := &swarmNetworkResolver{contextName: contextName, factory: factory}
+	templates.SetNetworkCIDRResolver(resolver)
+	cfg.ExistingNetworkSubnets = resolver.ExistingNetworkSubnets
+}
+
+// ExistingNetworkSubnets returns the subnets of the cluster's overlay
+// networks by name, or nil when the cluster cannot be reached.
+func (r *swarmNetworkResolver) ExistingNetworkSubnets() map[string][]string {
+	if err := r.ensureClient(); err != nil {
+		return nil
+	}
+	if err := r.loadNetworks(); err != nil {
+		return nil
+	}
+	return r.byName
 }
 
 func (r *swarmNetworkResolver) NetworkCIDRs(name string) ([]string, error) {
@@ -48,7 +70,7 @@ func (r *swarmNetworkResolver) ensureClient() error {
 	if r.client != nil || r.clientErr != nil {
 		return r.clientErr
 	}
-	client, err := swarm.NewClient(r.contextName)
+	client, err := r.factory(r.contextName)
 	if err != nil {
 		r.clientErr = err
 		return err
@@ -0,0 +1,149 @@
+package cmdutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+)
+
+// PlacementWarnings reports services whose placement platforms or
+// max_replicas_per_node cannot be satisfied by the declared deployment nodes.
+// Services whose constraints cannot be evaluated offline are skipped.
+func PlacementWarnings(cfg *config.Config, partitionFilters []string, stackFilters []string) []string {
+	nodes := ResolveDeploymentNodeSpecs(cfg)
+	if len(nodes) == 0 {
+		return nil
+	}
+	var warnings []string
+	for _, stackName := range sortedStackNames(cfg) {
+		stack := cfg.Stacks[stackName]
+		if len(stackFilters) > 0 && !StackInFilters(stackFilters, stackName) {
+			continue
+		}
+		if !cfg.StackSelectedForRuntime(stackName, partitionFilters) {
+			continue
+		}
+		partitions := []string{""}
+		if stack.Mode == "partitioned" && len(cfg.Project.Partitions) > 0 {
+			partitions = cfg.StackRuntimePartitions(stackName, partitionFilters)
+		}
+		for _, partition := range partitions {
+			services, err := cfg.StackServices(stackName, partition)
+			if err != nil {
+				return warnings
+			}
+			names := make([]string, 0, len(services))
+			for name := range services {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, serviceName := range names {
+				service := services[serviceName]
+				label := ServiceScopeLabel(stackName, partition, serviceName)
+				warnings = append(warnings, servicePlacementWarnings(label, service, nodes)...)
+			}
+		}
+	}
+	return warnings
+}
+
+func servicePlacementWarnings(label string, service config.Service, nodes map[string]config.NodeSpec) []string {
+	placement := service.Placement
+	maxPerNode := 0
+	if placement.MaxReplicasPerNode != nil {
+		maxPerNode = *placement.MaxReplicasPerNode
+	}
+	if len(placement.Platforms) == 0 && maxPerNode <= 0 {
+		return nil
+	}
+	eligible, unknown := NodesForConstraints(nodes, placement.Constraints)
+	if unknown {
+		return nil
+	}
+	var warnings []string
+	if len(placement.Platforms) > 0 {
+		var matched []string
+		for _, name := range eligible {
+			if nodeMatchesPlatforms(nodes[name], placement.Platforms) {
+				matched = append(matched, name)
+			}
+		}
+		if len(matched) == 0 {
+			warnings = append(warnings, fmt.Sprintf("placement check: no deployment target node matches platforms for %s (required: %s)", label, formatPlatforms(placement.Platforms)))
+			return warnings
+		}
+		eligible = matched
+	}
+	mode := strings.TrimSpace(service.Mode)
+	if maxPerNode > 0 && (mode == "" || mode == "replicated") && service.Replicas > maxPerNode*len(eligible) {
+		warnings = append(warnings, fmt.Sprintf("placement check: %s requests %d replicas but max_replicas_per_node=%d allows %d on %d eligible node(s)", label, service.Replicas, maxPerNode, maxPerNode*len(eligible), len(eligible)))
+	}
+	return warnings
+}
+
+// NodesLackingImagePlatform returns the deployment nodes a service with the
+// given constraints can land on whose declared platform is not among the
+// image platforms ("os/arch" or "os/arch/variant"). Nodes without a declared
+// platform are skipped, as are constraints that cannot be evaluated offline.
+func NodesLackingImagePlatform(nodes map[string]config.NodeSpec, constraints []string, platforms []string) []string {
+	if len(platforms) == 0 {
+		return nil
+	}
+	eligible, unknown := NodesForConstraints(nodes, constraints)
+	if unknown {
+		return nil
+	}
+	available := make([]config.NodePlatform, 0, len(platforms))
+	for _, platform := range platforms {
+		parts := strings.SplitN(platform, "/", 3)
+		entry := config.NodePlatform{OS: parts[0]}
+		if len(parts) > 1 {
+			entry.Arch = parts[1]
+		}
+		available = append(available, entry)
+	}
+	var missing []string
+	for _, name := range eligible {
+		if !nodeMatchesPlatforms(nodes[name], available) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// nodeMatchesPlatforms treats nodes without a declared platform as matching,
+// since their platform cannot be checked offline.
+func nodeMatchesPlatforms(node config.NodeSpec, platforms []config.NodePlatform) bool {
+	if node.Platform.OS == "" {
+		return true
+	}
+	for _, platform := range platforms {
+		if config.PlatformMatches(platform, node.Platform) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPlatforms(platforms []config.NodePlatform) string {
+	out := make([]string, 0, len(platforms))
+	for _, platform := range platforms {
+		value := platform.OS
+		if platform.Arch != "" {
+			value += "/" + platform.Arch
+		}
+		out = append(out, value)
+	}
+	return strings.Join(out, ", ")
+}
+
+func sortedStackNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(cfg.Stacks))
+	for name := range cfg.Stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,65 @@
+package cmdutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+)
+
+func TestServicePlacementWarnings(t *testing.T) {
+	nodes := map[string]config.NodeSpec{
+		"a": {Labels: map[string]string{"zone": "a"}, Platform: config.NodePlatform{OS: "linux", Arch: "amd64"}},
+		"b": {Labels: map[string]string{"zone": "b"}, Platform: config.NodePlatform{OS: "linux", Arch: "arm64"}},
+	}
+
+	warnings := servicePlacementWarnings("core/api", config.Service{
+		Placement: config.Placement{
+			Constraints: []string{"node.labels.zone==a"},
+			Platforms:   []config.NodePlatform{{OS: "linux", Arch: "arm64"}},
+		},
+	}, nodes)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "no deployment target node matches platforms for core/api (required: linux/arm64)") {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+
+	warnings = servicePlacementWarnings("core/api", config.Service{
+		Replicas: 3,
+		Placement: config.Placement{
+			MaxReplicasPerNode: new(1),
+			Platforms:          []config.NodePlatform{{OS: "linux"}},
+		},
+	}, nodes)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "requests 3 replicas but max_replicas_per_node=1 allows 2 on 2 eligible node(s)") {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+
+	warnings = servicePlacementWarnings("core/api", config.Service{
+		Replicas:  2,
+		Placement: config.Placement{MaxReplicasPerNode: new(1)},
+	}, nodes)
+	if len(warnings) != 0 {
+		t.Fatalf("expected no warnings, got %v", warnings)
+	}
+}
+
+func TestNodesLackingImagePlatform(t *testing.T) {
+	nodes := map[string]config.NodeSpec{
+		"a": {Labels: map[string]string{"zone": "a"}, Platform: config.NodePlatform{OS: "linux", Arch: "amd64"}},
+		"b": {Labels: map[string]string{"zone": "b"}, Platform: config.NodePlatform{OS: "linux", Arch: "arm64"}},
+		"c": {Labels: map[string]string{"zone": "b"}},
+	}
+
+	if missing := NodesLackingImagePlatform(nodes, nil, []string{"linux/amd64"}); len(missing) != 1 || missing[0] != "b" {
+		t.Fatalf("expected arm64 node to lack the image platform, got %v", missing)
+	}
+	if missing := NodesLackingImagePlatform(nodes, []string{"node.labels.zone==a"}, []string{"linux/amd64"}); len(missing) != 0 {
+		t.Fatalf("expected constrained service to fit, got %v", missing)
+	}
+	if missing := NodesLackingImagePlatform(nodes, nil, []string{"linux/amd64", "linux/arm64/v8"}); len(missing) != 0 {
+		t.Fatalf("expected multi-platform image to fit, got %v", missing)
+	}
+	if missing := NodesLackingImagePlatform(nodes, nil, nil); len(missing) != 0 {
+		t.Fatalf("expected unknown image platforms to be skipped, got %v", missing)
+	}
+}
@@ -26,15 +26,16 @@ type ProjectOptions struct {
 }
 
 type ProjectContext struct {
-	Config        *config.Config
-	Partition     string
-	Stack         string
-	Values        any
-	ValuesSources []string
-	Secrets       *secrets.Store
-	ContextName   string
-	ValuesScope   templates.Scope
-	clientFactory func(string) (swarm.Client, error)
+	Config              *config.Config
+	Partition           string
+	Stack               string
+	Values              any
+	ValuesSources       []string
+	Secrets             *secrets.Store
+	ContextName         string
+	ValuesScope         templates.Scope
+	RegistryCredentials swarm.RegistryCredentialSource
+	clientFactory       func(string) (swarm.Client, error)
 }
 
 type ProjectScope struct {
@@ -92,7 +93,7 @@ func ResolveProjectScope(cfg *config.Config, opts ProjectOptions) (*ProjectScope
 	}
 
 	contextName := ResolveContext(cfg, opts.Context)
-	ConfigureTemplateNetworkResolver(contextName)
+	ConfigureTemplateNetworkResolver(cfg, contextName, opts.ClientFactory)
 	return &ProjectScope{
 		Partition:   partition,
 		Stack:       stack,
@@ -170,5 +171,8 @@ func (p *ProjectContext) SwarmClient() (swarm.Client, error) {
 		}
 		return nil, err
 	}
+	if p.RegistryCredentials != nil {
+		swarm.UseRegistryCredentials(client, p.RegistryCredentials)
+	}
 	return client, nil
 }
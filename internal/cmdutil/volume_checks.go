@@ -151,6 +151,7 @@ func collectVolumeRequirements(cfg *config.Config, partitionFilters []string, st
 				if len(service.Volumes) == 0 {
 					continue
 				}
+				volumeDefs := cfg.StackVolumeDefs(stackName, partition)
 				var names []string
 				var roles []string
 				for _, ref := range service.Volumes {
@@ -167,6 +168,9 @@ func collectVolumeRequirements(cfg *config.Config, partitionFilters []string, st
 						}
 						continue
 					}
+					if volumeDefs[ref.Name].UsesDriver() {
+						continue
+					}
 					names = append(names, ref.Name)
 				}
 				requirements = append(requirements, volumeRequirement{
@@ -203,8 +207,12 @@ func hasVolumeRequirements(cfg *config.Config, partitionFilters []string, stackF
 			if len(services) == 0 {
 				continue
 			}
+			volumeDefs := cfg.StackVolumeDefs(stackName, partition)
 			for _, service := range services {
 				for _, ref := range service.Volumes {
+					if ref.Name != "" && volumeDefs[ref.Name].UsesDriver() {
+						continue
+					}
 					if ref.Name != "" || ref.Standard != "" {
 						if ref.Standard != "" && ref.Standard != serviceStandard {
 							return true
@@ -25,6 +25,13 @@ func debugResolvedMapWithTrace(cfg *Config, partition string, stackFilters []str
 	projectMap["configs"] = cfg.projectConfigDefsWithTrace(partition, trace)
 	projectMap["secrets"] = cfg.projectSecretDefsWithTrace(partition, trace)
 	projectMap["secrets_engine"] = cfg.projectSecretsEngineWithTrace(partition, trace)
+	subnets, err := NetworkSubnets(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(subnets) > 0 {
+		projectMap["network_subnets"] = subnets
+	}
 	if partition != "" {
 		projectMap["partitions"] = []string{partition}
 	} else if len(allowedPartitions) > 0 {
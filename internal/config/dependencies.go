@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SplitServiceDependency splits a depends_on entry into its stack and service
+// parts. Entries without a "<stack>/" prefix refer to the declaring stack.
+func SplitServiceDependency(dep string) (string, string, bool) {
+	stack, service, ok := strings.Cut(dep, "/")
+	if !ok {
+		return "", dep, false
+	}
+	return stack, service, true
+}
+
+func validateCrossStackDependencies(cfg *Config, stackName string, stack Stack, serviceName string, service Service) []string {
+	var errs []string
+	scope := fmt.Sprintf("stack %s.services.%s.depends_on", stackName, serviceName)
+	for _, dep := range service.DependsOn {
+		targetStackName, targetService, cross := SplitServiceDependency(dep)
+		if !cross {
+			continue
+		}
+		if targetStackName == "" || targetService == "" {
+			errs = append(errs, fmt.Sprintf("%s: %q must be <stack>/<service>", scope, dep))
+			continue
+		}
+		targetStack, ok := cfg.Stacks[targetStackName]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: stack %q not found", scope, targetStackName))
+			continue
+		}
+		if _, ok := targetStack.Services[targetService]; !ok {
+			errs = append(errs, fmt.Sprintf("%s: service %q not found in stack %q", scope, targetService, targetStackName))
+			continue
+		}
+		if stack.Mode != "partitioned" && targetStack.Mode == "partitioned" {
+			errs = append(errs, fmt.Sprintf("%s: shared stack services cannot depend on partitioned stack %q", scope, targetStackName))
+		}
+	}
+	return errs
+}
+
+func validateDependencyCycles(cfg *Config) []string {
+	graph := make(map[string][]string)
+	for stackName, stack := range cfg.Stacks {
+		for serviceName, service := range stack.Services {
+			node := stackName + "/" + serviceName
+			for _, dep := range service.DependsOn {
+				targetStack, targetService, cross := SplitServiceDependency(dep)
+				if !cross {
+					targetStack = stackName
+				}
+				graph[node] = append(graph[node], targetStack+"/"+targetService)
+			}
+		}
+	}
+	nodes := make([]string, 0, len(graph))
+	for node := range graph {
+		nodes = append(nodes, node)
+		sort.Strings(graph[node])
+	}
+	sort.Strings(nodes)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(graph))
+	var errs []string
+	var path []string
+	var visit func(node string)
+	visit = func(node string) {
+		switch state[node] {
+		case visited:
+			return
+		case visiting:
+			start := 0
+			for i, item := range path {
+				if item == node {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string(nil), path[start:]...), node)
+			errs = append(errs, fmt.Sprintf("depends_on: dependency cycle %s", strings.Join(cycle, " -> ")))
+			return
+		}
+		state[node] = visiting
+		path = append(path, node)
+		for _, dep := range graph[node] {
+			visit(dep)
+		}
+		path = path[:len(path)-1]
+		state[node] = visited
+	}
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	EndpointModeVIP   = "vip"
+	EndpointModeDNSRR = "dnsrr"
+)
+
+// PortUsesIngress reports whether a port is published through the routing
+// mesh, which is the default publish mode.
+func PortUsesIngress(port Port) bool {
+	return port.Mode == "" || port.Mode == "ingress"
+}
+
+func validateEndpointMode(scope string, service Service) []string {
+	mode := strings.TrimSpace(service.EndpointMode)
+	switch mode {
+	case "", EndpointModeVIP:
+		return nil
+	case EndpointModeDNSRR:
+	default:
+		return []string{fmt.Sprintf("%s.endpoint_mode: invalid value %q (expected vip or dnsrr)", scope, service.EndpointMode)}
+	}
+	var errs []string
+	for i, port := range service.Ports {
+		if PortUsesIngress(port) {
+			errs = append(errs, fmt.Sprintf("%s.ports[%d]: endpoint_mode dnsrr requires mode host (ingress ports need a virtual IP)", scope, i))
+		}
+	}
+	return errs
+}
+
+func validateProjectPolicy(cfg *Config) []string {
+	var errs []string
+	for _, name := range cfg.Project.Policy.ForbidIngressPorts {
+		if len(cfg.Project.Deployments) > 0 && !deploymentInProject(cfg, name) {
+			errs = append(errs, fmt.Sprintf("project.policy.forbid_ingress_ports: deployment %q not found in project.deployments", name))
+		}
+	}
+	for _, name := range cfg.Project.Policy.RequireImageDigests {
+		if len(cfg.Project.Deployments) > 0 && !deploymentInProject(cfg, name) {
+			errs = append(errs, fmt.Sprintf("project.policy.require_image_digests: deployment %q not found in project.deployments", name))
+		}
+	}
+	return errs
+}
+
+// RequiresImageDigests reports whether project.policy.require_image_digests
+// lists the selected deployment.
+func (cfg *Config) RequiresImageDigests() bool {
+	return cfg.Project.Deployment != "" && slices.Contains(cfg.Project.Policy.RequireImageDigests, cfg.Project.Deployment)
+}
+
+// validateDeploymentPolicy checks the effective services of the selected
+// deployment, after overlays, against endpoint rules and project policy.
+func validateDeploymentPolicy(cfg *Config) []string {
+	deployment := cfg.Project.Deployment
+	if deployment == "" {
+		return nil
+	}
+	forbidIngress := false
+	for _, name := range cfg.Project.Policy.ForbidIngressPorts {
+		if name == deployment {
+			forbidIngress = true
+			break
+		}
+	}
+	var errs []string
+	for _, stackName := range slices.Sorted(maps.Keys(cfg.Stacks)) {
+		stack := cfg.Stacks[stackName]
+		partitions := []string{""}
+		if stack.Mode == "partitioned" && len(cfg.Project.Partitions) > 0 {
+			partitions = cfg.StackRuntimePartitions(stackName, nil)
+		}
+		for _, partition := range partitions {
+			services, err := cfg.StackServices(stackName, partition)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("stack %s: %v", stackName, err))
+				continue
+			}
+			for _, serviceName := range slices.Sorted(maps.Keys(services)) {
+				service := services[serviceName]
+				scope := fmt.Sprintf("stack %s.services.%s", stackName, serviceName)
+				if partition != "" {
+					scope = fmt.Sprintf("stack %s (partition %s).services.%s", stackName, partition, serviceName)
+				}
+				errs = append(errs, validateEndpointMode(scope, service)...)
+				if !forbidIngress {
+					continue
+				}
+				for i, port := range service.Ports {
+					if PortUsesIngress(port) && port.Published > 0 {
+						errs = append(errs, fmt.Sprintf("%s.ports[%d]: ingress published port %d is forbidden in deployment %q by project.policy.forbid_ingress_ports", scope, i, port.Published, deployment))
+					}
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateEndpointModeRejectsIngressWithDNSRR(t *testing.T) {
+	service := Service{
+		EndpointMode: "dnsrr",
+		Ports: []Port{
+			{Target: 80, Published: 8080},
+			{Target: 443, Published: 8443, Mode: "host"},
+		},
+	}
+	errs := validateEndpointMode("stacks.core.services.web", service)
+	if len(errs) != 1 || !strings.Contains(errs[0], "ports[0]") {
+		t.Fatalf("expected a single ports[0] error, got %v", errs)
+	}
+
+	service.EndpointMode = "round-robin"
+	errs = validateEndpointMode("stacks.core.services.web", service)
+	if len(errs) != 1 || !strings.Contains(errs[0], "endpoint_mode: invalid value") {
+		t.Fatalf("expected invalid endpoint_mode error, got %v", errs)
+	}
+}
+
+func TestValidateDeploymentForbidsIngressPorts(t *testing.T) {
+	cfg := &Config{
+		Project: Project{
+			Name:        "primary",
+			Deployments: []string{"dev", "prod"},
+			Deployment:  "prod",
+			Policy: ProjectPolicy{
+				ForbidIngressPorts: []string{"prod"},
+			},
+		},
+		Stacks: map[string]Stack{
+			"core": {
+				Mode: "shared",
+				Services: map[string]Service{
+					"web": {
+						Image: "nginx",
+						Ports: []Port{
+							{Target: 80, Published: 8080},
+							{Target: 443, Published: 8443, Mode: "host"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := ValidateDeployment(cfg)
+	if err == nil {
+		t.Fatalf("expected ingress port to be forbidden in prod")
+	}
+	if !strings.Contains(err.Error(), "ports[0]") || strings.Contains(err.Error(), "ports[1]") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.Project.Deployment = "dev"
+	if err := ValidateDeployment(cfg); err != nil {
+		t.Fatalf("expected dev deployment to allow ingress ports, got %v", err)
+	}
+}
+
+func TestValidateProjectPolicyUnknownDeployment(t *testing.T) {
+	cfg := &Config{
+		Project: Project{
+			Name:        "primary",
+			Deployments: []string{"dev"},
+			Policy: ProjectPolicy{
+				ForbidIngressPorts:  []string{"prod"},
+				RequireImageDigests: []string{"stage"},
+			},
+		},
+	}
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "project.policy.forbid_ingress_ports") || !strings.Contains(err.Error(), "project.policy.require_image_digests") {
+		t.Fatalf("expected policy deployment errors, got %v", err)
+	}
+}
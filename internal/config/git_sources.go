@@ -42,6 +42,39 @@ const gitSourcePrefix = "git:"
 var errExactSHA1NotSupported = errors.New("git server does not allow exact object fetch")
 var resolvedRefCache sync.Map
 
+// refreshedHeads holds the URLs whose HEAD must be re-read from the remote
+// rather than from the local clone; see ForgetResolvedRefs.
+var refreshedHeads sync.Map
+
+// loadedSourceRefs holds the commit each url|ref read by this process resolved
+// to; see ResolvedSourceRef.
+var loadedSourceRefs sync.Map
+
+// ForgetResolvedRefs drops the branch, tag and HEAD resolutions cached for the
+// lifetime of the process, so the next load re-reads them from the remotes.
+// Long-running callers use it to pick up new commits between cycles.
+func ForgetResolvedRefs() {
+	resolvedRefCache.Range(func(key, _ any) bool {
+		if rawURL, ok := strings.CutSuffix(key.(string), "|HEAD"); ok {
+			refreshedHeads.Store(rawURL, struct{}{})
+		}
+		resolvedRefCache.Delete(key)
+		return true
+	})
+	loadedSourceRefs.Clear()
+}
+
+// ResolvedSourceRef returns the commit that ref of the git source at url
+// resolved to when this process last read the source, without contacting the
+// remote. It reports false for sources not read since ForgetResolvedRefs.
+func ResolvedSourceRef(url, ref string) (string, bool) {
+	commit, ok := loadedSourceRefs.Load(url + "|" + ref)
+	if !ok {
+		return "", false
+	}
+	return commit.(string), true
+}
+
 // SourceMetadata records the git subtree fingerprint for a path.
 type SourceMetadata struct {
 	URL       string `json:"url"`
@@ -406,6 +439,7 @@ func readGitPath(ctx context.Context, rawURL, ref, p string, opts LoadOptions) (
 	if err != nil {
 		return gitPathResult{}, err
 	}
+	loadedSourceRefs.Store(rawURL+"|"+ref, commitHash.String())
 	debugf(opts, "git: commit=%s", commitHash.String())
 	commit, err := loadCommit(ctx, repo, remoteURL, auth, commitHash, opts)
 	if err != nil {
@@ -473,20 +507,33 @@ func readGitPath(ctx context.Context, rawURL, ref, p string, opts LoadOptions) (
 func resolveRefHash(ctx context.Context, repo *git.Repository, rawURL, ref string, auth transport.AuthMethod, opts LoadOptions) (plumbing.Hash, error) {
 	ref = strings.TrimSpace(ref)
 	if ref == "" || strings.EqualFold(ref, "HEAD") {
-		if hash, ok := resolveLocalHEAD(repo); ok {
-			return hash, nil
+		headKey := rawURL + "|HEAD"
+		if cached, ok := resolvedRefCache.Load(headKey); ok && !opts.Offline {
+			if hash, ok := cached.(plumbing.Hash); ok && hash != plumbing.ZeroHash {
+				return hash, nil
+			}
+		}
+		if _, refreshed := refreshedHeads.Load(rawURL); !refreshed || opts.Offline {
+			if hash, ok := resolveLocalHEAD(repo); ok {
+				resolvedRefCache.Store(headKey, hash)
+				return hash, nil
+			}
 		}
 		if opts.Offline {
 			return plumbing.ZeroHash, fmt.Errorf("ref HEAD not found in local repo and offline is enabled")
 		}
 		adv, err := advertisedRefs(ctx, rawURL, auth, opts)
 		if err != nil {
+			if hash, ok := resolveLocalHEAD(repo); ok {
+				return hash, nil
+			}
 			return plumbing.ZeroHash, err
 		}
 		if adv.Head == nil {
 			return plumbing.ZeroHash, fmt.Errorf("ref HEAD not advertised by remote")
 		}
 		rememberRef(repo, "HEAD", *adv.Head, opts)
+		resolvedRefCache.Store(headKey, *adv.Head)
 		return *adv.Head, nil
 	}
 	if plumbing.IsHash(ref) {
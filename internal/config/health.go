@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultHealthTimeout bounds how long apply waits for a deployed service to
+// report all desired replicas running and healthy.
+const DefaultHealthTimeout = 2 * time.Minute
+
+func ParseHealthTimeout(raw string) (time.Duration, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return DefaultHealthTimeout, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("health_timeout: invalid duration %q", raw)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("health_timeout: must be > 0")
+	}
+	return duration, nil
+}
+
+// ResolveHealthTimeout returns the most specific non-empty health_timeout.
+func ResolveHealthTimeout(values ...string) string {
+	out := ""
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = value
+		}
+	}
+	return out
+}
+
+func StackPartitionHealthTimeout(stack Stack, partition string) string {
+	if partition == "" || len(stack.Partitions) == 0 {
+		return ""
+	}
+	if part, ok := stack.Partitions[partition]; ok {
+		return part.HealthTimeout
+	}
+	return ""
+}
+
+func validateHealthTimeout(scope string, raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return []string{fmt.Sprintf("%s.health_timeout: invalid duration %q", scope, raw)}
+	}
+	if duration <= 0 {
+		return []string{fmt.Sprintf("%s.health_timeout: must be > 0", scope)}
+	}
+	return nil
+}
@@ -346,6 +346,9 @@ func hasStackLocalFields(stack Stack) bool {
 		stack.RestartPolicy != nil ||
 		stack.UpdateConfig != nil ||
 		stack.RollbackConfig != nil ||
+		stack.HealthTimeout != "" ||
+		stack.Resources != nil ||
+		stack.Logging != nil ||
 		len(stack.Partitions) > 0 ||
 		len(stack.Overlays.Deployments) > 0 ||
 		len(stack.Overlays.Partitions.Rules) > 0 ||
@@ -366,15 +369,40 @@ func hasServiceLocalFields(service Service) bool {
 		service.Workdir != "" ||
 		len(service.Env) > 0 ||
 		len(service.Ports) > 0 ||
+		service.EndpointMode != "" ||
+		service.User != "" ||
+		service.Hostname != "" ||
+		service.Init != nil ||
+		service.ReadOnly ||
+		service.StopSignal != "" ||
+		service.StopGracePeriod != "" ||
+		len(service.Tmpfs) > 0 ||
+		len(service.CapAdd) > 0 ||
+		len(service.CapDrop) > 0 ||
+		len(service.Sysctls) > 0 ||
+		len(service.Ulimits) > 0 ||
+		len(service.ExtraHosts) > 0 ||
+		len(service.DNS) > 0 ||
+		len(service.DNSSearch) > 0 ||
+		len(service.DNSOptions) > 0 ||
 		service.Mode != "" ||
 		service.Replicas != 0 ||
 		service.RestartPolicy != nil ||
 		service.UpdateConfig != nil ||
 		service.RollbackConfig != nil ||
+		service.HealthTimeout != "" ||
+		service.Rollout != "" ||
+		service.Canary != nil ||
+		service.Resources != nil ||
+		service.Logging != nil ||
 		len(service.Labels) > 0 ||
 		len(service.Placement.Constraints) > 0 ||
+		len(service.Placement.Preferences) > 0 ||
+		service.Placement.MaxReplicasPerNode != nil ||
+		len(service.Placement.Platforms) > 0 ||
 		service.Healthcheck != nil ||
 		len(service.DependsOn) > 0 ||
+		!service.Jobs.Empty() ||
 		service.Egress ||
 		len(service.Networks) > 0 ||
 		service.NetworkEphemeral != nil ||
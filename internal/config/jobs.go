@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	JobPhaseBeforeUpdate = "before_update"
+	JobPhaseAfterUpdate  = "after_update"
+	JobPhaseOnRollback   = "on_rollback"
+)
+
+const (
+	JobCleanupAlways  = "always"
+	JobCleanupSuccess = "success"
+	JobCleanupNever   = "never"
+)
+
+// DefaultJobTimeout bounds a lifecycle job that does not set its own timeout.
+const DefaultJobTimeout = 5 * time.Minute
+
+var jobNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+type JobPhase struct {
+	Phase string
+	Jobs  []Job
+}
+
+func (j ServiceJobs) Empty() bool {
+	return len(j.BeforeUpdate) == 0 && len(j.AfterUpdate) == 0 && len(j.OnRollback) == 0
+}
+
+// Phases returns the job phases in execution order.
+func (j ServiceJobs) Phases() []JobPhase {
+	return []JobPhase{
+		{Phase: JobPhaseBeforeUpdate, Jobs: j.BeforeUpdate},
+		{Phase: JobPhaseAfterUpdate, Jobs: j.AfterUpdate},
+		{Phase: JobPhaseOnRollback, Jobs: j.OnRollback},
+	}
+}
+
+func NormalizeJobCleanup(raw string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	switch value {
+	case "":
+		return JobCleanupSuccess, nil
+	case JobCleanupAlways, JobCleanupSuccess, JobCleanupNever:
+		return value, nil
+	}
+	return "", fmt.Errorf("cleanup: invalid value %q", raw)
+}
+
+func ParseJobTimeout(raw string) (time.Duration, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return DefaultJobTimeout, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("timeout: invalid duration %q", raw)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("timeout: must be > 0")
+	}
+	return duration, nil
+}
+
+func validateServiceJobs(scope string, jobs ServiceJobs) []string {
+	var errs []string
+	seen := make(map[string]string)
+	for _, phase := range jobs.Phases() {
+		for i, job := range phase.Jobs {
+			jobScope := fmt.Sprintf("%s.%s[%d]", scope, phase.Phase, i)
+			switch {
+			case job.Name == "":
+				errs = append(errs, jobScope+".name is required")
+			case !jobNamePattern.MatchString(job.Name):
+				errs = append(errs, fmt.Sprintf("%s.name: invalid value %q (letters, digits, '_', '.', '-')", jobScope, job.Name))
+			default:
+				if previous, ok := seen[job.Name]; ok {
+					errs = append(errs, fmt.Sprintf("%s.name: %q already used in %s", jobScope, job.Name, previous))
+				}
+				seen[job.Name] = phase.Phase
+			}
+			if len(job.Command) == 0 {
+				errs = append(errs, jobScope+".command is required")
+			}
+			for key := range job.Env {
+				if key == "" {
+					errs = append(errs, fmt.Sprintf("%s.env: empty key is not allowed", jobScope))
+				}
+			}
+			if _, err := ParseJobTimeout(job.Timeout); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s", jobScope, err))
+			}
+			if _, err := NormalizeJobCleanup(job.Cleanup); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s", jobScope, err))
+			}
+		}
+	}
+	return errs
+}
@@ -651,6 +651,12 @@ func mergeReleaseService(base Service, overlay map[string]any) (Service, error)
 	if patch.RollbackConfig != nil {
 		base.RollbackConfig = MergeUpdatePolicies(base.RollbackConfig, patch.RollbackConfig)
 	}
+	if _, ok := overlay["health_timeout"]; ok {
+		base.HealthTimeout = patch.HealthTimeout
+	}
+	if patch.Resources != nil {
+		base.Resources = MergeResources(base.Resources, patch.Resources)
+	}
 	return base, nil
 }
 
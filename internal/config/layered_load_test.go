@@ -186,6 +186,52 @@ stacks:
 	}
 }
 
+func TestLoadFilesWithReleaseOptionsPinsServiceResources(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "project.yaml")
+	releasePath := filepath.Join(dir, "release.yaml")
+	if err := os.WriteFile(basePath, []byte(`
+project:
+  name: demo
+stacks:
+  core:
+    services:
+      api:
+        image: ghcr.io/acme/api:main
+        resources:
+          limits:
+            cpus: 0.5
+            memory: 256M
+          reservations:
+            memory: 128M
+`), 0o644); err != nil {
+		t.Fatalf("write base: %v", err)
+	}
+	if err := os.WriteFile(releasePath, []byte(`
+stacks:
+  core:
+    services:
+      api:
+        resources:
+          limits:
+            memory: 1G
+`), 0o644); err != nil {
+		t.Fatalf("write release: %v", err)
+	}
+
+	cfg, err := LoadFilesWithReleaseOptions([]string{basePath}, []string{releasePath}, LoadOptions{})
+	if err != nil {
+		t.Fatalf("load configs: %v", err)
+	}
+	resources := cfg.Stacks["core"].Services["api"].Resources
+	if resources == nil || resources.Limits == nil || resources.Reservations == nil {
+		t.Fatalf("expected merged resources, got %#v", resources)
+	}
+	if *resources.Limits.Memory != "1G" || *resources.Limits.CPUs != "0.5" || *resources.Reservations.Memory != "128M" {
+		t.Fatalf("unexpected resources: limits=%#v reservations=%#v", resources.Limits, resources.Reservations)
+	}
+}
+
 func TestLoadFilesWithReleaseOptionsAppliesServiceFieldsAfterImportedStackExpansion(t *testing.T) {
 	dir := t.TempDir()
 	stackPath := filepath.Join(dir, "participant.stack.yaml")
@@ -313,6 +313,9 @@ func Validate(cfg *Config) error {
 	if cfg.Project.PreserveUnusedResources != nil && *cfg.Project.PreserveUnusedResources < 0 {
 		errs = append(errs, "project.preserve_unused_resources must be >= 0")
 	}
+	if cfg.Project.ReleaseHistory != nil && *cfg.Project.ReleaseHistory < 0 {
+		errs = append(errs, "project.release_history must be >= 0")
+	}
 
 	for _, partition := range cfg.Project.Partitions {
 		if partition == "_" {
@@ -323,9 +326,10 @@ func Validate(cfg *Config) error {
 		}
 	}
 
-	if err := ValidateDeployment(cfg); err != nil {
-		errs = append(errs, err.Error())
-	}
+	errs = append(errs, validateDeploymentSelection(cfg)...)
+	errs = append(errs, validateProjectPolicy(cfg)...)
+	errs = append(errs, validateRegistries(cfg)...)
+	errs = append(errs, validateNotifications(cfg)...)
 
 	if err := validateConfigDefs("project.configs", cfg.Project.Configs); err != nil {
 		errs = append(errs, err.Error())
@@ -336,6 +340,11 @@ func Validate(cfg *Config) error {
 	errs = append(errs, validateRestartPolicy("project.restart_policy", cfg.Project.RestartPolicy)...)
 	errs = append(errs, validateUpdatePolicy("project.update_config", cfg.Project.UpdateConfig)...)
 	errs = append(errs, validateUpdatePolicy("project.rollback_config", cfg.Project.RollbackConfig)...)
+	errs = append(errs, validateHealthTimeout("project", cfg.Project.HealthTimeout)...)
+	errs = append(errs, validateResources("project.resources", cfg.Project.Resources)...)
+	errs = append(errs, validateLogging("project.defaults.logging", cfg.Project.Defaults.Logging)...)
+	errs = append(errs, validateNetworkDefaults("project.defaults.networks", cfg.Project.Defaults.Networks)...)
+	errs = append(errs, validateNetworkAddressPool(cfg)...)
 	if err := validateSecretsEngine(cfg.Project.SecretsEngine); err != nil {
 		errs = append(errs, err.Error())
 	}
@@ -362,6 +371,8 @@ func Validate(cfg *Config) error {
 		}
 	}
 
+	errs = append(errs, validateDependencyCycles(cfg)...)
+
 	for nodeName, node := range cfg.Project.Nodes {
 		if err := validateNode(nodeName, node); err != nil {
 			errs = append(errs, err.Error())
@@ -451,7 +462,20 @@ func validateSecretsEngine(engine *SecretsEngine) error {
 	return nil
 }
 
+// ValidateDeployment checks the selected deployment and the effective
+// services it deploys against project policy.
 func ValidateDeployment(cfg *Config) error {
+	errs := validateDeploymentSelection(cfg)
+	if len(errs) == 0 {
+		errs = validateDeploymentPolicy(cfg)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", joinErrors(errs))
+	}
+	return nil
+}
+
+func validateDeploymentSelection(cfg *Config) []string {
 	var errs []string
 	if cfg.Project.Deployment != "" {
 		if err := validateLogicalName("project.deployment", cfg.Project.Deployment); err != nil {
@@ -486,10 +510,7 @@ func ValidateDeployment(cfg *Config) error {
 			errs = append(errs, fmt.Sprintf("project.contexts.%s is required", name))
 		}
 	}
-	if len(errs) > 0 {
-		return fmt.Errorf("%s", joinErrors(errs))
-	}
-	return nil
+	return errs
 }
 
 func validateStack(cfg *Config, name string, stack Stack, standards map[string]StandardMount, serviceStandard string) error {
@@ -504,6 +525,9 @@ func validateStack(cfg *Config, name string, stack Stack, standards map[string]S
 	errs = append(errs, validateRestartPolicy("stack "+name+".restart_policy", stack.RestartPolicy)...)
 	errs = append(errs, validateUpdatePolicy("stack "+name+".update_config", stack.UpdateConfig)...)
 	errs = append(errs, validateUpdatePolicy("stack "+name+".rollback_config", stack.RollbackConfig)...)
+	errs = append(errs, validateHealthTimeout("stack "+name, stack.HealthTimeout)...)
+	errs = append(errs, validateResources("stack "+name+".resources", stack.Resources)...)
+	errs = append(errs, validateLogging("stack "+name+".logging", stack.Logging)...)
 	if name == "core" && stack.Mode == "partitioned" {
 		errs = append(errs, "stack \"core\": reserved for shared stack mode")
 	}
@@ -525,6 +549,9 @@ func validateStack(cfg *Config, name string, stack Stack, standards map[string]S
 		errs = append(errs, validateRestartPolicy("stack "+name+".partitions."+partitionName+".restart_policy", partition.RestartPolicy)...)
 		errs = append(errs, validateUpdatePolicy("stack "+name+".partitions."+partitionName+".update_config", partition.UpdateConfig)...)
 		errs = append(errs, validateUpdatePolicy("stack "+name+".partitions."+partitionName+".rollback_config", partition.RollbackConfig)...)
+		errs = append(errs, validateHealthTimeout("stack "+name+".partitions."+partitionName, partition.HealthTimeout)...)
+		errs = append(errs, validateResources("stack "+name+".partitions."+partitionName+".resources", partition.Resources)...)
+		errs = append(errs, validateLogging("stack "+name+".partitions."+partitionName+".logging", partition.Logging)...)
 		if err := validateConfigDefs("stack "+name+".partition "+partitionName+".configs", partition.Configs.Defs); err != nil {
 			errs = append(errs, err.Error())
 		}
@@ -545,6 +572,7 @@ func validateStack(cfg *Config, name string, stack Stack, standards map[string]S
 		if err := validateServiceIncludedIn("stack "+name+".services."+serviceName+".included_in", service.IncludedIn, cfg); err != nil {
 			errs = append(errs, err.Error())
 		}
+		errs = append(errs, validateCrossStackDependencies(cfg, name, stack, serviceName, service)...)
 		if err := validateServiceConfigs("stack "+name+".services."+serviceName+".configs", service.Configs); err != nil {
 			errs = append(errs, err.Error())
 		}
@@ -558,7 +586,7 @@ func validateStack(cfg *Config, name string, stack Stack, standards map[string]S
 			errs = append(errs, err.Error())
 		}
 	}
-	if err := validateStackVolumes("stack "+name+".volumes", stack.Volumes); err != nil {
+	if err := validateStackVolumes("stack "+name+".volumes", stack.Volumes, nil); err != nil {
 		errs = append(errs, err.Error())
 	}
 	if err := validateStackOverlays(cfg, name, stack); err != nil {
@@ -591,6 +619,12 @@ func validateService(stackName string, serviceName string, service Service, serv
 	errs = append(errs, validateRestartPolicy(scope+".restart_policy", service.RestartPolicy)...)
 	errs = append(errs, validateUpdatePolicy(scope+".update_config", service.UpdateConfig)...)
 	errs = append(errs, validateUpdatePolicy(scope+".rollback_config", service.RollbackConfig)...)
+	errs = append(errs, validateHealthTimeout(scope, service.HealthTimeout)...)
+	errs = append(errs, validateResources(scope+".resources", service.Resources)...)
+	errs = append(errs, validateLogging(scope+".logging", service.Logging)...)
+	errs = append(errs, validateServiceJobs(scope+".jobs", service.Jobs)...)
+	errs = append(errs, validateServiceRollout(scope, service)...)
+	errs = append(errs, validateServiceRuntime(scope, service)...)
 	if len(service.Networks) > 0 {
 		errs = append(errs, fmt.Sprintf("%s.networks: networks are derived; remove service-level networks", scope))
 	}
@@ -609,22 +643,22 @@ func validateService(stackName string, serviceName string, service Service, serv
 			errs = append(errs, fmt.Sprintf("%s.labels: %q uses reserved prefix swarmcp.io/", scope, key))
 		}
 	}
-	for _, constraint := range service.Placement.Constraints {
-		if strings.TrimSpace(constraint) == "" {
-			errs = append(errs, fmt.Sprintf("%s.placement.constraints: empty constraint", scope))
-		}
-	}
+	errs = append(errs, validatePlacement(scope, service.Mode, service.Placement)...)
 
 	for _, dep := range service.DependsOn {
 		if err := validateLogicalName(scope+".depends_on", dep); err != nil {
 			errs = append(errs, err.Error())
 			continue
 		}
+		if _, _, cross := SplitServiceDependency(dep); cross {
+			continue
+		}
 		if _, ok := services[dep]; !ok {
 			errs = append(errs, fmt.Sprintf("%s.depends_on: service %q not found", scope, dep))
 		}
 	}
 
+	errs = append(errs, validateEndpointMode(scope, service)...)
 	for i, port := range service.Ports {
 		if port.Target <= 0 {
 			errs = append(errs, fmt.Sprintf("%s.ports[%d].target: must be > 0", scope, i))
@@ -869,7 +903,9 @@ func validateServiceSecrets(scope string, refs []SecretRef) error {
 	return nil
 }
 
-func validateStackVolumes(scope string, volumes map[string]VolumeDef) error {
+// validateStackVolumes checks stack volume definitions. Overlays pass the
+// base stack volumes so they may adjust a volume without repeating its target.
+func validateStackVolumes(scope string, volumes map[string]VolumeDef, base map[string]VolumeDef) error {
 	if len(volumes) == 0 {
 		return nil
 	}
@@ -878,9 +914,18 @@ func validateStackVolumes(scope string, volumes map[string]VolumeDef) error {
 		if err := validateLogicalName(scope+" "+name, name); err != nil {
 			errs = append(errs, err.Error())
 		}
-		if strings.TrimSpace(def.Target) == "" {
+		existing, inherited := base[name]
+		if strings.TrimSpace(def.Target) == "" && !inherited {
 			errs = append(errs, fmt.Sprintf("%s.%s.target: required", scope, name))
 		}
+		if len(def.DriverOpts) > 0 && !def.UsesDriver() && !existing.UsesDriver() {
+			errs = append(errs, fmt.Sprintf("%s.%s.driver_opts: requires driver", scope, name))
+		}
+		for key := range def.DriverOpts {
+			if strings.TrimSpace(key) == "" {
+				errs = append(errs, fmt.Sprintf("%s.%s.driver_opts: option name is required", scope, name))
+			}
+		}
 	}
 	if len(errs) > 0 {
 		return fmt.Errorf("%s", joinErrors(errs))
@@ -1097,6 +1142,9 @@ func validateOverlayStack(scope string, stackName string, stack OverlayStack, cf
 	if err := validateSecretDefs(scope+".secrets", stack.Secrets.Defs); err != nil {
 		errs = append(errs, err.Error())
 	}
+	if err := validateStackVolumes(scope+".volumes", stack.Volumes, cfg.Stacks[stackName].Volumes); err != nil {
+		errs = append(errs, err.Error())
+	}
 	baseServices := map[string]Service{}
 	if baseStack, ok := cfg.Stacks[stackName]; ok {
 		for name, service := range baseStack.Services {
@@ -154,6 +154,67 @@ func TestValidateServiceIncludedInRejectsUnknownTargetsAndEmptyRule(t *testing.T
 	}
 }
 
+func TestValidateCrossStackDependsOn(t *testing.T) {
+	cfg := &Config{
+		Project: Project{Name: "primary", Partitions: []string{"blue"}},
+		Stacks: map[string]Stack{
+			"core": {
+				Services: map[string]Service{
+					"db":  {Image: "postgres:16"},
+					"api": {Image: "api:latest", DependsOn: []string{"app/web"}},
+				},
+			},
+			"app": {
+				Mode: "partitioned",
+				Services: map[string]Service{
+					"web": {Image: "web:latest", DependsOn: []string{"core/db", "core/missing", "nope/db"}},
+				},
+			},
+		},
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	message := err.Error()
+	if !strings.Contains(message, `service "missing" not found in stack "core"`) {
+		t.Fatalf("expected missing service error, got %v", err)
+	}
+	if !strings.Contains(message, `stack "nope" not found`) {
+		t.Fatalf("expected missing stack error, got %v", err)
+	}
+	if !strings.Contains(message, `shared stack services cannot depend on partitioned stack "app"`) {
+		t.Fatalf("expected shared to partitioned error, got %v", err)
+	}
+	if strings.Contains(message, "core/db") {
+		t.Fatalf("expected core/db to be accepted, got %v", err)
+	}
+}
+
+func TestValidateDependsOnCycle(t *testing.T) {
+	cfg := &Config{
+		Project: Project{Name: "primary"},
+		Stacks: map[string]Stack{
+			"core": {
+				Services: map[string]Service{
+					"db": {Image: "postgres:16", DependsOn: []string{"app/web"}},
+				},
+			},
+			"app": {
+				Services: map[string]Service{
+					"web": {Image: "web:latest", DependsOn: []string{"core/db"}},
+				},
+			},
+		},
+	}
+
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle app/web -> core/db -> app/web") {
+		t.Fatalf("expected dependency cycle error, got %v", err)
+	}
+}
+
 func TestValidateStackIncludedInRejectsUnknownTargetsAndEmptyRule(t *testing.T) {
 	cfg := &Config{
 		Project: Project{
@@ -473,3 +534,268 @@ func TestNormalizeTemplateScalarsParsesSynergyDevopsCoreStack(t *testing.T) {
 		t.Fatalf("expected normalized downstream core stack to parse, got: %v\nnormalized:\n%s", err, normalized)
 	}
 }
+
+func TestValidateHealthTimeout(t *testing.T) {
+	cfg := &Config{
+		Project: Project{Name: "primary", HealthTimeout: "soon"},
+		Stacks: map[string]Stack{
+			"core": {
+				HealthTimeout: "90s",
+				Services: map[string]Service{
+					"api": {Image: "api:latest", HealthTimeout: "-1s"},
+				},
+			},
+		},
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	message := err.Error()
+	if !strings.Contains(message, `project.health_timeout: invalid duration "soon"`) {
+		t.Fatalf("expected project health_timeout error, got %v", err)
+	}
+	if !strings.Contains(message, "stack core.services.api.health_timeout: must be > 0") {
+		t.Fatalf("expected service health_timeout error, got %v", err)
+	}
+	if strings.Contains(message, "stack core.health_timeout") {
+		t.Fatalf("expected stack health_timeout to be valid, got %v", err)
+	}
+}
+
+func TestValidateServiceJobs(t *testing.T) {
+	cfg := &Config{
+		Project: Project{Name: "primary"},
+		Stacks: map[string]Stack{
+			"core": {
+				Services: map[string]Service{
+					"api": {
+						Image: "api:latest",
+						Jobs: ServiceJobs{
+							BeforeUpdate: []Job{{Name: "migrate", Command: []string{"/migrate"}, Timeout: "later"}},
+							AfterUpdate:  []Job{{Name: "migrate", Command: []string{"/seed"}}},
+							OnRollback:   []Job{{Name: "undo", Cleanup: "sometimes"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	message := err.Error()
+	for _, want := range []string{
+		`stack core.services.api.jobs.before_update[0].timeout: invalid duration "later"`,
+		`stack core.services.api.jobs.after_update[0].name: "migrate" already used in before_update`,
+		"stack core.services.api.jobs.on_rollback[0].command is required",
+		`stack core.services.api.jobs.on_rollback[0].cleanup: invalid value "sometimes"`,
+	} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateServiceRollout(t *testing.T) {
+	cfg := &Config{
+		Project: Project{Name: "primary"},
+		Stacks: map[string]Stack{
+			"core": {
+				Services: map[string]Service{
+					"api":    {Image: "api:latest", Rollout: "canary", Canary: &Canary{Replicas: 2, Window: "5m"}},
+					"worker": {Image: "worker:latest", Rollout: "blue-green", Canary: &Canary{Replicas: -1, Window: "soon"}},
+				},
+			},
+		},
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	message := err.Error()
+	for _, want := range []string{
+		`stack core.services.worker.rollout: invalid value "blue-green" (expected rolling|canary)`,
+		"stack core.services.worker.canary.replicas: must be >= 0",
+		`stack core.services.worker.canary.window: invalid duration "soon"`,
+	} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+	if strings.Contains(message, "services.api.") {
+		t.Fatalf("expected api rollout to be valid, got %v", err)
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	cfg := &Config{
+		Project: Project{
+			Name:      "primary",
+			Resources: &Resources{Limits: &ResourceLimits{Memory: new("lots")}},
+		},
+		Stacks: map[string]Stack{
+			"core": {
+				Services: map[string]Service{
+					"api": {
+						Image: "api:latest",
+						Resources: &Resources{
+							Limits: &ResourceLimits{CPUs: new("0"), Memory: new("512M")},
+							Reservations: &ResourceReservations{
+								GenericResources: []GenericResource{{Kind: "gpu"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	message := err.Error()
+	for _, want := range []string{
+		`project.resources.limits.memory: invalid value "lots"`,
+		"stack core.services.api.resources.limits.cpus: must be > 0",
+		"stack core.services.api.resources.reservations.generic_resources[0]: value or named is required",
+	} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+	if strings.Contains(message, "limits.memory: invalid value \"512M\"") {
+		t.Fatalf("expected 512M to be valid, got %v", err)
+	}
+}
+
+func TestValidatePlacement(t *testing.T) {
+	cfg := &Config{
+		Project: Project{Name: "primary"},
+		Stacks: map[string]Stack{
+			"core": {
+				Services: map[string]Service{
+					"api": {
+						Image: "api:latest",
+						Placement: Placement{
+							Preferences: []PlacementPreference{{Spread: "node.labels.zone"}, {Spread: " "}},
+							Platforms:   []NodePlatform{{OS: "linux", Arch: "arm64"}, {Arch: "amd64"}},
+						},
+					},
+					"agent": {
+						Image:     "agent:latest",
+						Mode:      "global",
+						Placement: Placement{MaxReplicasPerNode: new(2)},
+					},
+				},
+			},
+		},
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	message := err.Error()
+	for _, want := range []string{
+		"stack core.services.api.placement.preferences[1].spread is required",
+		"stack core.services.api.placement.platforms[1].os is required",
+		"stack core.services.agent.placement.max_replicas_per_node: not supported for global services",
+	} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+	if strings.Contains(message, "preferences[0]") || strings.Contains(message, "platforms[0]") {
+		t.Fatalf("expected first preference and platform to be valid, got %v", err)
+	}
+}
+
+func TestValidateServiceRuntimeOptions(t *testing.T) {
+	var service Service
+	if err := yaml.Unmarshal([]byte("ulimits:\n  nproc: 65535\n  nofile:\n    soft: 40000\n    hard: 20000\n"), &service); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := service.Ulimits["nproc"]; got != (Ulimit{Soft: 65535, Hard: 65535}) {
+		t.Fatalf("expected scalar ulimit to set soft and hard, got %+v", got)
+	}
+	service.Image = "api:latest"
+	service.StopGracePeriod = "soon"
+	service.Tmpfs = []string{"/run", "tmp", "/cache:size=big"}
+	service.ExtraHosts = []string{"db:10.0.0.5", "db", "cache:{{ value \"cache_ip\" }}"}
+	service.DNS = []string{"10.0.0.2", "dns.local"}
+	cfg := &Config{
+		Project: Project{Name: "primary"},
+		Stacks: map[string]Stack{
+			"core": {Services: map[string]Service{"api": service}},
+		},
+	}
+
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	message := err.Error()
+	for _, want := range []string{
+		`stack core.services.api.stop_grace_period: invalid duration "soon"`,
+		`stack core.services.api.tmpfs: "tmp" must start with an absolute target path`,
+		`stack core.services.api.tmpfs: invalid size "big" in "/cache:size=big"`,
+		`stack core.services.api.extra_hosts: "db" must be <host>:<ip>`,
+		`stack core.services.api.dns: "dns.local" is not an IP address`,
+		"stack core.services.api.ulimits.nofile: soft limit exceeds hard limit",
+	} {
+		if !strings.Contains(message, want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+	if strings.Contains(message, "cache_ip") {
+		t.Fatalf("expected templated extra host to be skipped, got %v", err)
+	}
+}
+
+func TestValidateStackVolumeDriverOptsRequireDriver(t *testing.T) {
+	err := validateStackVolumes("stack data.volumes", map[string]VolumeDef{
+		"pg": {Target: "/data", DriverOpts: map[string]string{"type": "nfs"}},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "driver_opts: requires driver") {
+		t.Fatalf("expected driver_opts error, got %v", err)
+	}
+
+	err = validateStackVolumes("overlays.deployments.dev.stacks.data.volumes", map[string]VolumeDef{
+		"pg": {DriverOpts: map[string]string{"o": "addr=10.1.0.5"}},
+	}, map[string]VolumeDef{
+		"pg": {Target: "/data", Driver: "local"},
+	})
+	if err != nil {
+		t.Fatalf("expected overlay to inherit target and driver, got %v", err)
+	}
+}
+
+func TestValidateNetworkDefaultsIPAM(t *testing.T) {
+	errs := validateNetworkDefaults("project.defaults.networks", NetworkDefaults{
+		MTU:        -1,
+		DriverOpts: map[string]string{"encrypted": ""},
+		Settings: map[string]NetworkSettings{
+			"primary_egress": {
+				IPAM: []NetworkIPAM{
+					{Subnet: "10.20.0.0/24", Gateway: "10.20.1.1", IPRange: "10.20.0.128/25"},
+					{Subnet: "10.30.0.1/24"},
+				},
+			},
+		},
+	})
+	want := []string{
+		"project.defaults.networks.mtu: must be >= 0",
+		"project.defaults.networks.driver_opts.encrypted: use the encrypted/mtu fields instead",
+		"project.defaults.networks.settings.primary_egress.ipam[0].gateway: 10.20.1.1 is outside subnet 10.20.0.0/24",
+		`project.defaults.networks.settings.primary_egress.ipam[1].subnet: "10.30.0.1/24" has host bits set (expected 10.30.0.0/24)`,
+	}
+	if strings.Join(errs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected errors:\n%s", strings.Join(errs, "\n"))
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MergeLogging layers logging settings; later scopes win. Options merge by key
+// while the driver is unchanged and are reset when a later scope switches
+// drivers.
+func MergeLogging(settings ...*Logging) *Logging {
+	var out *Logging
+	for _, item := range settings {
+		if item == nil {
+			continue
+		}
+		driver := strings.TrimSpace(item.Driver)
+		if out == nil || (driver != "" && driver != out.Driver) {
+			out = &Logging{Driver: driver}
+		}
+		for key, value := range item.Options {
+			if out.Options == nil {
+				out.Options = make(map[string]string, len(item.Options))
+			}
+			out.Options[key] = value
+		}
+	}
+	if out == nil || (out.Driver == "" && len(out.Options) == 0) {
+		return nil
+	}
+	return out
+}
+
+func StackPartitionLogging(stack Stack, partition string) *Logging {
+	if partition == "" || len(stack.Partitions) == 0 {
+		return nil
+	}
+	if part, ok := stack.Partitions[partition]; ok {
+		return part.Logging
+	}
+	return nil
+}
+
+func validateLogging(scope string, logging *Logging) []string {
+	if logging == nil {
+		return nil
+	}
+	var errs []string
+	for key := range logging.Options {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, fmt.Sprintf("%s.options: empty key is not allowed", scope))
+		}
+	}
+	return errs
+}
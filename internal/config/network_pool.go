@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/cmmoran/swarmcp/internal/sliceutil"
+)
+
+// maxPoolSlotBits bounds the slot space hashed into for very large pools
+// (IPv6); the first 2^32 subnets of the pool are more than enough.
+const maxPoolSlotBits = 32
+
+func InternalNetworkName(cfg *Config, partition string) string {
+	base := strings.TrimSpace(cfg.Project.Defaults.Networks.Internal)
+	if base == "" {
+		base = fmt.Sprintf("%s_<partition>_internal", cfg.Project.Name)
+	}
+	return RenderNetworkTemplate(base, cfg.Project.Name, partition)
+}
+
+// PoolNetworkNames lists the networks that draw subnets from the address
+// pool: every partition internal network, every stack network and every
+// service ephemeral network, across all partitions, sorted by name.
+func PoolNetworkNames(cfg *Config) []string {
+	var names []string
+	for _, partition := range cfg.Project.Partitions {
+		names = append(names, InternalNetworkName(cfg, partition))
+	}
+	for _, stackName := range slices.Sorted(maps.Keys(cfg.Stacks)) {
+		stack := cfg.Stacks[stackName]
+		partitions := []string{""}
+		if stack.Mode == "partitioned" && len(cfg.Project.Partitions) > 0 {
+			partitions = cfg.Project.Partitions
+		}
+		for _, partition := range partitions {
+			names = append(names, StackInstanceName(cfg.Project.Name, stackName, partition, stack.Mode))
+			services, err := cfg.StackServices(stackName, partition)
+			if err != nil {
+				continue
+			}
+			for serviceName, service := range services {
+				if service.NetworkEphemeral == nil {
+					continue
+				}
+				names = append(names, EphemeralNetworkName(cfg, stackName, stack.Mode, partition, serviceName))
+			}
+		}
+	}
+	slices.Sort(names)
+	return sliceutil.DedupeSortedStrings(names)
+}
+
+// NetworkSubnets allocates a subnet from project.defaults.networks.address_pool
+// for each pool network without pinned IPAM. Networks that already exist keep
+// their pool subnet; the others hash to a preferred slot and probe forward
+// past taken slots and pinned subnets. Without cluster state, a new network
+// whose slot collides can shift a network that sorts after it.
+func NetworkSubnets(cfg *Config) (map[string]string, error) {
+	pool := cfg.Project.Defaults.Networks.AddressPool
+	if pool == nil || strings.TrimSpace(pool.Base) == "" {
+		return nil, nil
+	}
+	base, err := parseAddressPool(pool)
+	if err != nil {
+		return nil, err
+	}
+	var reserved []netip.Prefix
+	for _, settings := range cfg.Project.Defaults.Networks.Settings {
+		for _, ipam := range settings.IPAM {
+			if prefix, err := netip.ParsePrefix(strings.TrimSpace(ipam.Subnet)); err == nil {
+				reserved = append(reserved, prefix.Masked())
+			}
+		}
+	}
+	var pending []string
+	for _, name := range PoolNetworkNames(cfg) {
+		if settings, ok := networkSettingsEntry(cfg, name); ok && len(settings.IPAM) > 0 {
+			continue
+		}
+		pending = append(pending, name)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	slotBits := min(pool.Size-base.Bits(), maxPoolSlotBits)
+	slots := uint64(1) << slotBits
+	if uint64(len(pending)) > slots {
+		return nil, fmt.Errorf("project.defaults.networks.address_pool: %s holds %d /%d subnets but %d networks need one", base, slots, pool.Size, len(pending))
+	}
+	used := make(map[uint64]struct{}, len(pending))
+	out := make(map[string]string, len(pending))
+	var existing map[string][]string
+	if cfg.ExistingNetworkSubnets != nil {
+		existing = cfg.ExistingNetworkSubnets()
+	}
+	var fresh []string
+	for _, name := range pending {
+		if subnet, slot, ok := existingPoolSubnet(base, pool.Size, slots, existing[name], reserved); ok {
+			if _, taken := used[slot]; !taken {
+				used[slot] = struct{}{}
+				out[name] = subnet.String()
+				continue
+			}
+		}
+		fresh = append(fresh, name)
+	}
+	for _, name := range fresh {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(name))
+		start := hash.Sum64() % slots
+		allocated := false
+		for i := uint64(0); i < slots; i++ {
+			slot := (start + i) % slots
+			if _, ok := used[slot]; ok {
+				continue
+			}
+			subnet := poolSubnet(base, pool.Size, slot)
+			if prefixOverlapsAny(subnet, reserved) {
+				continue
+			}
+			used[slot] = struct{}{}
+			out[name] = subnet.String()
+			allocated = true
+			break
+		}
+		if !allocated {
+			return nil, fmt.Errorf("project.defaults.networks.address_pool: no free /%d subnet left in %s for network %q", pool.Size, base, name)
+		}
+	}
+	return out, nil
+}
+
+// existingPoolSubnet finds the pool slot of a subnet an existing network
+// already has. Subnets outside the pool, of another size or overlapping a
+// pinned subnet are ignored.
+func existingPoolSubnet(base netip.Prefix, size int, slots uint64, subnets []string, reserved []netip.Prefix) (netip.Prefix, uint64, bool) {
+	for _, raw := range subnets {
+		subnet, err := netip.ParsePrefix(strings.TrimSpace(raw))
+		if err != nil || subnet.Bits() != size || subnet != subnet.Masked() || !base.Contains(subnet.Addr()) || prefixOverlapsAny(subnet, reserved) {
+			continue
+		}
+		offset := new(big.Int).Sub(new(big.Int).SetBytes(subnet.Addr().AsSlice()), new(big.Int).SetBytes(base.Addr().AsSlice()))
+		slot := offset.Rsh(offset, uint(base.Addr().BitLen()-size))
+		if !slot.IsUint64() || slot.Uint64() >= slots {
+			continue
+		}
+		return subnet, slot.Uint64(), true
+	}
+	return netip.Prefix{}, 0, false
+}
+
+func parseAddressPool(pool *NetworkAddressPool) (netip.Prefix, error) {
+	base, err := netip.ParsePrefix(strings.TrimSpace(pool.Base))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("project.defaults.networks.address_pool.base: invalid CIDR %q", pool.Base)
+	}
+	if base != base.Masked() {
+		return netip.Prefix{}, fmt.Errorf("project.defaults.networks.address_pool.base: %q has host bits set (expected %s)", pool.Base, base.Masked())
+	}
+	if pool.Size < base.Bits() || pool.Size > base.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("project.defaults.networks.address_pool.size: must be between %d and %d", base.Bits(), base.Addr().BitLen())
+	}
+	return base, nil
+}
+
+func poolSubnet(base netip.Prefix, size int, slot uint64) netip.Prefix {
+	raw := base.Addr().AsSlice()
+	offset := new(big.Int).Lsh(new(big.Int).SetUint64(slot), uint(base.Addr().BitLen()-size))
+	value := new(big.Int).Add(new(big.Int).SetBytes(raw), offset)
+	addr, _ := netip.AddrFromSlice(value.FillBytes(make([]byte, len(raw))))
+	return netip.PrefixFrom(addr, size)
+}
+
+func prefixOverlapsAny(prefix netip.Prefix, others []netip.Prefix) bool {
+	for _, other := range others {
+		if prefix.Overlaps(other) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateNetworkAddressPool(cfg *Config) []string {
+	pool := cfg.Project.Defaults.Networks.AddressPool
+	if pool == nil {
+		return nil
+	}
+	if strings.TrimSpace(pool.Base) == "" {
+		return []string{"project.defaults.networks.address_pool.base: required"}
+	}
+	if _, err := NetworkSubnets(cfg); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
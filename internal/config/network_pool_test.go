@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func addressPoolConfig() *Config {
+	return &Config{
+		Project: Project{
+			Name:       "demo",
+			Partitions: []string{"dev", "qa"},
+			Defaults: ProjectDefaults{
+				Networks: NetworkDefaults{
+					AddressPool: &NetworkAddressPool{Base: "10.80.0.0/16", Size: 24},
+				},
+			},
+		},
+		Stacks: map[string]Stack{
+			"core": {Mode: "shared", Services: map[string]Service{
+				"api": {Image: "api", NetworkEphemeral: &ServiceNetworkEphemeral{}},
+			}},
+			"app": {Mode: "partitioned", Services: map[string]Service{"web": {Image: "web"}}},
+		},
+	}
+}
+
+func TestNetworkSubnetsAllocatesFromPool(t *testing.T) {
+	cfg := addressPoolConfig()
+	subnets, err := NetworkSubnets(cfg)
+	if err != nil {
+		t.Fatalf("NetworkSubnets: %v", err)
+	}
+	names := []string{"demo_dev_internal", "demo_qa_internal", "demo_dev_app", "demo_qa_app", "demo_core", "demo_core_svc_api"}
+	if len(subnets) != len(names) {
+		t.Fatalf("unexpected allocation: %#v", subnets)
+	}
+	pool := netip.MustParsePrefix("10.80.0.0/16")
+	seen := make(map[string]string)
+	for _, name := range names {
+		subnet, err := netip.ParsePrefix(subnets[name])
+		if err != nil || subnet.Bits() != 24 || !pool.Contains(subnet.Addr()) {
+			t.Fatalf("unexpected subnet for %s: %q", name, subnets[name])
+		}
+		if other, ok := seen[subnets[name]]; ok {
+			t.Fatalf("%s and %s share subnet %s", name, other, subnets[name])
+		}
+		seen[subnets[name]] = name
+	}
+
+	cfg.Project.Partitions = append(cfg.Project.Partitions, "prod")
+	again, err := NetworkSubnets(cfg)
+	if err != nil {
+		t.Fatalf("NetworkSubnets: %v", err)
+	}
+	for _, name := range names {
+		if again[name] != subnets[name] {
+			t.Fatalf("allocation for %s moved from %s to %s", name, subnets[name], again[name])
+		}
+	}
+}
+
+func TestNetworkSubnetsSkipsPinnedNetworks(t *testing.T) {
+	cfg := addressPoolConfig()
+	cfg.Project.Defaults.Networks.AddressPool = &NetworkAddressPool{Base: "10.80.0.0/22", Size: 24}
+	cfg.Project.Defaults.Networks.Settings = map[string]NetworkSettings{
+		"<project>_<partition>_internal": {IPAM: []NetworkIPAM{{Subnet: "10.80.0.0/23"}}},
+	}
+	cfg.Stacks = nil
+	subnets, err := NetworkSubnets(cfg)
+	if err != nil {
+		t.Fatalf("NetworkSubnets: %v", err)
+	}
+	if len(subnets) != 0 {
+		t.Fatalf("expected pinned networks to be skipped, got %#v", subnets)
+	}
+
+	cfg.Project.Defaults.Networks.Settings = map[string]NetworkSettings{
+		"demo_dev_internal": {IPAM: []NetworkIPAM{{Subnet: "10.80.0.0/23"}}},
+	}
+	subnets, err = NetworkSubnets(cfg)
+	if err != nil {
+		t.Fatalf("NetworkSubnets: %v", err)
+	}
+	if _, ok := subnets["demo_dev_internal"]; ok {
+		t.Fatalf("expected pinned network to be skipped, got %#v", subnets)
+	}
+	if got := subnets["demo_qa_internal"]; got != "10.80.2.0/24" && got != "10.80.3.0/24" {
+		t.Fatalf("expected allocation outside the pinned subnet, got %q", got)
+	}
+}
+
+func TestValidateNetworkAddressPool(t *testing.T) {
+	cfg := addressPoolConfig()
+	cfg.Project.Defaults.Networks.AddressPool = &NetworkAddressPool{Base: "10.80.0.0/23", Size: 24}
+	errs := validateNetworkAddressPool(cfg)
+	if len(errs) != 1 || !strings.Contains(errs[0], "holds 2 /24 subnets but 6 networks need one") {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	cfg.Project.Defaults.Networks.AddressPool = &NetworkAddressPool{Base: "10.80.0.0/16", Size: 8}
+	errs = validateNetworkAddressPool(cfg)
+	if len(errs) != 1 || errs[0] != "project.defaults.networks.address_pool.size: must be between 16 and 32" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestNetworkSubnetsKeepsExistingNetworksWhenOneIsAdded(t *testing.T) {
+	cfg := addressPoolConfig()
+	cfg.Project.Defaults.Networks.AddressPool = &NetworkAddressPool{Base: "10.80.0.0/22", Size: 24}
+	cfg.Stacks = nil
+	before, err := NetworkSubnets(cfg)
+	if err != nil {
+		t.Fatalf("NetworkSubnets: %v", err)
+	}
+	cfg.ExistingNetworkSubnets = func() map[string][]string {
+		existing := make(map[string][]string, len(before))
+		for name, subnet := range before {
+			existing[name] = []string{subnet}
+		}
+		return existing
+	}
+
+	// Find a new partition whose network, hashed on its own, would take the
+	// subnet of an existing one.
+	var added string
+	for i := 0; added == "" && i < 1000; i++ {
+		candidate := fmt.Sprintf("a%d", i)
+		probe := addressPoolConfig()
+		probe.Project.Defaults.Networks.AddressPool = cfg.Project.Defaults.Networks.AddressPool
+		probe.Stacks = nil
+		probe.Project.Partitions = append(slices.Clone(cfg.Project.Partitions), candidate)
+		hashed, err := NetworkSubnets(probe)
+		if err != nil {
+			t.Fatalf("NetworkSubnets: %v", err)
+		}
+		for name, subnet := range before {
+			if hashed[name] != subnet {
+				added = candidate
+			}
+		}
+	}
+	if added == "" {
+		t.Fatalf("expected a colliding partition name")
+	}
+
+	cfg.Project.Partitions = append(cfg.Project.Partitions, added)
+	after, err := NetworkSubnets(cfg)
+	if err != nil {
+		t.Fatalf("NetworkSubnets: %v", err)
+	}
+	for name, subnet := range before {
+		if after[name] != subnet {
+			t.Fatalf("existing network %s moved from %s to %s", name, subnet, after[name])
+		}
+	}
+	addedNetwork := InternalNetworkName(cfg, added)
+	for name, subnet := range before {
+		if after[addedNetwork] == subnet {
+			t.Fatalf("new network %s took the subnet of %s", addedNetwork, name)
+		}
+	}
+	if after[addedNetwork] == "" {
+		t.Fatalf("expected %s to get a subnet, got %#v", addedNetwork, after)
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// NetworkSettingsFor returns the effective settings for a managed network:
+// the network defaults overlaid with the matching settings entry, if any.
+// Driver options merge; IPAM comes only from the settings entry.
+func NetworkSettingsFor(cfg *Config, name string) NetworkSettings {
+	defaults := cfg.Project.Defaults.Networks
+	out := NetworkSettings{
+		Encrypted: new(defaults.Encrypted),
+		MTU:       defaults.MTU,
+	}
+	if len(defaults.DriverOpts) > 0 {
+		out.DriverOpts = make(map[string]string, len(defaults.DriverOpts))
+		for key, value := range defaults.DriverOpts {
+			out.DriverOpts[key] = value
+		}
+	}
+	settings, ok := networkSettingsEntry(cfg, name)
+	if !ok {
+		return out
+	}
+	if settings.Encrypted != nil {
+		out.Encrypted = new(*settings.Encrypted)
+	}
+	if settings.MTU > 0 {
+		out.MTU = settings.MTU
+	}
+	if len(settings.DriverOpts) > 0 && out.DriverOpts == nil {
+		out.DriverOpts = make(map[string]string, len(settings.DriverOpts))
+	}
+	for key, value := range settings.DriverOpts {
+		out.DriverOpts[key] = value
+	}
+	out.IPAM = append([]NetworkIPAM(nil), settings.IPAM...)
+	return out
+}
+
+func networkSettingsEntry(cfg *Config, name string) (NetworkSettings, bool) {
+	settings := cfg.Project.Defaults.Networks.Settings
+	if entry, ok := settings[name]; ok {
+		return entry, true
+	}
+	partitions := append([]string{""}, cfg.Project.Partitions...)
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		for _, partition := range partitions {
+			if RenderNetworkTemplate(key, cfg.Project.Name, partition) == name {
+				return settings[key], true
+			}
+		}
+	}
+	return NetworkSettings{}, false
+}
+
+func validateNetworkDefaults(scope string, defaults NetworkDefaults) []string {
+	var errs []string
+	if defaults.MTU < 0 {
+		errs = append(errs, fmt.Sprintf("%s.mtu: must be >= 0", scope))
+	}
+	errs = append(errs, validateNetworkDriverOpts(scope+".driver_opts", defaults.DriverOpts)...)
+	for _, key := range slices.Sorted(maps.Keys(defaults.Settings)) {
+		settings := defaults.Settings[key]
+		entryScope := fmt.Sprintf("%s.settings.%s", scope, key)
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, fmt.Sprintf("%s.settings: network name is required", scope))
+		}
+		if settings.MTU < 0 {
+			errs = append(errs, fmt.Sprintf("%s.mtu: must be >= 0", entryScope))
+		}
+		errs = append(errs, validateNetworkDriverOpts(entryScope+".driver_opts", settings.DriverOpts)...)
+		for i, ipam := range settings.IPAM {
+			errs = append(errs, validateNetworkIPAM(fmt.Sprintf("%s.ipam[%d]", entryScope, i), ipam)...)
+		}
+	}
+	return errs
+}
+
+func validateNetworkDriverOpts(scope string, opts map[string]string) []string {
+	var errs []string
+	for _, key := range slices.Sorted(maps.Keys(opts)) {
+		switch strings.TrimSpace(key) {
+		case "":
+			errs = append(errs, fmt.Sprintf("%s: option name is required", scope))
+		case "encrypted", "com.docker.network.driver.mtu":
+			errs = append(errs, fmt.Sprintf("%s.%s: use the encrypted/mtu fields instead", scope, key))
+		}
+	}
+	return errs
+}
+
+func validateNetworkIPAM(scope string, ipam NetworkIPAM) []string {
+	if containsBalancedTemplateDelimiters(ipam.Subnet) || containsBalancedTemplateDelimiters(ipam.Gateway) || containsBalancedTemplateDelimiters(ipam.IPRange) {
+		return nil
+	}
+	subnet, err := netip.ParsePrefix(strings.TrimSpace(ipam.Subnet))
+	if err != nil {
+		return []string{fmt.Sprintf("%s.subnet: invalid CIDR %q", scope, ipam.Subnet)}
+	}
+	var errs []string
+	if subnet != subnet.Masked() {
+		errs = append(errs, fmt.Sprintf("%s.subnet: %q has host bits set (expected %s)", scope, ipam.Subnet, subnet.Masked()))
+	}
+	if gateway := strings.TrimSpace(ipam.Gateway); gateway != "" {
+		addr, err := netip.ParseAddr(gateway)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Sprintf("%s.gateway: invalid address %q", scope, ipam.Gateway))
+		case !subnet.Contains(addr):
+			errs = append(errs, fmt.Sprintf("%s.gateway: %s is outside subnet %s", scope, gateway, subnet))
+		}
+	}
+	if ipRange := strings.TrimSpace(ipam.IPRange); ipRange != "" {
+		prefix, err := netip.ParsePrefix(ipRange)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Sprintf("%s.ip_range: invalid CIDR %q", scope, ipam.IPRange))
+		case prefix.Bits() < subnet.Bits() || !subnet.Contains(prefix.Addr()):
+			errs = append(errs, fmt.Sprintf("%s.ip_range: %s is outside subnet %s", scope, ipRange, subnet))
+		}
+	}
+	return errs
+}
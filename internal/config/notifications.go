@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+const (
+	NotificationWebhook = "webhook"
+	NotificationSlack   = "slack"
+	NotificationCommand = "command"
+)
+
+// NotificationEvents lists the event types a notification can subscribe to.
+var NotificationEvents = []string{
+	"plan_computed",
+	"apply_started",
+	"stack_deployed",
+	"stack_failed",
+	"prune_performed",
+	"apply_completed",
+	"apply_failed",
+	"rollback",
+}
+
+// Notification sends apply events to a JSON webhook, a Slack/Mattermost
+// compatible incoming webhook, or a local command. An empty Events list
+// subscribes to every event.
+type Notification struct {
+	Type      string            `yaml:"type"`
+	URL       string            `yaml:"url,omitempty"`
+	URLSecret string            `yaml:"url_secret,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	Command   []string          `yaml:"command,omitempty"`
+	Events    []string          `yaml:"events,omitempty"`
+	Template  string            `yaml:"template,omitempty"`
+	Timeout   string            `yaml:"timeout,omitempty"`
+}
+
+// Wants reports whether the notification subscribes to event.
+func (n Notification) Wants(event string) bool {
+	return len(n.Events) == 0 || slices.Contains(n.Events, event)
+}
+
+// TimeoutDuration returns the delivery timeout, 10s when unset.
+func (n Notification) TimeoutDuration() time.Duration {
+	if duration, err := time.ParseDuration(strings.TrimSpace(n.Timeout)); err == nil && duration > 0 {
+		return duration
+	}
+	return 10 * time.Second
+}
+
+// ParseNotificationTemplate parses a notification message template. Templates
+// use text/template with the sprig functions.
+func ParseNotificationTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(sprig.TxtFuncMap()).Option("missingkey=zero").Parse(text)
+}
+
+func validateNotifications(cfg *Config) []string {
+	var errs []string
+	for _, name := range slices.Sorted(maps.Keys(cfg.Project.Notifications)) {
+		notification := cfg.Project.Notifications[name]
+		scope := "project.notifications." + name
+		switch notification.Type {
+		case NotificationWebhook, NotificationSlack:
+			if (notification.URL == "") == (notification.URLSecret == "") {
+				errs = append(errs, fmt.Sprintf("%s: exactly one of url or url_secret is required", scope))
+			}
+			if notification.URL != "" {
+				if parsed, err := url.Parse(notification.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+					errs = append(errs, fmt.Sprintf("%s.url: must be an http or https URL", scope))
+				}
+			}
+			if len(notification.Command) > 0 {
+				errs = append(errs, fmt.Sprintf("%s.command: only allowed for type %s", scope, NotificationCommand))
+			}
+			if notification.Type == NotificationSlack && len(notification.Headers) > 0 {
+				errs = append(errs, fmt.Sprintf("%s.headers: only allowed for type %s", scope, NotificationWebhook))
+			}
+		case NotificationCommand:
+			if len(notification.Command) == 0 || strings.TrimSpace(notification.Command[0]) == "" {
+				errs = append(errs, fmt.Sprintf("%s.command is required", scope))
+			}
+			if notification.URL != "" || notification.URLSecret != "" || len(notification.Headers) > 0 {
+				errs = append(errs, fmt.Sprintf("%s: url, url_secret and headers are not allowed for type %s", scope, NotificationCommand))
+			}
+		case "":
+			errs = append(errs, fmt.Sprintf("%s.type is required (%s|%s|%s)", scope, NotificationWebhook, NotificationSlack, NotificationCommand))
+		default:
+			errs = append(errs, fmt.Sprintf("%s.type: unknown type %q (%s|%s|%s)", scope, notification.Type, NotificationWebhook, NotificationSlack, NotificationCommand))
+		}
+		for _, event := range notification.Events {
+			if !slices.Contains(NotificationEvents, event) {
+				errs = append(errs, fmt.Sprintf("%s.events: unknown event %q (%s)", scope, event, strings.Join(NotificationEvents, "|")))
+			}
+		}
+		if notification.Template != "" {
+			if _, err := ParseNotificationTemplate(name, notification.Template); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.template: %v", scope, err))
+			}
+		}
+		if strings.TrimSpace(notification.Timeout) != "" {
+			if duration, err := time.ParseDuration(strings.TrimSpace(notification.Timeout)); err != nil || duration <= 0 {
+				errs = append(errs, fmt.Sprintf("%s.timeout: must be a positive duration", scope))
+			}
+		}
+	}
+	return errs
+}
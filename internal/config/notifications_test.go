@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateNotifications(t *testing.T) {
+	cfg := &Config{
+		Project: Project{
+			Name: "primary",
+			Notifications: map[string]Notification{
+				"ci":     {Type: NotificationWebhook, URL: "https://hooks.example.com/swarmcp", Events: []string{"apply_failed", "stack_failed"}},
+				"chat":   {Type: NotificationSlack, URLSecret: "slack_webhook", Template: "{{ .Message }}"},
+				"hook":   {Type: NotificationCommand, Command: []string{"./notify.sh"}, Timeout: "30s"},
+				"both":   {Type: NotificationWebhook, URL: "https://hooks.example.com", URLSecret: "hook"},
+				"scheme": {Type: NotificationSlack, URL: "ftp://hooks.example.com"},
+				"argv":   {Type: NotificationCommand, URL: "https://hooks.example.com"},
+				"events": {Type: NotificationWebhook, URL: "https://hooks.example.com", Events: []string{"deployed"}},
+				"tmpl":   {Type: NotificationSlack, URL: "https://hooks.example.com", Template: "{{ .Message "},
+				"wait":   {Type: NotificationSlack, URL: "https://hooks.example.com", Timeout: "soon"},
+				"kind":   {Type: "email"},
+			},
+		},
+	}
+	errs := strings.Join(validateNotifications(cfg), "\n")
+	for _, want := range []string{
+		"project.notifications.both: exactly one of url or url_secret is required",
+		"project.notifications.scheme.url: must be an http or https URL",
+		"project.notifications.argv.command is required",
+		"project.notifications.argv: url, url_secret and headers are not allowed for type command",
+		`project.notifications.events.events: unknown event "deployed"`,
+		"project.notifications.tmpl.template:",
+		"project.notifications.wait.timeout: must be a positive duration",
+		`project.notifications.kind.type: unknown type "email"`,
+	} {
+		if !strings.Contains(errs, want) {
+			t.Fatalf("expected %q in:\n%s", want, errs)
+		}
+	}
+	for _, name := range []string{"ci", "chat", "hook"} {
+		if strings.Contains(errs, "project.notifications."+name+".") || strings.Contains(errs, "project.notifications."+name+":") {
+			t.Fatalf("unexpected errors for valid notification %s:\n%s", name, errs)
+		}
+	}
+	if !cfg.Project.Notifications["ci"].Wants("stack_failed") || cfg.Project.Notifications["ci"].Wants("apply_started") || !cfg.Project.Notifications["chat"].Wants("apply_started") {
+		t.Fatalf("unexpected event filtering")
+	}
+}
@@ -304,6 +304,7 @@ type OverlayStack struct {
 	Sources    Sources                     `yaml:"sources"`
 	Configs    ConfigDefsOrRefs            `yaml:"configs"`
 	Secrets    SecretDefsOrRefs            `yaml:"secrets"`
+	Volumes    map[string]VolumeDef        `yaml:"volumes"`
 	Partitions map[string]OverlayPartition `yaml:"partitions"`
 	Services   map[string]OverlayService   `yaml:"services"`
 }
@@ -498,3 +498,49 @@ func TestOverlayPrecedenceSealedStackWinsOverService(t *testing.T) {
 		t.Fatalf("expected stack sealed overlay to win; got %q", service.Image)
 	}
 }
+
+func TestStackVolumeDefsDeploymentOverlay(t *testing.T) {
+	cfg := &Config{
+		Project: Project{Name: "primary", Deployment: "dev"},
+		Stacks: map[string]Stack{
+			"data": {
+				Mode: "shared",
+				Volumes: map[string]VolumeDef{
+					"pg": {
+						Target:     "/var/lib/postgresql/data",
+						Driver:     "local",
+						DriverOpts: map[string]string{"type": "nfs", "o": "addr=10.0.0.5", "device": ":/pg"},
+					},
+					"cache": {
+						Target:     "/cache",
+						Driver:     "local",
+						DriverOpts: map[string]string{"type": "tmpfs"},
+					},
+				},
+				Overlays: StackOverlays{
+					Deployments: map[string]OverlayStack{
+						"dev": {
+							Volumes: map[string]VolumeDef{
+								"pg":    {DriverOpts: map[string]string{"o": "addr=10.1.0.5"}},
+								"cache": {Driver: "rexray"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	defs := cfg.StackVolumeDefs("data", "")
+	pg := defs["pg"]
+	if pg.Target != "/var/lib/postgresql/data" || pg.DriverOpts["o"] != "addr=10.1.0.5" || pg.DriverOpts["device"] != ":/pg" {
+		t.Fatalf("expected merged pg volume, got %#v", pg)
+	}
+	cache := defs["cache"]
+	if cache.Driver != "rexray" || len(cache.DriverOpts) != 0 {
+		t.Fatalf("expected driver switch to drop options, got %#v", cache)
+	}
+	if cfg.Stacks["data"].Volumes["pg"].DriverOpts["o"] != "addr=10.0.0.5" {
+		t.Fatalf("expected base volume to be unchanged")
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+func validatePlacement(scope string, mode string, placement Placement) []string {
+	var errs []string
+	for _, constraint := range placement.Constraints {
+		if strings.TrimSpace(constraint) == "" {
+			errs = append(errs, fmt.Sprintf("%s.placement.constraints: empty constraint", scope))
+		}
+	}
+	for i, pref := range placement.Preferences {
+		if strings.TrimSpace(pref.Spread) == "" {
+			errs = append(errs, fmt.Sprintf("%s.placement.preferences[%d].spread is required", scope, i))
+		}
+	}
+	if placement.MaxReplicasPerNode != nil {
+		if *placement.MaxReplicasPerNode < 0 {
+			errs = append(errs, fmt.Sprintf("%s.placement.max_replicas_per_node: must be >= 0", scope))
+		}
+		if mode == "global" && *placement.MaxReplicasPerNode > 0 {
+			errs = append(errs, fmt.Sprintf("%s.placement.max_replicas_per_node: not supported for global services", scope))
+		}
+	}
+	for i, platform := range placement.Platforms {
+		if strings.TrimSpace(platform.OS) == "" {
+			errs = append(errs, fmt.Sprintf("%s.placement.platforms[%d].os is required", scope, i))
+		}
+	}
+	return errs
+}
+
+// PlatformMatches reports whether a node platform satisfies a placement
+// platform. An empty arch accepts any architecture.
+func PlatformMatches(required NodePlatform, node NodePlatform) bool {
+	if required.OS != node.OS {
+		return false
+	}
+	return required.Arch == "" || required.Arch == node.Arch
+}
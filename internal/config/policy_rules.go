@@ -22,14 +22,23 @@ var layeredPolicyRules = []layeredPolicyRule{
 	{pattern: []string{"project", "restart_policy"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "update_config"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "rollback_config"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "resources"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "secrets_engine"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "preserve_unused_resources"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "release_history"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "partitions"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "deployments"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "defaults", "logging"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "policy", "forbid_ingress_ports"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "policy", "require_image_digests"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "defaults", "networks", "shared"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "defaults", "networks", "internal"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "defaults", "networks", "egress"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "defaults", "networks", "attachable"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "defaults", "networks", "encrypted"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "defaults", "networks", "mtu"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "defaults", "networks", "settings", "*", "ipam"}, action: layeredPolicyReplace},
+	{pattern: []string{"project", "defaults", "networks", "address_pool"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "defaults", "volumes", "driver"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "defaults", "volumes", "base_path"}, action: layeredPolicyReplace},
 	{pattern: []string{"project", "defaults", "volumes", "layout"}, action: layeredPolicyReplace},
@@ -48,22 +57,43 @@ var layeredPolicyRules = []layeredPolicyRule{
 	{pattern: []string{"stacks", "*", "restart_policy"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "update_config"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "rollback_config"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "logging"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "resources"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "partitions", "*", "restart_policy"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "partitions", "*", "update_config"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "partitions", "*", "rollback_config"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "partitions", "*", "logging"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "partitions", "*", "resources"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "source"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "image"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "command"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "args"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "workdir"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "ports"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "endpoint_mode"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "user"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "hostname"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "init"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "read_only"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "stop_signal"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "stop_grace_period"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "tmpfs"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "cap_add"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "cap_drop"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "extra_hosts"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "dns"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "dns_search"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "dns_opt"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "mode"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "replicas"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "restart_policy"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "update_config"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "rollback_config"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "logging"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "resources"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "healthcheck"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "depends_on"}, action: layeredPolicyReplace},
+	{pattern: []string{"stacks", "*", "services", "*", "jobs"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "egress"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "networks"}, action: layeredPolicyReplace},
 	{pattern: []string{"stacks", "*", "services", "*", "network_ephemeral"}, action: layeredPolicyReplace},
@@ -133,6 +163,30 @@ var releasePolicyRoot = &releasePolicyNode{
 										{segment: "labels", kind: releaseValueScalarMap},
 										{segment: "update_config", kind: releaseValueUpdatePolicyMap},
 										{segment: "rollback_config", kind: releaseValueUpdatePolicyMap},
+										{segment: "health_timeout", kind: releaseValueScalar},
+										{
+											segment: "resources",
+											kind:    releaseValueMap,
+											children: []*releasePolicyNode{
+												{
+													segment: "limits",
+													kind:    releaseValueMap,
+													children: []*releasePolicyNode{
+														{segment: "cpus", kind: releaseValueScalar},
+														{segment: "memory", kind: releaseValueScalar},
+														{segment: "pids", kind: releaseValueScalar},
+													},
+												},
+												{
+													segment: "reservations",
+													kind:    releaseValueMap,
+													children: []*releasePolicyNode{
+														{segment: "cpus", kind: releaseValueScalar},
+														{segment: "memory", kind: releaseValueScalar},
+													},
+												},
+											},
+										},
 									},
 								},
 							},
@@ -1,6 +1,9 @@
 package config
 
-const DefaultPreserveUnusedResources = 5
+const (
+	DefaultPreserveUnusedResources = 5
+	DefaultReleaseHistory          = 20
+)
 
 func PreserveUnusedResources(cfg *Config) int {
 	if cfg == nil {
@@ -11,3 +14,13 @@ func PreserveUnusedResources(cfg *Config) int {
 	}
 	return DefaultPreserveUnusedResources
 }
+
+func ReleaseHistory(cfg *Config) int {
+	if cfg == nil {
+		return DefaultReleaseHistory
+	}
+	if cfg.Project.ReleaseHistory != nil {
+		return *cfg.Project.ReleaseHistory
+	}
+	return DefaultReleaseHistory
+}
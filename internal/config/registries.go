@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// RegistryAuth holds the pull credentials for one registry host. Secrets
+// are resolved through the project secrets file or secrets engine; entries
+// under deployments override the fields they set for that deployment.
+type RegistryAuth struct {
+	Username       string                  `yaml:"username"`
+	UsernameSecret string                  `yaml:"username_secret"`
+	PasswordSecret string                  `yaml:"password_secret"`
+	Deployments    map[string]RegistryAuth `yaml:"deployments"`
+}
+
+// DeploymentRegistries returns project.registries with the overrides of the
+// selected deployment applied.
+func (cfg *Config) DeploymentRegistries() map[string]RegistryAuth {
+	if len(cfg.Project.Registries) == 0 {
+		return nil
+	}
+	out := make(map[string]RegistryAuth, len(cfg.Project.Registries))
+	for host, auth := range cfg.Project.Registries {
+		out[host] = auth.forDeployment(cfg.Project.Deployment)
+	}
+	return out
+}
+
+func (auth RegistryAuth) forDeployment(deployment string) RegistryAuth {
+	effective := RegistryAuth{
+		Username:       auth.Username,
+		UsernameSecret: auth.UsernameSecret,
+		PasswordSecret: auth.PasswordSecret,
+	}
+	override, ok := auth.Deployments[deployment]
+	if deployment == "" || !ok {
+		return effective
+	}
+	if override.Username != "" || override.UsernameSecret != "" {
+		effective.Username = override.Username
+		effective.UsernameSecret = override.UsernameSecret
+	}
+	if override.PasswordSecret != "" {
+		effective.PasswordSecret = override.PasswordSecret
+	}
+	return effective
+}
+
+func validateRegistries(cfg *Config) []string {
+	var errs []string
+	for _, host := range slices.Sorted(maps.Keys(cfg.Project.Registries)) {
+		auth := cfg.Project.Registries[host]
+		scope := "project.registries." + host
+		if strings.TrimSpace(host) == "" || strings.Contains(host, "://") || strings.Contains(host, "/") {
+			errs = append(errs, fmt.Sprintf("%s: key must be a registry host (for example registry.example.com or docker.io)", scope))
+		}
+		for _, name := range slices.Sorted(maps.Keys(auth.Deployments)) {
+			override := auth.Deployments[name]
+			overrideScope := scope + ".deployments." + name
+			if len(cfg.Project.Deployments) > 0 && !deploymentInProject(cfg, name) {
+				errs = append(errs, fmt.Sprintf("%s: deployment %q not found in project.deployments", overrideScope, name))
+			}
+			if len(override.Deployments) > 0 {
+				errs = append(errs, fmt.Sprintf("%s.deployments: not allowed", overrideScope))
+			}
+			if override.Username != "" && override.UsernameSecret != "" {
+				errs = append(errs, fmt.Sprintf("%s: username and username_secret are mutually exclusive", overrideScope))
+			}
+			errs = append(errs, validateRegistryCredentials(overrideScope, auth.forDeployment(name))...)
+		}
+		if auth.Username != "" && auth.UsernameSecret != "" {
+			errs = append(errs, fmt.Sprintf("%s: username and username_secret are mutually exclusive", scope))
+		}
+		if len(auth.Deployments) == 0 || auth.Username != "" || auth.UsernameSecret != "" || auth.PasswordSecret != "" {
+			errs = append(errs, validateRegistryCredentials(scope, auth.forDeployment(""))...)
+		}
+	}
+	return errs
+}
+
+func validateRegistryCredentials(scope string, auth RegistryAuth) []string {
+	var errs []string
+	if auth.Username == "" && auth.UsernameSecret == "" {
+		errs = append(errs, fmt.Sprintf("%s: username or username_secret is required", scope))
+	}
+	if auth.PasswordSecret == "" {
+		errs = append(errs, fmt.Sprintf("%s.password_secret is required", scope))
+	}
+	return errs
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeploymentRegistriesAppliesOverrides(t *testing.T) {
+	cfg := &Config{
+		Project: Project{
+			Name:        "primary",
+			Deployments: []string{"dev", "prod"},
+			Deployment:  "prod",
+			Registries: map[string]RegistryAuth{
+				"registry.example.com": {
+					Username:       "ci",
+					PasswordSecret: "registry_password",
+					Deployments: map[string]RegistryAuth{
+						"prod": {UsernameSecret: "prod_registry_user", PasswordSecret: "prod_registry_password"},
+					},
+				},
+			},
+		},
+	}
+	if errs := validateRegistries(cfg); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	got := cfg.DeploymentRegistries()["registry.example.com"]
+	if got.Username != "" || got.UsernameSecret != "prod_registry_user" || got.PasswordSecret != "prod_registry_password" || got.Deployments != nil {
+		t.Fatalf("unexpected prod credentials %#v", got)
+	}
+	cfg.Project.Deployment = "dev"
+	got = cfg.DeploymentRegistries()["registry.example.com"]
+	if got.Username != "ci" || got.PasswordSecret != "registry_password" {
+		t.Fatalf("unexpected dev credentials %#v", got)
+	}
+}
+
+func TestValidateRegistries(t *testing.T) {
+	cfg := &Config{
+		Project: Project{
+			Name:        "primary",
+			Deployments: []string{"dev"},
+			Registries: map[string]RegistryAuth{
+				"https://registry.example.com": {Username: "ci", PasswordSecret: "pw"},
+				"ghcr.io":                      {Username: "ci", UsernameSecret: "user"},
+				"docker.io": {
+					Deployments: map[string]RegistryAuth{
+						"staging": {Username: "ci", PasswordSecret: "pw"},
+					},
+				},
+			},
+		},
+	}
+	errs := strings.Join(validateRegistries(cfg), "\n")
+	for _, want := range []string{
+		"project.registries.https://registry.example.com: key must be a registry host",
+		"project.registries.ghcr.io: username and username_secret are mutually exclusive",
+		"project.registries.ghcr.io.password_secret is required",
+		`project.registries.docker.io.deployments.staging: deployment "staging" not found in project.deployments`,
+	} {
+		if !strings.Contains(errs, want) {
+			t.Fatalf("expected %q in:\n%s", want, errs)
+		}
+	}
+	if strings.Contains(errs, "project.registries.docker.io: username") {
+		t.Fatalf("expected deployment-only registry to skip base validation:\n%s", errs)
+	}
+}
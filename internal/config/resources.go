@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+)
+
+func ParseResourceCPUs(field string, raw string) (int64, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return 0, fmt.Errorf("resources.%s.cpus: must not be empty", field)
+	}
+	cpus, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(cpus) || math.IsInf(cpus, 0) {
+		return 0, fmt.Errorf("resources.%s.cpus: invalid value %q", field, raw)
+	}
+	if cpus <= 0 {
+		return 0, fmt.Errorf("resources.%s.cpus: must be > 0", field)
+	}
+	return int64(math.Round(cpus * 1e9)), nil
+}
+
+func ParseResourceMemory(field string, raw string) (int64, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return 0, fmt.Errorf("resources.%s.memory: must not be empty", field)
+	}
+	bytes, err := units.RAMInBytes(value)
+	if err != nil {
+		return 0, fmt.Errorf("resources.%s.memory: invalid value %q", field, raw)
+	}
+	if bytes <= 0 {
+		return 0, fmt.Errorf("resources.%s.memory: must be > 0", field)
+	}
+	return bytes, nil
+}
+
+// MergeResources layers resource settings field by field; later values win.
+// Generic resources are replaced as a whole.
+func MergeResources(resources ...*Resources) *Resources {
+	var limits ResourceLimits
+	var reservations ResourceReservations
+	limitsSet := false
+	reservationsSet := false
+	for _, item := range resources {
+		if item == nil {
+			continue
+		}
+		if item.Limits != nil {
+			if value := trimmedString(item.Limits.CPUs); value != nil {
+				limits.CPUs = value
+				limitsSet = true
+			}
+			if value := trimmedString(item.Limits.Memory); value != nil {
+				limits.Memory = value
+				limitsSet = true
+			}
+			if item.Limits.Pids != nil {
+				limits.Pids = new(*item.Limits.Pids)
+				limitsSet = true
+			}
+		}
+		if item.Reservations != nil {
+			if value := trimmedString(item.Reservations.CPUs); value != nil {
+				reservations.CPUs = value
+				reservationsSet = true
+			}
+			if value := trimmedString(item.Reservations.Memory); value != nil {
+				reservations.Memory = value
+				reservationsSet = true
+			}
+			if item.Reservations.GenericResources != nil {
+				reservations.GenericResources = append([]GenericResource(nil), item.Reservations.GenericResources...)
+				reservationsSet = true
+			}
+		}
+	}
+	if !limitsSet && !reservationsSet {
+		return nil
+	}
+	out := &Resources{}
+	if limitsSet {
+		out.Limits = &limits
+	}
+	if reservationsSet {
+		out.Reservations = &reservations
+	}
+	return out
+}
+
+func trimmedString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+func StackPartitionResources(stack Stack, partition string) *Resources {
+	if partition == "" || len(stack.Partitions) == 0 {
+		return nil
+	}
+	if part, ok := stack.Partitions[partition]; ok {
+		return part.Resources
+	}
+	return nil
+}
+
+func validateResources(scope string, resources *Resources) []string {
+	if resources == nil {
+		return nil
+	}
+	var errs []string
+	if limits := resources.Limits; limits != nil {
+		if limits.CPUs != nil {
+			if _, err := ParseResourceCPUs("limits", *limits.CPUs); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s", scope, strings.TrimPrefix(err.Error(), "resources.")))
+			}
+		}
+		if limits.Memory != nil {
+			if _, err := ParseResourceMemory("limits", *limits.Memory); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s", scope, strings.TrimPrefix(err.Error(), "resources.")))
+			}
+		}
+		if limits.Pids != nil && *limits.Pids < 0 {
+			errs = append(errs, fmt.Sprintf("%s.limits.pids: must be >= 0", scope))
+		}
+	}
+	if reservations := resources.Reservations; reservations != nil {
+		if reservations.CPUs != nil {
+			if _, err := ParseResourceCPUs("reservations", *reservations.CPUs); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s", scope, strings.TrimPrefix(err.Error(), "resources.")))
+			}
+		}
+		if reservations.Memory != nil {
+			if _, err := ParseResourceMemory("reservations", *reservations.Memory); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s", scope, strings.TrimPrefix(err.Error(), "resources.")))
+			}
+		}
+		for i, generic := range reservations.GenericResources {
+			itemScope := fmt.Sprintf("%s.reservations.generic_resources[%d]", scope, i)
+			if strings.TrimSpace(generic.Kind) == "" {
+				errs = append(errs, itemScope+".kind is required")
+			}
+			switch {
+			case generic.Value != nil && generic.Named != "":
+				errs = append(errs, itemScope+": set either value or named, not both")
+			case generic.Value == nil && generic.Named == "":
+				errs = append(errs, itemScope+": value or named is required")
+			case generic.Value != nil && *generic.Value <= 0:
+				errs = append(errs, itemScope+".value: must be > 0")
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	RolloutRolling = "rolling"
+	RolloutCanary  = "canary"
+)
+
+const (
+	// DefaultCanaryReplicas is the size of a canary that does not set replicas.
+	DefaultCanaryReplicas = 1
+	// DefaultCanaryWindow is how long a healthy canary is watched before the
+	// main service is updated.
+	DefaultCanaryWindow = time.Minute
+)
+
+type Canary struct {
+	Replicas int    `yaml:"replicas"`
+	Window   string `yaml:"window"`
+}
+
+// UsesCanary reports whether updates of the service go through a canary.
+func (s Service) UsesCanary() bool {
+	return strings.TrimSpace(s.Rollout) == RolloutCanary
+}
+
+func ParseCanaryWindow(raw string) (time.Duration, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return DefaultCanaryWindow, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("window: invalid duration %q", raw)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("window: must be > 0")
+	}
+	return duration, nil
+}
+
+func validateServiceRollout(scope string, service Service) []string {
+	var errs []string
+	switch strings.TrimSpace(service.Rollout) {
+	case "", RolloutRolling, RolloutCanary:
+	default:
+		errs = append(errs, fmt.Sprintf("%s.rollout: invalid value %q (expected rolling|canary)", scope, service.Rollout))
+	}
+	if service.Canary == nil {
+		return errs
+	}
+	if service.Canary.Replicas < 0 {
+		errs = append(errs, fmt.Sprintf("%s.canary.replicas: must be >= 0", scope))
+	}
+	if _, err := ParseCanaryWindow(service.Canary.Window); err != nil {
+		errs = append(errs, fmt.Sprintf("%s.canary.%s", scope, err))
+	}
+	return errs
+}
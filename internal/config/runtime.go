@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"go.yaml.in/yaml/v4"
+)
+
+func (u *Ulimit) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var limit int64
+		if err := value.Decode(&limit); err != nil {
+			return err
+		}
+		*u = Ulimit{Soft: limit, Hard: limit}
+		return nil
+	}
+	type rawUlimit Ulimit
+	var raw rawUlimit
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*u = Ulimit(raw)
+	return nil
+}
+
+// TmpfsMount is a parsed tmpfs entry of the form <target>[:size=<bytes>,mode=<octal>].
+type TmpfsMount struct {
+	Target    string
+	SizeBytes int64
+	Mode      os.FileMode
+}
+
+func ParseTmpfs(raw string) (TmpfsMount, error) {
+	target, options, _ := strings.Cut(strings.TrimSpace(raw), ":")
+	if target == "" || !strings.HasPrefix(target, "/") {
+		return TmpfsMount{}, fmt.Errorf("tmpfs: %q must start with an absolute target path", raw)
+	}
+	out := TmpfsMount{Target: target}
+	if options == "" {
+		return out, nil
+	}
+	for _, option := range strings.Split(options, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "size":
+			size, err := units.RAMInBytes(value)
+			if err != nil || size <= 0 {
+				return TmpfsMount{}, fmt.Errorf("tmpfs: invalid size %q in %q", value, raw)
+			}
+			out.SizeBytes = size
+		case "mode":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err != nil {
+				return TmpfsMount{}, fmt.Errorf("tmpfs: invalid mode %q in %q", value, raw)
+			}
+			out.Mode = os.FileMode(mode)
+		default:
+			return TmpfsMount{}, fmt.Errorf("tmpfs: unsupported option %q in %q (expected size or mode)", key, raw)
+		}
+	}
+	return out, nil
+}
+
+// ParseExtraHost splits a compose style "<host>:<ip>" entry.
+func ParseExtraHost(raw string) (string, string, error) {
+	host, ip, ok := strings.Cut(strings.TrimSpace(raw), ":")
+	host = strings.TrimSpace(host)
+	ip = strings.TrimSpace(ip)
+	if !ok || host == "" || net.ParseIP(ip) == nil {
+		return "", "", fmt.Errorf("extra_hosts: %q must be <host>:<ip>", raw)
+	}
+	return host, ip, nil
+}
+
+func ParseStopGracePeriod(raw string) (*time.Duration, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return nil, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, fmt.Errorf("stop_grace_period: invalid duration %q", raw)
+	}
+	if duration < 0 {
+		return nil, fmt.Errorf("stop_grace_period: must be >= 0")
+	}
+	return &duration, nil
+}
+
+// validateServiceRuntime checks runtime options before rendering; templated
+// values are checked again when the service intent is built.
+func validateServiceRuntime(scope string, service Service) []string {
+	var errs []string
+	if !containsBalancedTemplateDelimiters(service.StopGracePeriod) {
+		if _, err := ParseStopGracePeriod(service.StopGracePeriod); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.%s", scope, err))
+		}
+	}
+	for _, entry := range service.Tmpfs {
+		if containsBalancedTemplateDelimiters(entry) {
+			continue
+		}
+		if _, err := ParseTmpfs(entry); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.%s", scope, err))
+		}
+	}
+	for _, entry := range service.ExtraHosts {
+		if containsBalancedTemplateDelimiters(entry) {
+			continue
+		}
+		if _, _, err := ParseExtraHost(entry); err != nil {
+			errs = append(errs, fmt.Sprintf("%s.%s", scope, err))
+		}
+	}
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{
+		{"cap_add", service.CapAdd},
+		{"cap_drop", service.CapDrop},
+		{"dns_search", service.DNSSearch},
+		{"dns_opt", service.DNSOptions},
+	} {
+		for _, value := range field.values {
+			if strings.TrimSpace(value) == "" {
+				errs = append(errs, fmt.Sprintf("%s.%s: empty value", scope, field.name))
+			}
+		}
+	}
+	for _, server := range service.DNS {
+		if containsBalancedTemplateDelimiters(server) {
+			continue
+		}
+		if net.ParseIP(strings.TrimSpace(server)) == nil {
+			errs = append(errs, fmt.Sprintf("%s.dns: %q is not an IP address", scope, server))
+		}
+	}
+	for key := range service.Sysctls {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, fmt.Sprintf("%s.sysctls: empty key is not allowed", scope))
+		}
+	}
+	for name, limit := range service.Ulimits {
+		switch {
+		case strings.TrimSpace(name) == "":
+			errs = append(errs, fmt.Sprintf("%s.ulimits: empty name is not allowed", scope))
+		case limit.Soft < 0 || limit.Hard < 0:
+			errs = append(errs, fmt.Sprintf("%s.ulimits.%s: limits must be >= 0", scope, name))
+		case limit.Soft > limit.Hard:
+			errs = append(errs, fmt.Sprintf("%s.ulimits.%s: soft limit exceeds hard limit", scope, name))
+		}
+	}
+	return errs
+}
@@ -275,6 +275,9 @@ func validateRuntimeServiceDependencies(stackName string, partition string, serv
 			if dep == "" {
 				continue
 			}
+			if _, _, cross := SplitServiceDependency(dep); cross {
+				continue
+			}
 			if _, ok := services[dep]; ok {
 				continue
 			}
@@ -8,38 +8,83 @@ type Config struct {
 	CacheDir string           `yaml:"-"`
 	Offline  bool             `yaml:"-"`
 	Debug    bool             `yaml:"-"`
+	// ExistingNetworkSubnets, when set, reports the subnets of the networks
+	// that already exist on the cluster by name. NetworkSubnets keeps them so
+	// adding a network never moves a deployed one.
+	ExistingNetworkSubnets func() map[string][]string `yaml:"-"`
 }
 
 type Project struct {
-	Name                    string               `yaml:"name"`
-	Partitions              []string             `yaml:"partitions"`
-	Deployments             []string             `yaml:"deployments"`
-	Deployment              string               `yaml:"deployment"`
-	Contexts                map[string]string    `yaml:"contexts"`
-	Targets                 DeploymentTargets    `yaml:"deployment_targets"`
-	Defaults                ProjectDefaults      `yaml:"defaults"`
-	RestartPolicy           *RestartPolicy       `yaml:"restart_policy"`
-	UpdateConfig            *UpdatePolicy        `yaml:"update_config"`
-	RollbackConfig          *UpdatePolicy        `yaml:"rollback_config"`
-	PreserveUnusedResources *int                 `yaml:"preserve_unused_resources"`
-	Nodes                   map[string]Node      `yaml:"nodes"`
-	Sources                 Sources              `yaml:"sources"`
-	Values                  []ValueSource        `yaml:"values"`
-	Configs                 map[string]ConfigDef `yaml:"configs"`
-	Secrets                 map[string]SecretDef `yaml:"secrets"`
-	SecretsEngine           *SecretsEngine       `yaml:"secrets_engine"`
+	Name                    string                  `yaml:"name"`
+	Partitions              []string                `yaml:"partitions"`
+	Deployments             []string                `yaml:"deployments"`
+	Deployment              string                  `yaml:"deployment"`
+	Contexts                map[string]string       `yaml:"contexts"`
+	Targets                 DeploymentTargets       `yaml:"deployment_targets"`
+	Defaults                ProjectDefaults         `yaml:"defaults"`
+	Policy                  ProjectPolicy           `yaml:"policy"`
+	RestartPolicy           *RestartPolicy          `yaml:"restart_policy"`
+	UpdateConfig            *UpdatePolicy           `yaml:"update_config"`
+	RollbackConfig          *UpdatePolicy           `yaml:"rollback_config"`
+	HealthTimeout           string                  `yaml:"health_timeout"`
+	Resources               *Resources              `yaml:"resources"`
+	PreserveUnusedResources *int                    `yaml:"preserve_unused_resources"`
+	ReleaseHistory          *int                    `yaml:"release_history"`
+	Nodes                   map[string]Node         `yaml:"nodes"`
+	Registries              map[string]RegistryAuth `yaml:"registries"`
+	Notifications           map[string]Notification `yaml:"notifications"`
+	Sources                 Sources                 `yaml:"sources"`
+	Values                  []ValueSource           `yaml:"values"`
+	Configs                 map[string]ConfigDef    `yaml:"configs"`
+	Secrets                 map[string]SecretDef    `yaml:"secrets"`
+	SecretsEngine           *SecretsEngine          `yaml:"secrets_engine"`
 }
 
 type ProjectDefaults struct {
 	Networks NetworkDefaults `yaml:"networks"`
 	Volumes  VolumeDefaults  `yaml:"volumes"`
+	Logging  *Logging        `yaml:"logging"`
+}
+
+// ProjectPolicy holds deployment-scoped rules checked once the target
+// deployment is known.
+type ProjectPolicy struct {
+	ForbidIngressPorts  []string `yaml:"forbid_ingress_ports"`
+	RequireImageDigests []string `yaml:"require_image_digests"`
 }
 
 type NetworkDefaults struct {
-	Internal   string   `yaml:"internal"`
-	Egress     string   `yaml:"egress"`
-	Attachable bool     `yaml:"attachable"`
-	Shared     []string `yaml:"shared"`
+	Internal    string                     `yaml:"internal"`
+	Egress      string                     `yaml:"egress"`
+	Attachable  bool                       `yaml:"attachable"`
+	Shared      []string                   `yaml:"shared"`
+	Encrypted   bool                       `yaml:"encrypted"`
+	MTU         int                        `yaml:"mtu"`
+	DriverOpts  map[string]string          `yaml:"driver_opts"`
+	Settings    map[string]NetworkSettings `yaml:"settings"`
+	AddressPool *NetworkAddressPool        `yaml:"address_pool"`
+}
+
+// NetworkAddressPool is the range managed networks draw their subnets from
+// when no IPAM is pinned for them in settings.
+type NetworkAddressPool struct {
+	Base string `yaml:"base"`
+	Size int    `yaml:"size"`
+}
+
+// NetworkSettings overrides the network defaults for one managed network,
+// keyed by network name (the <project> and <partition> tokens are allowed).
+type NetworkSettings struct {
+	Encrypted  *bool             `yaml:"encrypted"`
+	MTU        int               `yaml:"mtu"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
+	IPAM       []NetworkIPAM     `yaml:"ipam"`
+}
+
+type NetworkIPAM struct {
+	Subnet  string `yaml:"subnet"`
+	Gateway string `yaml:"gateway"`
+	IPRange string `yaml:"ip_range"`
 }
 
 type VolumeDefaults struct {
@@ -93,6 +138,9 @@ type Stack struct {
 	RestartPolicy  *RestartPolicy            `yaml:"restart_policy"`
 	UpdateConfig   *UpdatePolicy             `yaml:"update_config"`
 	RollbackConfig *UpdatePolicy             `yaml:"rollback_config"`
+	HealthTimeout  string                    `yaml:"health_timeout"`
+	Resources      *Resources                `yaml:"resources"`
+	Logging        *Logging                  `yaml:"logging"`
 	Partitions     map[string]StackPartition `yaml:"partitions"`
 	Overlays       StackOverlays             `yaml:"overlays"`
 	Sources        Sources                   `yaml:"sources"`
@@ -108,6 +156,9 @@ type StackPartition struct {
 	RestartPolicy  *RestartPolicy   `yaml:"restart_policy"`
 	UpdateConfig   *UpdatePolicy    `yaml:"update_config"`
 	RollbackConfig *UpdatePolicy    `yaml:"rollback_config"`
+	HealthTimeout  string           `yaml:"health_timeout"`
+	Resources      *Resources       `yaml:"resources"`
+	Logging        *Logging         `yaml:"logging"`
 	Sources        Sources          `yaml:"sources"`
 	Configs        ConfigDefsOrRefs `yaml:"configs"`
 	Secrets        SecretDefsOrRefs `yaml:"secrets"`
@@ -122,16 +173,38 @@ type Service struct {
 	Workdir          string                   `yaml:"workdir"`
 	IncludedIn       []InclusionRule          `yaml:"included_in"`
 	Env              map[string]string        `yaml:"env"`
+	User             string                   `yaml:"user"`
+	Hostname         string                   `yaml:"hostname"`
+	Init             *bool                    `yaml:"init"`
+	ReadOnly         bool                     `yaml:"read_only"`
+	StopSignal       string                   `yaml:"stop_signal"`
+	StopGracePeriod  string                   `yaml:"stop_grace_period"`
+	Tmpfs            []string                 `yaml:"tmpfs"`
+	CapAdd           []string                 `yaml:"cap_add"`
+	CapDrop          []string                 `yaml:"cap_drop"`
+	Sysctls          map[string]string        `yaml:"sysctls"`
+	Ulimits          map[string]Ulimit        `yaml:"ulimits"`
+	ExtraHosts       []string                 `yaml:"extra_hosts"`
+	DNS              []string                 `yaml:"dns"`
+	DNSSearch        []string                 `yaml:"dns_search"`
+	DNSOptions       []string                 `yaml:"dns_opt"`
 	Ports            []Port                   `yaml:"ports"`
+	EndpointMode     string                   `yaml:"endpoint_mode"`
 	Mode             string                   `yaml:"mode"`
 	Replicas         int                      `yaml:"replicas"`
 	RestartPolicy    *RestartPolicy           `yaml:"restart_policy"`
 	UpdateConfig     *UpdatePolicy            `yaml:"update_config"`
 	RollbackConfig   *UpdatePolicy            `yaml:"rollback_config"`
+	HealthTimeout    string                   `yaml:"health_timeout"`
+	Rollout          string                   `yaml:"rollout"`
+	Canary           *Canary                  `yaml:"canary"`
+	Resources        *Resources               `yaml:"resources"`
+	Logging          *Logging                 `yaml:"logging"`
 	Labels           map[string]string        `yaml:"labels"`
 	Placement        Placement                `yaml:"placement"`
 	Healthcheck      map[string]any           `yaml:"healthcheck"`
 	DependsOn        []string                 `yaml:"depends_on"`
+	Jobs             ServiceJobs              `yaml:"jobs"`
 	Egress           bool                     `yaml:"egress"`
 	Networks         []string                 `yaml:"networks"`
 	NetworkEphemeral *ServiceNetworkEphemeral `yaml:"network_ephemeral"`
@@ -157,8 +230,72 @@ type ServiceNetworkEphemeral struct {
 	Attachable *bool `yaml:"attachable"`
 }
 
+type ServiceJobs struct {
+	BeforeUpdate []Job `yaml:"before_update"`
+	AfterUpdate  []Job `yaml:"after_update"`
+	OnRollback   []Job `yaml:"on_rollback"`
+}
+
+type Job struct {
+	Name    string            `yaml:"name"`
+	Command []string          `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Workdir string            `yaml:"workdir"`
+	Env     map[string]string `yaml:"env"`
+	Timeout string            `yaml:"timeout"`
+	Cleanup string            `yaml:"cleanup"`
+}
+
+type Resources struct {
+	Limits       *ResourceLimits       `yaml:"limits"`
+	Reservations *ResourceReservations `yaml:"reservations"`
+}
+
+type ResourceLimits struct {
+	CPUs   *string `yaml:"cpus"`
+	Memory *string `yaml:"memory"`
+	Pids   *int64  `yaml:"pids"`
+}
+
+type ResourceReservations struct {
+	CPUs             *string           `yaml:"cpus"`
+	Memory           *string           `yaml:"memory"`
+	GenericResources []GenericResource `yaml:"generic_resources"`
+}
+
+// GenericResource is either a discrete count (value) or a named resource
+// (named) of the given kind.
+type GenericResource struct {
+	Kind  string `yaml:"kind"`
+	Value *int64 `yaml:"value"`
+	Named string `yaml:"named"`
+}
+
+// Logging selects the service log driver. Options only carry over from a
+// lower scope while the driver stays the same.
+type Logging struct {
+	Driver  string            `yaml:"driver"`
+	Options map[string]string `yaml:"options"`
+}
+
+// Ulimit accepts either a single value used for soft and hard limits or a
+// {soft, hard} map, as in compose files.
+type Ulimit struct {
+	Soft int64 `yaml:"soft"`
+	Hard int64 `yaml:"hard"`
+}
+
 type Placement struct {
-	Constraints []string `yaml:"constraints"`
+	Constraints        []string              `yaml:"constraints"`
+	Preferences        []PlacementPreference `yaml:"preferences"`
+	MaxReplicasPerNode *int                  `yaml:"max_replicas_per_node"`
+	Platforms          []NodePlatform        `yaml:"platforms"`
+}
+
+// PlacementPreference spreads tasks evenly over the values of a node
+// descriptor such as node.labels.zone.
+type PlacementPreference struct {
+	Spread string `yaml:"spread"`
 }
 
 type RestartPolicy struct {
@@ -219,9 +356,14 @@ type SecretDefsOrRefs struct {
 	Defs map[string]SecretDef
 }
 
+// VolumeDef declares a stack volume. Without a driver it is a bind under
+// project.defaults.volumes.base_path; with a driver it is a named Docker
+// volume created on each node by that driver.
 type VolumeDef struct {
-	Target  string `yaml:"target"`
-	Subpath string `yaml:"subpath"`
+	Target     string            `yaml:"target"`
+	Subpath    string            `yaml:"subpath"`
+	Driver     string            `yaml:"driver"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
 }
 
 type VolumeRef struct {
@@ -65,3 +65,91 @@ func StackVolumeSource(basePath string, projectName string, stackName string, mo
 	}
 	return filepath.Join(parts...)
 }
+
+// UsesDriver reports whether the volume is a named Docker volume rather than
+// a bind under the project base path.
+func (def VolumeDef) UsesDriver() bool {
+	return strings.TrimSpace(def.Driver) != ""
+}
+
+// StackVolumeName is the Docker volume name for a driver-backed stack volume,
+// namespaced like other stack resources.
+func StackVolumeName(projectName string, stackName string, mode string, partitionName string, volumeName string) string {
+	return StackInstanceName(projectName, stackName, partitionName, mode) + "_" + volumeName
+}
+
+// StackVolumeDefs returns the stack volumes after deployment and partition
+// overlays, in the same order used for config and secret definitions.
+func (cfg *Config) StackVolumeDefs(stackName string, partition string) map[string]VolumeDef {
+	stack, ok := cfg.Stacks[stackName]
+	if !ok {
+		return nil
+	}
+	var unsealed []map[string]VolumeDef
+	var sealed []map[string]VolumeDef
+	add := func(overlay *OverlayStack) {
+		if overlay == nil || len(overlay.Volumes) == 0 {
+			return
+		}
+		if overlay.Sealed {
+			sealed = append([]map[string]VolumeDef{overlay.Volumes}, sealed...)
+			return
+		}
+		unsealed = append(unsealed, overlay.Volumes)
+	}
+	add(overlayStack(cfg.deploymentOverlay(), stackName))
+	for _, overlay := range cfg.partitionOverlays(partition) {
+		add(overlayStack(&overlay, stackName))
+	}
+	add(cfg.stackDeploymentOverlay(stackName))
+	for _, overlay := range cfg.stackPartitionOverlays(stackName, partition) {
+		add(&overlay)
+	}
+	return mergeVolumeDefs(stack.Volumes, append(unsealed, sealed...)...)
+}
+
+func mergeVolumeDefs(base map[string]VolumeDef, overlays ...map[string]VolumeDef) map[string]VolumeDef {
+	if len(overlays) == 0 {
+		return base
+	}
+	out := make(map[string]VolumeDef, len(base))
+	for name, def := range base {
+		out[name] = def
+	}
+	for _, overlay := range overlays {
+		for name, def := range overlay {
+			if existing, ok := out[name]; ok {
+				out[name] = mergeVolumeDef(existing, def)
+				continue
+			}
+			out[name] = def
+		}
+	}
+	return out
+}
+
+// mergeVolumeDef keeps driver options while the driver is unchanged and
+// drops them when an overlay switches drivers.
+func mergeVolumeDef(base VolumeDef, overlay VolumeDef) VolumeDef {
+	if overlay.Target != "" {
+		base.Target = overlay.Target
+	}
+	if overlay.Subpath != "" {
+		base.Subpath = overlay.Subpath
+	}
+	if overlay.Driver != "" && overlay.Driver != base.Driver {
+		base.Driver = overlay.Driver
+		base.DriverOpts = nil
+	}
+	if len(overlay.DriverOpts) > 0 {
+		opts := make(map[string]string, len(base.DriverOpts)+len(overlay.DriverOpts))
+		for key, value := range base.DriverOpts {
+			opts[key] = value
+		}
+		for key, value := range overlay.DriverOpts {
+			opts[key] = value
+		}
+		base.DriverOpts = opts
+	}
+	return base
+}
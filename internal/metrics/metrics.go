@@ -0,0 +1,137 @@
+// Package metrics exposes gauges in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Label is one name/value pair of a sample. Samples keep their labels in the
+// order given so the output stays stable.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one gauge value.
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Registry holds gauge samples in named groups. A group is replaced as a
+// whole, so series that disappear from a refresh (a removed service, say)
+// are dropped instead of keeping their last value.
+type Registry struct {
+	mu     sync.RWMutex
+	help   map[string]string
+	groups map[string][]Sample
+}
+
+// NewRegistry returns a registry that documents the metrics named in help.
+func NewRegistry(help map[string]string) *Registry {
+	return &Registry{help: help, groups: make(map[string][]Sample)}
+}
+
+// Replace sets the samples of a group, discarding its previous samples.
+func (r *Registry) Replace(group string, samples []Sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.groups[group] = samples
+}
+
+// WriteText writes every sample, grouped by metric name, in the Prometheus
+// text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	byName := make(map[string][]Sample)
+	for _, samples := range r.groups {
+		for _, sample := range samples {
+			byName[sample.Name] = append(byName[sample.Name], sample)
+		}
+	}
+	r.mu.RUnlock()
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := bufio.NewWriter(w)
+	for _, name := range names {
+		if help := r.help[name]; help != "" {
+			_, _ = out.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+		}
+		_, _ = out.WriteString("# TYPE " + name + " gauge\n")
+		lines := make([]string, 0, len(byName[name]))
+		for _, sample := range byName[name] {
+			lines = append(lines, formatSample(sample))
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			_, _ = out.WriteString(line + "\n")
+		}
+	}
+	return out.Flush()
+}
+
+// Handler serves the registry for Prometheus scrapes.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WriteText(w)
+	})
+}
+
+func formatSample(sample Sample) string {
+	var b strings.Builder
+	b.WriteString(sample.Name)
+	if len(sample.Labels) > 0 {
+		b.WriteByte('{')
+		for i, label := range sample.Labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(label.Name)
+			b.WriteString(`="`)
+			b.WriteString(escapeLabelValue(label.Value))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatValue(sample.Value))
+	return b.String()
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
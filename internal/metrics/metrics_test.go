@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	registry := NewRegistry(map[string]string{"swarmcp_up": "Whether the last refresh succeeded."})
+	registry.Replace("prod", []Sample{
+		{Name: "swarmcp_up", Labels: []Label{{Name: "deployment", Value: "prod"}}, Value: 1},
+		{Name: "swarmcp_replicas", Labels: []Label{{Name: "service", Value: `we"b`}}, Value: 2.5},
+	})
+	registry.Replace("qa", []Sample{
+		{Name: "swarmcp_up", Labels: []Label{{Name: "deployment", Value: "qa"}}, Value: 0},
+	})
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	want := `# TYPE swarmcp_replicas gauge
+swarmcp_replicas{service="we\"b"} 2.5
+# HELP swarmcp_up Whether the last refresh succeeded.
+# TYPE swarmcp_up gauge
+swarmcp_up{deployment="prod"} 1
+swarmcp_up{deployment="qa"} 0
+`
+	if out.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	registry.Replace("prod", nil)
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	if strings.Contains(body, "swarmcp_replicas") || !strings.Contains(body, `swarmcp_up{deployment="qa"} 0`) {
+		t.Fatalf("expected replaced group to drop its series, got:\n%s", body)
+	}
+	if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+}
@@ -0,0 +1,250 @@
+// Package notify delivers apply events to the sinks declared in
+// project.notifications.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/state"
+)
+
+const (
+	EventPlanComputed   = "plan_computed"
+	EventApplyStarted   = "apply_started"
+	EventStackDeployed  = "stack_deployed"
+	EventStackFailed    = "stack_failed"
+	EventPrunePerformed = "prune_performed"
+	EventApplyCompleted = "apply_completed"
+	EventApplyFailed    = "apply_failed"
+	EventRollback       = "rollback"
+)
+
+// Event is the payload sent to every sink. Webhooks receive it as JSON
+// unless a template is set, and templates render against it.
+type Event struct {
+	Event      string            `json:"event"`
+	Time       string            `json:"time"`
+	Command    string            `json:"command"`
+	Project    string            `json:"project"`
+	Deployment string            `json:"deployment,omitempty"`
+	Context    string            `json:"context,omitempty"`
+	Partitions []string          `json:"partitions,omitempty"`
+	Stacks     []string          `json:"stacks,omitempty"`
+	Release    string            `json:"release,omitempty"`
+	Summary    state.PlanSummary `json:"summary"`
+	Stack      string            `json:"stack,omitempty"`
+	Output     string            `json:"output,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Message    string            `json:"message"`
+}
+
+// As returns a copy of the event with its type set to kind.
+func (e Event) As(kind string) Event {
+	e.Event = kind
+	return e
+}
+
+// Sink is one resolved notification: URL holds the webhook URL after
+// url_secret resolution and Dir the working directory of commands.
+type Sink struct {
+	Name         string
+	Notification config.Notification
+	URL          string
+	Dir          string
+}
+
+// Notifier sends events to its sinks. Delivery failures are reported to the
+// warning writer and never fail the caller. A nil Notifier sends nothing.
+type Notifier struct {
+	sinks  []Sink
+	warn   io.Writer
+	client *http.Client
+}
+
+// New returns a notifier for sinks, or nil when there are none.
+func New(sinks []Sink, warn io.Writer) *Notifier {
+	if len(sinks) == 0 {
+		return nil
+	}
+	return &Notifier{sinks: sinks, warn: warn, client: &http.Client{}}
+}
+
+// Send delivers event to every sink subscribed to its type, one after the
+// other, each bounded by the sink timeout.
+func (n *Notifier) Send(event Event) {
+	if n == nil {
+		return
+	}
+	if event.Time == "" {
+		event.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+	if event.Message == "" {
+		event.Message = Message(event)
+	}
+	for _, sink := range n.sinks {
+		if !sink.Notification.Wants(event.Event) {
+			continue
+		}
+		if err := n.deliver(sink, event); err != nil && n.warn != nil {
+			_, _ = fmt.Fprintf(n.warn, "warning: notification %s: %s: %v\n", sink.Name, event.Event, err)
+		}
+	}
+}
+
+func (n *Notifier) deliver(sink Sink, event Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sink.Notification.TimeoutDuration())
+	defer cancel()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	switch sink.Notification.Type {
+	case config.NotificationWebhook:
+		body := payload
+		if sink.Notification.Template != "" {
+			rendered, err := render(sink, event)
+			if err != nil {
+				return err
+			}
+			body = []byte(rendered)
+		}
+		return n.post(ctx, sink, body)
+	case config.NotificationSlack:
+		text := event.Message
+		if sink.Notification.Template != "" {
+			if text, err = render(sink, event); err != nil {
+				return err
+			}
+		}
+		body, err := json.Marshal(map[string]string{"text": text})
+		if err != nil {
+			return err
+		}
+		return n.post(ctx, sink, body)
+	case config.NotificationCommand:
+		message := event.Message
+		if sink.Notification.Template != "" {
+			if message, err = render(sink, event); err != nil {
+				return err
+			}
+		}
+		return runCommand(ctx, sink, event, payload, message)
+	default:
+		return fmt.Errorf("unknown notification type %q", sink.Notification.Type)
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, sink Sink, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "swarmcp")
+	for key, value := range sink.Notification.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The URL may carry a token; report the failure without it.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func runCommand(ctx context.Context, sink Sink, event Event, payload []byte, message string) error {
+	argv := sink.Notification.Command
+	command := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	command.Dir = sink.Dir
+	command.Stdin = bytes.NewReader(payload)
+	command.Env = append(os.Environ(),
+		"SWARMCP_EVENT="+event.Event,
+		"SWARMCP_PROJECT="+event.Project,
+		"SWARMCP_DEPLOYMENT="+event.Deployment,
+		"SWARMCP_STACK="+event.Stack,
+		"SWARMCP_RELEASE="+event.Release,
+		"SWARMCP_MESSAGE="+message,
+	)
+	output, err := command.CombinedOutput()
+	if err != nil {
+		if detail := strings.TrimSpace(string(output)); detail != "" {
+			return fmt.Errorf("%s: %w: %s", argv[0], err, detail)
+		}
+		return fmt.Errorf("%s: %w", argv[0], err)
+	}
+	return nil
+}
+
+func render(sink Sink, event Event) (string, error) {
+	tmpl, err := config.ParseNotificationTemplate(sink.Name, sink.Notification.Template)
+	if err != nil {
+		return "", fmt.Errorf("template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, event); err != nil {
+		return "", fmt.Errorf("template: %w", err)
+	}
+	return out.String(), nil
+}
+
+// Message returns the default one-line text of an event.
+func Message(event Event) string {
+	target := event.Project
+	if event.Deployment != "" {
+		target += "/" + event.Deployment
+	}
+	summary := event.Summary
+	stacks := fmt.Sprintf("%d", summary.StacksDeployed)
+	if len(summary.StackNames) > 0 {
+		stacks += " (" + strings.Join(summary.StackNames, ", ") + ")"
+	}
+	var text string
+	switch event.Event {
+	case EventPlanComputed:
+		text = fmt.Sprintf("plan computed: stacks %s, create networks %d configs %d secrets %d, remove networks %d configs %d secrets %d", stacks, summary.NetworksCreated, summary.ConfigsCreated, summary.SecretsCreated, summary.NetworksRemoved, summary.ConfigsRemoved, summary.SecretsRemoved)
+	case EventApplyStarted:
+		text = "apply started: stacks " + stacks
+	case EventStackDeployed:
+		text = fmt.Sprintf("stack %s deployed", event.Stack)
+	case EventStackFailed:
+		text = fmt.Sprintf("stack %s failed: %s", event.Stack, event.Error)
+	case EventPrunePerformed:
+		text = fmt.Sprintf("pruned networks %d configs %d secrets %d", summary.NetworksRemoved, summary.ConfigsRemoved, summary.SecretsRemoved)
+	case EventApplyCompleted:
+		text = "apply completed: stacks " + stacks
+		if event.Release != "" {
+			text += ", release " + event.Release
+		}
+	case EventApplyFailed:
+		text = "apply failed: " + event.Error
+	case EventRollback:
+		text = "rolled back to release " + event.Release
+		if event.Error != "" {
+			text = fmt.Sprintf("rollback to release %s failed: %s", event.Release, event.Error)
+		}
+	default:
+		text = event.Event
+	}
+	return fmt.Sprintf("swarmcp %s [%s] %s", event.Command, target, text)
+}
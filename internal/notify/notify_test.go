@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/config"
+	"github.com/cmmoran/swarmcp/internal/state"
+)
+
+type capturedRequest struct {
+	path   string
+	header http.Header
+	body   string
+}
+
+func TestNotifierSendsToSinks(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []capturedRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, capturedRequest{path: r.URL.Path, header: r.Header.Clone(), body: string(body)})
+		mu.Unlock()
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var warnings bytes.Buffer
+	notifier := New([]Sink{
+		{Name: "ci", URL: server.URL + "/ci", Notification: config.Notification{Type: config.NotificationWebhook, Headers: map[string]string{"X-Token": "abc"}}},
+		{Name: "custom", URL: server.URL + "/custom", Notification: config.Notification{Type: config.NotificationWebhook, Template: `{"failed":"{{ .Stack }}","stacks":"{{ join "," .Summary.StackNames }}"}`, Events: []string{EventStackFailed}}},
+		{Name: "chat", URL: server.URL + "/chat", Notification: config.Notification{Type: config.NotificationSlack, Events: []string{EventStackFailed}}},
+		{Name: "broken", URL: server.URL + "/broken", Notification: config.Notification{Type: config.NotificationSlack, Events: []string{EventApplyStarted}}},
+		{Name: "hook", Dir: dir, Notification: config.Notification{Type: config.NotificationCommand, Command: []string{"sh", "-c", `cat > event.json && printf '%s' "$SWARMCP_EVENT $SWARMCP_MESSAGE" > env.txt`}, Events: []string{EventStackFailed}}},
+	}, &warnings)
+
+	event := Event{Command: "apply", Project: "demo", Deployment: "prod", Summary: state.PlanSummary{StacksDeployed: 1, StackNames: []string{"app"}}}
+	notifier.Send(event.As(EventApplyStarted))
+	failed := event.As(EventStackFailed)
+	failed.Stack = "app"
+	failed.Error = "service web unhealthy"
+	failed.Output = "service web: update failed: unhealthy (rolled back)"
+	notifier.Send(failed)
+
+	byPath := map[string][]capturedRequest{}
+	for _, req := range requests {
+		byPath[req.path] = append(byPath[req.path], req)
+	}
+	if len(byPath["/ci"]) != 2 || len(byPath["/custom"]) != 1 || len(byPath["/chat"]) != 1 || len(byPath["/broken"]) != 1 {
+		t.Fatalf("unexpected deliveries: %#v", byPath)
+	}
+	var received Event
+	if err := json.Unmarshal([]byte(byPath["/ci"][1].body), &received); err != nil {
+		t.Fatalf("decode webhook body: %v", err)
+	}
+	if received.Event != EventStackFailed || received.Stack != "app" || received.Output == "" || received.Time == "" || received.Summary.StacksDeployed != 1 {
+		t.Fatalf("unexpected webhook event %#v", received)
+	}
+	if byPath["/ci"][0].header.Get("X-Token") != "abc" || byPath["/ci"][0].header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected webhook headers %v", byPath["/ci"][0].header)
+	}
+	if got := byPath["/custom"][0].body; got != `{"failed":"app","stacks":"app"}` {
+		t.Fatalf("unexpected templated body %s", got)
+	}
+	wantText := `{"text":"swarmcp apply [demo/prod] stack app failed: service web unhealthy"}`
+	if got := byPath["/chat"][0].body; got != wantText {
+		t.Fatalf("unexpected slack body %s", got)
+	}
+	if !strings.Contains(warnings.String(), "warning: notification broken: apply_started: post: unexpected status 500") {
+		t.Fatalf("expected delivery warning, got %q", warnings.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "event.json"))
+	if err != nil {
+		t.Fatalf("read command stdin: %v", err)
+	}
+	if err := json.Unmarshal(data, &received); err != nil || received.Event != EventStackFailed {
+		t.Fatalf("unexpected command stdin %s (%v)", data, err)
+	}
+	env, err := os.ReadFile(filepath.Join(dir, "env.txt"))
+	if err != nil {
+		t.Fatalf("read command env: %v", err)
+	}
+	if string(env) != "stack_failed swarmcp apply [demo/prod] stack app failed: service web unhealthy" {
+		t.Fatalf("unexpected command env %q", env)
+	}
+}
+
+func TestNilNotifierIsNoop(t *testing.T) {
+	var notifier *Notifier
+	notifier.Send(Event{Event: EventApplyStarted})
+	if New(nil, nil) != nil {
+		t.Fatalf("expected nil notifier without sinks")
+	}
+}
@@ -82,6 +82,11 @@ const (
 	LabelPartition = "swarmcp.io/partition"
 	LabelStack     = "swarmcp.io/stack"
 	LabelService   = "swarmcp.io/service"
+	LabelRelease   = "swarmcp.io/release"
+	// LabelReleaseParts is set on the first config of a release record that
+	// is split across several configs; LabelReleasePart numbers the others.
+	LabelReleaseParts = "swarmcp.io/release-parts"
+	LabelReleasePart  = "swarmcp.io/release-part"
 )
 
 func RenderProject(cfg *config.Config, store *secrets.Store, values any, partitionFilters []string, stackFilters []string, allowMissing bool, infer bool) (Summary, error) {
@@ -55,6 +55,36 @@ func RenderServiceTemplates(engine *templates.Engine, scope templates.Scope, dat
 	if rendered.Mode, err = RenderTemplateString(engine, scope, data, "mode", rendered.Mode); err != nil {
 		return config.Service{}, err
 	}
+	if rendered.User, err = RenderTemplateString(engine, scope, data, "user", rendered.User); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.Hostname, err = RenderTemplateString(engine, scope, data, "hostname", rendered.Hostname); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.StopSignal, err = RenderTemplateString(engine, scope, data, "stop_signal", rendered.StopSignal); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.StopGracePeriod, err = RenderTemplateString(engine, scope, data, "stop_grace_period", rendered.StopGracePeriod); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.Tmpfs, err = renderTemplateStrings(engine, scope, data, "tmpfs", rendered.Tmpfs); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.ExtraHosts, err = renderTemplateStrings(engine, scope, data, "extra_hosts", rendered.ExtraHosts); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.DNS, err = renderTemplateStrings(engine, scope, data, "dns", rendered.DNS); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.DNSSearch, err = renderTemplateStrings(engine, scope, data, "dns_search", rendered.DNSSearch); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.DNSOptions, err = renderTemplateStrings(engine, scope, data, "dns_opt", rendered.DNSOptions); err != nil {
+		return config.Service{}, err
+	}
+	if rendered.Sysctls, err = renderTemplateStringMap(engine, scope, data, "sysctls", rendered.Sysctls); err != nil {
+		return config.Service{}, err
+	}
 	if rendered.Command, err = renderTemplateStrings(engine, scope, data, "command", rendered.Command); err != nil {
 		return config.Service{}, err
 	}
@@ -67,6 +97,9 @@ func RenderServiceTemplates(engine *templates.Engine, scope templates.Scope, dat
 	if rendered.Placement.Constraints, err = renderTemplateStrings(engine, scope, data, "placement.constraints", rendered.Placement.Constraints); err != nil {
 		return config.Service{}, err
 	}
+	if rendered.Placement.Preferences, err = renderTemplatePlacementPreferences(engine, scope, data, rendered.Placement.Preferences); err != nil {
+		return config.Service{}, err
+	}
 	if rendered.Networks, err = renderTemplateStrings(engine, scope, data, "networks", rendered.Networks); err != nil {
 		return config.Service{}, err
 	}
@@ -94,11 +127,71 @@ func RenderServiceTemplates(engine *templates.Engine, scope templates.Scope, dat
 	if rendered.RollbackConfig, err = renderTemplateUpdatePolicy(engine, scope, data, rendered.RollbackConfig, "rollback_config"); err != nil {
 		return config.Service{}, err
 	}
+	if rendered.Jobs, err = renderTemplateJobs(engine, scope, data, rendered.Jobs); err != nil {
+		return config.Service{}, err
+	}
 	rendered.NetworkEphemeral = service.NetworkEphemeral
 
 	return rendered, nil
 }
 
+func renderTemplateJobs(engine *templates.Engine, scope templates.Scope, data TemplateData, jobs config.ServiceJobs) (config.ServiceJobs, error) {
+	var err error
+	if jobs.BeforeUpdate, err = renderTemplateJobList(engine, scope, data, "jobs."+config.JobPhaseBeforeUpdate, jobs.BeforeUpdate); err != nil {
+		return config.ServiceJobs{}, err
+	}
+	if jobs.AfterUpdate, err = renderTemplateJobList(engine, scope, data, "jobs."+config.JobPhaseAfterUpdate, jobs.AfterUpdate); err != nil {
+		return config.ServiceJobs{}, err
+	}
+	if jobs.OnRollback, err = renderTemplateJobList(engine, scope, data, "jobs."+config.JobPhaseOnRollback, jobs.OnRollback); err != nil {
+		return config.ServiceJobs{}, err
+	}
+	return jobs, nil
+}
+
+func renderTemplateJobList(engine *templates.Engine, scope templates.Scope, data TemplateData, name string, jobs []config.Job) ([]config.Job, error) {
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	rendered := make([]config.Job, 0, len(jobs))
+	for i, job := range jobs {
+		prefix := fmt.Sprintf("%s[%d]", name, i)
+		var err error
+		if job.Command, err = renderTemplateStrings(engine, scope, data, prefix+".command", job.Command); err != nil {
+			return nil, err
+		}
+		if job.Args, err = renderTemplateStrings(engine, scope, data, prefix+".args", job.Args); err != nil {
+			return nil, err
+		}
+		if job.Workdir, err = RenderTemplateString(engine, scope, data, prefix+".workdir", job.Workdir); err != nil {
+			return nil, err
+		}
+		if job.Env, err = renderTemplateStringMap(engine, scope, data, prefix+".env", job.Env); err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, job)
+	}
+	return rendered, nil
+}
+
+// RenderTemplateLogging renders effective logging settings in the service
+// scope. Logging is merged across scopes before rendering, so it is not part
+// of RenderServiceTemplates.
+func RenderTemplateLogging(engine *templates.Engine, scope templates.Scope, data TemplateData, logging *config.Logging) (*config.Logging, error) {
+	if logging == nil {
+		return nil, nil
+	}
+	driver, err := RenderTemplateString(engine, scope, data, "logging.driver", logging.Driver)
+	if err != nil {
+		return nil, err
+	}
+	options, err := renderTemplateStringMap(engine, scope, data, "logging.options", logging.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &config.Logging{Driver: strings.TrimSpace(driver), Options: options}, nil
+}
+
 func RenderTemplateString(engine *templates.Engine, scope templates.Scope, data TemplateData, name string, value string) (string, error) {
 	if value == "" {
 		return "", nil
@@ -262,6 +355,21 @@ func renderTemplatePorts(engine *templates.Engine, scope templates.Scope, data T
 	return rendered, nil
 }
 
+func renderTemplatePlacementPreferences(engine *templates.Engine, scope templates.Scope, data TemplateData, prefs []config.PlacementPreference) ([]config.PlacementPreference, error) {
+	if len(prefs) == 0 {
+		return nil, nil
+	}
+	rendered := make([]config.PlacementPreference, 0, len(prefs))
+	for i, pref := range prefs {
+		var err error
+		if pref.Spread, err = RenderTemplateString(engine, scope, data, fmt.Sprintf("placement.preferences[%d].spread", i), pref.Spread); err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, pref)
+	}
+	return rendered, nil
+}
+
 func renderTemplateConfigRefs(engine *templates.Engine, scope templates.Scope, data TemplateData, refs []config.ConfigRef) ([]config.ConfigRef, error) {
 	if len(refs) == 0 {
 		return nil, nil
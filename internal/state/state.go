@@ -27,8 +27,10 @@ type PlanSummary struct {
 	StackNames      []string `json:"stack_names,omitempty"`
 	ConfigsRemoved  int      `json:"configs_removed"`
 	SecretsRemoved  int      `json:"secrets_removed"`
+	NetworksRemoved int      `json:"networks_removed"`
 	ConfigsSkipped  int      `json:"configs_skipped"`
 	SecretsSkipped  int      `json:"secrets_skipped"`
+	NetworksSkipped int      `json:"networks_skipped"`
 	ServicesCreated int      `json:"services_created"`
 	ServicesUpdated int      `json:"services_updated"`
 }
@@ -1,6 +1,7 @@
 package swarm
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"crypto/tls"
@@ -14,20 +15,34 @@ import (
 	"path/filepath"
 
 	"github.com/cmmoran/swarmcp/internal/fsutil"
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/docker/cli/cli/connhelper"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	networktypes "github.com/docker/docker/api/types/network"
 	dockerapi "github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 var ErrNotImplemented = errors.New("swarm client not implemented")
 
+// ErrLockExists is returned by CreateLock when a lock with the same name is
+// already held.
+var ErrLockExists = errors.New("lock already exists")
+
+// LabelLock marks the swarm configs that back swarmcp locks.
+const LabelLock = "swarmcp.io/lock"
+
 type Client interface {
 	ListConfigs(ctx context.Context) ([]Config, error)
 	ListSecrets(ctx context.Context) ([]Secret, error)
 	ListServices(ctx context.Context) ([]Service, error)
+	ListServiceTasks(ctx context.Context, serviceID string) ([]Task, error)
+	ServiceLogs(ctx context.Context, serviceID string, tail int) ([]byte, error)
 	ListNetworks(ctx context.Context) ([]Network, error)
+	NetworkContainers(ctx context.Context, id string) (int, error)
 	ListNodes(ctx context.Context) ([]Node, error)
 	ConfigContent(ctx context.Context, id string) ([]byte, error)
 	CreateNetwork(ctx context.Context, spec NetworkSpec) (string, error)
@@ -36,8 +51,14 @@ type Client interface {
 	CreateSecret(ctx context.Context, spec SecretSpec) (string, error)
 	RemoveConfig(ctx context.Context, id string) error
 	RemoveSecret(ctx context.Context, id string) error
+	RemoveNetwork(ctx context.Context, id string) error
+	RemoveService(ctx context.Context, id string) error
 	UpdateService(ctx context.Context, service Service, spec dockerapi.ServiceSpec) error
+	RollbackService(ctx context.Context, service Service) error
 	UpdateNode(ctx context.Context, node Node, spec dockerapi.NodeSpec) error
+	ListLocks(ctx context.Context) ([]Lock, error)
+	CreateLock(ctx context.Context, spec LockSpec) (string, error)
+	RemoveLock(ctx context.Context, id string) error
 }
 
 func NewClient(contextName string) (Client, error) {
@@ -91,7 +112,8 @@ func NewClient(contextName string) (Client, error) {
 }
 
 type apiClient struct {
-	cli *client.Client
+	cli                 *client.Client
+	registryCredentials RegistryCredentialSource
 }
 
 func (c *apiClient) ListConfigs(ctx context.Context) ([]Config, error) {
@@ -136,17 +158,66 @@ func (c *apiClient) ListServices(ctx context.Context) ([]Service, error) {
 	out := make([]Service, 0, len(services))
 	for _, svc := range services {
 		out = append(out, Service{
-			ID:      svc.ID,
-			Name:    svc.Spec.Annotations.Name,
-			Labels:  svc.Spec.Annotations.Labels,
-			Spec:    svc.Spec,
-			Version: svc.Version.Index,
-			Status:  svc.ServiceStatus,
+			ID:           svc.ID,
+			Name:         svc.Spec.Annotations.Name,
+			Labels:       svc.Spec.Annotations.Labels,
+			Spec:         svc.Spec,
+			Version:      svc.Version.Index,
+			Status:       svc.ServiceStatus,
+			UpdateStatus: svc.UpdateStatus,
+			PreviousSpec: svc.PreviousSpec,
+			CreatedAt:    svc.CreatedAt,
 		})
 	}
 	return out, nil
 }
 
+func (c *apiClient) ListServiceTasks(ctx context.Context, serviceID string) ([]Task, error) {
+	tasks, err := c.cli.TaskList(ctx, types.TaskListOptions{Filters: filters.NewArgs(filters.Arg("service", serviceID))})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		entry := Task{
+			ID:           task.ID,
+			ServiceID:    task.ServiceID,
+			NodeID:       task.NodeID,
+			Slot:         task.Slot,
+			Spec:         task.Spec,
+			CreatedAt:    task.CreatedAt,
+			DesiredState: task.DesiredState,
+			State:        task.Status.State,
+			Message:      task.Status.Message,
+			Err:          task.Status.Err,
+		}
+		if task.Status.ContainerStatus != nil {
+			entry.ExitCode = task.Status.ContainerStatus.ExitCode
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// ServiceLogs returns the combined stdout and stderr of the service's tasks,
+// limited to the last tail lines when tail is positive.
+func (c *apiClient) ServiceLogs(ctx context.Context, serviceID string, tail int) ([]byte, error) {
+	options := container.LogsOptions{ShowStdout: true, ShowStderr: true}
+	if tail > 0 {
+		options.Tail = fmt.Sprintf("%d", tail)
+	}
+	reader, err := c.cli.ServiceLogs(ctx, serviceID, options)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var out bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, &out, reader); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
 func (c *apiClient) ListNetworks(ctx context.Context) ([]Network, error) {
 	networks, err := c.cli.NetworkList(ctx, networktypes.ListOptions{})
 	if err != nil {
@@ -155,22 +226,67 @@ func (c *apiClient) ListNetworks(ctx context.Context) ([]Network, error) {
 	out := make([]Network, 0, len(networks))
 	for _, net := range networks {
 		var subnets []string
+		var ipam []NetworkIPAM
 		for _, cfg := range net.IPAM.Config {
 			if cfg.Subnet != "" {
 				subnets = append(subnets, cfg.Subnet)
 			}
+			ipam = append(ipam, NetworkIPAM{Subnet: cfg.Subnet, Gateway: cfg.Gateway, IPRange: cfg.IPRange})
 		}
 		out = append(out, Network{
-			ID:      net.ID,
-			Name:    net.Name,
-			Driver:  net.Driver,
-			Scope:   net.Scope,
-			Subnets: subnets,
+			ID:         net.ID,
+			Name:       net.Name,
+			Driver:     net.Driver,
+			Scope:      net.Scope,
+			Subnets:    subnets,
+			Attachable: net.Attachable,
+			Internal:   net.Internal,
+			Labels:     net.Labels,
+			Options:    net.Options,
+			IPAM:       ipam,
 		})
 	}
 	return out, nil
 }
 
+// NetworkContainers counts the endpoints attached to a network. Network lists
+// never include containers, so this inspects the network verbosely: the
+// daemon reports the containers on its own node plus the service tasks of
+// other nodes it learned about through the network's gossip. A node only
+// learns about an overlay network once it has an endpoint on it, so an
+// overlay with peers but no visible endpoints is counted as in use by one
+// endpoint per peer rather than as unused.
+func (c *apiClient) NetworkContainers(ctx context.Context, id string) (int, error) {
+	net, err := c.cli.NetworkInspect(ctx, id, networktypes.InspectOptions{Verbose: true})
+	if err != nil {
+		return 0, err
+	}
+	return networkEndpoints(net), nil
+}
+
+func networkEndpoints(net networktypes.Inspect) int {
+	endpoints := make(map[string]struct{}, len(net.Containers))
+	for id, container := range net.Containers {
+		if container.EndpointID != "" {
+			id = container.EndpointID
+		}
+		endpoints[id] = struct{}{}
+	}
+	for _, service := range net.Services {
+		for _, task := range service.Tasks {
+			id := task.EndpointID
+			if id == "" {
+				id = task.Name
+			}
+			endpoints[id] = struct{}{}
+		}
+	}
+	if len(endpoints) == 0 {
+		return len(net.Peers)
+	}
+	return len(endpoints)
+}
+
 func (c *apiClient) ListNodes(ctx context.Context) ([]Node, error) {
 	nodes, err := c.cli.NodeList(ctx, types.NodeListOptions{})
 	if err != nil {
@@ -199,12 +315,24 @@ func (c *apiClient) ConfigContent(ctx context.Context, id string) ([]byte, error
 }
 
 func (c *apiClient) CreateNetwork(ctx context.Context, spec NetworkSpec) (string, error) {
-	resp, err := c.cli.NetworkCreate(ctx, spec.Name, networktypes.CreateOptions{
+	opts := networktypes.CreateOptions{
 		Driver:     spec.Driver,
 		Attachable: spec.Attachable,
 		Internal:   spec.Internal,
 		Labels:     spec.Labels,
-	})
+		Options:    spec.Options,
+	}
+	if len(spec.IPAM) > 0 {
+		opts.IPAM = &networktypes.IPAM{Driver: "default"}
+		for _, entry := range spec.IPAM {
+			opts.IPAM.Config = append(opts.IPAM.Config, networktypes.IPAMConfig{
+				Subnet:  entry.Subnet,
+				Gateway: entry.Gateway,
+				IPRange: entry.IPRange,
+			})
+		}
+	}
+	resp, err := c.cli.NetworkCreate(ctx, spec.Name, opts)
 	if err != nil {
 		return "", err
 	}
@@ -212,7 +340,14 @@ func (c *apiClient) CreateNetwork(ctx context.Context, spec NetworkSpec) (string
 }
 
 func (c *apiClient) CreateService(ctx context.Context, spec dockerapi.ServiceSpec) (string, error) {
-	resp, err := c.cli.ServiceCreate(ctx, spec, types.ServiceCreateOptions{})
+	auth, err := registryAuthForSpec(spec, c.registryCredentials)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.cli.ServiceCreate(ctx, spec, types.ServiceCreateOptions{
+		EncodedRegistryAuth: auth,
+		QueryRegistry:       queryRegistry(spec),
+	})
 	if err != nil {
 		return "", err
 	}
@@ -255,8 +390,99 @@ func (c *apiClient) RemoveSecret(ctx context.Context, id string) error {
 	return c.cli.SecretRemove(ctx, id)
 }
 
+func (c *apiClient) RemoveNetwork(ctx context.Context, id string) error {
+	return c.cli.NetworkRemove(ctx, id)
+}
+
+func (c *apiClient) ListLocks(ctx context.Context) ([]Lock, error) {
+	configs, err := c.cli.ConfigList(ctx, types.ConfigListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", LabelLock)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Lock, 0, len(configs))
+	for _, cfg := range configs {
+		data := cfg.Spec.Data
+		if len(data) == 0 {
+			inspected, _, err := c.cli.ConfigInspectWithRaw(ctx, cfg.ID)
+			if err != nil {
+				if cerrdefs.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			data = inspected.Spec.Data
+		}
+		out = append(out, Lock{
+			ID:        cfg.ID,
+			Name:      cfg.Spec.Annotations.Name,
+			Labels:    cfg.Spec.Annotations.Labels,
+			Data:      data,
+			CreatedAt: cfg.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+func (c *apiClient) CreateLock(ctx context.Context, spec LockSpec) (string, error) {
+	labels := make(map[string]string, len(spec.Labels)+1)
+	for key, value := range spec.Labels {
+		labels[key] = value
+	}
+	labels[LabelLock] = "true"
+	resp, err := c.cli.ConfigCreate(ctx, dockerapi.ConfigSpec{
+		Annotations: dockerapi.Annotations{
+			Name:   spec.Name,
+			Labels: labels,
+		},
+		Data: spec.Data,
+	})
+	if err != nil {
+		if cerrdefs.IsConflict(err) || cerrdefs.IsAlreadyExists(err) {
+			return "", fmt.Errorf("%w: %s", ErrLockExists, spec.Name)
+		}
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (c *apiClient) RemoveLock(ctx context.Context, id string) error {
+	if err := c.cli.ConfigRemove(ctx, id); err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *apiClient) RemoveService(ctx context.Context, id string) error {
+	return c.cli.ServiceRemove(ctx, id)
+}
+
 func (c *apiClient) UpdateService(ctx context.Context, service Service, spec dockerapi.ServiceSpec) error {
-	_, err := c.cli.ServiceUpdate(ctx, service.ID, dockerapi.Version{Index: service.Version}, spec, types.ServiceUpdateOptions{})
+	auth, err := registryAuthForSpec(spec, c.registryCredentials)
+	if err != nil {
+		return err
+	}
+	_, err = c.cli.ServiceUpdate(ctx, service.ID, dockerapi.Version{Index: service.Version}, spec, types.ServiceUpdateOptions{
+		EncodedRegistryAuth: auth,
+		QueryRegistry:       queryRegistry(spec),
+	})
+	return err
+}
+
+// queryRegistry reports whether the docker client should resolve the image
+// from the registry. Resolution replaces placement platforms with those of
+// the image manifest, so it is skipped when platforms are declared.
+func queryRegistry(spec dockerapi.ServiceSpec) bool {
+	placement := spec.TaskTemplate.Placement
+	return placement == nil || len(placement.Platforms) == 0
+}
+
+// RollbackService asks the manager to restore the service's previous spec.
+func (c *apiClient) RollbackService(ctx context.Context, service Service) error {
+	_, err := c.cli.ServiceUpdate(ctx, service.ID, dockerapi.Version{Index: service.Version}, service.Spec, types.ServiceUpdateOptions{
+		Rollback: "previous",
+	})
 	return err
 }
 
@@ -3,9 +3,14 @@ package swarm
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	networktypes "github.com/docker/docker/api/types/network"
+	dockerapi "github.com/docker/docker/api/types/swarm"
 )
 
 func TestDockerConfigDirUsesEnvAndHome(t *testing.T) {
@@ -72,3 +77,76 @@ func TestLoadTLSConfig(t *testing.T) {
 		t.Fatalf("expected insecure TLS config when skipVerify is true: %#v", cfg)
 	}
 }
+
+func TestRegistryAuthForImageReadsDockerConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	data := `{"auths":{"registry.example.com":{"auth":"dXNlcjpzZWNyZXQ="},"https://index.docker.io/v1/":{"auth":"aHViOmh1YnBhc3M="}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	auth, ok := RegistryAuthForImage("registry.example.com/team/api:1.0")
+	if !ok || auth.Username != "user" || auth.Password != "secret" || auth.ServerAddress != "registry.example.com" {
+		t.Fatalf("unexpected private registry auth: %+v ok=%v", auth, ok)
+	}
+	auth, ok = RegistryAuthForImage("nginx:latest")
+	if !ok || auth.Username != "hub" || auth.ServerAddress != dockerHubAuthKey {
+		t.Fatalf("unexpected docker hub auth: %+v ok=%v", auth, ok)
+	}
+	if _, ok := RegistryAuthForImage("ghcr.io/org/app:1"); ok {
+		t.Fatalf("expected no auth for unknown registry")
+	}
+}
+
+func TestRegistryCredentialsTakePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	data := `{"auths":{"registry.example.com":{"auth":"dXNlcjpzZWNyZXQ="},"ghcr.io":{"auth":"dXNlcjpzZWNyZXQ="}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(data), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	creds := RegistryCredentials{
+		"registry.example.com": {Username: "ci", Password: "token"},
+		"index.docker.io":      {Username: "hub-ci", Password: "hub-token"},
+	}
+
+	auth, ok := creds.AuthForImage("registry.example.com/team/api:1.0")
+	if !ok || auth.Username != "ci" || auth.ServerAddress != "registry.example.com" {
+		t.Fatalf("unexpected configured auth: %+v ok=%v", auth, ok)
+	}
+	auth, ok = creds.AuthForImage("nginx:latest")
+	if !ok || auth.Username != "hub-ci" || auth.ServerAddress != dockerHubAuthKey {
+		t.Fatalf("unexpected docker hub auth: %+v ok=%v", auth, ok)
+	}
+	auth, ok = creds.AuthForImage("ghcr.io/org/app:1")
+	if !ok || auth.Username != "user" {
+		t.Fatalf("expected docker config fallback, got %+v ok=%v", auth, ok)
+	}
+
+	encoded, err := registryAuthForSpec(dockerapi.ServiceSpec{TaskTemplate: dockerapi.TaskSpec{ContainerSpec: &dockerapi.ContainerSpec{Image: "registry.example.com/team/api:1.0"}}}, func() (RegistryCredentials, error) {
+		return nil, errors.New("vault sealed")
+	})
+	if err == nil || encoded != "" || !strings.Contains(err.Error(), "vault sealed") {
+		t.Fatalf("expected credential source error, got %q, %v", encoded, err)
+	}
+}
+
+func TestNetworkEndpointsCountsRemoteTasksAndPeers(t *testing.T) {
+	net := networktypes.Inspect{
+		Containers: map[string]networktypes.EndpointResource{"c1": {Name: "app.1", EndpointID: "e1"}},
+		Services: map[string]networktypes.ServiceInfo{
+			"app": {Tasks: []networktypes.Task{{Name: "app.1", EndpointID: "e1"}, {Name: "app.2", EndpointID: "e2"}}},
+		},
+	}
+	if got := networkEndpoints(net); got != 2 {
+		t.Fatalf("expected local and remote endpoints counted once, got %d", got)
+	}
+	peered := networktypes.Inspect{Peers: []networktypes.PeerInfo{{Name: "worker-1", IP: "10.0.0.2"}}}
+	if got := networkEndpoints(peered); got != 1 {
+		t.Fatalf("expected a network with peers to count as in use, got %d", got)
+	}
+	if got := networkEndpoints(networktypes.Inspect{}); got != 0 {
+		t.Fatalf("expected an unattached network to be unused, got %d", got)
+	}
+}
@@ -0,0 +1,390 @@
+package swarm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+var (
+	// ErrManifestNotFound reports a tag or digest the registry does not have.
+	ErrManifestNotFound = errors.New("manifest not found")
+	// ErrRegistryUnauthorized reports missing or rejected registry credentials.
+	ErrRegistryUnauthorized = errors.New("registry authorization failed")
+)
+
+// ImageManifest describes the manifest an image reference points at.
+// Platforms lists "os/arch" or "os/arch/variant" for every image the
+// manifest (or index) provides.
+type ImageManifest struct {
+	Digest    string
+	Platforms []string
+}
+
+// ImageResolver resolves image tags to manifest digests through the OCI
+// distribution API, authenticating with Credentials or the local Docker
+// config.
+type ImageResolver struct {
+	HTTPClient  *http.Client
+	Credentials RegistryCredentialSource
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+func NewImageResolver() *ImageResolver {
+	return &ImageResolver{HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// ResolveDigest returns image pinned to the digest its tag currently points
+// at, as "<name>:<tag>@sha256:...". Images that already carry a digest are
+// returned unchanged.
+func (r *ImageResolver) ResolveDigest(ctx context.Context, image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(strings.TrimSpace(image))
+	if err != nil {
+		return "", fmt.Errorf("parse image %q: %w", image, err)
+	}
+	if _, ok := named.(reference.Canonical); ok {
+		return image, nil
+	}
+	tagged := reference.TagNameOnly(named).(reference.NamedTagged)
+	key := tagged.String()
+	r.mu.Lock()
+	pinned, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok {
+		return pinned, nil
+	}
+	digest, err := r.manifestDigest(ctx, tagged)
+	if err != nil {
+		return "", fmt.Errorf("resolve image %q: %w", image, err)
+	}
+	pinned = reference.FamiliarString(tagged) + "@" + digest
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]string)
+	}
+	r.cache[key] = pinned
+	r.mu.Unlock()
+	return pinned, nil
+}
+
+// InspectImage fetches the manifest of image and reports its digest and the
+// platforms it is published for. Errors wrap ErrManifestNotFound or
+// ErrRegistryUnauthorized when the registry rejects the lookup.
+func (r *ImageResolver) InspectImage(ctx context.Context, image string) (ImageManifest, error) {
+	named, err := reference.ParseNormalizedNamed(strings.TrimSpace(image))
+	if err != nil {
+		return ImageManifest{}, fmt.Errorf("parse image %q: %w", image, err)
+	}
+	named = reference.TagNameOnly(named)
+	var target string
+	if canonical, ok := named.(reference.Canonical); ok {
+		target = canonical.Digest().String()
+	} else {
+		target = named.(reference.NamedTagged).Tag()
+	}
+	base := registryEndpoint(reference.Domain(named)) + "/v2/" + reference.Path(named)
+	resp, err := r.fetchManifest(ctx, named, http.MethodGet, base+"/manifests/"+target)
+	if err != nil {
+		return ImageManifest{}, fmt.Errorf("inspect image %q: %w", image, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return ImageManifest{}, fmt.Errorf("inspect image %q: %w", image, registryStatusError(resp))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ImageManifest{}, fmt.Errorf("inspect image %q: %w", image, err)
+	}
+	manifest := ImageManifest{Digest: resp.Header.Get("Docker-Content-Digest")}
+	if manifest.Digest == "" {
+		sum := sha256.Sum256(body)
+		manifest.Digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	var doc struct {
+		Manifests []struct {
+			Platform *struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+				Variant      string `json:"variant"`
+			} `json:"platform"`
+		} `json:"manifests"`
+		Config *struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return ImageManifest{}, fmt.Errorf("inspect image %q: parse manifest: %w", image, err)
+	}
+	for _, entry := range doc.Manifests {
+		// Attestation manifests are listed as unknown/unknown.
+		if entry.Platform == nil || entry.Platform.OS == "" || entry.Platform.OS == "unknown" {
+			continue
+		}
+		manifest.Platforms = append(manifest.Platforms, formatImagePlatform(entry.Platform.OS, entry.Platform.Architecture, entry.Platform.Variant))
+	}
+	if len(doc.Manifests) == 0 && doc.Config != nil && doc.Config.Digest != "" {
+		platform, err := r.configPlatform(ctx, named, base+"/blobs/"+doc.Config.Digest)
+		if err != nil {
+			return ImageManifest{}, fmt.Errorf("inspect image %q: %w", image, err)
+		}
+		if platform != "" {
+			manifest.Platforms = append(manifest.Platforms, platform)
+		}
+	}
+	return manifest, nil
+}
+
+// configPlatform reads the platform of a single-platform image from its
+// config blob.
+func (r *ImageResolver) configPlatform(ctx context.Context, ref reference.Named, endpoint string) (string, error) {
+	resp, err := r.fetchManifest(ctx, ref, http.MethodGet, endpoint)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", registryStatusError(resp)
+	}
+	var config struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return "", fmt.Errorf("parse image config: %w", err)
+	}
+	if config.OS == "" {
+		return "", nil
+	}
+	return formatImagePlatform(config.OS, config.Architecture, config.Variant), nil
+}
+
+func formatImagePlatform(os string, arch string, variant string) string {
+	platform := os + "/" + arch
+	if variant != "" {
+		platform += "/" + variant
+	}
+	return platform
+}
+
+func (r *ImageResolver) manifestDigest(ctx context.Context, ref reference.NamedTagged) (string, error) {
+	endpoint := registryEndpoint(reference.Domain(ref)) + "/v2/" + reference.Path(ref) + "/manifests/" + ref.Tag()
+	resp, err := r.fetchManifest(ctx, ref, http.MethodHead, endpoint)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" && resp.StatusCode == http.StatusOK {
+		return digest, nil
+	}
+	// Some registries omit the digest header on HEAD; hash the manifest.
+	resp, err = r.fetchManifest(ctx, ref, http.MethodGet, endpoint)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", registryStatusError(resp)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// fetchManifest requests the manifest, answering a 401 challenge with the
+// Docker config credentials for the registry.
+func (r *ImageResolver) fetchManifest(ctx context.Context, ref reference.Named, method string, endpoint string) (*http.Response, error) {
+	resp, err := r.do(ctx, method, endpoint, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	_ = resp.Body.Close()
+	authorization, err := r.authorize(ctx, ref, challenge)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = r.do(ctx, method, endpoint, authorization)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, registryStatusError(resp)
+	}
+	return resp, nil
+}
+
+func (r *ImageResolver) do(ctx context.Context, method string, endpoint string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil, ErrManifestNotFound
+	}
+	return resp, nil
+}
+
+func (r *ImageResolver) authorize(ctx context.Context, ref reference.Named, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	var creds RegistryCredentials
+	if r.Credentials != nil {
+		resolved, err := r.Credentials()
+		if err != nil {
+			return "", fmt.Errorf("registry credentials: %w", err)
+		}
+		creds = resolved
+	}
+	auth, hasAuth := creds.AuthForImage(ref.String())
+	switch scheme {
+	case "basic":
+		if !hasAuth || auth.Username == "" {
+			return "", fmt.Errorf("%w: registry %s requires credentials", ErrRegistryUnauthorized, reference.Domain(ref))
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(auth.Username, auth.Password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		realm := params["realm"]
+		if realm == "" {
+			return "", fmt.Errorf("registry %s: bearer challenge without realm", reference.Domain(ref))
+		}
+		query := url.Values{}
+		if service := params["service"]; service != "" {
+			query.Set("service", service)
+		}
+		scope := params["scope"]
+		if scope == "" {
+			scope = "repository:" + reference.Path(ref) + ":pull"
+		}
+		query.Set("scope", scope)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+query.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		if hasAuth && auth.Username != "" {
+			req.SetBasicAuth(auth.Username, auth.Password)
+		}
+		resp, err := r.HTTPClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("registry token: %w", registryStatusError(resp))
+		}
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+			return "", fmt.Errorf("registry token: %w", err)
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		return "Bearer " + token.Token, nil
+	}
+	return "", fmt.Errorf("registry %s: unsupported auth challenge %q", reference.Domain(ref), challenge)
+}
+
+// parseChallenge splits a WWW-Authenticate header into its lower-cased
+// scheme and parameters.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+	for rest != "" {
+		var part string
+		rest = strings.TrimLeft(rest, " ,")
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				part, rest = value[1:], ""
+			} else {
+				part, rest = value[1:end+1], value[end+2:]
+			}
+		} else {
+			part, rest, _ = strings.Cut(value, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = part
+	}
+	return strings.ToLower(scheme), params
+}
+
+// registryEndpoint returns the base URL of a registry. Loopback registries
+// are reached over plain HTTP, as the Docker daemon allows by default.
+func registryEndpoint(domain string) string {
+	if domain == "docker.io" {
+		return "https://registry-1.docker.io"
+	}
+	host := domain
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return "http://" + domain
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return "http://" + domain
+	}
+	return "https://" + domain
+}
+
+func registryStatusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	message := strings.TrimSpace(string(body))
+	var err error
+	if message == "" {
+		err = fmt.Errorf("registry returned %s", resp.Status)
+	} else {
+		err = fmt.Errorf("registry returned %s: %s", resp.Status, message)
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: %w", ErrRegistryUnauthorized, err)
+	}
+	return err
+}
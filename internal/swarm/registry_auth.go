@@ -0,0 +1,211 @@
+package swarm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+type dockerConfigFile struct {
+	Auths       map[string]dockerAuthEntry `json:"auths,omitempty"`
+	CredsStore  string                     `json:"credsStore,omitempty"`
+	CredHelpers map[string]string          `json:"credHelpers,omitempty"`
+}
+
+type dockerAuthEntry struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+type credentialHelperOutput struct {
+	Username string `json:"Username"`
+	Secret   string `json:"Secret"`
+}
+
+// RegistryCredentials maps registry hosts, as they appear in image
+// references ("docker.io" for Docker Hub), to pull credentials that take
+// precedence over the local Docker config.
+type RegistryCredentials map[string]registry.AuthConfig
+
+// RegistryCredentialSource supplies RegistryCredentials on first use, so
+// commands that never pull images do not resolve them.
+type RegistryCredentialSource func() (RegistryCredentials, error)
+
+// AuthForImage returns the configured credentials for the registry of
+// image, falling back to the local Docker config.
+func (c RegistryCredentials) AuthForImage(image string) (registry.AuthConfig, bool) {
+	host := registryHost(image)
+	for key, auth := range c {
+		if host != "" && normalizeRegistryKey(key) == host {
+			if auth.ServerAddress == "" {
+				auth.ServerAddress = registryServerAddress(host)
+			}
+			return auth, true
+		}
+	}
+	return RegistryAuthForImage(image)
+}
+
+// UseRegistryCredentials makes client authenticate service image pulls with
+// the credentials from source ahead of the local Docker config. Clients that
+// do not talk to the Docker API are left unchanged.
+func UseRegistryCredentials(client Client, source RegistryCredentialSource) {
+	if c, ok := client.(*apiClient); ok {
+		c.registryCredentials = source
+	}
+}
+
+func registryAuthForSpec(spec dockerapi.ServiceSpec, source RegistryCredentialSource) (string, error) {
+	if spec.TaskTemplate.ContainerSpec == nil {
+		return "", nil
+	}
+	var creds RegistryCredentials
+	if source != nil {
+		resolved, err := source()
+		if err != nil {
+			return "", fmt.Errorf("registry credentials: %w", err)
+		}
+		creds = resolved
+	}
+	auth, ok := creds.AuthForImage(spec.TaskTemplate.ContainerSpec.Image)
+	if !ok {
+		return "", nil
+	}
+	encoded, err := registry.EncodeAuthConfig(auth)
+	if err != nil {
+		return "", nil
+	}
+	return encoded, nil
+}
+
+func RegistryAuthForImage(image string) (registry.AuthConfig, bool) {
+	host := registryHost(image)
+	if host == "" {
+		return registry.AuthConfig{}, false
+	}
+	cfg, ok := loadDockerConfigFile(filepath.Join(dockerConfigDir(), "config.json"))
+	if !ok {
+		return registry.AuthConfig{}, false
+	}
+	serverAddress := registryServerAddress(host)
+	if helper := cfg.CredHelpers[host]; helper != "" {
+		return credentialHelperAuth(helper, serverAddress)
+	}
+	for key, entry := range cfg.Auths {
+		if normalizeRegistryKey(key) != host {
+			continue
+		}
+		if auth, ok := authFromEntry(entry, serverAddress); ok {
+			return auth, true
+		}
+	}
+	if cfg.CredsStore != "" {
+		return credentialHelperAuth(cfg.CredsStore, serverAddress)
+	}
+	return registry.AuthConfig{}, false
+}
+
+func registryServerAddress(host string) string {
+	if host == "docker.io" {
+		return dockerHubAuthKey
+	}
+	return host
+}
+
+func registryHost(image string) string {
+	image = strings.TrimSpace(image)
+	if image == "" {
+		return ""
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ""
+	}
+	return reference.Domain(named)
+}
+
+func normalizeRegistryKey(key string) string {
+	key = strings.TrimSpace(key)
+	key = strings.TrimPrefix(key, "https://")
+	key = strings.TrimPrefix(key, "http://")
+	if idx := strings.Index(key, "/"); idx >= 0 {
+		key = key[:idx]
+	}
+	switch key {
+	case "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return key
+}
+
+func loadDockerConfigFile(path string) (dockerConfigFile, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return dockerConfigFile{}, false
+	}
+	var cfg dockerConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return dockerConfigFile{}, false
+	}
+	return cfg, true
+}
+
+func authFromEntry(entry dockerAuthEntry, serverAddress string) (registry.AuthConfig, bool) {
+	auth := registry.AuthConfig{
+		Username:      entry.Username,
+		Password:      entry.Password,
+		IdentityToken: entry.IdentityToken,
+		ServerAddress: serverAddress,
+	}
+	if entry.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return registry.AuthConfig{}, false
+		}
+		user, pass, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return registry.AuthConfig{}, false
+		}
+		auth.Username = user
+		auth.Password = pass
+	}
+	if auth.Username == "" && auth.IdentityToken == "" {
+		return registry.AuthConfig{}, false
+	}
+	return auth, true
+}
+
+func credentialHelperAuth(helper string, serverAddress string) (registry.AuthConfig, bool) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverAddress)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return registry.AuthConfig{}, false
+	}
+	var out credentialHelperOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return registry.AuthConfig{}, false
+	}
+	auth := registry.AuthConfig{ServerAddress: serverAddress}
+	if out.Username == "<token>" {
+		auth.IdentityToken = out.Secret
+	} else {
+		auth.Username = out.Username
+		auth.Password = out.Secret
+	}
+	return auth, true
+}
@@ -0,0 +1,145 @@
+package swarm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+var testManifests = map[string]string{
+	"1.0":   `{"schemaVersion":2,"config":{"digest":"sha256:c0ff33"}}`,
+	"multi": `{"schemaVersion":2,"manifests":[{"platform":{"os":"linux","architecture":"amd64"}},{"platform":{"os":"linux","architecture":"arm64","variant":"v8"}},{"platform":{"os":"unknown","architecture":"unknown"}}]}`,
+}
+
+// newTestRegistry serves team/api:1.0 (linux/amd64) and team/api:multi
+// behind bearer token auth that only accepts user:secret.
+func newTestRegistry(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+	manifestCalls := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "user" || pass != "secret" || r.URL.Query().Get("scope") != "repository:team/api:pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"t0k3n"}`))
+		case strings.HasPrefix(r.URL.Path, "/v2/team/api/"):
+			if r.Header.Get("Authorization") != "Bearer t0k3n" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test-registry"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Path == "/v2/team/api/blobs/sha256:c0ff33" {
+				_, _ = w.Write([]byte(`{"os":"linux","architecture":"amd64"}`))
+				return
+			}
+			manifestCalls++
+			manifest, ok := testManifests[strings.TrimPrefix(r.URL.Path, "/v2/team/api/manifests/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", testDigest)
+			_, _ = w.Write([]byte(manifest))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &manifestCalls
+}
+
+func TestImageResolverResolvesTagWithDockerConfigCredentials(t *testing.T) {
+	server, calls := newTestRegistry(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	auth := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"auths":{"`+host+`":{"auth":"`+auth+`"}}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	resolver := NewImageResolver()
+	pinned, err := resolver.ResolveDigest(context.Background(), host+"/team/api:1.0")
+	if err != nil {
+		t.Fatalf("ResolveDigest: %v", err)
+	}
+	if pinned != host+"/team/api:1.0@"+testDigest {
+		t.Fatalf("unexpected pinned image %q", pinned)
+	}
+	if _, err := resolver.ResolveDigest(context.Background(), host+"/team/api:1.0"); err != nil || *calls != 1 {
+		t.Fatalf("expected cached resolution, calls=%d err=%v", *calls, err)
+	}
+	if _, err := resolver.ResolveDigest(context.Background(), host+"/team/api:2.0"); err == nil || !strings.Contains(err.Error(), "manifest not found") {
+		t.Fatalf("expected missing tag error, got %v", err)
+	}
+	already := "nginx:alpine@" + testDigest
+	if got, err := resolver.ResolveDigest(context.Background(), already); err != nil || got != already {
+		t.Fatalf("expected pinned image unchanged, got %q, %v", got, err)
+	}
+}
+
+func TestImageResolverRequiresCredentials(t *testing.T) {
+	server, _ := newTestRegistry(t)
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	_, err := NewImageResolver().ResolveDigest(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/team/api:1.0")
+	if err == nil || !strings.Contains(err.Error(), "401") || !errors.Is(err, ErrRegistryUnauthorized) {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+}
+
+func TestImageResolverInspectImagePlatforms(t *testing.T) {
+	server, _ := newTestRegistry(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	auth := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"auths":{"`+host+`":{"auth":"`+auth+`"}}}`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	resolver := NewImageResolver()
+	ctx := context.Background()
+
+	multi, err := resolver.InspectImage(ctx, host+"/team/api:multi")
+	if err != nil {
+		t.Fatalf("InspectImage multi: %v", err)
+	}
+	if multi.Digest != testDigest || !slices.Equal(multi.Platforms, []string{"linux/amd64", "linux/arm64/v8"}) {
+		t.Fatalf("unexpected index manifest %#v", multi)
+	}
+	single, err := resolver.InspectImage(ctx, host+"/team/api:1.0")
+	if err != nil {
+		t.Fatalf("InspectImage single: %v", err)
+	}
+	if !slices.Equal(single.Platforms, []string{"linux/amd64"}) {
+		t.Fatalf("unexpected single manifest platforms %#v", single.Platforms)
+	}
+	if _, err := resolver.InspectImage(ctx, host+"/team/api:2.0"); !errors.Is(err, ErrManifestNotFound) {
+		t.Fatalf("expected missing tag error, got %v", err)
+	}
+}
+
+func TestImageResolverUsesConfiguredCredentials(t *testing.T) {
+	server, _ := newTestRegistry(t)
+	host := strings.TrimPrefix(server.URL, "http://")
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	resolver := NewImageResolver()
+	resolver.Credentials = func() (RegistryCredentials, error) {
+		return RegistryCredentials{host: {Username: "user", Password: "secret"}}, nil
+	}
+	if _, err := resolver.ResolveDigest(context.Background(), host+"/team/api:1.0"); err != nil {
+		t.Fatalf("ResolveDigest: %v", err)
+	}
+}
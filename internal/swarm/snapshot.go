@@ -0,0 +1,235 @@
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+const SnapshotAPIVersion = "swarmcp.io/snapshot/v1"
+
+// ErrSnapshotReadOnly is returned by every mutating call of a snapshot-backed
+// client.
+var ErrSnapshotReadOnly = errors.New("swarm snapshot is read-only")
+
+// Snapshot is a point-in-time capture of the cluster state swarmcp reads:
+// configs with content, secret metadata, services with their tasks, networks
+// and nodes.
+type Snapshot struct {
+	APIVersion string            `json:"api_version"`
+	CapturedAt time.Time         `json:"captured_at"`
+	Context    string            `json:"context,omitempty"`
+	Configs    []SnapshotConfig  `json:"configs"`
+	Secrets    []Secret          `json:"secrets"`
+	Services   []Service         `json:"services"`
+	Tasks      map[string][]Task `json:"tasks,omitempty"`
+	Networks   []Network         `json:"networks"`
+	Nodes      []Node            `json:"nodes"`
+}
+
+type SnapshotConfig struct {
+	Config
+	Data []byte `json:"data,omitempty"`
+}
+
+// CaptureSnapshot reads the cluster through client. Service logs are not
+// captured.
+func CaptureSnapshot(ctx context.Context, client Client, contextName string) (Snapshot, error) {
+	snap := Snapshot{
+		APIVersion: SnapshotAPIVersion,
+		CapturedAt: time.Now().UTC(),
+		Context:    contextName,
+	}
+	configs, err := client.ListConfigs(ctx)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("list configs: %w", err)
+	}
+	for _, cfg := range configs {
+		data, err := client.ConfigContent(ctx, cfg.ID)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("read config %s: %w", cfg.Name, err)
+		}
+		snap.Configs = append(snap.Configs, SnapshotConfig{Config: cfg, Data: data})
+	}
+	if snap.Secrets, err = client.ListSecrets(ctx); err != nil {
+		return Snapshot{}, fmt.Errorf("list secrets: %w", err)
+	}
+	if snap.Services, err = client.ListServices(ctx); err != nil {
+		return Snapshot{}, fmt.Errorf("list services: %w", err)
+	}
+	for _, svc := range snap.Services {
+		tasks, err := client.ListServiceTasks(ctx, svc.ID)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("list tasks of %s: %w", svc.Name, err)
+		}
+		if len(tasks) == 0 {
+			continue
+		}
+		if snap.Tasks == nil {
+			snap.Tasks = make(map[string][]Task)
+		}
+		snap.Tasks[svc.ID] = tasks
+	}
+	if snap.Networks, err = client.ListNetworks(ctx); err != nil {
+		return Snapshot{}, fmt.Errorf("list networks: %w", err)
+	}
+	for i, net := range snap.Networks {
+		if snap.Networks[i].Containers, err = client.NetworkContainers(ctx, net.ID); err != nil {
+			return Snapshot{}, fmt.Errorf("inspect network %s: %w", net.Name, err)
+		}
+	}
+	if snap.Nodes, err = client.ListNodes(ctx); err != nil {
+		return Snapshot{}, fmt.Errorf("list nodes: %w", err)
+	}
+	return snap, nil
+}
+
+func WriteSnapshot(path string, snap Snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+func ReadSnapshot(path string) (Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, fmt.Errorf("parse snapshot %s: %w", path, err)
+	}
+	if snap.APIVersion != SnapshotAPIVersion {
+		return Snapshot{}, fmt.Errorf("snapshot %s: unsupported api_version %q (expected %q)", path, snap.APIVersion, SnapshotAPIVersion)
+	}
+	return snap, nil
+}
+
+// NewSnapshotClient returns a read-only Client backed by snap.
+func NewSnapshotClient(snap Snapshot) Client {
+	return &snapshotClient{snap: snap}
+}
+
+type snapshotClient struct {
+	snap Snapshot
+}
+
+func (c *snapshotClient) ListConfigs(ctx context.Context) ([]Config, error) {
+	out := make([]Config, 0, len(c.snap.Configs))
+	for _, cfg := range c.snap.Configs {
+		out = append(out, cfg.Config)
+	}
+	return out, nil
+}
+
+func (c *snapshotClient) ListSecrets(ctx context.Context) ([]Secret, error) {
+	return append([]Secret(nil), c.snap.Secrets...), nil
+}
+
+func (c *snapshotClient) ListServices(ctx context.Context) ([]Service, error) {
+	return append([]Service(nil), c.snap.Services...), nil
+}
+
+func (c *snapshotClient) ListServiceTasks(ctx context.Context, serviceID string) ([]Task, error) {
+	return append([]Task(nil), c.snap.Tasks[serviceID]...), nil
+}
+
+func (c *snapshotClient) ServiceLogs(ctx context.Context, serviceID string, tail int) ([]byte, error) {
+	return nil, fmt.Errorf("service logs are not captured in snapshots")
+}
+
+func (c *snapshotClient) ListNetworks(ctx context.Context) ([]Network, error) {
+	return append([]Network(nil), c.snap.Networks...), nil
+}
+
+func (c *snapshotClient) NetworkContainers(ctx context.Context, id string) (int, error) {
+	for _, net := range c.snap.Networks {
+		if net.ID == id || net.Name == id {
+			return net.Containers, nil
+		}
+	}
+	return 0, fmt.Errorf("network %q not found in snapshot", id)
+}
+
+func (c *snapshotClient) ListNodes(ctx context.Context) ([]Node, error) {
+	return append([]Node(nil), c.snap.Nodes...), nil
+}
+
+func (c *snapshotClient) ConfigContent(ctx context.Context, id string) ([]byte, error) {
+	for _, cfg := range c.snap.Configs {
+		if cfg.ID == id || cfg.Name == id {
+			return cfg.Data, nil
+		}
+	}
+	return nil, fmt.Errorf("config %q not found in snapshot", id)
+}
+
+func (c *snapshotClient) ListLocks(ctx context.Context) ([]Lock, error) {
+	var out []Lock
+	for _, cfg := range c.snap.Configs {
+		if cfg.Labels[LabelLock] == "" {
+			continue
+		}
+		out = append(out, Lock{ID: cfg.ID, Name: cfg.Name, Labels: cfg.Labels, Data: cfg.Data, CreatedAt: cfg.CreatedAt})
+	}
+	return out, nil
+}
+
+func (c *snapshotClient) CreateNetwork(ctx context.Context, spec NetworkSpec) (string, error) {
+	return "", ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) CreateService(ctx context.Context, spec dockerapi.ServiceSpec) (string, error) {
+	return "", ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) CreateConfig(ctx context.Context, spec ConfigSpec) (string, error) {
+	return "", ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) CreateSecret(ctx context.Context, spec SecretSpec) (string, error) {
+	return "", ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) CreateLock(ctx context.Context, spec LockSpec) (string, error) {
+	return "", ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) RemoveConfig(ctx context.Context, id string) error {
+	return ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) RemoveSecret(ctx context.Context, id string) error {
+	return ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) RemoveNetwork(ctx context.Context, id string) error {
+	return ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) RemoveService(ctx context.Context, id string) error {
+	return ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) RemoveLock(ctx context.Context, id string) error {
+	return ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) UpdateService(ctx context.Context, service Service, spec dockerapi.ServiceSpec) error {
+	return ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) RollbackService(ctx context.Context, service Service) error {
+	return ErrSnapshotReadOnly
+}
+
+func (c *snapshotClient) UpdateNode(ctx context.Context, node Node, spec dockerapi.NodeSpec) error {
+	return ErrSnapshotReadOnly
+}
@@ -0,0 +1,73 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	source := NewSnapshotClient(Snapshot{
+		Configs: []SnapshotConfig{
+			{Config: Config{ID: "c1", Name: "app.conf", Labels: map[string]string{"swarmcp.io/managed": "true"}, CreatedAt: time.Unix(100, 0).UTC()}, Data: []byte("listen 80;")},
+			{Config: Config{ID: "l1", Name: "swarmcp-lock.demo", Labels: map[string]string{LabelLock: "true"}}, Data: []byte(`{}`)},
+		},
+		Secrets:  []Secret{{ID: "s1", Name: "token"}},
+		Services: []Service{{ID: "svc1", Name: "demo_web", Spec: dockerapi.ServiceSpec{Annotations: dockerapi.Annotations{Name: "demo_web"}}}},
+		Tasks:    map[string][]Task{"svc1": {{ID: "t1", ServiceID: "svc1", State: dockerapi.TaskStateRunning}}},
+		Networks: []Network{{ID: "n1", Name: "demo_internal", Subnets: []string{"10.0.0.0/24"}}},
+		Nodes:    []Node{{ID: "node1", Hostname: "worker-1"}},
+	})
+
+	snap, err := CaptureSnapshot(context.Background(), source, "prod")
+	if err != nil {
+		t.Fatalf("CaptureSnapshot: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cluster.json")
+	if err := WriteSnapshot(path, snap); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+	loaded, err := ReadSnapshot(path)
+	if err != nil {
+		t.Fatalf("ReadSnapshot: %v", err)
+	}
+	if loaded.Context != "prod" {
+		t.Fatalf("expected context to round-trip, got %q", loaded.Context)
+	}
+
+	client := NewSnapshotClient(loaded)
+	ctx := context.Background()
+	content, err := client.ConfigContent(ctx, "c1")
+	if err != nil || string(content) != "listen 80;" {
+		t.Fatalf("unexpected config content %q, %v", content, err)
+	}
+	tasks, err := client.ListServiceTasks(ctx, "svc1")
+	if err != nil || len(tasks) != 1 || tasks[0].State != dockerapi.TaskStateRunning {
+		t.Fatalf("unexpected tasks %#v, %v", tasks, err)
+	}
+	services, _ := client.ListServices(ctx)
+	if len(services) != 1 || services[0].Spec.Annotations.Name != "demo_web" {
+		t.Fatalf("unexpected services %#v", services)
+	}
+	locks, _ := client.ListLocks(ctx)
+	if len(locks) != 1 || locks[0].Name != "swarmcp-lock.demo" {
+		t.Fatalf("unexpected locks %#v", locks)
+	}
+	if _, err := client.CreateConfig(ctx, ConfigSpec{Name: "x"}); !errors.Is(err, ErrSnapshotReadOnly) {
+		t.Fatalf("expected read-only error, got %v", err)
+	}
+}
+
+func TestReadSnapshotRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.json")
+	if err := WriteSnapshot(path, Snapshot{APIVersion: "other"}); err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+	if _, err := ReadSnapshot(path); err == nil {
+		t.Fatalf("expected api_version error")
+	}
+}
@@ -0,0 +1,910 @@
+// Package swarmsim provides an in-memory swarm.Client for tests. It models
+// configs, secrets, services with versions, update status and task
+// convergence, networks and nodes, and rejects invalid calls with the errors
+// a Swarm manager would return.
+package swarmsim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	cerrdefs "github.com/containerd/errdefs"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+// Operations recorded by the simulator and accepted by FailNext.
+const (
+	OpCreateConfig    = "create config"
+	OpCreateSecret    = "create secret"
+	OpCreateNetwork   = "create network"
+	OpCreateService   = "create service"
+	OpUpdateService   = "update service"
+	OpRollbackService = "rollback service"
+	OpRemoveConfig    = "remove config"
+	OpRemoveSecret    = "remove secret"
+	OpRemoveNetwork   = "remove network"
+	OpRemoveService   = "remove service"
+	OpUpdateNode      = "update node"
+)
+
+// Simulator is an in-memory swarm.Client. The zero value is not usable; use
+// New. It is safe for concurrent use.
+type Simulator struct {
+	// ConvergeAfter is the number of ListServiceTasks polls a service's new
+	// tasks stay starting before they reach their final state.
+	ConvergeAfter int
+
+	mu       sync.Mutex
+	seq      int
+	configs  []*config
+	secrets  []*secret
+	networks []*swarm.Network
+	nodes    []*swarm.Node
+	services []*service
+	ops      []string
+	failNext map[string]error
+	failures map[string]string
+	logs     map[string]string
+}
+
+type config struct {
+	swarm.Config
+	data []byte
+}
+
+type secret struct {
+	swarm.Secret
+}
+
+type service struct {
+	swarm.Service
+	tasks   []swarm.Task
+	pending int
+}
+
+// New returns a simulator with a single active manager node.
+func New() *Simulator {
+	s := &Simulator{
+		failNext: make(map[string]error),
+		failures: make(map[string]string),
+		logs:     make(map[string]string),
+	}
+	s.AddNode("manager-1", dockerapi.NodeRoleManager, nil)
+	return s
+}
+
+// AddNode registers an active node and returns its ID.
+func (s *Simulator) AddNode(hostname string, role dockerapi.NodeRole, labels map[string]string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID("node")
+	s.nodes = append(s.nodes, &swarm.Node{
+		ID:       id,
+		Hostname: hostname,
+		Labels:   maps.Clone(labels),
+		Spec: dockerapi.NodeSpec{
+			Annotations:  dockerapi.Annotations{Labels: maps.Clone(labels)},
+			Role:         role,
+			Availability: dockerapi.NodeAvailabilityActive,
+		},
+		Version: 1,
+	})
+	return id
+}
+
+// FailNext makes the next op on the named resource fail with err. Removals
+// match either the resource name or its ID.
+func (s *Simulator) FailNext(op string, name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext[op+" "+name] = err
+}
+
+// FailImage makes tasks created for image fail with message until
+// ClearFailure is called.
+func (s *Simulator) FailImage(image string, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[image] = message
+}
+
+func (s *Simulator) ClearFailure(image string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, image)
+}
+
+// SetLogs sets the log output returned for the named service.
+func (s *Simulator) SetLogs(serviceName string, logs string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs[serviceName] = logs
+}
+
+// AttachContainer attaches a standalone container to the named network. Like
+// Docker, network lists do not report it and the network cannot be removed
+// while it is attached.
+func (s *Simulator) AttachContainer(network string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if net := s.findNetwork(network); net != nil {
+		net.Containers++
+	}
+}
+
+// Operations returns the successful mutations in call order, formatted as
+// "<op> <name>".
+func (s *Simulator) Operations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.ops)
+}
+
+// Service returns the named service as ListServices reports it.
+func (s *Simulator) Service(name string) (swarm.Service, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, svc := range s.services {
+		if svc.Name == name {
+			return s.serviceView(svc), true
+		}
+	}
+	return swarm.Service{}, false
+}
+
+func (s *Simulator) ListConfigs(ctx context.Context) ([]swarm.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]swarm.Config, 0, len(s.configs))
+	for _, cfg := range s.configs {
+		item := cfg.Config
+		item.Labels = maps.Clone(item.Labels)
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func (s *Simulator) ListSecrets(ctx context.Context) ([]swarm.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]swarm.Secret, 0, len(s.secrets))
+	for _, sec := range s.secrets {
+		item := sec.Secret
+		item.Labels = maps.Clone(item.Labels)
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func (s *Simulator) ListServices(ctx context.Context) ([]swarm.Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]swarm.Service, 0, len(s.services))
+	for _, svc := range s.services {
+		out = append(out, s.serviceView(svc))
+	}
+	return out, nil
+}
+
+// ListServiceTasks advances the service's task convergence by one poll.
+func (s *Simulator) ListServiceTasks(ctx context.Context, serviceID string) ([]swarm.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc := s.findService(serviceID)
+	if svc == nil {
+		return nil, nil
+	}
+	if svc.pending > 0 {
+		svc.pending--
+	}
+	if svc.pending == 0 {
+		s.converge(svc)
+	}
+	return clone(svc.tasks), nil
+}
+
+func (s *Simulator) ServiceLogs(ctx context.Context, serviceID string, tail int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc := s.findService(serviceID)
+	if svc == nil {
+		return nil, notFound("service", serviceID)
+	}
+	return []byte(s.logs[svc.Name]), nil
+}
+
+func (s *Simulator) ListNetworks(ctx context.Context) ([]swarm.Network, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]swarm.Network, 0, len(s.networks))
+	for _, net := range s.networks {
+		item := *net
+		item.Containers = 0
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// NetworkContainers counts the running tasks attached to the network plus the
+// standalone containers attached with AttachContainer.
+func (s *Simulator) NetworkContainers(ctx context.Context, id string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	net := s.findNetwork(id)
+	if net == nil {
+		return 0, notFound("network", id)
+	}
+	return net.Containers + s.networkUsers(net), nil
+}
+
+func (s *Simulator) ListNodes(ctx context.Context) ([]swarm.Node, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]swarm.Node, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		out = append(out, *node)
+	}
+	return out, nil
+}
+
+func (s *Simulator) ConfigContent(ctx context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg := s.findConfig(id)
+	if cfg == nil {
+		return nil, notFound("config", id)
+	}
+	return slices.Clone(cfg.data), nil
+}
+
+func (s *Simulator) CreateNetwork(ctx context.Context, spec swarm.NetworkSpec) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.injected(OpCreateNetwork, spec.Name); err != nil {
+		return "", err
+	}
+	if s.findNetwork(spec.Name) != nil {
+		return "", fmt.Errorf("%w: network with name %s already exists", cerrdefs.ErrConflict, spec.Name)
+	}
+	driver := spec.Driver
+	if driver == "" {
+		driver = "overlay"
+	}
+	net := &swarm.Network{
+		ID:         s.nextID("network"),
+		Name:       spec.Name,
+		Driver:     driver,
+		Scope:      "swarm",
+		Attachable: spec.Attachable,
+		Internal:   spec.Internal,
+		Labels:     maps.Clone(spec.Labels),
+		Options:    maps.Clone(spec.Options),
+		IPAM:       slices.Clone(spec.IPAM),
+	}
+	for _, ipam := range spec.IPAM {
+		net.Subnets = append(net.Subnets, ipam.Subnet)
+	}
+	s.networks = append(s.networks, net)
+	s.record(OpCreateNetwork, spec.Name)
+	return net.ID, nil
+}
+
+func (s *Simulator) CreateService(ctx context.Context, spec dockerapi.ServiceSpec) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := spec.Annotations.Name
+	if err := s.injected(OpCreateService, name); err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("%w: service name is required", cerrdefs.ErrInvalidArgument)
+	}
+	if s.findService(name) != nil {
+		return "", fmt.Errorf("%w: service %s already exists", cerrdefs.ErrConflict, name)
+	}
+	if err := s.validateReferences(spec); err != nil {
+		return "", err
+	}
+	spec = clone(spec)
+	now := time.Now()
+	svc := &service{Service: swarm.Service{
+		ID:        s.nextID("service"),
+		Name:      name,
+		Labels:    maps.Clone(spec.Annotations.Labels),
+		Spec:      spec,
+		Version:   s.nextVersion(),
+		CreatedAt: now,
+	}}
+	s.services = append(s.services, svc)
+	s.schedule(svc, now)
+	s.record(OpCreateService, name)
+	return svc.ID, nil
+}
+
+func (s *Simulator) CreateConfig(ctx context.Context, spec swarm.ConfigSpec) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.injected(OpCreateConfig, spec.Name); err != nil {
+		return "", err
+	}
+	return s.createConfig(spec.Name, spec.Labels, spec.Data)
+}
+
+func (s *Simulator) CreateSecret(ctx context.Context, spec swarm.SecretSpec) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.injected(OpCreateSecret, spec.Name); err != nil {
+		return "", err
+	}
+	if s.findSecret(spec.Name) != nil {
+		return "", fmt.Errorf("%w: secret %s already exists", cerrdefs.ErrConflict, spec.Name)
+	}
+	sec := &secret{Secret: swarm.Secret{
+		ID:        s.nextID("secret"),
+		Name:      spec.Name,
+		Labels:    maps.Clone(spec.Labels),
+		CreatedAt: time.Now(),
+	}}
+	s.secrets = append(s.secrets, sec)
+	s.record(OpCreateSecret, spec.Name)
+	return sec.ID, nil
+}
+
+func (s *Simulator) RemoveConfig(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cfg := s.findConfig(id)
+	if cfg == nil {
+		return notFound("config", id)
+	}
+	if err := s.injected(OpRemoveConfig, cfg.Name, cfg.ID); err != nil {
+		return err
+	}
+	if users := s.configUsers(cfg); len(users) > 0 {
+		return fmt.Errorf("%w: config '%s' is in use by the following service: %s", cerrdefs.ErrInvalidArgument, cfg.Name, strings.Join(users, ", "))
+	}
+	s.configs = slices.DeleteFunc(s.configs, func(item *config) bool { return item == cfg })
+	s.record(OpRemoveConfig, cfg.Name)
+	return nil
+}
+
+func (s *Simulator) RemoveSecret(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec := s.findSecret(id)
+	if sec == nil {
+		return notFound("secret", id)
+	}
+	if err := s.injected(OpRemoveSecret, sec.Name, sec.ID); err != nil {
+		return err
+	}
+	if users := s.secretUsers(sec); len(users) > 0 {
+		return fmt.Errorf("%w: secret '%s' is in use by the following service: %s", cerrdefs.ErrInvalidArgument, sec.Name, strings.Join(users, ", "))
+	}
+	s.secrets = slices.DeleteFunc(s.secrets, func(item *secret) bool { return item == sec })
+	s.record(OpRemoveSecret, sec.Name)
+	return nil
+}
+
+func (s *Simulator) RemoveNetwork(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	net := s.findNetwork(id)
+	if net == nil {
+		return notFound("network", id)
+	}
+	if err := s.injected(OpRemoveNetwork, net.Name, net.ID); err != nil {
+		return err
+	}
+	if users := s.networkServices(net); len(users) > 0 {
+		return fmt.Errorf("%w: network %s is in use by service %s", cerrdefs.ErrConflict, net.Name, strings.Join(users, ", "))
+	}
+	if net.Containers > 0 {
+		return fmt.Errorf("%w: error while removing network: network %s has active endpoints", cerrdefs.ErrConflict, net.Name)
+	}
+	s.networks = slices.DeleteFunc(s.networks, func(item *swarm.Network) bool { return item == net })
+	s.record(OpRemoveNetwork, net.Name)
+	return nil
+}
+
+func (s *Simulator) RemoveService(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc := s.findService(id)
+	if svc == nil {
+		return notFound("service", id)
+	}
+	if err := s.injected(OpRemoveService, svc.Name, svc.ID); err != nil {
+		return err
+	}
+	s.services = slices.DeleteFunc(s.services, func(item *service) bool { return item == svc })
+	s.record(OpRemoveService, svc.Name)
+	return nil
+}
+
+func (s *Simulator) UpdateService(ctx context.Context, current swarm.Service, spec dockerapi.ServiceSpec) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc := s.findService(current.ID)
+	if svc == nil {
+		return notFound("service", current.ID)
+	}
+	if err := s.injected(OpUpdateService, svc.Name); err != nil {
+		return err
+	}
+	if current.Version != svc.Version {
+		return fmt.Errorf("%w: update out of sequence", cerrdefs.ErrInvalidArgument)
+	}
+	if spec.Annotations.Name != svc.Name {
+		return fmt.Errorf("%w: renaming services is not supported", cerrdefs.ErrInvalidArgument)
+	}
+	if modeKind(spec.Mode) != modeKind(svc.Spec.Mode) {
+		return fmt.Errorf("%w: service mode change is not allowed", cerrdefs.ErrInvalidArgument)
+	}
+	if err := s.validateReferences(spec); err != nil {
+		return err
+	}
+	previous := svc.Spec
+	svc.PreviousSpec = &previous
+	svc.Spec = clone(spec)
+	svc.Labels = maps.Clone(spec.Annotations.Labels)
+	svc.Version = s.nextVersion()
+	s.startUpdate(svc, dockerapi.UpdateStateUpdating, "update in progress")
+	s.record(OpUpdateService, svc.Name)
+	return nil
+}
+
+func (s *Simulator) RollbackService(ctx context.Context, current swarm.Service) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc := s.findService(current.ID)
+	if svc == nil {
+		return notFound("service", current.ID)
+	}
+	if err := s.injected(OpRollbackService, svc.Name); err != nil {
+		return err
+	}
+	if current.Version != svc.Version {
+		return fmt.Errorf("%w: update out of sequence", cerrdefs.ErrInvalidArgument)
+	}
+	if svc.PreviousSpec == nil {
+		return fmt.Errorf("%w: service %s does not have a previous spec", cerrdefs.ErrFailedPrecondition, svc.Name)
+	}
+	spec := *svc.PreviousSpec
+	previous := svc.Spec
+	svc.PreviousSpec = &previous
+	svc.Spec = spec
+	svc.Labels = maps.Clone(spec.Annotations.Labels)
+	svc.Version = s.nextVersion()
+	s.startUpdate(svc, dockerapi.UpdateStateRollbackStarted, "manually requested rollback")
+	s.record(OpRollbackService, svc.Name)
+	return nil
+}
+
+func (s *Simulator) UpdateNode(ctx context.Context, current swarm.Node, spec dockerapi.NodeSpec) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var node *swarm.Node
+	for _, item := range s.nodes {
+		if item.ID == current.ID {
+			node = item
+		}
+	}
+	if node == nil {
+		return notFound("node", current.ID)
+	}
+	if err := s.injected(OpUpdateNode, node.Hostname, node.ID); err != nil {
+		return err
+	}
+	if current.Version != node.Version {
+		return fmt.Errorf("%w: update out of sequence", cerrdefs.ErrInvalidArgument)
+	}
+	node.Spec = spec
+	node.Name = spec.Annotations.Name
+	node.Labels = maps.Clone(spec.Annotations.Labels)
+	node.Version = s.nextVersion()
+	s.record(OpUpdateNode, node.Hostname)
+	return nil
+}
+
+func (s *Simulator) ListLocks(ctx context.Context) ([]swarm.Lock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []swarm.Lock
+	for _, cfg := range s.configs {
+		if cfg.Labels[swarm.LabelLock] == "" {
+			continue
+		}
+		out = append(out, swarm.Lock{ID: cfg.ID, Name: cfg.Name, Labels: maps.Clone(cfg.Labels), Data: slices.Clone(cfg.data), CreatedAt: cfg.CreatedAt})
+	}
+	return out, nil
+}
+
+func (s *Simulator) CreateLock(ctx context.Context, spec swarm.LockSpec) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	labels := maps.Clone(spec.Labels)
+	if labels == nil {
+		labels = make(map[string]string, 1)
+	}
+	labels[swarm.LabelLock] = "true"
+	id, err := s.createConfig(spec.Name, labels, spec.Data)
+	if cerrdefs.IsConflict(err) {
+		return "", fmt.Errorf("%w: %s", swarm.ErrLockExists, spec.Name)
+	}
+	return id, err
+}
+
+func (s *Simulator) RemoveLock(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs = slices.DeleteFunc(s.configs, func(item *config) bool { return item.ID == id })
+	return nil
+}
+
+func (s *Simulator) createConfig(name string, labels map[string]string, data []byte) (string, error) {
+	if s.findConfig(name) != nil {
+		return "", fmt.Errorf("%w: config %s already exists", cerrdefs.ErrConflict, name)
+	}
+	cfg := &config{
+		Config: swarm.Config{
+			ID:        s.nextID("config"),
+			Name:      name,
+			Labels:    maps.Clone(labels),
+			CreatedAt: time.Now(),
+		},
+		data: slices.Clone(data),
+	}
+	s.configs = append(s.configs, cfg)
+	s.record(OpCreateConfig, name)
+	return cfg.ID, nil
+}
+
+// validateReferences rejects specs that mount configs or secrets or attach
+// networks the cluster does not have.
+func (s *Simulator) validateReferences(spec dockerapi.ServiceSpec) error {
+	if container := spec.TaskTemplate.ContainerSpec; container != nil {
+		for _, ref := range container.Configs {
+			if ref != nil && s.findConfig(firstNonEmpty(ref.ConfigID, ref.ConfigName)) == nil {
+				return fmt.Errorf("%w: config %s not found", cerrdefs.ErrInvalidArgument, firstNonEmpty(ref.ConfigName, ref.ConfigID))
+			}
+		}
+		for _, ref := range container.Secrets {
+			if ref != nil && s.findSecret(firstNonEmpty(ref.SecretID, ref.SecretName)) == nil {
+				return fmt.Errorf("%w: secret %s not found", cerrdefs.ErrInvalidArgument, firstNonEmpty(ref.SecretName, ref.SecretID))
+			}
+		}
+	}
+	for _, att := range attachments(spec) {
+		if s.findNetwork(att.Target) == nil {
+			return notFound("network", att.Target)
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) startUpdate(svc *service, state dockerapi.UpdateState, message string) {
+	now := time.Now()
+	svc.UpdateStatus = &dockerapi.UpdateStatus{State: state, StartedAt: &now, Message: message}
+	for i := range svc.tasks {
+		task := &svc.tasks[i]
+		if task.DesiredState == dockerapi.TaskStateRunning {
+			task.DesiredState = dockerapi.TaskStateShutdown
+			if task.State == dockerapi.TaskStateRunning {
+				task.State = dockerapi.TaskStateShutdown
+			}
+		}
+	}
+	s.schedule(svc, now)
+}
+
+// schedule creates the tasks of the service's current spec in the starting
+// state; they converge once ConvergeAfter polls have passed.
+func (s *Simulator) schedule(svc *service, now time.Time) {
+	desired := dockerapi.TaskStateRunning
+	if isJob(svc.Spec.Mode) {
+		desired = dockerapi.TaskStateComplete
+	}
+	nodes := s.activeNodes()
+	for slot := 1; slot <= s.taskCount(svc.Spec.Mode, len(nodes)); slot++ {
+		task := swarm.Task{
+			ID:           s.nextID("task"),
+			ServiceID:    svc.ID,
+			Slot:         slot,
+			Spec:         clone(svc.Spec.TaskTemplate),
+			CreatedAt:    now,
+			DesiredState: desired,
+			State:        dockerapi.TaskStateStarting,
+		}
+		if len(nodes) > 0 {
+			task.NodeID = nodes[(slot-1)%len(nodes)].ID
+		}
+		svc.tasks = append(svc.tasks, task)
+	}
+	svc.pending = s.ConvergeAfter
+	if svc.pending == 0 {
+		s.converge(svc)
+	}
+}
+
+// converge moves starting tasks to their final state and completes the
+// service's update, pausing it when a task failed.
+func (s *Simulator) converge(svc *service) {
+	failed := ""
+	for i := range svc.tasks {
+		task := &svc.tasks[i]
+		if task.State != dockerapi.TaskStateStarting {
+			continue
+		}
+		image := ""
+		if task.Spec.ContainerSpec != nil {
+			image = task.Spec.ContainerSpec.Image
+		}
+		if message, ok := s.failures[image]; ok {
+			task.State = dockerapi.TaskStateFailed
+			task.Err = message
+			task.ExitCode = 1
+			failed = task.ID
+			continue
+		}
+		task.State = task.DesiredState
+		task.Message = string(task.DesiredState)
+	}
+	status := svc.UpdateStatus
+	if status == nil || (status.State != dockerapi.UpdateStateUpdating && status.State != dockerapi.UpdateStateRollbackStarted) {
+		return
+	}
+	now := time.Now()
+	switch {
+	case failed != "" && status.State == dockerapi.UpdateStateUpdating:
+		status.State = dockerapi.UpdateStatePaused
+		status.Message = "update paused due to failure or early termination of task " + failed
+	case failed != "":
+		status.State = dockerapi.UpdateStateRollbackPaused
+		status.Message = "rollback paused due to failure or early termination of task " + failed
+	case status.State == dockerapi.UpdateStateUpdating:
+		status.State = dockerapi.UpdateStateCompleted
+		status.Message = "update completed"
+		status.CompletedAt = &now
+	default:
+		status.State = dockerapi.UpdateStateRollbackCompleted
+		status.Message = "rollback completed"
+		status.CompletedAt = &now
+	}
+}
+
+// serviceView returns the service with task counts as ListServices reports
+// them.
+func (s *Simulator) serviceView(svc *service) swarm.Service {
+	out := clone(svc.Service)
+	status := &dockerapi.ServiceStatus{}
+	for _, task := range svc.tasks {
+		if task.DesiredState == dockerapi.TaskStateShutdown {
+			continue
+		}
+		status.DesiredTasks++
+		if task.State == dockerapi.TaskStateRunning {
+			status.RunningTasks++
+		}
+		if task.State == dockerapi.TaskStateComplete {
+			status.CompletedTasks++
+		}
+	}
+	out.Status = status
+	return out
+}
+
+func (s *Simulator) taskCount(mode dockerapi.ServiceMode, nodes int) int {
+	switch {
+	case mode.Replicated != nil:
+		if mode.Replicated.Replicas != nil {
+			return int(*mode.Replicated.Replicas)
+		}
+		return 1
+	case mode.ReplicatedJob != nil:
+		if mode.ReplicatedJob.TotalCompletions != nil {
+			return int(*mode.ReplicatedJob.TotalCompletions)
+		}
+		if mode.ReplicatedJob.MaxConcurrent != nil {
+			return int(*mode.ReplicatedJob.MaxConcurrent)
+		}
+		return 1
+	case mode.Global != nil, mode.GlobalJob != nil:
+		return nodes
+	}
+	return 1
+}
+
+func (s *Simulator) activeNodes() []*swarm.Node {
+	var out []*swarm.Node
+	for _, node := range s.nodes {
+		if node.Spec.Availability == dockerapi.NodeAvailabilityActive || node.Spec.Availability == "" {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+func (s *Simulator) configUsers(cfg *config) []string {
+	var users []string
+	for _, svc := range s.services {
+		if container := svc.Spec.TaskTemplate.ContainerSpec; container != nil {
+			for _, ref := range container.Configs {
+				if ref != nil && (ref.ConfigID == cfg.ID || (ref.ConfigID == "" && ref.ConfigName == cfg.Name)) {
+					users = append(users, svc.Name)
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+func (s *Simulator) secretUsers(sec *secret) []string {
+	var users []string
+	for _, svc := range s.services {
+		if container := svc.Spec.TaskTemplate.ContainerSpec; container != nil {
+			for _, ref := range container.Secrets {
+				if ref != nil && (ref.SecretID == sec.ID || (ref.SecretID == "" && ref.SecretName == sec.Name)) {
+					users = append(users, svc.Name)
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+func (s *Simulator) networkServices(net *swarm.Network) []string {
+	var users []string
+	for _, svc := range s.services {
+		for _, att := range attachments(svc.Spec) {
+			if att.Target == net.ID || att.Target == net.Name {
+				users = append(users, svc.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+func (s *Simulator) networkUsers(net *swarm.Network) int {
+	count := 0
+	for _, svc := range s.services {
+		for _, att := range attachments(svc.Spec) {
+			if att.Target != net.ID && att.Target != net.Name {
+				continue
+			}
+			for _, task := range svc.tasks {
+				if task.State == dockerapi.TaskStateRunning {
+					count++
+				}
+			}
+			break
+		}
+	}
+	return count
+}
+
+func (s *Simulator) findConfig(ref string) *config {
+	for _, cfg := range s.configs {
+		if cfg.ID == ref || cfg.Name == ref {
+			return cfg
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findSecret(ref string) *secret {
+	for _, sec := range s.secrets {
+		if sec.ID == ref || sec.Name == ref {
+			return sec
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findNetwork(ref string) *swarm.Network {
+	for _, net := range s.networks {
+		if net.ID == ref || net.Name == ref {
+			return net
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) findService(ref string) *service {
+	for _, svc := range s.services {
+		if svc.ID == ref || svc.Name == ref {
+			return svc
+		}
+	}
+	return nil
+}
+
+// injected returns and clears the FailNext error registered for op on any of
+// refs.
+func (s *Simulator) injected(op string, refs ...string) error {
+	for _, ref := range refs {
+		key := op + " " + ref
+		if err, ok := s.failNext[key]; ok {
+			delete(s.failNext, key)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) record(op string, name string) {
+	s.ops = append(s.ops, op+" "+name)
+}
+
+func (s *Simulator) nextID(kind string) string {
+	s.seq++
+	return fmt.Sprintf("%s-%04d", kind, s.seq)
+}
+
+func (s *Simulator) nextVersion() uint64 {
+	s.seq++
+	return uint64(s.seq)
+}
+
+func attachments(spec dockerapi.ServiceSpec) []dockerapi.NetworkAttachmentConfig {
+	return spec.TaskTemplate.Networks
+}
+
+func modeKind(mode dockerapi.ServiceMode) string {
+	switch {
+	case mode.Global != nil:
+		return "global"
+	case mode.ReplicatedJob != nil:
+		return "replicated-job"
+	case mode.GlobalJob != nil:
+		return "global-job"
+	}
+	return "replicated"
+}
+
+func isJob(mode dockerapi.ServiceMode) bool {
+	return mode.ReplicatedJob != nil || mode.GlobalJob != nil
+}
+
+// clone deep-copies API values through JSON so callers never share pointers
+// with the simulator's state, as with a real API round trip.
+func clone[T any](value T) T {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	var out T
+	if err := json.Unmarshal(data, &out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func notFound(kind string, ref string) error {
+	return fmt.Errorf("%w: %s %s not found", cerrdefs.ErrNotFound, kind, ref)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+var _ swarm.Client = (*Simulator)(nil)
@@ -0,0 +1,122 @@
+package swarmsim
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cmmoran/swarmcp/internal/swarm"
+	cerrdefs "github.com/containerd/errdefs"
+	dockerapi "github.com/docker/docker/api/types/swarm"
+)
+
+func serviceSpec(name string, image string, configID string) dockerapi.ServiceSpec {
+	replicas := uint64(2)
+	container := &dockerapi.ContainerSpec{Image: image}
+	if configID != "" {
+		container.Configs = []*dockerapi.ConfigReference{{ConfigID: configID, ConfigName: "app.conf"}}
+	}
+	return dockerapi.ServiceSpec{
+		Annotations:  dockerapi.Annotations{Name: name},
+		Mode:         dockerapi.ServiceMode{Replicated: &dockerapi.ReplicatedService{Replicas: &replicas}},
+		TaskTemplate: dockerapi.TaskSpec{ContainerSpec: container},
+	}
+}
+
+func TestSimulatorValidatesLikeSwarm(t *testing.T) {
+	ctx := context.Background()
+	sim := New()
+	configID, err := sim.CreateConfig(ctx, swarm.ConfigSpec{Name: "app.conf", Data: []byte("x")})
+	if err != nil {
+		t.Fatalf("CreateConfig: %v", err)
+	}
+	if _, err := sim.CreateConfig(ctx, swarm.ConfigSpec{Name: "app.conf"}); !cerrdefs.IsConflict(err) {
+		t.Fatalf("expected name conflict, got %v", err)
+	}
+	if _, err := sim.CreateService(ctx, serviceSpec("web", "nginx:1", "missing")); !cerrdefs.IsInvalidArgument(err) {
+		t.Fatalf("expected missing config to be rejected, got %v", err)
+	}
+	if _, err := sim.CreateService(ctx, serviceSpec("web", "nginx:1", configID)); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	if err := sim.RemoveConfig(ctx, configID); !cerrdefs.IsInvalidArgument(err) {
+		t.Fatalf("expected in-use config removal to fail, got %v", err)
+	}
+
+	svc, _ := sim.Service("web")
+	renamed := serviceSpec("api", "nginx:2", configID)
+	if err := sim.UpdateService(ctx, svc, renamed); err == nil {
+		t.Fatalf("expected rename to be rejected")
+	}
+	global := serviceSpec("web", "nginx:2", configID)
+	global.Mode = dockerapi.ServiceMode{Global: &dockerapi.GlobalService{}}
+	if err := sim.UpdateService(ctx, svc, global); err == nil {
+		t.Fatalf("expected mode change to be rejected")
+	}
+	if err := sim.UpdateService(ctx, svc, serviceSpec("web", "nginx:2", configID)); err != nil {
+		t.Fatalf("UpdateService: %v", err)
+	}
+	if err := sim.UpdateService(ctx, svc, serviceSpec("web", "nginx:3", configID)); err == nil {
+		t.Fatalf("expected stale version to be rejected")
+	}
+	if _, err := sim.CreateLock(ctx, swarm.LockSpec{Name: "app.conf"}); !errors.Is(err, swarm.ErrLockExists) {
+		t.Fatalf("expected lock conflict, got %v", err)
+	}
+
+	netID, err := sim.CreateNetwork(ctx, swarm.NetworkSpec{Name: "tools", Driver: "overlay", Attachable: true})
+	if err != nil {
+		t.Fatalf("CreateNetwork: %v", err)
+	}
+	sim.AttachContainer("tools")
+	networks, _ := sim.ListNetworks(ctx)
+	for _, net := range networks {
+		if net.Containers != 0 {
+			t.Fatalf("expected network lists to omit containers, got %#v", net)
+		}
+	}
+	if count, err := sim.NetworkContainers(ctx, netID); err != nil || count != 1 {
+		t.Fatalf("expected one attached container, got %d (%v)", count, err)
+	}
+	if err := sim.RemoveNetwork(ctx, netID); !cerrdefs.IsConflict(err) {
+		t.Fatalf("expected network with containers to stay, got %v", err)
+	}
+}
+
+func TestSimulatorConvergesAndPausesFailedUpdates(t *testing.T) {
+	ctx := context.Background()
+	sim := New()
+	sim.ConvergeAfter = 2
+	id, err := sim.CreateService(ctx, serviceSpec("web", "nginx:1", ""))
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	tasks, _ := sim.ListServiceTasks(ctx, id)
+	if len(tasks) != 2 || tasks[0].State != dockerapi.TaskStateStarting {
+		t.Fatalf("expected 2 starting tasks, got %#v", tasks)
+	}
+	tasks, _ = sim.ListServiceTasks(ctx, id)
+	if tasks[0].State != dockerapi.TaskStateRunning || tasks[1].State != dockerapi.TaskStateRunning {
+		t.Fatalf("expected tasks to converge after 2 polls, got %#v", tasks)
+	}
+
+	sim.ConvergeAfter = 0
+	sim.FailImage("nginx:bad", "exit 1")
+	svc, _ := sim.Service("web")
+	if err := sim.UpdateService(ctx, svc, serviceSpec("web", "nginx:bad", "")); err != nil {
+		t.Fatalf("UpdateService: %v", err)
+	}
+	svc, _ = sim.Service("web")
+	if svc.UpdateStatus == nil || svc.UpdateStatus.State != dockerapi.UpdateStatePaused {
+		t.Fatalf("expected paused update, got %#v", svc.UpdateStatus)
+	}
+	if err := sim.RollbackService(ctx, svc); err != nil {
+		t.Fatalf("RollbackService: %v", err)
+	}
+	svc, _ = sim.Service("web")
+	if svc.Spec.TaskTemplate.ContainerSpec.Image != "nginx:1" || svc.UpdateStatus.State != dockerapi.UpdateStateRollbackCompleted {
+		t.Fatalf("expected rollback to nginx:1, got %s %s", svc.Spec.TaskTemplate.ContainerSpec.Image, svc.UpdateStatus.State)
+	}
+	if svc.Status.RunningTasks != 2 || svc.Status.DesiredTasks != 2 {
+		t.Fatalf("unexpected service status %#v", svc.Status)
+	}
+}
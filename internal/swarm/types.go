@@ -26,6 +26,20 @@ type Config struct {
 	CreatedAt time.Time         `yaml:"created_at,omitempty" json:"created_at,omitempty"`
 }
 
+type Lock struct {
+	ID        string            `yaml:"id" json:"id"`
+	Name      string            `yaml:"name" json:"name"`
+	Labels    map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Data      []byte            `yaml:"data,omitempty" json:"data,omitempty"`
+	CreatedAt time.Time         `yaml:"created_at,omitempty" json:"created_at,omitempty"`
+}
+
+type LockSpec struct {
+	Name   string            `yaml:"name" json:"name"`
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Data   []byte            `yaml:"data,omitempty" json:"data,omitempty"`
+}
+
 type Secret struct {
 	ID        string            `yaml:"id" json:"id"`
 	Name      string            `yaml:"name" json:"name"`
@@ -34,20 +48,45 @@ type Secret struct {
 }
 
 type Service struct {
-	ID      string
-	Name    string
-	Labels  map[string]string
-	Spec    dockerapi.ServiceSpec
-	Version uint64
-	Status  *dockerapi.ServiceStatus
+	ID           string                   `json:"id"`
+	Name         string                   `json:"name"`
+	Labels       map[string]string        `json:"labels,omitempty"`
+	Spec         dockerapi.ServiceSpec    `json:"spec"`
+	Version      uint64                   `json:"version,omitempty"`
+	Status       *dockerapi.ServiceStatus `json:"status,omitempty"`
+	UpdateStatus *dockerapi.UpdateStatus  `json:"update_status,omitempty"`
+	PreviousSpec *dockerapi.ServiceSpec   `json:"previous_spec,omitempty"`
+	CreatedAt    time.Time                `json:"created_at"`
+}
+
+type Task struct {
+	ID           string              `json:"id"`
+	ServiceID    string              `json:"service_id,omitempty"`
+	NodeID       string              `json:"node_id,omitempty"`
+	Slot         int                 `json:"slot,omitempty"`
+	Spec         dockerapi.TaskSpec  `json:"spec"`
+	CreatedAt    time.Time           `json:"created_at"`
+	DesiredState dockerapi.TaskState `json:"desired_state,omitempty"`
+	State        dockerapi.TaskState `json:"state,omitempty"`
+	Message      string              `json:"message,omitempty"`
+	Err          string              `json:"err,omitempty"`
+	ExitCode     int                 `json:"exit_code,omitempty"`
 }
 
 type Network struct {
-	ID      string
-	Name    string
-	Driver  string
-	Scope   string
-	Subnets []string
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver,omitempty"`
+	Scope      string            `json:"scope,omitempty"`
+	Subnets    []string          `json:"subnets,omitempty"`
+	Attachable bool              `json:"attachable,omitempty"`
+	Internal   bool              `json:"internal,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Options    map[string]string `json:"options,omitempty"`
+	IPAM       []NetworkIPAM     `json:"ipam,omitempty"`
+	// Containers is the number of attached containers. It is only filled in
+	// by snapshots; use Client.NetworkContainers against a live cluster.
+	Containers int `json:"containers,omitempty"`
 }
 
 type NetworkSpec struct {
@@ -56,13 +95,21 @@ type NetworkSpec struct {
 	Attachable bool              `yaml:"attachable,omitempty" json:"attachable,omitempty"`
 	Internal   bool              `yaml:"internal,omitempty" json:"internal,omitempty"`
 	Labels     map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Options    map[string]string `yaml:"options,omitempty" json:"options,omitempty"`
+	IPAM       []NetworkIPAM     `yaml:"ipam,omitempty" json:"ipam,omitempty"`
+}
+
+type NetworkIPAM struct {
+	Subnet  string `yaml:"subnet" json:"subnet"`
+	Gateway string `yaml:"gateway,omitempty" json:"gateway,omitempty"`
+	IPRange string `yaml:"ip_range,omitempty" json:"ip_range,omitempty"`
 }
 
 type Node struct {
-	ID       string
-	Name     string
-	Hostname string
-	Labels   map[string]string
-	Spec     dockerapi.NodeSpec
-	Version  uint64
+	ID       string             `json:"id"`
+	Name     string             `json:"name"`
+	Hostname string             `json:"hostname,omitempty"`
+	Labels   map[string]string  `json:"labels,omitempty"`
+	Spec     dockerapi.NodeSpec `json:"spec"`
+	Version  uint64             `json:"version,omitempty"`
 }
@@ -62,3 +62,40 @@ func TestRuntimeValueStandardVolumesCSV(t *testing.T) {
 		t.Fatalf("unexpected output: %q", out)
 	}
 }
+
+func TestRuntimeValueNetworkSubnet(t *testing.T) {
+	cfg := &config.Config{
+		Project: config.Project{
+			Name:       "platform",
+			Partitions: []string{"dev"},
+			Defaults: config.ProjectDefaults{
+				Networks: config.NetworkDefaults{
+					AddressPool: &config.NetworkAddressPool{Base: "10.80.0.0/16", Size: 24},
+					Settings: map[string]config.NetworkSettings{
+						"platform_egress": {IPAM: []config.NetworkIPAM{{Subnet: "10.90.0.0/24"}}},
+					},
+				},
+			},
+		},
+	}
+	subnets, err := config.NetworkSubnets(cfg)
+	if err != nil {
+		t.Fatalf("NetworkSubnets: %v", err)
+	}
+	resolver := NewScopeResolver(cfg, Scope{Project: "platform", Partition: "dev"}, false, false, nil, nil, nil)
+
+	out, err := resolver.RuntimeValue("network_subnet", "{project}_{partition}_internal")
+	if err != nil {
+		t.Fatalf("runtime_value: %v", err)
+	}
+	if out == "" || out != subnets["platform_dev_internal"] {
+		t.Fatalf("unexpected subnet %q (allocated %#v)", out, subnets)
+	}
+	out, err = resolver.RuntimeValue("network_subnet", "platform_egress")
+	if err != nil || out != "10.90.0.0/24" {
+		t.Fatalf("unexpected pinned subnet %q: %v", out, err)
+	}
+	if _, err := resolver.RuntimeValue("network_subnet", "unknown"); err == nil {
+		t.Fatalf("expected error for unallocated network")
+	}
+}
@@ -209,11 +209,35 @@ func (r *ScopeResolver) RuntimeValue(args ...string) (string, error) {
 	switch kind {
 	case "standard_volumes":
 		return r.runtimeStandardVolumes(args[1:])
+	case "network_subnet":
+		return r.runtimeNetworkSubnet(args[1:])
 	default:
 		return "", fmt.Errorf("runtime_value %q: unsupported kind", kind)
 	}
 }
 
+func (r *ScopeResolver) runtimeNetworkSubnet(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("runtime_value network_subnet: expects a network name")
+	}
+	if r.cfg == nil {
+		return "", nil
+	}
+	name := config.RenderNetworkTemplate(ExpandTokens(strings.TrimSpace(args[0]), r.scope), r.cfg.Project.Name, r.scope.Partition)
+	if settings := config.NetworkSettingsFor(r.cfg, name); len(settings.IPAM) > 0 {
+		return strings.TrimSpace(settings.IPAM[0].Subnet), nil
+	}
+	subnets, err := config.NetworkSubnets(r.cfg)
+	if err != nil {
+		return "", fmt.Errorf("runtime_value network_subnet: %w", err)
+	}
+	subnet, ok := subnets[name]
+	if !ok {
+		return "", fmt.Errorf("runtime_value network_subnet: no subnet allocated for network %q", name)
+	}
+	return subnet, nil
+}
+
 func (r *ScopeResolver) runtimeStandardVolumes(args []string) (string, error) {
 	if r.cfg == nil {
 		return "", nil